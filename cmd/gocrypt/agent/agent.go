@@ -0,0 +1,422 @@
+// Package agent allows the gocrypt agent to be embedded in another process, such as a test, instead of being run as a
+// separate service. Nothing in this package reads the process environment or exits the process, so everything is
+// configured through the Config.
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rsheasby/gocrypt/gocrypt/config"
+	"github.com/rsheasby/gocrypt/gocrypt/logging"
+	"github.com/rsheasby/gocrypt/gocrypt/metrics"
+	"github.com/rsheasby/gocrypt/gocrypt/requestManager"
+	"github.com/rsheasby/gocrypt/gocrypt/requestWorker"
+	"github.com/rsheasby/gocrypt/gocrypt/transportHelpers"
+	"github.com/rsheasby/gocrypt/hashAlgorithms"
+	"github.com/rsheasby/gocrypt/transport"
+	"github.com/rsheasby/gocrypt/transport/memoryTransport"
+	"github.com/rsheasby/gocrypt/transport/redisTransport"
+)
+
+// Config specifies how the agent runs. See config.Config for the individual fields.
+type Config = config.Config
+
+// RedisConfig specifies how the agent connects to redis. See config.RedisConfig for the individual fields.
+type RedisConfig = config.RedisConfig
+
+// DefaultConfig returns the default config. The Redis Host must still be set, unless a Transport is provided.
+func DefaultConfig() (cfg Config) {
+	return config.Default()
+}
+
+// Namespace is a namespace served by the agent. See config.Namespace for the individual fields.
+type Namespace = config.Namespace
+
+// Agent receives requests from the transport, handles them, and publishes the responses back to the clients.
+type Agent struct {
+	cfg        Config
+	logger     *slog.Logger
+	namespaces []namespace
+	// pool is only set if the agent created its own redis transports.
+	pool *redis.Pool
+	// metricsHandler is only set if the agent serves its own metrics.
+	metricsHandler http.Handler
+	// load counts the busy workers, and startedAt is when the agent was created, for the heartbeat.
+	load      *requestWorker.Load
+	startedAt time.Time
+
+	mu      sync.Mutex
+	started bool
+	// managerStatuses and workersDone are set once the request managers and workers have started, for the health
+	// checks.
+	managerStatuses []*requestManager.Status
+	workersDone     chan struct{}
+	stopping        bool
+	stop            chan struct{}
+	stopOnce        sync.Once
+	done            chan struct{}
+}
+
+// New validates the config and creates an agent, which handles requests once Run is called. If no Transport is
+// provided, a redis transport is created from the Redis config. Nothing is connected to until Run is called.
+func New(cfg Config) (a *Agent, err error) {
+	cfg = cfg.WithDefaults()
+	err = cfg.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid agent config: %w", err)
+	}
+
+	a = &Agent{
+		cfg:       cfg,
+		logger:    cfg.Logger,
+		load:      &requestWorker.Load{},
+		startedAt: time.Now(),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	if a.logger == nil {
+		a.logger = logging.Discard()
+	}
+	a.namespaces = a.newNamespaces()
+
+	if cfg.MetricsAddress != "" {
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(prometheus.NewGoCollector(),
+			prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+		a.cfg.Metrics, err = metrics.New(registry, a.queueDepther())
+		if err != nil {
+			return nil, err
+		}
+		a.metricsHandler = promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+	}
+	return a, nil
+}
+
+// namespace is a namespace served by the agent, along with the transport which carries its requests and responses.
+type namespace struct {
+	name      string
+	weight    int
+	transport transport.Transport
+	// redisTransport is only set if the agent created its own redis transport for the namespace.
+	redisTransport *redisTransport.Transport
+	logger         *slog.Logger
+}
+
+// newNamespaces returns the configured namespaces, creating a redis transport for each namespace without a transport.
+// If no namespaces are configured, the Transport or redis Keys are served as a single namespace.
+func (a *Agent) newNamespaces() (namespaces []namespace) {
+	configured := a.cfg.Namespaces
+	if len(configured) == 0 {
+		configured = []Namespace{{Transport: a.cfg.Transport, Weight: 1}}
+	}
+
+	for _, c := range configured {
+		ns := namespace{
+			name:      c.Name,
+			weight:    c.Weight,
+			transport: c.Transport,
+			logger:    a.logger,
+		}
+		if ns.transport == nil {
+			if a.pool == nil {
+				a.pool = newPool(a.cfg)
+			}
+			opts := append(redisOptions(a.cfg.Redis), redisTransport.WithAgentInfo(a.info))
+			if len(a.cfg.Namespaces) > 0 {
+				opts = append(opts, redisTransport.WithNamespace(c.Name))
+			}
+			ns.redisTransport = redisTransport.New(a.pool, opts...)
+			ns.transport = ns.redisTransport
+		}
+		if len(a.cfg.Namespaces) > 0 {
+			ns.logger = a.logger.With(logging.Namespace(c.Name))
+		}
+		namespaces = append(namespaces, ns)
+	}
+	return namespaces
+}
+
+// info returns the agent's info for its heartbeat.
+func (a *Agent) info() (info transport.AgentInfo) {
+	info = transport.AgentInfo{
+		Version:     Version(),
+		Threads:     a.cfg.Threads,
+		BusyThreads: a.load.Busy(),
+		StartedAt:   a.startedAt,
+	}
+	for _, algorithm := range hashAlgorithms.Algorithms {
+		info.Algorithms = append(info.Algorithms, algorithm.String())
+	}
+	return info
+}
+
+// Version returns the version of the agent's build, or "devel" if it wasn't built from a tagged module version.
+func Version() string {
+	buildInfo, ok := debug.ReadBuildInfo()
+	if !ok || buildInfo.Main.Version == "" || buildInfo.Main.Version == "(devel)" {
+		return "devel"
+	}
+	return buildInfo.Main.Version
+}
+
+// queueDepther returns the QueueDepther reporting the total queue depth of the namespaces. The queue depth is only
+// reported if every namespace's transport supports it.
+func (a *Agent) queueDepther() (queue metrics.QueueDepther) {
+	var queues queueDepths
+	for _, ns := range a.namespaces {
+		queue, ok := ns.transport.(metrics.QueueDepther)
+		if !ok {
+			return nil
+		}
+		queues = append(queues, queue)
+	}
+	if len(queues) == 1 {
+		return queues[0]
+	}
+	return queues
+}
+
+// queueDepths reports the total queue depth of several transports.
+type queueDepths []metrics.QueueDepther
+
+func (q queueDepths) QueueDepth() (depth int64, err error) {
+	for _, queue := range q {
+		queueDepth, err := queue.QueueDepth()
+		if err != nil {
+			return 0, err
+		}
+		depth += queueDepth
+	}
+	return depth, nil
+}
+
+func newPool(cfg Config) (pool *redis.Pool) {
+	// Each namespace's request manager holds a connection while it's waiting for a request.
+	managers := len(cfg.Namespaces)
+	if managers == 0 {
+		managers = 1
+	}
+	return &redis.Pool{
+		MaxIdle:     cfg.Threads + managers,
+		IdleTimeout: cfg.Redis.ConnectionTimeout,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp",
+				cfg.Redis.Host,
+				redis.DialUseTLS(cfg.Redis.TLS),
+				redis.DialUsername(cfg.Redis.Username),
+				redis.DialPassword(cfg.Redis.Password),
+			)
+		},
+	}
+}
+
+func redisOptions(cfg RedisConfig) (opts []redisTransport.Option) {
+	opts = []redisTransport.Option{
+		redisTransport.WithPopTimeout(cfg.PopTimeout),
+	}
+	// Keys can't be changed when namespaces are used, so the defaults are left for WithNamespace to replace.
+	if cfg.Keys != redisTransport.DefaultKeys() {
+		opts = append(opts, redisTransport.WithKeys(cfg.Keys))
+	}
+	if cfg.Streams {
+		opts = append(opts, redisTransport.WithStreams())
+	}
+	if cfg.ReliableQueue {
+		opts = append(opts, redisTransport.WithReliableQueue())
+	}
+	if cfg.AgentID != "" {
+		opts = append(opts, redisTransport.WithAgentID(cfg.AgentID))
+	}
+	return opts
+}
+
+// Run starts the request manager and workers, and handles requests until the context is cancelled or Shutdown is
+// called. An error is returned if the transport can't be reached, unless Durable mode is enabled. Run can only be
+// called once.
+//
+// When the agent is stopped, no more requests are received, and the workers are given the ShutdownGracePeriod to handle
+// the requests which have already been received. Any which haven't been started by then are requeued for another agent,
+// and Run returns once the workers have finished the requests they're busy with.
+func (a *Agent) Run(ctx context.Context) (err error) {
+	a.mu.Lock()
+	if a.started {
+		a.mu.Unlock()
+		return fmt.Errorf("agent has already been run")
+	}
+	a.started = true
+	a.mu.Unlock()
+	defer close(a.done)
+
+	if a.pool != nil {
+		defer a.pool.Close()
+	}
+	// The heartbeat keeps running until everything has been drained, so that another agent doesn't requeue requests
+	// which are still being handled.
+	heartbeatCtx, stopHeartbeat := context.WithCancel(context.Background())
+	defer stopHeartbeat()
+	receiveCtx, stopReceiving := context.WithCancel(context.Background())
+	defer stopReceiving()
+	workCtx, stopWorking := context.WithCancel(context.Background())
+	defer stopWorking()
+
+	// The heartbeat registers the agent, so that clients can list it. In reliable mode, it must be set before any
+	// requests are moved into the processing list, otherwise another agent could requeue them straight away.
+	for _, ns := range a.namespaces {
+		if ns.redisTransport == nil {
+			continue
+		}
+		err = ns.redisTransport.Heartbeat()
+		if err != nil && !a.cfg.Durable {
+			return fmt.Errorf("couldn't set agent heartbeat: %w", err)
+		}
+		// The redis transport logs using the standard library logger, as it's part of the library.
+		transportLogger := slog.NewLogLogger(ns.logger.Handler(), slog.LevelWarn)
+		ns.redisTransport.StartHeartbeat(heartbeatCtx, transportLogger)
+		if a.cfg.Redis.ReliableQueue {
+			ns.redisTransport.StartReaper(heartbeatCtx, transportLogger)
+			ns.logger.Info("Reliable mode enabled.", slog.String("agent_id", ns.redisTransport.AgentID()))
+		}
+	}
+
+	servers, err := a.startHTTPServers()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, server := range servers {
+			server.Close()
+		}
+	}()
+
+	requestChan, managerStatuses, err := a.startRequestManagers(receiveCtx)
+	if err != nil {
+		return err
+	}
+	workersDone := requestWorker.StartMany(workCtx, requestChan, a.namespaces[0].transport, &a.cfg, a.load, a.logger)
+	a.mu.Lock()
+	a.managerStatuses = managerStatuses
+	a.workersDone = workersDone
+	a.mu.Unlock()
+	if a.pool != nil {
+		a.logger.Info("gocrypt agent started, and Redis connection successfully opened.",
+			slog.String("redis_host", a.cfg.Redis.Host))
+	} else {
+		a.logger.Info("gocrypt agent started.")
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-a.stop:
+	}
+	a.mu.Lock()
+	a.stopping = true
+	a.mu.Unlock()
+	a.logger.Info("Stopping gocrypt agent. Waiting for received requests to be handled.",
+		slog.Duration("grace_period", a.cfg.ShutdownGracePeriod))
+	stopReceiving()
+
+	// The workers stop by themselves once the request manager has stopped and they've emptied the request channel.
+	gracePeriod := time.NewTimer(a.cfg.ShutdownGracePeriod)
+	defer gracePeriod.Stop()
+	select {
+	case <-workersDone:
+	case <-gracePeriod.C:
+		a.logger.Warn("Shutdown grace period expired. Requests which haven't been started will be requeued.")
+		stopWorking()
+		<-workersDone
+	}
+
+	// Anything left in the channel was never started. The channel is closed once the request manager has stopped.
+	requeued := 0
+	for req := range requestChan {
+		req.Requeue(a.logger)
+		requeued++
+	}
+	if requeued > 0 {
+		a.logger.Info("Requeued requests.", slog.Int("requeued", requeued))
+	}
+
+	// Everything has been drained, so the agent can stop being listed straight away.
+	stopHeartbeat()
+	for _, ns := range a.namespaces {
+		if ns.redisTransport == nil {
+			continue
+		}
+		err = ns.redisTransport.Deregister()
+		if err != nil {
+			ns.logger.Warn("Failed to deregister agent.", logging.Err(err))
+		}
+	}
+	a.logger.Info("gocrypt agent stopped.")
+	return nil
+}
+
+// startRequestManagers starts a request manager for each namespace. With several namespaces, their requests are merged
+// according to the namespaces' weights.
+func (a *Agent) startRequestManagers(ctx context.Context) (requestChan chan *transportHelpers.ReceivedRequest,
+	statuses []*requestManager.Status, err error) {
+	var sources []requestManager.Source
+	for _, ns := range a.namespaces {
+		results, status, err := requestManager.Start(ctx, ns.transport, &a.cfg, ns.logger)
+		if err != nil {
+			if ns.name != "" {
+				return nil, nil, fmt.Errorf("couldn't start up request manager for namespace %q: %w", ns.name, err)
+			}
+			return nil, nil, fmt.Errorf("couldn't start up request manager: %w", err)
+		}
+		sources = append(sources, requestManager.Source{Requests: results, Weight: ns.weight})
+		statuses = append(statuses, status)
+	}
+	if len(sources) == 1 {
+		return sources[0].Requests, statuses, nil
+	}
+	return requestManager.Merge(sources), statuses, nil
+}
+
+// Shutdown stops the agent, and waits for Run to return or the context to be cancelled, whichever happens first.
+func (a *Agent) Shutdown(ctx context.Context) (err error) {
+	a.stopOnce.Do(func() {
+		close(a.stop)
+	})
+
+	a.mu.Lock()
+	started := a.started
+	a.mu.Unlock()
+	if !started {
+		return nil
+	}
+
+	select {
+	case <-a.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// StartLoopback runs an agent with a single worker using a new in-memory transport, and returns the transport. A
+// RemotePasswordHasher created using remotePasswordHasher.WithTransport with this transport can then be used without
+// redis or a separately running agent, which is mainly useful for tests. The agent stops when the context is cancelled.
+func StartLoopback(ctx context.Context, logger *slog.Logger) (t *memoryTransport.Transport, err error) {
+	t = memoryTransport.New()
+	cfg := DefaultConfig()
+	cfg.Transport = t
+	cfg.Threads = 1
+	cfg.Logger = logger
+
+	a, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	go a.Run(ctx) //nolint
+	return t, nil
+}
@@ -0,0 +1,328 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rsheasby/gocrypt/gocrypt/logging"
+	"github.com/rsheasby/gocrypt/gocrypt/metrics"
+	"github.com/rsheasby/gocrypt/localPasswordHasher"
+	"github.com/rsheasby/gocrypt/protocol"
+	"github.com/rsheasby/gocrypt/remotePasswordHasher"
+	"github.com/rsheasby/gocrypt/remotePasswordHasher/otelObserver"
+	"github.com/rsheasby/gocrypt/transport"
+	"github.com/rsheasby/gocrypt/transport/memoryTransport"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
+)
+
+// These tests exercise the RemotePasswordHasher and the agent end to end, without redis.
+
+func TestLoopbackAgentShouldHandleRequests(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tr, err := StartLoopback(ctx, nil)
+	assert.Nil(t, err, "No error should be returned when starting the loopback agent")
+
+	rph, err := remotePasswordHasher.New(4, 10*time.Second, nil, remotePasswordHasher.WithTransport(tr))
+	assert.Nil(t, err, "No error should be returned when creating the hasher with the loopback transport")
+	lph, _ := localPasswordHasher.New(4)
+
+	password := "password123!"
+	hash, err := rph.HashPassword(password)
+	assert.Nil(t, err, "No error should be returned when hashing")
+	isValid, _ := lph.ValidatePassword(password, hash)
+	assert.True(t, isValid, "Hash from the agent should validate using the local hasher")
+
+	isValid, err = rph.ValidatePassword(password, hash)
+	assert.Nil(t, err, "No error should be returned when validating")
+	assert.True(t, isValid, "Correct password should validate")
+
+	isValid, err = rph.ValidatePassword("wrong", hash)
+	assert.Nil(t, err, "No error should be returned when validating")
+	assert.False(t, isValid, "Incorrect password shouldn't validate")
+
+	_, err = rph.ValidatePassword(password, "$2y")
+	assert.True(t, errors.Is(err, remotePasswordHasher.ErrInvalidHash), "Agent errors should be returned")
+
+	argon2idRph, _ := remotePasswordHasher.New(0, 10*time.Second, nil, remotePasswordHasher.WithTransport(tr),
+		remotePasswordHasher.WithArgon2id(64, 1, 1))
+	isValid, newHash, err := argon2idRph.ValidateAndRehash(password, hash)
+	assert.Nil(t, err, "No error should be returned when rehashing")
+	assert.True(t, isValid, "Correct password should validate")
+	assert.True(t, strings.HasPrefix(newHash, "$argon2id$"), "Bcrypt hash should be rehashed with argon2id")
+}
+
+func TestLoopbackAgentShouldStopWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	tr, _ := StartLoopback(ctx, nil)
+	cancel()
+
+	rph, _ := remotePasswordHasher.New(4, 100*time.Millisecond, nil, remotePasswordHasher.WithTransport(tr))
+	_, err := rph.HashPassword("password")
+	assert.True(t, errors.Is(err, remotePasswordHasher.ErrTimeout), "Requests shouldn't be handled once the agent stops")
+}
+
+func TestNewShouldValidateConfig(t *testing.T) {
+	_, err := New(Config{})
+	assert.NotNil(t, err, "Either a transport or a redis host should be required")
+
+	cfg := DefaultConfig()
+	cfg.Redis.Host = "localhost:6379"
+	cfg.Redis.ConnectionTimeout = cfg.Redis.PopTimeout
+	_, err = New(cfg)
+	assert.NotNil(t, err, "The connection timeout should be required to be longer than the pop timeout")
+
+	cfg = DefaultConfig()
+	cfg.Transport = memoryTransport.New()
+	cfg.Threads = -1
+	_, err = New(cfg)
+	assert.NotNil(t, err, "A negative amount of threads shouldn't be allowed")
+
+	cfg = DefaultConfig()
+	cfg.Threads = -1
+	cfg.PublishAttempts = -1
+	_, err = New(cfg)
+	assert.Error(t, err, "Invalid configs should be refused")
+	for _, problem := range []string{"redis host", "threads", "publish attempts"} {
+		assert.Contains(t, err.Error(), problem, "Every problem with the config should be reported")
+	}
+
+	_, err = New(Config{Transport: memoryTransport.New()})
+	assert.Nil(t, err, "Zero values should be replaced with the defaults")
+}
+
+func TestAgentShouldRunUntilShutdown(t *testing.T) {
+	tr := memoryTransport.New()
+	a, err := New(Config{Transport: tr, Threads: 1})
+	assert.Nil(t, err, "No error should be returned when creating the agent")
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- a.Run(context.Background())
+	}()
+
+	rph, _ := remotePasswordHasher.New(4, 10*time.Second, nil, remotePasswordHasher.WithTransport(tr))
+	_, err = rph.HashPassword("password")
+	assert.Nil(t, err, "Requests should be handled while the agent is running")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.Nil(t, a.Shutdown(ctx), "No error should be returned when shutting down")
+	select {
+	case err = <-runErr:
+		assert.Nil(t, err, "Run should return without an error once the agent is shut down")
+	default:
+		assert.Fail(t, "Run should have returned once Shutdown returned")
+	}
+	assert.NotNil(t, a.Run(context.Background()), "The agent shouldn't be able to run twice")
+}
+
+func TestAgentShouldServeSeveralNamespaces(t *testing.T) {
+	staging := memoryTransport.New()
+	production := memoryTransport.New()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	a, err := New(Config{Threads: 2, Namespaces: []Namespace{
+		{Name: "staging", Transport: staging},
+		{Name: "production", Transport: production, Weight: 3},
+	}})
+	assert.Nil(t, err, "No error should be returned when creating the agent")
+	go a.Run(ctx) //nolint
+
+	for name, tr := range map[string]*memoryTransport.Transport{"staging": staging, "production": production} {
+		rph, _ := remotePasswordHasher.New(4, 10*time.Second, nil, remotePasswordHasher.WithTransport(tr))
+		hash, err := rph.HashPassword("password")
+		assert.Nil(t, err, "Requests in the %s namespace should be handled", name)
+		isValid, err := rph.ValidatePassword("password", hash)
+		assert.Nil(t, err, "Responses should be published to the %s namespace", name)
+		assert.True(t, isValid, "Requests in the %s namespace should be handled correctly", name)
+	}
+
+	_, err = New(Config{Transport: staging, Namespaces: []Namespace{{Name: "staging"}}})
+	assert.NotNil(t, err, "A transport shouldn't be allowed with namespaces")
+	_, err = New(Config{Namespaces: []Namespace{{Name: "a", Transport: staging}, {Name: "a", Transport: production}}})
+	assert.NotNil(t, err, "Namespaces shouldn't be allowed more than once")
+	_, err = New(Config{Namespaces: []Namespace{{Name: "a*", Transport: staging, Weight: -1}}})
+	assert.NotNil(t, err, "Invalid names and weights shouldn't be allowed")
+	_, err = New(Config{Namespaces: []Namespace{{Name: "Processing", Transport: staging}}})
+	assert.NotNil(t, err, "Names which overlap with the default keys shouldn't be allowed")
+	_, err = New(Config{Namespaces: []Namespace{{Name: "a", Transport: staging}, {Name: "b"}}})
+	assert.NotNil(t, err, "A redis host should be required for namespaces without a transport")
+}
+
+func TestAgentShouldNotLoseRequestsWhenShutDown(t *testing.T) {
+	tr := memoryTransport.New()
+	a, _ := New(Config{
+		Transport:           tr,
+		Threads:             1,
+		PublishAttempts:     1,
+		ShutdownGracePeriod: time.Nanosecond,
+	})
+
+	const requestCount = 20
+	var awaiters []transport.Awaiter
+	for i := 0; i < requestCount; i++ {
+		req := &protocol.Request{
+			RequestType:     protocol.Request_HASHPASSWORD,
+			ResponseKey:     fmt.Sprintf("ABCDEFGHIJKLMNOPQRSTUVWXYZ%d", i),
+			Password:        []byte("password"),
+			Cost:            4,
+			ExpiryTimestamp: math.MaxInt64,
+		}
+		reqBytes, _ := proto.Marshal(req)
+		awaiter, _ := tr.AwaitResponse(req.ResponseKey)
+		defer awaiter.Close()
+		awaiters = append(awaiters, awaiter)
+		_ = tr.SubmitRequest(reqBytes)
+	}
+
+	go a.Run(context.Background()) //nolint
+	_, err := awaiters[0].Wait(context.Background(), 5*time.Second)
+	assert.Nil(t, err, "The first request should be handled before the agent is shut down")
+	assert.Nil(t, a.Shutdown(context.Background()), "No error should be returned when shutting down")
+
+	handled := 1
+	for _, awaiter := range awaiters[1:] {
+		_, err := awaiter.Wait(context.Background(), time.Millisecond)
+		if err == nil {
+			handled++
+		}
+	}
+	queued := 0
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		_, err := tr.ReceiveRequest(ctx)
+		cancel()
+		if err != nil {
+			break
+		}
+		queued++
+	}
+	assert.Equal(t, requestCount, handled+queued, "Every request should either be handled or left on the queue")
+}
+
+func TestAgentShouldRecordMetrics(t *testing.T) {
+	tr := memoryTransport.New()
+	registry := prometheus.NewRegistry()
+	m, _ := metrics.New(registry, tr)
+
+	_, err := New(Config{Transport: tr, Metrics: m, MetricsAddress: ":9090"})
+	assert.NotNil(t, err, "Metrics created separately shouldn't be allowed with a metrics address")
+
+	a, err := New(Config{Transport: tr, Threads: 1, Metrics: m})
+	assert.Nil(t, err, "No error should be returned when creating the agent")
+	go a.Run(context.Background())         //nolint
+	defer a.Shutdown(context.Background()) //nolint
+
+	rph, _ := remotePasswordHasher.New(4, 10*time.Second, nil, remotePasswordHasher.WithTransport(tr))
+	hash, _ := rph.HashPassword("password")
+	_, _ = rph.ValidatePassword("password", hash)
+
+	count, err := testutil.GatherAndCount(registry, "gocrypt_requests_total", "gocrypt_hash_duration_seconds",
+		"gocrypt_verify_duration_seconds")
+	assert.Nil(t, err, "No error should be returned when gathering the metrics")
+	assert.Equal(t, 4, count, "Both requests should be counted, and their durations recorded")
+}
+
+func TestAgentShouldNeverLogPasswordsOrHashes(t *testing.T) {
+	logBuffer := &bytes.Buffer{}
+	logger, _ := logging.New(logBuffer, logging.Options{Format: logging.FormatJSON, Level: slog.LevelDebug})
+	tr := memoryTransport.New()
+	a, _ := New(Config{Transport: tr, Threads: 1, Logger: logger})
+	go a.Run(context.Background()) //nolint
+
+	password := "correct horse battery staple"
+	rph, _ := remotePasswordHasher.New(4, 10*time.Second, nil, remotePasswordHasher.WithTransport(tr),
+		remotePasswordHasher.WithLegacyBcrypt())
+	hash, err := rph.HashPassword(password)
+	assert.Nil(t, err, "No error should be returned when hashing")
+	_, _, _ = rph.ValidateAndRehash(password, hash)
+	_, _ = rph.ValidatePassword(password, "$2y$04$notquiteahash")
+	_, _ = rph.ValidatePassword(password, "pbkdf2_sha256$1$salt$broken")
+	// The requests are logged after the responses are published, so wait for the agent to finish.
+	_ = a.Shutdown(context.Background())
+
+	encoded := sha512.Sum512([]byte(password))
+	logs := logBuffer.String()
+	assert.Contains(t, logs, `"request_type":"VERIFYPASSWORD"`, "Requests should be logged at the debug level")
+	for _, secret := range []string{password, hash, string(encoded[:]), "notquiteahash", "salt$broken"} {
+		assert.NotContains(t, logs, secret, "Passwords and hashes should never be logged")
+	}
+}
+
+func TestAgentShouldJoinClientTraces(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tr := memoryTransport.New()
+
+	a, _ := New(Config{Transport: tr, Threads: 1, TracerProvider: tp})
+	go a.Run(context.Background()) //nolint
+
+	rph, _ := remotePasswordHasher.New(4, 10*time.Second, nil, remotePasswordHasher.WithTransport(tr),
+		remotePasswordHasher.WithObserver(otelObserver.New(otelObserver.WithTracerProvider(tp))))
+	_, err := rph.ValidatePassword("password", "$2y")
+	assert.True(t, errors.Is(err, remotePasswordHasher.ErrInvalidHash), "Agent errors should be returned")
+	// The agent's span is ended after the response is published, so wait for it to finish.
+	_ = a.Shutdown(context.Background())
+
+	var clientSpan, agentSpan sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() == "gocrypt VERIFYPASSWORD" {
+			switch span.SpanKind() {
+			case trace.SpanKindClient:
+				clientSpan = span
+			case trace.SpanKindServer:
+				agentSpan = span
+			}
+		}
+	}
+	if assert.NotNil(t, clientSpan, "The client should record a span") &&
+		assert.NotNil(t, agentSpan, "The agent should record a span") {
+		assert.Equal(t, clientSpan.SpanContext().TraceID(), agentSpan.SpanContext().TraceID(),
+			"The agent's span should be in the client's trace")
+		assert.Equal(t, clientSpan.SpanContext().SpanID(), agentSpan.Parent().SpanID(),
+			"The agent's span should be a child of the client's span")
+		assert.True(t, agentSpan.Parent().IsRemote(), "The agent's parent span should be remote")
+		assert.Contains(t, agentSpan.Attributes(), attribute.String("gocrypt.outcome", "invalid_hash"),
+			"The agent's span should record the outcome")
+	}
+}
+
+func TestAgentShouldReportHealth(t *testing.T) {
+	a, _ := New(Config{Transport: memoryTransport.New(), Threads: 1})
+	check := func(handler http.HandlerFunc) (status int) {
+		recorder := httptest.NewRecorder()
+		handler(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		return recorder.Code
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, check(a.handleHealthz), "Agents which haven't started aren't alive")
+	assert.Equal(t, http.StatusServiceUnavailable, check(a.handleReadyz), "Agents which haven't started aren't ready")
+
+	go a.Run(context.Background()) //nolint
+	assert.Eventually(t, func() bool {
+		return check(a.handleHealthz) == http.StatusOK && check(a.handleReadyz) == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond, "Running agents should be alive and ready")
+
+	_ = a.Shutdown(context.Background())
+	assert.Equal(t, http.StatusServiceUnavailable, check(a.handleHealthz), "Stopped agents aren't alive")
+	assert.Equal(t, http.StatusServiceUnavailable, check(a.handleReadyz), "Stopped agents aren't ready")
+}
@@ -0,0 +1,43 @@
+package agent
+
+import (
+	"fmt"
+
+	"github.com/rsheasby/gocrypt/gocrypt/logging"
+	"github.com/rsheasby/gocrypt/transport"
+)
+
+// RegisteredAgents lists the agents which are registered in a namespace.
+type RegisteredAgents struct {
+	// Namespace is the name of the namespace, which is empty if no namespaces are configured.
+	Namespace string
+	Agents    []transport.AgentInfo
+}
+
+// ListAgents lists the running agents which are registered in each namespace of the config, in the same way as the
+// agent would connect to them. The config is validated first, and only redis transports are supported, as other
+// transports don't keep a registry of agents.
+func ListAgents(cfg Config) (registered []RegisteredAgents, err error) {
+	cfg = cfg.WithDefaults()
+	err = cfg.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid agent config: %w", err)
+	}
+
+	a := &Agent{cfg: cfg, logger: logging.Discard()}
+	namespaces := a.newNamespaces()
+	if a.pool != nil {
+		defer a.pool.Close()
+	}
+	for _, ns := range namespaces {
+		agents, err := transport.ListAgents(ns.transport)
+		if err != nil {
+			if ns.name != "" {
+				return nil, fmt.Errorf("couldn't list agents in namespace %q: %w", ns.name, err)
+			}
+			return nil, fmt.Errorf("couldn't list agents: %w", err)
+		}
+		registered = append(registered, RegisteredAgents{Namespace: ns.name, Agents: agents})
+	}
+	return registered, nil
+}
@@ -0,0 +1,112 @@
+package agent
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+
+	"github.com/rsheasby/gocrypt/gocrypt/logging"
+)
+
+// startHTTPServers starts serving the metrics on the MetricsAddress, and the health checks on the HealthAddress.
+// They're served by the same server if the addresses are the same. The servers must be closed once the agent stops.
+func (a *Agent) startHTTPServers() (servers []*http.Server, err error) {
+	muxes := make(map[string]*http.ServeMux)
+	muxFor := func(address string) (mux *http.ServeMux) {
+		if muxes[address] == nil {
+			muxes[address] = http.NewServeMux()
+		}
+		return muxes[address]
+	}
+	if a.metricsHandler != nil {
+		muxFor(a.cfg.MetricsAddress).Handle("/metrics", a.metricsHandler)
+	}
+	if a.cfg.HealthAddress != "" {
+		mux := muxFor(a.cfg.HealthAddress)
+		mux.HandleFunc("/healthz", a.handleHealthz)
+		mux.HandleFunc("/readyz", a.handleReadyz)
+	}
+
+	for address, mux := range muxes {
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			for _, server := range servers {
+				server.Close()
+			}
+			return nil, fmt.Errorf("couldn't listen on %s: %w", address, err)
+		}
+
+		server := &http.Server{
+			Handler: mux,
+		}
+		servers = append(servers, server)
+		go func() {
+			err := server.Serve(listener)
+			if err != http.ErrServerClosed {
+				a.logger.Error("HTTP server stopped.", logging.Err(err))
+			}
+		}()
+		a.logger.Info("Serving HTTP.", slog.String("address", listener.Addr().String()))
+	}
+	return servers, nil
+}
+
+// handleHealthz reports whether the agent is alive, which is the case while its workers are running.
+func (a *Agent) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	workersDone := a.workersDone
+	a.mu.Unlock()
+
+	if workersDone == nil {
+		http.Error(w, "workers haven't started", http.StatusServiceUnavailable)
+		return
+	}
+	select {
+	case <-workersDone:
+		http.Error(w, "workers have stopped", http.StatusServiceUnavailable)
+	default:
+		fmt.Fprintln(w, "ok")
+	}
+}
+
+// handleReadyz reports whether the agent is ready to handle requests, which is the case while the request managers are
+// receiving requests, and the transports can be reached. It fails as soon as the agent starts stopping.
+func (a *Agent) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	statuses := a.managerStatuses
+	stopping := a.stopping
+	a.mu.Unlock()
+
+	if stopping {
+		http.Error(w, "agent is stopping", http.StatusServiceUnavailable)
+		return
+	}
+	if statuses == nil {
+		http.Error(w, "request manager isn't running", http.StatusServiceUnavailable)
+		return
+	}
+	for i, ns := range a.namespaces {
+		problem := ""
+		switch {
+		case !statuses[i].Running():
+			problem = "request manager isn't running"
+		case statuses[i].BackingOff():
+			problem = "request manager is waiting to retry after a transport error"
+		default:
+			err := ns.transport.Ping()
+			if err != nil {
+				problem = fmt.Sprintf("transport can't be reached: %v", err)
+			}
+		}
+		if problem == "" {
+			continue
+		}
+		if len(a.cfg.Namespaces) > 0 {
+			problem = fmt.Sprintf("namespace %q: %s", ns.name, problem)
+		}
+		http.Error(w, problem, http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
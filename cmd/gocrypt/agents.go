@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rsheasby/gocrypt/gocrypt/agent"
+)
+
+// listAgents prints the agents which are registered in redis, and returns the exit code. It's configured with the same
+// settings as the agent, so running it with the agent's config lists the agents serving the same redis and namespaces.
+// The exit code is 1 if no agents are running, so that it can also be used to check the fleet.
+func listAgents(args []string, lookupEnv func(key string) (string, bool), output io.Writer) (exitCode int) {
+	s, problems, err := loadSettings(args, lookupEnv, output)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	} else if err != nil {
+		return 2
+	}
+	cfg, configProblems := s.agentConfig()
+	problems = append(problems, configProblems...)
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Fprintf(output, "Invalid configuration: %v\n", problem)
+		}
+		return 2
+	}
+
+	registered, err := agent.ListAgents(cfg)
+	if err != nil {
+		fmt.Fprintf(output, "Couldn't list agents: %v\n", err)
+		return 1
+	}
+	running := printAgents(output, registered, time.Now())
+	if running == 0 {
+		fmt.Fprintln(output, "No agents are running.")
+		return 1
+	}
+	return 0
+}
+
+// printAgents prints a table of the registered agents, with the time since their last heartbeat relative to now, and
+// returns how many were printed. The namespace column is only included if any namespaces are configured.
+func printAgents(output io.Writer, registered []agent.RegisteredAgents, now time.Time) (running int) {
+	withNamespaces := len(registered) > 0 && registered[0].Namespace != ""
+	for _, r := range registered {
+		running += len(r.Agents)
+	}
+	if running == 0 {
+		return 0
+	}
+
+	w := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	if withNamespaces {
+		fmt.Fprint(w, "NAMESPACE\t")
+	}
+	fmt.Fprintln(w, "ID\tHOST\tVERSION\tTHREADS\tLOAD\tALGORITHMS\tLAST SEEN")
+	for _, r := range registered {
+		for _, info := range r.Agents {
+			if withNamespaces {
+				fmt.Fprintf(w, "%s\t", r.Namespace)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d%%\t%s\t%s ago\n", info.ID, info.Hostname, info.Version, info.Threads,
+				int(info.Load()*100), strings.Join(info.Algorithms, ","),
+				now.Sub(info.HeartbeatAt).Truncate(time.Second))
+		}
+	}
+	_ = w.Flush()
+	return running
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/rsheasby/gocrypt/gocrypt/agent"
+	"github.com/rsheasby/gocrypt/transport"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrintAgentsShouldPrintATable(t *testing.T) {
+	now := time.Unix(1000, 0)
+	info := transport.AgentInfo{ID: "agent-1", Hostname: "host", Version: "v1.2.0", Threads: 4, BusyThreads: 1,
+		Algorithms: []string{"bcrypt", "argon2id"}, HeartbeatAt: now.Add(-3 * time.Second)}
+
+	output := &bytes.Buffer{}
+	running := printAgents(output, []agent.RegisteredAgents{{Agents: []transport.AgentInfo{info}}}, now)
+	assert.Equal(t, 1, running, "The running agents should be counted")
+	assert.Equal(t, "ID       HOST  VERSION  THREADS  LOAD  ALGORITHMS       LAST SEEN\n"+
+		"agent-1  host  v1.2.0   4        25%   bcrypt,argon2id  3s ago\n", output.String(),
+		"The agents should be printed as a table")
+
+	output.Reset()
+	running = printAgents(output, []agent.RegisteredAgents{
+		{Namespace: "staging", Agents: []transport.AgentInfo{info}},
+		{Namespace: "production"},
+	}, now)
+	assert.Equal(t, 1, running, "The running agents should be counted across namespaces")
+	assert.Contains(t, output.String(), "NAMESPACE", "The namespace should be printed when namespaces are configured")
+	assert.Contains(t, output.String(), "staging", "The namespace should be printed when namespaces are configured")
+
+	output.Reset()
+	assert.Equal(t, 0, printAgents(output, []agent.RegisteredAgents{{}}, now), "No agents should be counted")
+	assert.Zero(t, output.Len(), "Nothing should be printed without agents")
+}
+
+func TestListAgentsShouldRefuseInvalidSettings(t *testing.T) {
+	noEnv := func(string) (string, bool) { return "", false }
+	output := &bytes.Buffer{}
+	assert.Equal(t, 2, listAgents([]string{"-transport", "carrier-pigeon", "-redis-host", "localhost:6379"}, noEnv,
+		output), "Invalid settings should be refused")
+	assert.Contains(t, output.String(), "Invalid configuration", "The problem should be output")
+	assert.Equal(t, 2, listAgents([]string{"unexpected"}, noEnv, output), "Unexpected arguments should be refused")
+}
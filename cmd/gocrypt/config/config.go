@@ -1,78 +1,263 @@
 package config
 
 import (
-	"log"
-	"os"
+	"errors"
+	"fmt"
+	"log/slog"
 	"runtime"
 	"time"
 
-	"github.com/joho/godotenv"
+	"github.com/rsheasby/gocrypt/envelope"
+	"github.com/rsheasby/gocrypt/gocrypt/metrics"
+	"github.com/rsheasby/gocrypt/hashAlgorithms"
+	"github.com/rsheasby/gocrypt/requestHandler"
+	"github.com/rsheasby/gocrypt/transport"
+	"github.com/rsheasby/gocrypt/transport/redisTransport"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
-	// RequestQueueKey specifies the redis key that will be used for the request queue.
-	RequestQueueKey = "gocrypt:RequestQueue"
-	// ResponseKeyPrefix specifies the redis key prefix that will be used for response publishing.
-	ResponseKeyPrefix = "gocrypt:Response:"
-	// ErrorRetryTime specifies how long to wait before retrying when there's a redis read error.
-	ErrorRetryTime = 1 * time.Second
-	// ConnectionTimeout specifies the timeout for the redis connection. Must be longer than the PopTimeout.
-	ConnectionTimeout = 60 * time.Second
-	// PopTimeout specifies the connection timeout for the blocking queue pop. This could be arbitrarily long, but you
-	// have to set a limit so I reckon 10 seconds is reasonable.
-	PopTimeout = 10
-	// PublishAttempts specifies the maximum amount of times that the response publish will be retried if something
-	// goes wrong. Some tests rely on this being at least 3, so expect some failures if this is dropped below 3.
-	PublishAttempts = 5
-	// MinResponseKeyLength specifies the minimum length for the response key.
-	// 16 is a decent length to be relatively sure you won't have collisions,
-	// and is also the length of a UUID in binary representation.
-	// Our client uses test UUIDs with a timestamp which will be well over 40 characters,
-	// but there's no need to enforce that level of security on the agent-side.
-	MinResponseKeyLength = 16
+	// DefaultErrorRetryTime specifies how long to wait before retrying when there's a transport error.
+	DefaultErrorRetryTime = 1 * time.Second
+	// DefaultConnectionTimeout specifies the timeout for the redis connection. Must be longer than the PopTimeout.
+	DefaultConnectionTimeout = 60 * time.Second
+	// DefaultPublishAttempts specifies the maximum amount of times that the response publish will be retried if
+	// something goes wrong. Some tests rely on this being at least 3, so expect failures if it's dropped below 3.
+	DefaultPublishAttempts = 5
+	// DefaultMinResponseKeyLength specifies the minimum length for the response key. It's shared with the loopback
+	// agent.
+	DefaultMinResponseKeyLength = requestHandler.DefaultMinResponseKeyLength
+	// DefaultShutdownGracePeriod specifies how long queued requests are given to be handled once the agent is stopped.
+	// It's shorter than the 30 seconds most orchestrators wait before killing the process.
+	DefaultShutdownGracePeriod = 20 * time.Second
 )
 
-var (
-	// RedisHost specifies the host and port for the redis server.
-	RedisHost string
-	// RedisTLS specifies if the redis connection should use TLS.
-	RedisTLS bool
-	// RedisUsername specifies the username to use for redis auth.
-	RedisUsername string
-	// RedisPassword specifies the password to use for redis auth.
-	RedisPassword string
-	// Threads specifies how many worker threads should be started.
+// Config specifies how the agent runs. Zero values are replaced with the defaults by WithDefaults.
+type Config struct {
+	// Transport carries requests and responses between the clients and the agent. If it's nil, a redis transport is
+	// created using the Redis config.
+	Transport transport.Transport
+	// Redis configures the redis transport. It's ignored if a Transport is provided.
+	Redis RedisConfig
+	// Namespaces specifies the namespaces which the agent serves, each with its own request queue and responses, so
+	// that several applications or environments can share a redis server. If it's empty, the agent only serves the
+	// Transport, or the redis Keys.
+	Namespaces []Namespace
+	// Threads specifies how many worker threads should be started. Defaults to the number of CPUs.
 	Threads int
-	// Durable makes the service infinitely attempt retries whenever possible, instead of exiting on failures.
-	Durable = false
-)
+	// Durable makes the agent infinitely attempt retries whenever possible, instead of returning an error on failures.
+	Durable bool
+	// Peppers specifies the pepper keys used to pepper passwords before hashing. Nil if peppering isn't enabled.
+	Peppers *hashAlgorithms.Peppers
+	// Envelope specifies the keys used to open requests and seal responses. Nil if envelopes aren't enabled, in which
+	// case requests and responses are sent as plain protobuf messages.
+	Envelope *envelope.Keys
+	// PublishAttempts specifies the maximum amount of times that a response is published.
+	PublishAttempts int
+	// ErrorRetryTime specifies how long to wait before retrying when there's a transport error.
+	ErrorRetryTime time.Duration
+	// MinResponseKeyLength specifies the minimum length for the response key of requests.
+	MinResponseKeyLength int
+	// ShutdownGracePeriod specifies how long the workers are given to handle the requests which have already been
+	// received once the agent is stopped. Requests which haven't been started by then are requeued. Stopping can take
+	// up to the redis PopTimeout longer, as a blocking pop can't be interrupted.
+	ShutdownGracePeriod time.Duration
+	// MetricsAddress specifies the address to serve Prometheus metrics on at /metrics, such as ":9090". The metrics
+	// server isn't started if it's empty.
+	MetricsAddress string
+	// HealthAddress specifies the address to serve the /healthz liveness check and the /readyz readiness check on, such
+	// as ":8080". It can be the same as the MetricsAddress. The health checks aren't served if it's empty.
+	HealthAddress string
+	// Metrics records the agent's metrics. It's created by agent.New if the MetricsAddress is set, but can instead be
+	// created using metrics.New to register the metrics with another registry. Metrics aren't recorded if it's nil.
+	Metrics *metrics.Metrics
+	// TracerProvider creates a span for each request that's handled. If the request carries trace context from the
+	// client, the span joins the client's trace. Defaults to the global tracer provider, which doesn't record anything
+	// unless one has been set using otel.SetTracerProvider.
+	TracerProvider trace.TracerProvider
+	// Logger is used for all of the agent's logs. Logs are discarded if it's nil. Use logging.New to create a logger
+	// which redacts passwords and hashes, even if they're logged by mistake.
+	Logger *slog.Logger
+}
 
-// ReadEnvironment gets the environment variables and initialises the config variables
-func ReadEnvironment() {
-	err := godotenv.Load("gocrypt.env")
-	if err != nil {
-		log.Println("Failed to read gocrypt.env. Falling back to environment variables.")
-	}
+// Namespace is a namespace served by the agent.
+type Namespace struct {
+	// Name is inserted into the namespace's redis keys, such as "gocrypt:staging:RequestQueue". The empty name is the
+	// default namespace, which uses the default keys.
+	Name string
+	// Weight specifies the namespace's share of the requests handled while several namespaces have requests waiting,
+	// relative to the weights of the other namespaces. Defaults to 1.
+	Weight int
+	// Transport carries the namespace's requests and responses. If it's nil, a redis transport is created using the
+	// Redis config and the namespaced keys.
+	Transport transport.Transport
+}
 
-	RedisHost = os.Getenv("REDIS_HOST")
-	if RedisHost == "" {
-		log.Fatalln(`No Redis host specified. Environment variable "REDIS_HOST" should be set.`)
-	}
+// RedisConfig specifies how the agent connects to redis, and how requests and responses are sent through it.
+type RedisConfig struct {
+	// Host specifies the host and port for the redis server.
+	Host string
+	// TLS specifies if the redis connection should use TLS.
+	TLS bool
+	// Username specifies the username to use for redis auth.
+	Username string
+	// Password specifies the password to use for redis auth.
+	Password string
+	// ConnectionTimeout specifies how long idle redis connections are kept. Must be longer than the PopTimeout.
+	ConnectionTimeout time.Duration
+	// Streams makes the agent use a redis stream for requests, and a list per request for responses.
+	Streams bool
+	// ReliableQueue makes the agent move requests into its own processing list while they're being handled, instead of
+	// popping them off the queue, so that they're requeued if the agent dies. Requires Redis 6.2 or later.
+	ReliableQueue bool
+	// AgentID uniquely identifies this agent instance. It's used for the agent's processing list and heartbeat key, and
+	// as its consumer name with streams. Generated from the hostname and process ID if empty.
+	AgentID string
+	// Keys specifies the redis keys used for requests and responses. Empty keys are left as the default.
+	Keys redisTransport.Keys
+	// PopTimeout specifies how long to block waiting for a request before checking whether the agent has been stopped.
+	PopTimeout time.Duration
+}
 
-	_, RedisTLS = os.LookupEnv("REDIS_TLS")
-	if !RedisTLS {
-		log.Println("Warning: TLS not enabled. Remember to configure and use TLS for any production deployments!")
+// Default returns the default config. The Redis Host must still be set, unless a Transport is provided.
+func Default() (c Config) {
+	return Config{
+		Redis: RedisConfig{
+			ConnectionTimeout: DefaultConnectionTimeout,
+			Keys:              redisTransport.DefaultKeys(),
+			PopTimeout:        redisTransport.DefaultPopTimeout,
+		},
+		Threads:              runtime.NumCPU(),
+		PublishAttempts:      DefaultPublishAttempts,
+		ErrorRetryTime:       DefaultErrorRetryTime,
+		MinResponseKeyLength: DefaultMinResponseKeyLength,
+		ShutdownGracePeriod:  DefaultShutdownGracePeriod,
+		TracerProvider:       otel.GetTracerProvider(),
 	}
+}
 
-	RedisUsername = os.Getenv("REDIS_USERNAME")
-	if RedisUsername == "" {
-		log.Println("Warning: Redis authentication not enabled. " +
-			"Remember to configure and use auth for any production deployments!")
+// WithDefaults returns a copy of the config with zero values replaced with the defaults.
+func (c Config) WithDefaults() (withDefaults Config) {
+	defaults := Default()
+	if c.Redis.ConnectionTimeout == 0 {
+		c.Redis.ConnectionTimeout = defaults.Redis.ConnectionTimeout
+	}
+	if c.Redis.PopTimeout == 0 {
+		c.Redis.PopTimeout = defaults.Redis.PopTimeout
 	}
+	if c.Threads == 0 {
+		c.Threads = defaults.Threads
+	}
+	if c.PublishAttempts == 0 {
+		c.PublishAttempts = defaults.PublishAttempts
+	}
+	if c.ErrorRetryTime == 0 {
+		c.ErrorRetryTime = defaults.ErrorRetryTime
+	}
+	if c.MinResponseKeyLength == 0 {
+		c.MinResponseKeyLength = defaults.MinResponseKeyLength
+	}
+	if c.ShutdownGracePeriod == 0 {
+		c.ShutdownGracePeriod = defaults.ShutdownGracePeriod
+	}
+	if c.TracerProvider == nil {
+		c.TracerProvider = defaults.TracerProvider
+	}
+	if len(c.Namespaces) > 0 {
+		// The namespaces are copied, so that the caller's slice isn't modified.
+		c.Namespaces = append([]Namespace(nil), c.Namespaces...)
+		for i := range c.Namespaces {
+			if c.Namespaces[i].Weight == 0 {
+				c.Namespaces[i].Weight = 1
+			}
+		}
+	}
+	return c
+}
 
-	RedisPassword = os.Getenv("REDIS_PASSWORD")
+// Validate returns an error if the config can't be used to run an agent. Every problem is included in the error, which
+// is joined using errors.Join.
+func (c Config) Validate() (err error) {
+	var problems []error
+	if c.usesRedis() {
+		if c.Redis.Host == "" {
+			problems = append(problems, errors.New("either a transport or a redis host must be specified"))
+		}
+		if c.Redis.PopTimeout < time.Second {
+			problems = append(problems, fmt.Errorf("redis pop timeout must be at least 1 second, but is %v",
+				c.Redis.PopTimeout))
+		}
+		if c.Redis.ConnectionTimeout <= c.Redis.PopTimeout {
+			problems = append(problems, fmt.Errorf(
+				"redis connection timeout(%v) must be longer than the pop timeout(%v)", c.Redis.ConnectionTimeout,
+				c.Redis.PopTimeout))
+		}
+	}
+	if c.Threads < 1 {
+		problems = append(problems, fmt.Errorf("threads must be at least 1, but is %d", c.Threads))
+	}
+	if c.PublishAttempts < 1 {
+		problems = append(problems, fmt.Errorf("publish attempts must be at least 1, but is %d", c.PublishAttempts))
+	}
+	if c.ErrorRetryTime < 0 {
+		problems = append(problems, fmt.Errorf("error retry time can't be negative, but is %v", c.ErrorRetryTime))
+	}
+	if c.MinResponseKeyLength < 0 {
+		problems = append(problems, fmt.Errorf("minimum response key length can't be negative, but is %d",
+			c.MinResponseKeyLength))
+	}
+	if c.MetricsAddress != "" && c.Metrics != nil {
+		problems = append(problems,
+			errors.New("metrics address can't be used with metrics that were created separately"))
+	}
+	if c.ShutdownGracePeriod < 0 {
+		problems = append(problems, fmt.Errorf("shutdown grace period can't be negative, but is %v",
+			c.ShutdownGracePeriod))
+	}
+	problems = append(problems, c.validateNamespaces()...)
+	return errors.Join(problems...)
+}
 
-	_, Durable = os.LookupEnv("DURABLE")
+// usesRedis returns whether the agent creates a redis transport, which is the case unless every namespace has a
+// transport.
+func (c Config) usesRedis() bool {
+	if len(c.Namespaces) == 0 {
+		return c.Transport == nil
+	}
+	for _, namespace := range c.Namespaces {
+		if namespace.Transport == nil {
+			return true
+		}
+	}
+	return false
+}
 
-	Threads = runtime.NumCPU()
+func (c Config) validateNamespaces() (problems []error) {
+	if len(c.Namespaces) == 0 {
+		return nil
+	}
+	if c.Transport != nil {
+		problems = append(problems, errors.New("a transport can't be used with namespaces - "+
+			"each namespace's transport should be set instead"))
+	}
+	if c.Redis.Keys != (redisTransport.Keys{}) && c.Redis.Keys != redisTransport.DefaultKeys() {
+		problems = append(problems, errors.New("redis keys can't be changed when namespaces are used"))
+	}
+	names := make(map[string]bool)
+	for _, namespace := range c.Namespaces {
+		if names[namespace.Name] {
+			problems = append(problems, fmt.Errorf("namespace %q is specified more than once", namespace.Name))
+		}
+		names[namespace.Name] = true
+		err := redisTransport.ValidateNamespace(namespace.Name)
+		if err != nil {
+			problems = append(problems, err)
+		}
+		if namespace.Weight < 1 {
+			problems = append(problems, fmt.Errorf("weight of namespace %q must be at least 1, but is %d",
+				namespace.Name, namespace.Weight))
+		}
+	}
+	return problems
 }
@@ -1,3 +1,4 @@
+//go:build release
 // +build release
 
 package config
@@ -5,4 +6,8 @@ package config
 const (
 	VerboseLogging = false
 	UTCLogging     = true
+	// DefaultLogFormat is the log format used if LOG_FORMAT isn't set.
+	DefaultLogFormat = "json"
+	// DefaultLogLevel is the log level used if LOG_LEVEL isn't set.
+	DefaultLogLevel = "info"
 )
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rsheasby/gocrypt/gocrypt/logging"
+	"gopkg.in/yaml.v3"
+)
+
+// readConfigFile reads a YAML config file, and returns the value of each setting by its key, with nested sections
+// separated by dots. Lists are joined with commas, so that they're parsed the same way as environment variables.
+func readConfigFile(path string) (values map[string]string, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read config file: %v", err)
+	}
+	var root yaml.Node
+	err = yaml.Unmarshal(data, &root)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse config file %s: %v", path, err)
+	}
+
+	values = make(map[string]string)
+	// An empty file has no content at all.
+	if len(root.Content) == 0 {
+		return values, nil
+	}
+	if root.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("config file %s should contain a mapping of settings", path)
+	}
+	err = flattenNode("", root.Content[0], values)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	return values, nil
+}
+
+// flattenNode adds the values in the node to values, with the keys of nested mappings joined by dots.
+func flattenNode(key string, node *yaml.Node, values map[string]string) (err error) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			childKey := node.Content[i].Value
+			if key != "" {
+				childKey = key + "." + childKey
+			}
+			err = flattenNode(childKey, node.Content[i+1], values)
+			if err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		items := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return fmt.Errorf("%s should be a list of values", key)
+			}
+			items = append(items, item.Value)
+		}
+		values[key] = strings.Join(items, ",")
+	case yaml.ScalarNode:
+		// Settings without a value are left as they are.
+		if node.Tag != "!!null" {
+			values[key] = node.Value
+		}
+	case yaml.AliasNode:
+		return flattenNode(key, node.Alias, values)
+	}
+	return nil
+}
+
+// printConfig writes the settings of each option in the config file format, with secrets redacted.
+func printConfig(output io.Writer, options []*option) (err error) {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	for _, o := range options {
+		parts := strings.Split(o.key, ".")
+		section := root
+		for _, part := range parts[:len(parts)-1] {
+			section = childSection(section, part)
+		}
+		value := o.value.String()
+		if o.secret && value != "" {
+			value = logging.Redacted
+		}
+		section.Content = append(section.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: parts[len(parts)-1]},
+			&yaml.Node{Kind: yaml.ScalarNode, Value: value},
+		)
+	}
+
+	encoder := yaml.NewEncoder(output)
+	encoder.SetIndent(2)
+	err = encoder.Encode(root)
+	if err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// childSection returns the mapping with the key in the section, adding it if it doesn't exist yet.
+func childSection(section *yaml.Node, key string) (child *yaml.Node) {
+	for i := 0; i+1 < len(section.Content); i += 2 {
+		if section.Content[i].Value == key {
+			return section.Content[i+1]
+		}
+	}
+	child = &yaml.Node{Kind: yaml.MappingNode}
+	section.Content = append(section.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, child)
+	return child
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultHealthcheckTimeout specifies how long the healthcheck subcommand waits for the agent to respond.
+const DefaultHealthcheckTimeout = 5 * time.Second
+
+// healthcheck calls the liveness or readiness check of the agent running on this host, and returns the exit code for
+// Docker's HEALTHCHECK. The address is the agent's health_address setting, which is loaded from the config file, the
+// environment and the flags in the same way as the agent does, so the agent's own config is used. The -address flag
+// overrides it.
+func healthcheck(args []string, lookupEnv func(key string) (string, bool), output io.Writer) (exitCode int) {
+	var address string
+	var ready bool
+	var timeout time.Duration
+	s, problems, err := loadSettingsWithFlags(args, lookupEnv, output, func(flags *flag.FlagSet) {
+		flags.StringVar(&address, "address", "", "address the agent serves its health checks on, overriding "+
+			"health_address (healthcheck only)")
+		flags.BoolVar(&ready, "ready", false, "check /readyz instead of /healthz (healthcheck only)")
+		flags.DurationVar(&timeout, "timeout", DefaultHealthcheckTimeout, "how long to wait for the agent to respond "+
+			"(healthcheck only)")
+	})
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	} else if err != nil {
+		return 2
+	}
+	if address == "" {
+		if len(problems) > 0 {
+			for _, problem := range problems {
+				fmt.Fprintf(output, "Invalid configuration: %v\n", problem)
+			}
+			return 2
+		}
+		address = s.cfg.HealthAddress
+	}
+	if address == "" {
+		fmt.Fprintln(output, "No health address specified. The health_address setting or the -address flag should be "+
+			"set.")
+		return 2
+	}
+
+	url, err := healthcheckURL(address, ready)
+	if err != nil {
+		fmt.Fprintf(output, "Invalid health address %q: %v\n", address, err)
+		return 2
+	}
+	client := &http.Client{
+		Timeout: timeout,
+	}
+	res, err := client.Get(url)
+	if err != nil {
+		fmt.Fprintf(output, "Health check failed: %v\n", err)
+		return 1
+	}
+	defer res.Body.Close()
+
+	body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+	if res.StatusCode != http.StatusOK {
+		fmt.Fprintf(output, "Health check failed with status %d: %s\n", res.StatusCode, strings.TrimSpace(string(body)))
+		return 1
+	}
+	return 0
+}
+
+// healthcheckURL returns the URL of the health check served on the address. Addresses without a host, or with an
+// unspecified host such as "0.0.0.0", are reached through localhost.
+func healthcheckURL(address string, ready bool) (url string, err error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", err
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+
+	path := "/healthz"
+	if ready {
+		path = "/readyz"
+	}
+	return "http://" + net.JoinHostPort(host, port) + path, nil
+}
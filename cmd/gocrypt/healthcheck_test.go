@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthcheckShouldReportAgentHealth(t *testing.T) {
+	ready := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/readyz" && !ready {
+			http.Error(w, "request manager isn't running", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	address := strings.TrimPrefix(server.URL, "http://")
+
+	output := &bytes.Buffer{}
+	assert.Equal(t, 0, healthcheck([]string{"-address", address}, testEnv(nil), output), "Healthy agents should pass")
+	assert.Equal(t, 1, healthcheck([]string{"-address", address, "-ready"}, testEnv(nil), output),
+		"Agents which aren't ready should fail the readiness check")
+	assert.Contains(t, output.String(), "request manager isn't running", "The reason for the failure should be output")
+
+	ready = true
+	assert.Equal(t, 0, healthcheck([]string{"-address", address, "-ready"}, testEnv(nil), output),
+		"Ready agents should pass")
+
+	server.Close()
+	assert.Equal(t, 1, healthcheck([]string{"-address", address}, testEnv(nil), output),
+		"Unreachable agents should fail")
+	assert.Equal(t, 2, healthcheck([]string{"-address", "nonsense"}, testEnv(nil), output),
+		"Invalid addresses should be refused")
+}
+
+func TestHealthcheckShouldUseTheAgentsHealthAddress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	address := strings.TrimPrefix(server.URL, "http://")
+
+	output := &bytes.Buffer{}
+	path := writeConfigFile(t, "health_address: "+address+"\n")
+	assert.Equal(t, 0, healthcheck([]string{"-config", path}, testEnv(nil), output),
+		"The health address should be read from the config file")
+	assert.Equal(t, 0, healthcheck(nil, testEnv(map[string]string{"CONFIG_FILE": path}), output),
+		"The config file should be found using CONFIG_FILE")
+	assert.Equal(t, 0, healthcheck(nil, testEnv(map[string]string{"HEALTH_ADDRESS": address}), output),
+		"The health address should be read from the environment")
+	assert.Equal(t, 1, healthcheck([]string{"-config", path, "-address", "localhost:1"}, testEnv(nil), output),
+		"The -address flag should override the health address")
+
+	assert.Equal(t, 2, healthcheck(nil, testEnv(nil), output), "A missing health address should be refused")
+	assert.Equal(t, 2, healthcheck(nil, testEnv(map[string]string{"THREADS": "lots"}), output),
+		"Invalid settings should be refused when the health address comes from them")
+}
+
+func TestHealthcheckURLShouldUseLocalhostForUnspecifiedHosts(t *testing.T) {
+	url, _ := healthcheckURL(":8080", false)
+	assert.Equal(t, "http://localhost:8080/healthz", url, "Addresses without a host should use localhost")
+
+	url, _ = healthcheckURL("0.0.0.0:8080", true)
+	assert.Equal(t, "http://localhost:8080/readyz", url, "Unspecified hosts should use localhost")
+
+	url, _ = healthcheckURL("10.0.0.1:8080", false)
+	assert.Equal(t, "http://10.0.0.1:8080/healthz", url, "Specified hosts should be kept")
+}
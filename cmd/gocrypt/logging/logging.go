@@ -0,0 +1,161 @@
+// Package logging creates the agent's structured loggers, and provides the attributes used for the fields which are
+// logged consistently across the agent.
+//
+// Every logger created by New redacts passwords and hashes, even if they're logged by mistake: attributes with
+// sensitive keys, byte slices, and protocol messages are never written as they are.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/rsheasby/gocrypt/hashAlgorithms"
+	"github.com/rsheasby/gocrypt/protocol"
+)
+
+const (
+	// FormatText writes logs as key=value pairs.
+	FormatText = "text"
+	// FormatJSON writes logs as JSON objects, one per line.
+	FormatJSON = "json"
+)
+
+// Keys of the fields which are logged consistently across the agent.
+const (
+	KeyResponseKey = "response_key"
+	KeyRequestType = "request_type"
+	KeyAlgorithm   = "algorithm"
+	KeyCost        = "cost"
+	KeyLateness    = "lateness"
+	KeyAttempt     = "attempt"
+	KeyError       = "error"
+	KeyNamespace   = "namespace"
+)
+
+// Redacted replaces the values of attributes which could contain passwords or hashes.
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys which are always redacted, regardless of their value.
+var sensitiveKeys = map[string]bool{
+	"password":        true,
+	"legacy_password": true,
+	"hash":            true,
+	"new_hash":        true,
+}
+
+// Options configures a logger created by New.
+type Options struct {
+	// Format is either FormatText or FormatJSON. Defaults to FormatText.
+	Format string
+	// Level is the minimum level which is logged.
+	Level slog.Level
+	// AddSource adds the source file and line of each log call.
+	AddSource bool
+	// UTC logs times in UTC instead of the local time zone.
+	UTC bool
+}
+
+// New returns a logger which writes to the output in the specified format. An error is returned if the format isn't
+// supported.
+func New(output io.Writer, opts Options) (logger *slog.Logger, err error) {
+	handlerOpts := &slog.HandlerOptions{
+		AddSource: opts.AddSource,
+		Level:     opts.Level,
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			if opts.UTC && len(groups) == 0 && attr.Key == slog.TimeKey && attr.Value.Kind() == slog.KindTime {
+				attr.Value = slog.TimeValue(attr.Value.Time().UTC())
+			}
+			return redact(attr)
+		},
+	}
+
+	switch strings.ToLower(opts.Format) {
+	case FormatText, "":
+		return slog.New(slog.NewTextHandler(output, handlerOpts)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(output, handlerOpts)), nil
+	default:
+		return nil, fmt.Errorf("log format %q isn't supported - should be either %q or %q", opts.Format, FormatText,
+			FormatJSON)
+	}
+}
+
+// ParseLevel parses a level name, such as "debug", "info", "warn" or "error".
+func ParseLevel(name string) (level slog.Level, err error) {
+	err = level.UnmarshalText([]byte(name))
+	if err != nil {
+		return 0, fmt.Errorf("log level %q isn't supported - should be debug, info, warn or error", name)
+	}
+	return level, nil
+}
+
+// Discard returns a logger which discards everything, for when no logger is provided.
+func Discard() (logger *slog.Logger) {
+	return slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1}))
+}
+
+// redact replaces anything which could contain a password or hash. Protocol messages are replaced with the fields which
+// are safe to log.
+func redact(attr slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, Redacted)
+	}
+	if attr.Value.Kind() != slog.KindAny {
+		return attr
+	}
+	switch value := attr.Value.Any().(type) {
+	case []byte:
+		return slog.String(attr.Key, Redacted)
+	case *protocol.Request:
+		if value == nil {
+			return attr
+		}
+		return slog.Group(attr.Key, ResponseKey(value.ResponseKey), RequestType(value.RequestType))
+	case protocol.Request, protocol.Response, *protocol.Response:
+		return slog.String(attr.Key, Redacted)
+	}
+	return attr
+}
+
+// ResponseKey returns the attribute for a request's response key.
+func ResponseKey(responseKey string) slog.Attr {
+	return slog.String(KeyResponseKey, responseKey)
+}
+
+// RequestType returns the attribute for a request's type.
+func RequestType(requestType protocol.Request_RequestType) slog.Attr {
+	return slog.String(KeyRequestType, requestType.String())
+}
+
+// Algorithm returns the attribute for the hashing algorithm.
+func Algorithm(params hashAlgorithms.Params) slog.Attr {
+	return slog.String(KeyAlgorithm, params.Algorithm.String())
+}
+
+// Cost returns the attribute for the parameters which apply to the hashing algorithm.
+func Cost(params hashAlgorithms.Params) slog.Attr {
+	return slog.String(KeyCost, params.CostString())
+}
+
+// Lateness returns the attribute for how late an expired request was when it was received.
+func Lateness(lateness time.Duration) slog.Attr {
+	return slog.Duration(KeyLateness, lateness)
+}
+
+// Attempt returns the attribute for the attempt number of something which is retried.
+func Attempt(attempt int) slog.Attr {
+	return slog.Int(KeyAttempt, attempt)
+}
+
+// Namespace returns the attribute for the namespace which a request was received from.
+func Namespace(name string) slog.Attr {
+	return slog.String(KeyNamespace, name)
+}
+
+// Err returns the attribute for an error.
+func Err(err error) slog.Attr {
+	return slog.Any(KeyError, err)
+}
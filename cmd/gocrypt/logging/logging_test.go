@@ -0,0 +1,94 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rsheasby/gocrypt/hashAlgorithms"
+	"github.com/rsheasby/gocrypt/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewShouldSupportFormats(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	logger, err := New(buffer, Options{Format: FormatJSON, UTC: true})
+	assert.Nil(t, err, "JSON should be supported")
+	logger.Info("Test message.", ResponseKey("key"), Attempt(2), Lateness(1500*time.Millisecond),
+		Cost(hashAlgorithms.Params{Algorithm: hashAlgorithms.Bcrypt, Cost: 12}))
+
+	entry := make(map[string]interface{})
+	assert.Nil(t, json.Unmarshal(buffer.Bytes(), &entry), "Each entry should be a JSON object")
+	assert.Equal(t, "Test message.", entry[slog.MessageKey], "The message should be logged")
+	assert.Equal(t, "key", entry[KeyResponseKey], "The response key should be logged")
+	assert.Equal(t, float64(2), entry[KeyAttempt], "The attempt should be logged")
+	assert.Equal(t, float64(1500*time.Millisecond), entry[KeyLateness], "The lateness should be logged")
+	assert.Equal(t, "12", entry[KeyCost], "The cost should be logged")
+	assert.True(t, strings.HasSuffix(entry[slog.TimeKey].(string), "Z"), "The time should be logged in UTC")
+
+	buffer.Reset()
+	logger, err = New(buffer, Options{Format: "TEXT", Level: slog.LevelWarn})
+	assert.Nil(t, err, "Text should be supported, regardless of case")
+	logger.Info("Hidden message.")
+	logger.Warn("Shown message.", RequestType(protocol.Request_VERIFYPASSWORD))
+	assert.NotContains(t, buffer.String(), "Hidden message.", "Messages below the level shouldn't be logged")
+	assert.Contains(t, buffer.String(), "request_type=VERIFYPASSWORD", "Messages should be logged as key=value pairs")
+
+	_, err = New(buffer, Options{Format: "xml"})
+	assert.NotNil(t, err, "Unknown formats should be refused")
+}
+
+func TestParseLevelShouldParseLevelNames(t *testing.T) {
+	level, err := ParseLevel("debug")
+	assert.Nil(t, err, "debug should be a valid level")
+	assert.Equal(t, slog.LevelDebug, level, "debug should parse to the debug level")
+
+	level, _ = ParseLevel("WARN")
+	assert.Equal(t, slog.LevelWarn, level, "Levels should parse regardless of case")
+
+	_, err = ParseLevel("loud")
+	assert.NotNil(t, err, "Unknown levels should be refused")
+}
+
+func TestLoggersShouldRedactPasswordsAndHashes(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	logger, _ := New(buffer, Options{Format: FormatJSON})
+
+	req := &protocol.Request{
+		RequestType:    protocol.Request_VERIFYPASSWORD,
+		ResponseKey:    "response-key",
+		Password:       []byte("sha512-of-password"),
+		Hash:           "$2a$10$somehash",
+		LegacyPassword: []byte("hunter2"),
+	}
+	logger.Info("Everything that shouldn't be logged.",
+		slog.String("password", "hunter2"),
+		slog.String("Hash", "$2a$10$somehash"),
+		slog.Any("bytes", []byte("hunter2")),
+		slog.Any("request", req),
+		slog.Any("response", &protocol.Response{Hash: "$2a$10$somehash"}),
+		slog.Group("nested", slog.String("legacy_password", "hunter2")),
+	)
+
+	output := buffer.String()
+	for _, secret := range []string{"hunter2", "sha512-of-password", "$2a$10$somehash"} {
+		assert.NotContains(t, output, secret, "Secrets should never be logged")
+	}
+	assert.Contains(t, output, Redacted, "Redacted values should be marked as such")
+	assert.Contains(t, output, `"request":{"response_key":"response-key","request_type":"VERIFYPASSWORD"}`,
+		"Requests should be logged with the fields which are safe to log")
+
+	buffer.Reset()
+	logger.Warn("Errors should still be logged.", Err(errors.New("connection refused")))
+	assert.Contains(t, buffer.String(), "connection refused", "Errors should be logged")
+}
+
+func TestDiscardShouldLogNothing(t *testing.T) {
+	logger := Discard()
+	assert.False(t, logger.Enabled(context.Background(), slog.LevelError), "Nothing should be enabled")
+}
@@ -2,53 +2,96 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"flag"
+	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 
-	"github.com/gomodule/redigo/redis"
-	"github.com/rsheasby/gocrypt/gocrypt/config"
-	"github.com/rsheasby/gocrypt/gocrypt/requestManager"
-	"github.com/rsheasby/gocrypt/gocrypt/requestWorker"
+	"github.com/joho/godotenv"
+	"github.com/rsheasby/gocrypt/gocrypt/agent"
+	"github.com/rsheasby/gocrypt/gocrypt/logging"
 )
 
 func main() {
-	// Read config from env vars
-	config.ReadEnvironment()
-
-	// Setup logger
-	logOptions := log.Ldate | log.Ltime | log.Lmicroseconds
-	if config.VerboseLogging {
-		logOptions |= log.Llongfile
-	}
-	if config.UTCLogging {
-		logOptions |= log.LUTC
-	}
-	logger := log.New(os.Stderr, "gocrypt:", logOptions)
-
-	// Setup redis pool
-	pool := &redis.Pool{
-		MaxIdle:     config.Threads + 1,
-		IdleTimeout: config.ConnectionTimeout,
-		Dial: func() (redis.Conn, error) {
-			return redis.Dial("tcp",
-				config.RedisHost,
-				redis.DialUseTLS(config.RedisTLS),
-				redis.DialUsername(config.RedisUsername),
-				redis.DialPassword(config.RedisPassword),
-			)
-		},
-	}
-
-	// Open request manager. This exits the program if it's unable to connect to redis, unless Durable mode is enabled.
-	requestChan, err := requestManager.Start(context.Background(), pool, logger)
+	if len(os.Args) > 1 && os.Args[1] == "healthcheck" {
+		_ = godotenv.Load("gocrypt.env")
+		os.Exit(healthcheck(os.Args[2:], os.LookupEnv, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "agents" {
+		_ = godotenv.Load("gocrypt.env")
+		os.Exit(listAgents(os.Args[2:], os.LookupEnv, os.Stdout))
+	}
+
+	// The env file is loaded before anything else, so that its variables are treated as part of the environment.
+	envFileErr := godotenv.Load("gocrypt.env")
+	s, problems, err := loadSettings(os.Args[1:], os.LookupEnv, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	} else if err != nil {
+		os.Exit(2)
+	}
+
+	logger, err := s.newLogger(os.Stderr)
+	if err != nil {
+		problems = append(problems, err)
+		logger = slog.Default()
+	}
+	// Anything logged using the standard library logger goes through the same handler.
+	slog.SetDefault(logger)
+	if envFileErr != nil {
+		logger.Info("Failed to read gocrypt.env. Falling back to environment variables.")
+	}
+
+	cfg, configProblems := s.agentConfig()
+	problems = append(problems, configProblems...)
+	if s.printConfig {
+		err = printConfig(os.Stdout, s.options())
+		if err != nil {
+			problems = append(problems, err)
+		}
+	}
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			logger.Error("Invalid configuration.", logging.Err(problem))
+		}
+		os.Exit(1)
+	}
+	if s.printConfig {
+		os.Exit(0)
+	}
+	warnInsecure(cfg, logger)
+	cfg.Logger = logger
+
+	stopTracing := startTracing(&cfg, logger)
+
+	a, err := agent.New(cfg)
 	if err != nil {
-		logger.Fatalf("Couldn't start up request manager: %v", err)
+		fatal(logger, "Couldn't start gocrypt agent.", logging.Err(err))
 	}
-	logger.Printf("gocrypt agent started, and Redis connection successfully opened to %s.", config.RedisHost)
 
-	// Open request workers.
-	requestWorker.StartMany(context.Background(), requestChan, pool, config.Threads, logger)
+	// Stop gracefully on SIGINT or SIGTERM, so that requests which have already been received aren't lost.
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		logger.Info("Received signal.", slog.String("signal", sig.String()))
+		cancel()
+	}()
+
+	// Run the request manager and workers until a signal is received. This exits the program if it's unable to connect
+	// to redis, unless Durable mode is enabled.
+	err = a.Run(ctx)
+	stopTracing()
+	if err != nil {
+		fatal(logger, "gocrypt agent failed.", logging.Err(err))
+	}
+}
 
-	// Let them do their work. If there's a fatal error, they will terminate the process.
-	select {}
+// fatal logs the message at the error level, and exits the process.
+func fatal(logger *slog.Logger, msg string, args ...any) {
+	logger.Error(msg, args...)
+	os.Exit(1)
 }
@@ -0,0 +1,227 @@
+// Package metrics records the agent's Prometheus metrics. All of the methods do nothing if the Metrics is nil, so that
+// the rest of the agent doesn't need to check whether metrics are enabled.
+package metrics
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rsheasby/gocrypt/hashAlgorithms"
+	"github.com/rsheasby/gocrypt/protocol"
+)
+
+// Namespace is prepended to the name of every metric.
+const Namespace = "gocrypt"
+
+// Outcome specifies how a request was handled.
+type Outcome string
+
+const (
+	// OutcomeSuccess means that the request was handled, and the result was published.
+	OutcomeSuccess Outcome = "success"
+	// OutcomeInvalidHash means that the hash in the request couldn't be parsed.
+	OutcomeInvalidHash Outcome = "invalid_hash"
+	// OutcomeInvalidRequest means that the request was rejected before it was handled.
+	OutcomeInvalidRequest Outcome = "invalid_request"
+	// OutcomeExpired means that the request had already expired when it was received.
+	OutcomeExpired Outcome = "expired"
+)
+
+// costLegacy is the algorithm and cost label of legacy hashes, whose parameters aren't parsed.
+const costLegacy = "legacy"
+
+// unknownRequestType is the type label of requests whose type isn't known.
+const unknownRequestType = "unknown"
+
+// QueueDepther is implemented by transports which can report how many requests are waiting to be received.
+type QueueDepther interface {
+	QueueDepth() (depth int64, err error)
+}
+
+// Metrics holds the agent's metrics.
+type Metrics struct {
+	requests             *prometheus.CounterVec
+	hashDuration         *prometheus.HistogramVec
+	verifyDuration       *prometheus.HistogramVec
+	expiredLateness      prometheus.Histogram
+	publishRetries       prometheus.Counter
+	undeliveredPublishes prometheus.Counter
+	busyWorkers          prometheus.Gauge
+	idleWorkers          prometheus.Gauge
+}
+
+// New creates the metrics and registers them with the registerer. If queue isn't nil, the queue depth is also reported
+// using it whenever the metrics are collected.
+func New(registerer prometheus.Registerer, queue QueueDepther) (m *Metrics, err error) {
+	// Hashes take anywhere from a millisecond at the lowest costs to several seconds at the highest.
+	durationBuckets := prometheus.ExponentialBuckets(0.001, 2, 14)
+	m = &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "requests_total",
+			Help:      "Requests received by the agent, by request type and outcome.",
+		}, []string{"type", "outcome"}),
+		hashDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "hash_duration_seconds",
+			Help:      "Time taken to hash passwords, by algorithm and cost.",
+			Buckets:   durationBuckets,
+		}, []string{"algorithm", "cost"}),
+		verifyDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "verify_duration_seconds",
+			Help:      "Time taken to verify passwords, by the algorithm and cost of the hash.",
+			Buckets:   durationBuckets,
+		}, []string{"algorithm", "cost"}),
+		expiredLateness: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "expired_request_lateness_seconds",
+			Help:      "How long requests had already been expired for when they were received.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 14),
+		}),
+		publishRetries: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "publish_retries_total",
+			Help:      "Responses which were published again after a failed attempt.",
+		}),
+		undeliveredPublishes: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "publish_undelivered_total",
+			Help:      "Response publishes which weren't received by any client.",
+		}),
+		busyWorkers: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "workers_busy",
+			Help:      "Worker threads which are handling a request.",
+		}),
+		idleWorkers: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "workers_idle",
+			Help:      "Worker threads which are waiting for a request.",
+		}),
+	}
+
+	collectors := []prometheus.Collector{m.requests, m.hashDuration, m.verifyDuration, m.expiredLateness,
+		m.publishRetries, m.undeliveredPublishes, m.busyWorkers, m.idleWorkers}
+	if queue != nil {
+		collectors = append(collectors, newQueueDepthCollector(queue))
+	}
+	for _, collector := range collectors {
+		err = registerer.Register(collector)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't register metrics: %w", err)
+		}
+	}
+	return m, nil
+}
+
+// RequestHandled counts a request with the specified outcome.
+func (m *Metrics) RequestHandled(requestType protocol.Request_RequestType, outcome Outcome) {
+	if m == nil {
+		return
+	}
+	m.requests.WithLabelValues(requestTypeLabel(requestType), string(outcome)).Inc()
+}
+
+// requestTypeLabel returns the request type in lower case, or "unknown" if it isn't known, so that clients can't create
+// any number of series by sending invalid types.
+func requestTypeLabel(requestType protocol.Request_RequestType) (label string) {
+	if _, ok := protocol.Request_RequestType_name[int32(requestType)]; !ok {
+		return unknownRequestType
+	}
+	return strings.ToLower(requestType.String())
+}
+
+// ObserveHash records how long it took to hash a password with the specified params, labelled with their cost.
+func (m *Metrics) ObserveHash(params hashAlgorithms.Params, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.hashDuration.WithLabelValues(params.Algorithm.String(), costLabel(params)).Observe(duration.Seconds())
+}
+
+// ObserveVerify records how long it took to verify a password against the hash. The algorithm and cost are
+// parsed from the hash, and are "legacy" for hashes which can't be parsed.
+func (m *Metrics) ObserveVerify(hash string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	algorithm, cost := costLegacy, costLegacy
+	params, err := hashAlgorithms.ParseParams(hash)
+	if err == nil {
+		algorithm, cost = params.Algorithm.String(), costLabel(params)
+	}
+	m.verifyDuration.WithLabelValues(algorithm, cost).Observe(duration.Seconds())
+}
+
+// costLabel returns the cost of the params. It's the cost for bcrypt, ln for scrypt, and the base 2 logarithm of the
+// memory in KiB times the iterations, rounded down, for Argon2id. The params are always validated before hashing, and
+// when parsing hashes, so each algorithm only has a few dozen possible labels.
+func costLabel(params hashAlgorithms.Params) (label string) {
+	if params.Algorithm == hashAlgorithms.Argon2id {
+		return strconv.Itoa(bits.Len64(uint64(params.Memory)*uint64(params.Iterations)) - 1)
+	}
+	return strconv.Itoa(params.Cost)
+}
+
+// RequestExpired records how long the request had already been expired for when it was received.
+func (m *Metrics) RequestExpired(lateness time.Duration) {
+	if m == nil {
+		return
+	}
+	m.expiredLateness.Observe(lateness.Seconds())
+}
+
+// PublishRetried counts a response publish which is being retried.
+func (m *Metrics) PublishRetried() {
+	if m == nil {
+		return
+	}
+	m.publishRetries.Inc()
+}
+
+// PublishUndelivered counts a response publish which wasn't received by any client.
+func (m *Metrics) PublishUndelivered() {
+	if m == nil {
+		return
+	}
+	m.undeliveredPublishes.Inc()
+}
+
+// WorkerStarted counts a new idle worker.
+func (m *Metrics) WorkerStarted() {
+	if m == nil {
+		return
+	}
+	m.idleWorkers.Inc()
+}
+
+// WorkerStopped removes an idle worker which has stopped.
+func (m *Metrics) WorkerStopped() {
+	if m == nil {
+		return
+	}
+	m.idleWorkers.Dec()
+}
+
+// WorkerBusy moves a worker from idle to busy while it handles a request.
+func (m *Metrics) WorkerBusy() {
+	if m == nil {
+		return
+	}
+	m.idleWorkers.Dec()
+	m.busyWorkers.Inc()
+}
+
+// WorkerIdle moves a worker from busy back to idle once it's handled a request.
+func (m *Metrics) WorkerIdle() {
+	if m == nil {
+		return
+	}
+	m.busyWorkers.Dec()
+	m.idleWorkers.Inc()
+}
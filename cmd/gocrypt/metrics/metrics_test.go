@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rsheasby/gocrypt/hashAlgorithms"
+	"github.com/rsheasby/gocrypt/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeQueue int64
+
+func (q fakeQueue) QueueDepth() (depth int64, err error) {
+	return int64(q), nil
+}
+
+func TestMetricsShouldBeRecorded(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m, err := New(registry, fakeQueue(7))
+	assert.Nil(t, err, "No error should be returned when creating the metrics")
+
+	m.RequestHandled(protocol.Request_HASHPASSWORD, OutcomeSuccess)
+	m.RequestHandled(protocol.Request_HASHPASSWORD, OutcomeSuccess)
+	m.RequestHandled(protocol.Request_VERIFYPASSWORD, OutcomeInvalidHash)
+	m.RequestHandled(7, OutcomeInvalidRequest)
+	m.RequestHandled(8, OutcomeInvalidRequest)
+	m.PublishRetried()
+	m.PublishUndelivered()
+	m.WorkerStarted()
+	m.WorkerStarted()
+	m.WorkerBusy()
+
+	expected := `
+# HELP gocrypt_publish_retries_total Responses which were published again after a failed attempt.
+# TYPE gocrypt_publish_retries_total counter
+gocrypt_publish_retries_total 1
+# HELP gocrypt_publish_undelivered_total Response publishes which weren't received by any client.
+# TYPE gocrypt_publish_undelivered_total counter
+gocrypt_publish_undelivered_total 1
+# HELP gocrypt_queue_depth Requests waiting to be received by an agent.
+# TYPE gocrypt_queue_depth gauge
+gocrypt_queue_depth 7
+# HELP gocrypt_requests_total Requests received by the agent, by request type and outcome.
+# TYPE gocrypt_requests_total counter
+gocrypt_requests_total{outcome="invalid_hash",type="verifypassword"} 1
+gocrypt_requests_total{outcome="invalid_request",type="unknown"} 2
+gocrypt_requests_total{outcome="success",type="hashpassword"} 2
+# HELP gocrypt_workers_busy Worker threads which are handling a request.
+# TYPE gocrypt_workers_busy gauge
+gocrypt_workers_busy 1
+# HELP gocrypt_workers_idle Worker threads which are waiting for a request.
+# TYPE gocrypt_workers_idle gauge
+gocrypt_workers_idle 1
+`
+	err = testutil.GatherAndCompare(registry, strings.NewReader(expected), "gocrypt_publish_retries_total",
+		"gocrypt_publish_undelivered_total", "gocrypt_queue_depth", "gocrypt_requests_total", "gocrypt_workers_busy",
+		"gocrypt_workers_idle")
+	assert.Nil(t, err, "Gathered metrics should match the recorded values")
+}
+
+func TestMetricsShouldLabelDurationsByCost(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m, _ := New(registry, nil)
+
+	m.ObserveHash(hashAlgorithms.Params{Algorithm: hashAlgorithms.Bcrypt, Cost: 10}, time.Millisecond)
+	m.ObserveHash(hashAlgorithms.Params{Algorithm: hashAlgorithms.Bcrypt, Cost: 11}, time.Millisecond)
+	m.ObserveHash(hashAlgorithms.Params{Algorithm: hashAlgorithms.Argon2id, Memory: 64, Iterations: 1,
+		Parallelism: 2}, time.Millisecond)
+	m.ObserveVerify("$2y$12$z3QlTH2S0HFcX0bXY6B.8OQ.jj4mbdYPho4PnhEgM0qk2kbFNnw92", time.Millisecond)
+	m.ObserveVerify("not a hash", time.Millisecond)
+	m.RequestExpired(time.Second)
+
+	assert.ElementsMatch(t, []string{"bcrypt/10", "bcrypt/11", "argon2id/6"},
+		gatheredLabels(registry, "gocrypt_hash_duration_seconds"),
+		"Hashes should be labelled by algorithm and cost")
+	assert.ElementsMatch(t, []string{"bcrypt/12", "legacy/legacy"},
+		gatheredLabels(registry, "gocrypt_verify_duration_seconds"),
+		"Verifies should be labelled by the algorithm and cost of the hash, or as legacy if it can't be parsed")
+	assert.Equal(t, 1, testutil.CollectAndCount(m.expiredLateness), "Expired requests should be recorded")
+
+	_, err := New(registry, nil)
+	assert.NotNil(t, err, "Registering the metrics twice should fail")
+}
+
+func TestCostLabelShouldBeBoundedByTheParams(t *testing.T) {
+	labels := map[string]hashAlgorithms.Params{
+		"31": {Algorithm: hashAlgorithms.Bcrypt, Cost: 31},
+		"22": {Algorithm: hashAlgorithms.Scrypt, Cost: hashAlgorithms.MaxScryptCost, Parallelism: 4},
+		"17": {Algorithm: hashAlgorithms.Argon2id, Memory: 64 * 1024, Iterations: 3, Parallelism: 4},
+		"32": {Algorithm: hashAlgorithms.Argon2id, Memory: hashAlgorithms.MaxArgon2idMemory,
+			Iterations: hashAlgorithms.MaxArgon2idIterations, Parallelism: 1},
+	}
+	for label, params := range labels {
+		assert.Equal(t, label, costLabel(params), "%s %s should be labelled %s", params.Algorithm,
+			params.CostString(), label)
+	}
+}
+
+func TestNilMetricsShouldDoNothing(t *testing.T) {
+	var m *Metrics
+	assert.NotPanics(t, func() {
+		m.RequestHandled(protocol.Request_HASHPASSWORD, OutcomeSuccess)
+		m.ObserveHash(hashAlgorithms.Params{}, time.Millisecond)
+		m.ObserveVerify("", time.Millisecond)
+		m.RequestExpired(time.Second)
+		m.PublishRetried()
+		m.PublishUndelivered()
+		m.WorkerStarted()
+		m.WorkerBusy()
+		m.WorkerIdle()
+		m.WorkerStopped()
+	}, "Methods on nil metrics shouldn't panic")
+}
+
+// gatheredLabels returns the algorithm and cost labels of each series in the metric, in "algorithm/cost" format.
+func gatheredLabels(registry *prometheus.Registry, name string) (labels []string) {
+	families, _ := registry.Gather()
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			values := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				values[label.GetName()] = label.GetValue()
+			}
+			labels = append(labels, values["algorithm"]+"/"+values["cost"])
+		}
+	}
+	return labels
+}
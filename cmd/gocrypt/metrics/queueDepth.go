@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// queueDepthCollector asks the transport for the queue depth whenever the metrics are collected, so that it's always
+// up to date, even if the agent isn't receiving requests.
+type queueDepthCollector struct {
+	queue QueueDepther
+	desc  *prometheus.Desc
+}
+
+func newQueueDepthCollector(queue QueueDepther) (c *queueDepthCollector) {
+	return &queueDepthCollector{
+		queue: queue,
+		desc: prometheus.NewDesc(prometheus.BuildFQName(Namespace, "", "queue_depth"),
+			"Requests waiting to be received by an agent.", nil, nil),
+	}
+}
+
+// Describe sends the description of the queue depth metric.
+func (c *queueDepthCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- c.desc
+}
+
+// Collect sends the current queue depth. The scrape reports an error if the queue depth can't be retrieved.
+func (c *queueDepthCollector) Collect(metrics chan<- prometheus.Metric) {
+	depth, err := c.queue.QueueDepth()
+	if err != nil {
+		metrics <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+	metrics <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(depth))
+}
@@ -0,0 +1,91 @@
+package requestManager
+
+import (
+	"math/rand"
+	"reflect"
+
+	"github.com/rsheasby/gocrypt/gocrypt/transportHelpers"
+)
+
+// Source is a channel of requests from a request manager, along with its share of the requests passed on by Merge. The
+// weight must be at least 1.
+type Source struct {
+	Requests chan *transportHelpers.ReceivedRequest
+	Weight   int
+}
+
+// Merge passes the requests from each of the sources on to the result channel, so that the workers can be shared by
+// several request managers. While several sources have requests waiting, each source's share of the requests passed on
+// is proportional to its weight. A source with a low weight isn't held back while the other sources have nothing
+// waiting.
+// The result channel is closed once every source has been closed, so requests still waiting when the request managers
+// stop are passed on as well, and can be requeued.
+func Merge(sources []Source) (results chan *transportHelpers.ReceivedRequest) {
+	results = make(chan *transportHelpers.ReceivedRequest)
+	go func() {
+		defer close(results)
+		open := append([]Source(nil), sources...)
+		for len(open) > 0 {
+			i, req, ok := receiveWeighted(open)
+			if !ok {
+				open = append(open[:i], open[i+1:]...)
+				continue
+			}
+			results <- req
+		}
+	}()
+	return results
+}
+
+// receiveWeighted receives the next request from the sources. The sources which already have a request waiting are
+// picked from at random according to their weights, otherwise this blocks until any of them does. ok is false if the
+// source at index i has been closed.
+func receiveWeighted(sources []Source) (i int, req *transportHelpers.ReceivedRequest, ok bool) {
+	for _, i = range weightedOrder(sources) {
+		select {
+		case req, ok = <-sources[i].Requests:
+			return i, req, ok
+		default:
+		}
+	}
+
+	cases := make([]reflect.SelectCase, len(sources))
+	for i, source := range sources {
+		cases[i] = reflect.SelectCase{
+			Dir:  reflect.SelectRecv,
+			Chan: reflect.ValueOf(source.Requests),
+		}
+	}
+	i, value, ok := reflect.Select(cases)
+	if !ok {
+		return i, nil, false
+	}
+	return i, value.Interface().(*transportHelpers.ReceivedRequest), true
+}
+
+// weightedOrder returns the indexes of the sources in a random order, where each source is more likely to come before
+// the others the higher its weight is. Each source comes first in a share of the orders proportional to its weight.
+func weightedOrder(sources []Source) (order []int) {
+	remaining := make([]int, len(sources))
+	total := 0
+	for i, source := range sources {
+		remaining[i] = i
+		total += source.Weight
+	}
+
+	order = make([]int, 0, len(sources))
+	for len(remaining) > 0 {
+		// Weighted scheduling doesn't need to be unpredictable, so a cryptographic source isn't necessary.
+		pick := rand.Intn(total) //nolint:gosec
+		for j, i := range remaining {
+			pick -= sources[i].Weight
+			if pick < 0 {
+				order = append(order, i)
+				total -= sources[i].Weight
+				remaining = append(remaining[:j], remaining[j+1:]...)
+				break
+			}
+		}
+	}
+	return order
+}
@@ -0,0 +1,53 @@
+package requestManager
+
+import (
+	"testing"
+
+	"github.com/rsheasby/gocrypt/gocrypt/transportHelpers"
+	"github.com/rsheasby/gocrypt/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+// filledSource returns a closed source with the amount of requests waiting.
+func filledSource(responseKey string, weight int, amount int) (source Source) {
+	requests := make(chan *transportHelpers.ReceivedRequest, amount)
+	for i := 0; i < amount; i++ {
+		requests <- &transportHelpers.ReceivedRequest{Request: &protocol.Request{ResponseKey: responseKey}}
+	}
+	close(requests)
+	return Source{Requests: requests, Weight: weight}
+}
+
+func TestMergeShouldShareRequestsByWeight(t *testing.T) {
+	results := Merge([]Source{filledSource("heavy", 3, 4000), filledSource("light", 1, 4000)})
+
+	counts := make(map[string]int)
+	for i := 0; i < 4000; i++ {
+		counts[(<-results).ResponseKey]++
+	}
+	assert.InDelta(t, 3000, counts["heavy"], 200, "Sources should get a share of the requests according to weight")
+	assert.InDelta(t, 1000, counts["light"], 200, "Sources should get a share of the requests according to weight")
+
+	remaining := 0
+	for range results {
+		remaining++
+	}
+	assert.Equal(t, 4000, remaining, "Every request should be passed on")
+}
+
+func TestMergeShouldPassOnEverythingUntilEverySourceIsClosed(t *testing.T) {
+	idle := make(chan *transportHelpers.ReceivedRequest)
+	busy := make(chan *transportHelpers.ReceivedRequest, 3)
+	for i := 0; i < 3; i++ {
+		busy <- &transportHelpers.ReceivedRequest{Request: &protocol.Request{}}
+	}
+	close(busy)
+	results := Merge([]Source{{Requests: idle, Weight: 100}, {Requests: busy, Weight: 1}})
+
+	for i := 0; i < 3; i++ {
+		assert.NotNil(t, <-results, "Low weight sources shouldn't be held back by idle sources")
+	}
+	close(idle)
+	_, ok := <-results
+	assert.False(t, ok, "The results should be closed once every source is closed")
+}
@@ -2,53 +2,85 @@ package requestManager
 
 import (
 	"context"
-	"log"
-	"time"
+	"log/slog"
+	"sync/atomic"
 
 	"github.com/rsheasby/gocrypt/gocrypt/config"
-	"github.com/rsheasby/gocrypt/gocrypt/redisHelpers"
+	"github.com/rsheasby/gocrypt/gocrypt/logging"
+	"github.com/rsheasby/gocrypt/gocrypt/metrics"
+	"github.com/rsheasby/gocrypt/gocrypt/transportHelpers"
 	"github.com/rsheasby/gocrypt/protocol"
+	"github.com/rsheasby/gocrypt/requestHandler"
+	"github.com/rsheasby/gocrypt/transport"
 )
 
-// Start starts the request manager, which pulls requests from redis, validates them, and puts them into the result channel.
-func Start(ctx context.Context, pool redisHelpers.ConnGetter, logger *log.Logger) (results chan *protocol.Request, err error) {
-	results = make(chan *protocol.Request, 1)
+// Start starts the request manager, which pulls requests from the transport, validates them, and puts them into the
+// result channel. Requests which are rejected or expired are acknowledged straight away, and the rest must be
+// acknowledged once they've been handled.
+// Once the context is cancelled, no more requests are received, and the result channel is closed. Requests received
+// while the context is being cancelled are requeued. The returned status reports whether the loop is still running.
+func Start(ctx context.Context, t transport.Transport, cfg *config.Config, logger *slog.Logger) (
+	results chan *transportHelpers.ReceivedRequest, status *Status, err error) {
+	results = make(chan *transportHelpers.ReceivedRequest, 1)
 
-	if !config.Durable {
-		// Test redis connection before going into the request loop
-		conn := pool.Get()
-		defer conn.Close()
-		_, err = conn.Do("PING")
+	if !cfg.Durable {
+		// Test the transport connection before going into the request loop
+		err = t.Ping()
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		_ = conn.Close()
 	}
 
+	status = &Status{
+		running: 1,
+	}
 	go func() {
 		for {
 			if ctx.Err() != nil {
+				atomic.StoreInt32(&status.running, 0)
 				close(results)
 				return
 			}
-			req, err := redisHelpers.GetRequest(ctx, pool, logger)
+			req, err := transportHelpers.GetRequest(ctx, t, cfg, logger)
+			if err != nil {
+				status.backOff(ctx, cfg.ErrorRetryTime)
+				continue
+			}
+			err = requestHandler.ValidateRequest(req.Request, cfg.MinResponseKeyLength)
 			if err != nil {
+				logger.Warn("Invalid request received.", logging.ResponseKey(req.ResponseKey),
+					logging.RequestType(req.RequestType), logging.Err(err))
+				// Errors are only published once, as publishing retries would otherwise hold up the queue.
+				cfg.Metrics.RequestHandled(req.RequestType, metrics.OutcomeInvalidRequest)
+				if len(req.ResponseKey) != 0 {
+					transportHelpers.PublishError(protocol.Response_INVALID_REQUEST, err.Error(), req.Request, 1, t,
+						cfg, logger)
+				}
+				req.Ack(logger)
 				continue
 			}
-			err = validateRequest(req)
+			serverTime, _ := t.ServerTime()
+			lateness, err := requestHandler.CheckExpiry(req.Request, serverTime)
 			if err != nil {
-				logger.Printf("Invalid request received: %v", err)
+				cfg.Metrics.RequestHandled(req.RequestType, metrics.OutcomeExpired)
+				cfg.Metrics.RequestExpired(lateness)
+				logger.Warn("Expired request received.", logging.ResponseKey(req.ResponseKey),
+					logging.RequestType(req.RequestType), logging.Lateness(lateness))
+				transportHelpers.PublishError(protocol.Response_EXPIRED, err.Error(), req.Request, 1, t, cfg, logger)
+				req.Ack(logger)
 				continue
 			}
-			expiryTime := time.Unix(0, req.ExpiryTimestamp)
-			redisTime, _ := redisHelpers.GetRedisTime(pool)
-			lateness := float64(redisTime.UnixNano()-expiryTime.UnixNano()) / 1000000000
-			if lateness > 0 {
-				logger.Printf(`Expired request received with response key "%s". It was %1.3f seconds late.`,
-					req.ResponseKey, lateness)
+			// Once the context is cancelled, the workers may not get to the request before the agent stops, so it's
+			// left for another agent instead.
+			if ctx.Err() != nil {
+				req.Requeue(logger)
 				continue
 			}
-			results <- req
+			select {
+			case results <- req:
+			case <-ctx.Done():
+				req.Requeue(logger)
+			}
 		}
 	}()
 
@@ -4,30 +4,41 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"math"
 	"testing"
 	"time"
 
 	"github.com/gomodule/redigo/redis"
 	"github.com/rsheasby/gocrypt/gocrypt/config"
-	"github.com/rsheasby/gocrypt/gocrypt/redisHelpers"
+	"github.com/rsheasby/gocrypt/gocrypt/transportHelpers"
 	"github.com/rsheasby/gocrypt/protocol"
+	"github.com/rsheasby/gocrypt/transport/redisTransport"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/protobuf/proto"
 )
 
+// popTimeoutSeconds is the BRPOP timeout used with the default config.
+const popTimeoutSeconds = 10
+
+// testConfig returns the default config with a single worker thread.
+func testConfig() (cfg *config.Config) {
+	c := config.Default()
+	c.Threads = 1
+	return &c
+}
+
 func TestRequestManagerShouldTestRedisConnection(t *testing.T) {
 	// PING successful
-	pool := redisHelpers.NewMockPool()
+	pool := transportHelpers.NewMockPool()
 	pingCmd := pool.Conn.Command("PING").Expect("PONG")
 
 	ctx, cancel := context.WithCancel(context.Background())
 
 	logBuffer := &bytes.Buffer{}
-	logger := log.New(logBuffer, "", 0)
+	logger := slog.New(slog.NewTextHandler(logBuffer, nil))
 
-	_, err := Start(ctx, pool, logger)
+	_, _, err := Start(ctx, redisTransport.New(pool), testConfig(), logger)
 
 	assert.Nil(t, err, "Shouldn't return an error when the PING succeeds")
 	assert.True(t, pingCmd.Called, "Redis PING should be called when the request manager starts")
@@ -35,15 +46,15 @@ func TestRequestManagerShouldTestRedisConnection(t *testing.T) {
 	cancel()
 
 	// PING error
-	pool = redisHelpers.NewMockPool()
+	pool = transportHelpers.NewMockPool()
 	pool.Conn.Command("PING").ExpectError(fmt.Errorf("redis connection error"))
 
 	ctx, cancel = context.WithCancel(context.Background())
 
 	logBuffer = &bytes.Buffer{}
-	logger = log.New(logBuffer, "", 0)
+	logger = slog.New(slog.NewTextHandler(logBuffer, nil))
 
-	_, err = Start(ctx, pool, logger)
+	_, _, err = Start(ctx, redisTransport.New(pool), testConfig(), logger)
 
 	assert.Error(t, err, "Should return an error when the command fails.")
 
@@ -51,27 +62,27 @@ func TestRequestManagerShouldTestRedisConnection(t *testing.T) {
 }
 
 func TestRequestManagerShouldRespectContextCancellation(t *testing.T) {
-	pool := redisHelpers.NewMockPool()
+	pool := transportHelpers.NewMockPool()
 	pool.Conn.Command("PING").Expect("PONG")
 
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
 
 	logBuffer := &bytes.Buffer{}
-	logger := log.New(logBuffer, "", 0)
+	logger := slog.New(slog.NewTextHandler(logBuffer, nil))
 
-	results, _ := Start(ctx, pool, logger)
+	results, _, _ := Start(ctx, redisTransport.New(pool), testConfig(), logger)
 
 	select {
 	case _, open := <-results:
 		assert.False(t, open, "Channel should be closed after the context is cancelled.")
-	case <-time.After((config.PopTimeout + 2) * time.Second):
+	case <-time.After(redisTransport.DefaultPopTimeout + 2*time.Second):
 		assert.Fail(t, "Didn't receive a response within a reasonable time.")
 	}
 }
 
 func TestRequestManagerShouldReturnValidRequestsWhileLoggingErrors(t *testing.T) {
-	pool := redisHelpers.NewMockPool()
+	pool := transportHelpers.NewMockPool()
 	pool.Conn.Command("PING").Expect("PONG")
 	pool.Conn.Command("TIME").ExpectSlice(
 		time.Now().Unix(),
@@ -90,7 +101,7 @@ func TestRequestManagerShouldReturnValidRequestsWhileLoggingErrors(t *testing.T)
 	// Confirm that it doesn't break when there's an error in one of the requests.
 	hasReturnedError := false
 	hasTimedout := false
-	pool.Conn.Command("BRPOP", config.RequestQueueKey, config.PopTimeout).Handle(func(args []interface{}) (interface{}, error) {
+	pool.Conn.Command("BRPOP", redisTransport.RequestQueueKey, redisTransport.BulkRequestQueueKey, popTimeoutSeconds).Handle(func(args []interface{}) (interface{}, error) {
 		if !hasReturnedError {
 			hasReturnedError = true
 			return nil, fmt.Errorf("Random error")
@@ -100,7 +111,7 @@ func TestRequestManagerShouldReturnValidRequestsWhileLoggingErrors(t *testing.T)
 			return nil, redis.ErrNil
 		}
 		return []interface{}{
-				[]byte(config.RequestQueueKey),
+				[]byte(redisTransport.RequestQueueKey),
 				reqBytes,
 			},
 			nil
@@ -110,9 +121,9 @@ func TestRequestManagerShouldReturnValidRequestsWhileLoggingErrors(t *testing.T)
 	defer cancel()
 
 	logBuffer := &bytes.Buffer{}
-	logger := log.New(logBuffer, "", 0)
+	logger := slog.New(slog.NewTextHandler(logBuffer, nil))
 
-	results, err := Start(ctx, pool, logger)
+	results, _, err := Start(ctx, redisTransport.New(pool), testConfig(), logger)
 
 	assert.Nil(t, err, "No error should be returned when starting the request manager")
 
@@ -120,14 +131,14 @@ func TestRequestManagerShouldReturnValidRequestsWhileLoggingErrors(t *testing.T)
 	case req2 := <-results:
 		// We need to compare the string values, as it fails otherwise due to the internal state differences.
 		assert.EqualValues(t, req.String(), req2.String(), "Received request should be equal to the submitted request.")
-	case <-time.After((config.PopTimeout + 2) * time.Second):
+	case <-time.After(redisTransport.DefaultPopTimeout + 2*time.Second):
 		assert.Fail(t, "Didn't receive a response within a reasonable time.")
 	}
 	assert.NotZero(t, logBuffer.Len(), "There should be logs confirming the error.")
 }
 
 func TestRequestManagerShouldValidateRequests(t *testing.T) {
-	pool := redisHelpers.NewMockPool()
+	pool := transportHelpers.NewMockPool()
 	pool.Conn.Command("PING").Expect("PONG")
 
 	req := &protocol.Request{
@@ -136,14 +147,14 @@ func TestRequestManagerShouldValidateRequests(t *testing.T) {
 	reqBytes, _ := proto.Marshal(req)
 
 	// Confirm that it doesn't break when there's an error in one of the requests.
-	pool.Conn.Command("BRPOP", config.RequestQueueKey, config.PopTimeout).ExpectSlice([]byte(config.RequestQueueKey), reqBytes)
+	pool.Conn.Command("BRPOP", redisTransport.RequestQueueKey, redisTransport.BulkRequestQueueKey, popTimeoutSeconds).ExpectSlice([]byte(redisTransport.RequestQueueKey), reqBytes)
 
 	ctx, cancel := context.WithCancel(context.Background())
 
 	logBuffer := &bytes.Buffer{}
-	logger := log.New(logBuffer, "", 0)
+	logger := slog.New(slog.NewTextHandler(logBuffer, nil))
 
-	results, err := Start(ctx, pool, logger)
+	results, _, err := Start(ctx, redisTransport.New(pool), testConfig(), logger)
 
 	assert.Nil(t, err, "No error should be returned when starting the request manager")
 
@@ -154,7 +165,7 @@ func TestRequestManagerShouldValidateRequests(t *testing.T) {
 	select {
 	case _, ok := <-results:
 		assert.False(t, ok, "No message should be published since the request is invalid.")
-	case <-time.After((config.PopTimeout + 2) * time.Second):
+	case <-time.After(redisTransport.DefaultPopTimeout + 2*time.Second):
 		assert.Fail(t, "Didn't receive a response within a reasonable time.")
 	}
 
@@ -162,7 +173,7 @@ func TestRequestManagerShouldValidateRequests(t *testing.T) {
 }
 
 func TestRequestManagerShouldCheckExpiryTime(t *testing.T) {
-	pool := redisHelpers.NewMockPool()
+	pool := transportHelpers.NewMockPool()
 	pool.Conn.Command("PING").Expect("PONG")
 	pool.Conn.Command("TIME").ExpectSlice(int64(123), int64(0))
 
@@ -177,14 +188,14 @@ func TestRequestManagerShouldCheckExpiryTime(t *testing.T) {
 	reqBytes, _ := proto.Marshal(req)
 
 	// Confirm that it doesn't break when there's an error in one of the requests.
-	pool.Conn.Command("BRPOP", config.RequestQueueKey, config.PopTimeout).ExpectSlice([]byte(config.RequestQueueKey), reqBytes)
+	pool.Conn.Command("BRPOP", redisTransport.RequestQueueKey, redisTransport.BulkRequestQueueKey, popTimeoutSeconds).ExpectSlice([]byte(redisTransport.RequestQueueKey), reqBytes)
 
 	ctx, cancel := context.WithCancel(context.Background())
 
 	logBuffer := &bytes.Buffer{}
-	logger := log.New(logBuffer, "", 0)
+	logger := slog.New(slog.NewTextHandler(logBuffer, nil))
 
-	results, err := Start(ctx, pool, logger)
+	results, _, err := Start(ctx, redisTransport.New(pool), testConfig(), logger)
 
 	assert.Nil(t, err, "No error should be returned when starting the request manager")
 
@@ -195,9 +206,151 @@ func TestRequestManagerShouldCheckExpiryTime(t *testing.T) {
 	select {
 	case _, ok := <-results:
 		assert.False(t, ok, "No message should be published since the request is invalid.")
-	case <-time.After((config.PopTimeout + 2) * time.Second):
+	case <-time.After(redisTransport.DefaultPopTimeout + 2*time.Second):
 		assert.Fail(t, "Didn't receive a response within a reasonable time.")
 	}
 
 	assert.NotZero(t, logBuffer.Len(), "Should log when a request was received too late.")
 }
+
+func TestRequestManagerShouldPublishErrorsForRejectedRequests(t *testing.T) {
+	pool := transportHelpers.NewMockPool()
+	pool.Conn.Command("PING").Expect("PONG")
+	pool.Conn.Command("TIME").ExpectSlice(int64(123), int64(0))
+
+	invalidReq := &protocol.Request{
+		RequestType:     protocol.Request_HASHPASSWORD,
+		ResponseKey:     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		Cost:            10,
+		ExpiryTimestamp: math.MaxInt64,
+	}
+	invalidReqBytes, _ := proto.Marshal(invalidReq)
+
+	// Request should be 23 seconds late
+	expiredReq := &protocol.Request{
+		RequestType:     protocol.Request_HASHPASSWORD,
+		ResponseKey:     "ZYXWVUTSRQPONMLKJIHGFEDCBA",
+		Password:        []byte("abc"),
+		Cost:            10,
+		ExpiryTimestamp: 100,
+	}
+	expiredReqBytes, _ := proto.Marshal(expiredReq)
+
+	hasReturnedInvalid := false
+	pool.Conn.Command("BRPOP", redisTransport.RequestQueueKey, redisTransport.BulkRequestQueueKey, popTimeoutSeconds).Handle(func(args []interface{}) (interface{}, error) {
+		if !hasReturnedInvalid {
+			hasReturnedInvalid = true
+			return []interface{}{[]byte(redisTransport.RequestQueueKey), invalidReqBytes}, nil
+		}
+		return []interface{}{[]byte(redisTransport.RequestQueueKey), expiredReqBytes}, nil
+	})
+
+	type publishedResponse struct {
+		key string
+		res *protocol.Response
+	}
+	published := make(chan publishedResponse, 10)
+	pool.Conn.GenericCommand("PUBLISH").Handle(func(args []interface{}) (interface{}, error) {
+		res := &protocol.Response{}
+		assert.Nil(t, proto.Unmarshal(args[1].([]byte), res), "Unmarshalling of response should succeed")
+		select {
+		case published <- publishedResponse{key: args[0].(string), res: res}:
+		default:
+		}
+		return int64(1), nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logBuffer := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(logBuffer, nil))
+
+	_, _, err := Start(ctx, redisTransport.New(pool), testConfig(), logger)
+	assert.Nil(t, err, "No error should be returned when starting the request manager")
+
+	// The expired request will be received repeatedly, so wait until both have been published at least once.
+	responses := map[string]*protocol.Response{}
+	for len(responses) < 2 {
+		select {
+		case p := <-published:
+			responses[p.key] = p.res
+		case <-time.After(redisTransport.DefaultPopTimeout + 2*time.Second):
+			assert.Fail(t, "Didn't receive a response within a reasonable time.")
+			return
+		}
+	}
+
+	invalidRes := responses[redisTransport.ResponseKeyPrefix+invalidReq.ResponseKey]
+	if assert.NotNil(t, invalidRes, "An error should be published for the invalid request") {
+		assert.Equal(t, protocol.Response_INVALID_REQUEST, invalidRes.ErrorCode, "Invalid request should have the correct error code")
+		assert.NotEmpty(t, invalidRes.ErrorMessage, "Invalid request error should include a message")
+	}
+	expiredRes := responses[redisTransport.ResponseKeyPrefix+expiredReq.ResponseKey]
+	if assert.NotNil(t, expiredRes, "An error should be published for the expired request") {
+		assert.Equal(t, protocol.Response_EXPIRED, expiredRes.ErrorCode, "Expired request should have the correct error code")
+		assert.NotEmpty(t, expiredRes.ErrorMessage, "Expired request error should include a message")
+	}
+}
+
+func TestRequestManagerShouldRequeueRequestsReceivedWhileStopping(t *testing.T) {
+	pool := transportHelpers.NewMockPool()
+	pool.Conn.Command("PING").Expect("PONG")
+	pool.Conn.Command("TIME").ExpectSlice(int64(123), int64(0))
+
+	req := &protocol.Request{
+		RequestType:     protocol.Request_HASHPASSWORD,
+		ResponseKey:     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		Password:        []byte("abc"),
+		Cost:            10,
+		ExpiryTimestamp: math.MaxInt64,
+	}
+	reqBytes, _ := proto.Marshal(req)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The context is cancelled while the request is being popped, as happens when the agent is stopped during a BRPOP.
+	pool.Conn.Command("BRPOP", redisTransport.RequestQueueKey, redisTransport.BulkRequestQueueKey, popTimeoutSeconds).Handle(func(args []interface{}) (interface{}, error) {
+		cancel()
+		return []interface{}{[]byte(redisTransport.RequestQueueKey), reqBytes}, nil
+	})
+	pool.Conn.Command("MULTI").Expect("OK")
+	requeue := pool.Conn.Command("RPUSH", redisTransport.RequestQueueKey, reqBytes).Expect("QUEUED")
+	pool.Conn.Command("EXEC").ExpectSlice(int64(1))
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	results, _, err := Start(ctx, redisTransport.New(pool), testConfig(), logger)
+	assert.Nil(t, err, "No error should be returned when starting the request manager")
+
+	select {
+	case _, ok := <-results:
+		assert.False(t, ok, "Requests received while stopping shouldn't be passed on to the workers")
+	case <-time.After(redisTransport.DefaultPopTimeout + 2*time.Second):
+		assert.Fail(t, "The request manager should stop once the context is cancelled.")
+	}
+	assert.True(t, requeue.Called, "Requests received while stopping should be pushed back onto the queue")
+}
+
+func TestRequestManagerShouldReportItsStatus(t *testing.T) {
+	pool := transportHelpers.NewMockPool()
+	pool.Conn.Command("PING").Expect("PONG")
+	pool.Conn.Command("BRPOP", redisTransport.RequestQueueKey, redisTransport.BulkRequestQueueKey, popTimeoutSeconds).ExpectError(fmt.Errorf("random error"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := testConfig()
+	cfg.ErrorRetryTime = time.Minute
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	_, status, err := Start(ctx, redisTransport.New(pool), cfg, logger)
+	assert.Nil(t, err, "No error should be returned when starting the request manager")
+	assert.True(t, status.Running(), "The request manager should be running once it's started")
+	assert.Eventually(t, status.BackingOff, time.Second, time.Millisecond,
+		"The request manager should be backing off after a transport error")
+
+	cancel()
+	assert.Eventually(t, func() bool {
+		return !status.Running() && !status.BackingOff()
+	}, time.Second, time.Millisecond, "The request manager should stop straight away once the context is cancelled")
+}
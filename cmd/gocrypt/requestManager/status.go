@@ -0,0 +1,37 @@
+package requestManager
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Status reports what the request manager is doing, so that the agent's health can be checked. It's safe for concurrent
+// use.
+type Status struct {
+	running    int32
+	backingOff int32
+}
+
+// Running returns whether the request manager is receiving requests. It's false once the context is cancelled.
+func (s *Status) Running() bool {
+	return atomic.LoadInt32(&s.running) == 1
+}
+
+// BackingOff returns whether the request manager is waiting to retry after failing to receive a request.
+func (s *Status) BackingOff() bool {
+	return atomic.LoadInt32(&s.backingOff) == 1
+}
+
+// backOff waits for the retry time after failing to receive a request, unless the context is cancelled first.
+func (s *Status) backOff(ctx context.Context, retryTime time.Duration) {
+	atomic.StoreInt32(&s.backingOff, 1)
+	defer atomic.StoreInt32(&s.backingOff, 0)
+
+	timer := time.NewTimer(retryTime)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
@@ -1,40 +1,53 @@
 package requestWorker
 
 import (
-	"log"
+	"errors"
+	"log/slog"
+	"time"
 
-	"github.com/rsheasby/gocrypt/gocrypt/passwordHelpers"
-	"github.com/rsheasby/gocrypt/gocrypt/redisHelpers"
+	"github.com/rsheasby/gocrypt/gocrypt/config"
+	"github.com/rsheasby/gocrypt/gocrypt/logging"
+	"github.com/rsheasby/gocrypt/gocrypt/metrics"
+	"github.com/rsheasby/gocrypt/gocrypt/transportHelpers"
 	"github.com/rsheasby/gocrypt/protocol"
+	"github.com/rsheasby/gocrypt/requestHandler"
+	"github.com/rsheasby/gocrypt/transport"
 )
 
-func handleRequest(request *protocol.Request, pool redisHelpers.ConnGetter, logger *log.Logger) {
-	switch request.RequestType {
-	case protocol.Request_HASHPASSWORD:
-		handleHashRequest(request, pool, logger)
-	case protocol.Request_VERIFYPASSWORD:
-		handleValidateRequest(request, pool, logger)
+// handleRequest handles the request and publishes the response, recording the outcome in the metrics, a span and the
+// debug logs.
+func handleRequest(request *protocol.Request, t transport.Transport, cfg *config.Config, logger *slog.Logger) {
+	start := time.Now()
+	span := startSpan(request, cfg)
+	logger = logger.With(logging.ResponseKey(request.ResponseKey), logging.RequestType(request.RequestType))
+	handler := &requestHandler.Handler{
+		Peppers:  cfg.Peppers,
+		Observer: cfg.Metrics,
 	}
-}
-
-func handleHashRequest(req *protocol.Request, pool redisHelpers.ConnGetter, logger *log.Logger) {
-	hash := passwordHelpers.HashPassword(req.Password, int(req.Cost))
-
-	res := &protocol.Response{
-		Hash: hash,
-	}
-	redisHelpers.PublishResponse(res, req.ResponseKey, pool, logger)
-}
-
-func handleValidateRequest(req *protocol.Request, pool redisHelpers.ConnGetter, logger *log.Logger) {
-	isValid, err := passwordHelpers.ValidatePassword(req.Password, req.Hash)
-	if err != nil {
-		logger.Printf("Error when validating password: %v", err)
-		return
+	var outcome metrics.Outcome
+	res, err := handler.Handle(request)
+	switch {
+	case err == nil:
+		outcome = metrics.OutcomeSuccess
+		cfg.Metrics.RequestHandled(request.RequestType, outcome)
+		transportHelpers.PublishResponse(res, request, t, cfg, logger)
+	case errors.Is(err, requestHandler.ErrInvalidRequestType):
+		// The request manager refuses unknown request types, so this should never happen.
+		outcome = metrics.OutcomeInvalidRequest
+	default:
+		outcome = metrics.OutcomeInvalidHash
+		logger.Warn("Error when validating password.", logging.Err(err))
+		cfg.Metrics.RequestHandled(request.RequestType, outcome)
+		transportHelpers.PublishError(protocol.Response_INVALID_HASH, err.Error(), request, cfg.PublishAttempts, t,
+			cfg, logger)
 	}
+	endSpan(span, outcome)
 
-	res := &protocol.Response{
-		IsValid: isValid,
+	attrs := []any{slog.String("outcome", string(outcome)), slog.Duration("duration", time.Since(start))}
+	// Only the requested parameters are logged, as the hash being validated must never be logged.
+	if request.RequestType != protocol.Request_VERIFYPASSWORD {
+		params := requestHandler.RequestParams(request)
+		attrs = append(attrs, logging.Algorithm(params), logging.Cost(params))
 	}
-	redisHelpers.PublishResponse(res, req.ResponseKey, pool, logger)
+	logger.Debug("Handled request.", attrs...)
 }
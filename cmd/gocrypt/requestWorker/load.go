@@ -0,0 +1,31 @@
+package requestWorker
+
+import "sync/atomic"
+
+// Load counts how many workers are busy handling a request, so that it can be reported in the agent's heartbeat. A nil
+// Load counts nothing.
+type Load struct {
+	busy int64
+}
+
+// Busy returns how many workers are busy handling a request.
+func (l *Load) Busy() int {
+	if l == nil {
+		return 0
+	}
+	return int(atomic.LoadInt64(&l.busy))
+}
+
+func (l *Load) workerBusy() {
+	if l == nil {
+		return
+	}
+	atomic.AddInt64(&l.busy, 1)
+}
+
+func (l *Load) workerIdle() {
+	if l == nil {
+		return
+	}
+	atomic.AddInt64(&l.busy, -1)
+}
@@ -2,21 +2,43 @@ package requestWorker
 
 import (
 	"context"
-	"log"
+	"log/slog"
+	"sync"
 
-	"github.com/rsheasby/gocrypt/gocrypt/redisHelpers"
-	"github.com/rsheasby/gocrypt/protocol"
+	"github.com/rsheasby/gocrypt/gocrypt/config"
+	"github.com/rsheasby/gocrypt/gocrypt/transportHelpers"
+	"github.com/rsheasby/gocrypt/transport"
 )
 
-// Start starts the specified amount of request workers to receive and process requests, then publish the results back to the client via redis.
-func StartMany(ctx context.Context, reqChan chan *protocol.Request, pool redisHelpers.ConnGetter, count int, logger *log.Logger) {
-	for i := 0; i < count; i++ {
-		go requestWorker(ctx, reqChan, pool, logger)
+// StartMany starts the configured amount of request workers to receive and process requests, then publish the results
+// back to the client via the transport each request was received from, or t if it wasn't received from a transport.
+// Each request is acknowledged once its result has been published. The busy workers are counted in load, which may be
+// nil. The returned channel is closed once all of the workers have stopped.
+func StartMany(ctx context.Context, reqChan chan *transportHelpers.ReceivedRequest, t transport.Transport,
+	cfg *config.Config, load *Load, logger *slog.Logger) (done chan struct{}) {
+	var wg sync.WaitGroup
+	wg.Add(cfg.Threads)
+	for i := 0; i < cfg.Threads; i++ {
+		go func() {
+			defer wg.Done()
+			requestWorker(ctx, reqChan, t, cfg, load, logger)
+		}()
 	}
-	logger.Printf("Started %d worker thread(s).", count)
+	logger.Info("Started worker threads.", slog.Int("threads", cfg.Threads))
+
+	done = make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	return done
 }
 
-func requestWorker(ctx context.Context, reqChan chan *protocol.Request, pool redisHelpers.ConnGetter, logger *log.Logger) {
+func requestWorker(ctx context.Context, reqChan chan *transportHelpers.ReceivedRequest, t transport.Transport,
+	cfg *config.Config, load *Load, logger *slog.Logger) {
+	cfg.Metrics.WorkerStarted()
+	defer cfg.Metrics.WorkerStopped()
+
 	for {
 		// This is duplicated so that a cancelled context takes priority over the request channel.
 		if ctx.Err() != nil {
@@ -26,8 +48,17 @@ func requestWorker(ctx context.Context, reqChan chan *protocol.Request, pool red
 		select {
 		case <-ctx.Done():
 			return
-		case req := <-reqChan:
-			handleRequest(req, pool, logger)
+		case req, ok := <-reqChan:
+			// The channel is closed once the request manager stops.
+			if !ok {
+				return
+			}
+			cfg.Metrics.WorkerBusy()
+			load.workerBusy()
+			handleRequest(req.Request, req.Transport(t), cfg, logger)
+			req.Ack(logger)
+			load.workerIdle()
+			cfg.Metrics.WorkerIdle()
 		}
 	}
 }
@@ -4,30 +4,38 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"math"
 	"testing"
 	"time"
 
 	"github.com/rsheasby/gocrypt/gocrypt/config"
-	"github.com/rsheasby/gocrypt/gocrypt/redisHelpers"
+	"github.com/rsheasby/gocrypt/gocrypt/transportHelpers"
 	"github.com/rsheasby/gocrypt/protocol"
+	"github.com/rsheasby/gocrypt/transport/redisTransport"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/crypto/bcrypt"
 	"google.golang.org/protobuf/proto"
 )
 
+// testConfig returns the default config with a single worker thread.
+func testConfig() (cfg *config.Config) {
+	c := config.Default()
+	c.Threads = 1
+	return &c
+}
+
 func TestRequestWorkerShouldHonorContextCancellation(t *testing.T) {
-	pool := redisHelpers.NewMockPool()
+	pool := transportHelpers.NewMockPool()
 
 	ctx, cancel := context.WithCancel(context.Background())
 
 	logBuffer := &bytes.Buffer{}
-	logger := log.New(logBuffer, "", 0)
+	logger := slog.New(slog.NewTextHandler(logBuffer, nil))
 
 	done := make(chan struct{})
 	go func() {
-		requestWorker(ctx, make(chan *protocol.Request), pool, logger)
+		requestWorker(ctx, make(chan *transportHelpers.ReceivedRequest), redisTransport.New(pool), testConfig(), nil, logger)
 		done <- struct{}{}
 	}()
 
@@ -43,7 +51,7 @@ func TestRequestWorkerShouldHonorContextCancellation(t *testing.T) {
 
 func TestRequestWorkerShouldProcessHashRequestsAndPublishTheResultCorrectly(t *testing.T) {
 	t.Parallel()
-	pool := redisHelpers.NewMockPool()
+	pool := transportHelpers.NewMockPool()
 
 	hasErrored := false
 	hasErroredWithNoRecipients := false
@@ -61,7 +69,7 @@ func TestRequestWorkerShouldProcessHashRequestsAndPublishTheResultCorrectly(t *t
 		}
 
 		assert.Len(t, args, 2, "PUBLISH command should have 2 arguments")
-		assert.Equal(t, config.ResponseKeyPrefix+"ABCDEFGHIJKLMNOPQRSTUVWXYZ", args[0], "PUBLISH key is incorrect")
+		assert.Equal(t, redisTransport.ResponseKeyPrefix+"ABCDEFGHIJKLMNOPQRSTUVWXYZ", args[0], "PUBLISH key is incorrect")
 
 		resBytes, ok := args[1].([]byte)
 		assert.True(t, ok, "Response should be a byte array")
@@ -81,19 +89,19 @@ func TestRequestWorkerShouldProcessHashRequestsAndPublishTheResultCorrectly(t *t
 	defer cancel()
 
 	logBuffer := &bytes.Buffer{}
-	logger := log.New(logBuffer, "", 0)
+	logger := slog.New(slog.NewTextHandler(logBuffer, nil))
 
-	reqChan := make(chan *protocol.Request)
+	reqChan := make(chan *transportHelpers.ReceivedRequest)
 
-	StartMany(ctx, reqChan, pool, 1, logger)
+	StartMany(ctx, reqChan, redisTransport.New(pool), testConfig(), nil, logger)
 
-	reqChan <- &protocol.Request{
+	reqChan <- &transportHelpers.ReceivedRequest{Request: &protocol.Request{
 		RequestType:     protocol.Request_HASHPASSWORD,
 		ResponseKey:     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
 		Password:        []byte("abc"),
 		Cost:            int32(bcrypt.MinCost),
 		ExpiryTimestamp: math.MaxInt64,
-	}
+	}}
 
 	select {
 	case <-doneChan:
@@ -106,9 +114,59 @@ func TestRequestWorkerShouldProcessHashRequestsAndPublishTheResultCorrectly(t *t
 	assert.NotZero(t, logBuffer.Len(), "There should be some logs due to the simulated errors.")
 }
 
+func TestRequestWorkerShouldPublishOnTheResponseChannel(t *testing.T) {
+	t.Parallel()
+	pool := transportHelpers.NewMockPool()
+
+	doneChan := make(chan struct{})
+
+	comm := pool.Conn.GenericCommand("PUBLISH").Handle(func(args []interface{}) (interface{}, error) {
+		defer func() {
+			doneChan <- struct{}{}
+		}()
+		assert.Equal(t, redisTransport.ResponseKeyPrefix+"Client:test", args[0],
+			"Response should be published on the response channel")
+
+		channelRes := &protocol.ChannelResponse{}
+		assert.Nil(t, proto.Unmarshal(args[1].([]byte), channelRes), "Unmarshalling of channel response should succeed")
+		assert.Equal(t, "ABCDEFGHIJKLMNOPQRSTUVWXYZ", channelRes.ResponseKey,
+			"Channel response should include the response key")
+
+		res := &protocol.Response{}
+		assert.Nil(t, proto.Unmarshal(channelRes.Response, res), "Unmarshalling of response should succeed")
+		assert.Nil(t, bcrypt.CompareHashAndPassword([]byte(res.Hash), []byte("abc")), "Hash and password should validate")
+		return int64(1), nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reqChan := make(chan *transportHelpers.ReceivedRequest)
+
+	StartMany(ctx, reqChan, redisTransport.New(pool), testConfig(), nil, slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)))
+
+	reqChan <- &transportHelpers.ReceivedRequest{Request: &protocol.Request{
+		RequestType:     protocol.Request_HASHPASSWORD,
+		ResponseKey:     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		ResponseChannel: "Client:test",
+		Password:        []byte("abc"),
+		Cost:            int32(bcrypt.MinCost),
+		ExpiryTimestamp: math.MaxInt64,
+	}}
+
+	select {
+	case <-doneChan:
+		break
+	case <-time.After(10 * time.Second):
+		assert.Fail(t, "Didn't receive a response within a reasonable time")
+	}
+
+	assert.True(t, comm.Called, "Request worker should publish the hash result.")
+}
+
 func TestRequestWorkerShouldProcessVerifyValidRequestsAndPublishTheResultCorrectly(t *testing.T) {
 	t.Parallel()
-	pool := redisHelpers.NewMockPool()
+	pool := transportHelpers.NewMockPool()
 
 	hasErrored := false
 	hasErroredWithNoRecipients := false
@@ -126,7 +184,7 @@ func TestRequestWorkerShouldProcessVerifyValidRequestsAndPublishTheResultCorrect
 		}
 
 		assert.Len(t, args, 2, "PUBLISH command should have 2 arguments")
-		assert.Equal(t, config.ResponseKeyPrefix+"ABCDEFGHIJKLMNOPQRSTUVWXYZ", args[0], "PUBLISH key is incorrect")
+		assert.Equal(t, redisTransport.ResponseKeyPrefix+"ABCDEFGHIJKLMNOPQRSTUVWXYZ", args[0], "PUBLISH key is incorrect")
 
 		resBytes, ok := args[1].([]byte)
 		assert.True(t, ok, "Response should be a byte array")
@@ -146,19 +204,19 @@ func TestRequestWorkerShouldProcessVerifyValidRequestsAndPublishTheResultCorrect
 	defer cancel()
 
 	logBuffer := &bytes.Buffer{}
-	logger := log.New(logBuffer, "", 0)
+	logger := slog.New(slog.NewTextHandler(logBuffer, nil))
 
-	reqChan := make(chan *protocol.Request)
+	reqChan := make(chan *transportHelpers.ReceivedRequest)
 
-	StartMany(ctx, reqChan, pool, 1, logger)
+	StartMany(ctx, reqChan, redisTransport.New(pool), testConfig(), nil, logger)
 
-	reqChan <- &protocol.Request{
+	reqChan <- &transportHelpers.ReceivedRequest{Request: &protocol.Request{
 		RequestType:     protocol.Request_VERIFYPASSWORD,
 		ResponseKey:     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
 		Password:        []byte("abc"),
 		Hash:            "$2y$04$scoJ6DgfwqxqzQoTRdfvKOwQ1.aTPomv0rpoEub.FagPGAdvqW7Pa",
 		ExpiryTimestamp: math.MaxInt64,
-	}
+	}}
 
 	select {
 	case <-doneChan:
@@ -173,7 +231,7 @@ func TestRequestWorkerShouldProcessVerifyValidRequestsAndPublishTheResultCorrect
 
 func TestRequestWorkerShouldProcessVerifyInvalidRequestsAndPublishTheResultCorrectly(t *testing.T) {
 	t.Parallel()
-	pool := redisHelpers.NewMockPool()
+	pool := transportHelpers.NewMockPool()
 
 	hasErrored := false
 	hasErroredWithNoRecipients := false
@@ -191,7 +249,7 @@ func TestRequestWorkerShouldProcessVerifyInvalidRequestsAndPublishTheResultCorre
 		}
 
 		assert.Len(t, args, 2, "PUBLISH command should have 2 arguments")
-		assert.Equal(t, config.ResponseKeyPrefix+"ABCDEFGHIJKLMNOPQRSTUVWXYZ", args[0], "PUBLISH key is incorrect")
+		assert.Equal(t, redisTransport.ResponseKeyPrefix+"ABCDEFGHIJKLMNOPQRSTUVWXYZ", args[0], "PUBLISH key is incorrect")
 
 		resBytes, ok := args[1].([]byte)
 		assert.True(t, ok, "Response should be a byte array")
@@ -211,19 +269,19 @@ func TestRequestWorkerShouldProcessVerifyInvalidRequestsAndPublishTheResultCorre
 	defer cancel()
 
 	logBuffer := &bytes.Buffer{}
-	logger := log.New(logBuffer, "", 0)
+	logger := slog.New(slog.NewTextHandler(logBuffer, nil))
 
-	reqChan := make(chan *protocol.Request)
+	reqChan := make(chan *transportHelpers.ReceivedRequest)
 
-	StartMany(ctx, reqChan, pool, 1, logger)
+	StartMany(ctx, reqChan, redisTransport.New(pool), testConfig(), nil, logger)
 
-	reqChan <- &protocol.Request{
+	reqChan <- &transportHelpers.ReceivedRequest{Request: &protocol.Request{
 		RequestType:     protocol.Request_VERIFYPASSWORD,
 		ResponseKey:     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
 		Password:        []byte("Abc"),
 		Hash:            "$2y$04$scoJ6DgfwqxqzQoTRdfvKOwQ1.aTPomv0rpoEub.FagPGAdvqW7Pa",
 		ExpiryTimestamp: math.MaxInt64,
-	}
+	}}
 
 	select {
 	case <-doneChan:
@@ -236,41 +294,247 @@ func TestRequestWorkerShouldProcessVerifyInvalidRequestsAndPublishTheResultCorre
 	assert.NotZero(t, logBuffer.Len(), "There should be some logs due to the simulated errors.")
 }
 
+func TestRequestWorkerShouldProcessVerifyAndRehashRequestsAndPublishTheResultCorrectly(t *testing.T) {
+	t.Parallel()
+	pool := transportHelpers.NewMockPool()
+
+	doneChan := make(chan struct{})
+
+	comm := pool.Conn.GenericCommand("PUBLISH").Handle(func(args []interface{}) (interface{}, error) {
+		assert.Len(t, args, 2, "PUBLISH command should have 2 arguments")
+
+		resBytes, ok := args[1].([]byte)
+		assert.True(t, ok, "Response should be a byte array")
+
+		res := &protocol.Response{}
+		assert.Nil(t, proto.Unmarshal(resBytes, res), "Unmarshalling of response should succeed")
+
+		assert.True(t, res.IsValid, "Hash and password should validate")
+		assert.Nil(t, bcrypt.CompareHashAndPassword([]byte(res.Hash), []byte("abc")), "New hash and password should validate")
+		cost, _ := bcrypt.Cost([]byte(res.Hash))
+		assert.Equal(t, bcrypt.MinCost+1, cost, "New hash should use the requested cost")
+
+		defer func() {
+			doneChan <- struct{}{}
+		}()
+		return int64(1), nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logBuffer := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(logBuffer, nil))
+
+	reqChan := make(chan *transportHelpers.ReceivedRequest)
+
+	StartMany(ctx, reqChan, redisTransport.New(pool), testConfig(), nil, logger)
+
+	reqChan <- &transportHelpers.ReceivedRequest{Request: &protocol.Request{
+		RequestType:     protocol.Request_VERIFYPASSWORDANDREHASH,
+		ResponseKey:     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		Password:        []byte("abc"),
+		Hash:            "$2y$04$scoJ6DgfwqxqzQoTRdfvKOwQ1.aTPomv0rpoEub.FagPGAdvqW7Pa",
+		Cost:            int32(bcrypt.MinCost + 1),
+		ExpiryTimestamp: math.MaxInt64,
+	}}
+
+	select {
+	case <-doneChan:
+		break
+	case <-time.After(10 * time.Second):
+		assert.Fail(t, "Didn't receive a response within a reasonable time")
+	}
+
+	assert.True(t, comm.Called, "Request worker should publish the validation result.")
+}
+
+func TestRequestWorkerShouldNotRehashWhenCostIsSufficientOrPasswordIsInvalid(t *testing.T) {
+	t.Parallel()
+	pool := transportHelpers.NewMockPool()
+
+	doneChan := make(chan *protocol.Response)
+
+	pool.Conn.GenericCommand("PUBLISH").Handle(func(args []interface{}) (interface{}, error) {
+		resBytes, ok := args[1].([]byte)
+		assert.True(t, ok, "Response should be a byte array")
+
+		res := &protocol.Response{}
+		assert.Nil(t, proto.Unmarshal(resBytes, res), "Unmarshalling of response should succeed")
+
+		defer func() {
+			doneChan <- res
+		}()
+		return int64(1), nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logBuffer := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(logBuffer, nil))
+
+	reqChan := make(chan *transportHelpers.ReceivedRequest)
+
+	StartMany(ctx, reqChan, redisTransport.New(pool), testConfig(), nil, logger)
+
+	// Cost is already sufficient
+	reqChan <- &transportHelpers.ReceivedRequest{Request: &protocol.Request{
+		RequestType:     protocol.Request_VERIFYPASSWORDANDREHASH,
+		ResponseKey:     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		Password:        []byte("abc"),
+		Hash:            "$2y$04$scoJ6DgfwqxqzQoTRdfvKOwQ1.aTPomv0rpoEub.FagPGAdvqW7Pa",
+		Cost:            int32(bcrypt.MinCost),
+		ExpiryTimestamp: math.MaxInt64,
+	}}
+
+	select {
+	case res := <-doneChan:
+		assert.True(t, res.IsValid, "Hash and password should validate")
+		assert.Empty(t, res.Hash, "No new hash should be returned when the cost is sufficient")
+	case <-time.After(10 * time.Second):
+		assert.Fail(t, "Didn't receive a response within a reasonable time")
+	}
+
+	// Password is incorrect
+	reqChan <- &transportHelpers.ReceivedRequest{Request: &protocol.Request{
+		RequestType:     protocol.Request_VERIFYPASSWORDANDREHASH,
+		ResponseKey:     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		Password:        []byte("Abc"),
+		Hash:            "$2y$04$scoJ6DgfwqxqzQoTRdfvKOwQ1.aTPomv0rpoEub.FagPGAdvqW7Pa",
+		Cost:            int32(bcrypt.MinCost + 1),
+		ExpiryTimestamp: math.MaxInt64,
+	}}
+
+	select {
+	case res := <-doneChan:
+		assert.False(t, res.IsValid, "Hash and password shouldn't validate")
+		assert.Empty(t, res.Hash, "No new hash should be returned when the password is incorrect")
+	case <-time.After(10 * time.Second):
+		assert.Fail(t, "Didn't receive a response within a reasonable time")
+	}
+}
+
+func TestRequestWorkerShouldValidateAndReplaceLegacyHashes(t *testing.T) {
+	t.Parallel()
+	pool := transportHelpers.NewMockPool()
+
+	doneChan := make(chan *protocol.Response)
+
+	pool.Conn.GenericCommand("PUBLISH").Handle(func(args []interface{}) (interface{}, error) {
+		resBytes, ok := args[1].([]byte)
+		assert.True(t, ok, "Response should be a byte array")
+
+		res := &protocol.Response{}
+		assert.Nil(t, proto.Unmarshal(resBytes, res), "Unmarshalling of response should succeed")
+
+		defer func() {
+			doneChan <- res
+		}()
+		return int64(1), nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logBuffer := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(logBuffer, nil))
+
+	reqChan := make(chan *transportHelpers.ReceivedRequest)
+
+	StartMany(ctx, reqChan, redisTransport.New(pool), testConfig(), nil, logger)
+
+	legacyHash := "pbkdf2_sha256$1000$seasalt$YIWkt6M1JFXrHg5s0jZjBSc7C2Cz6QvchSJ0h8Y+i7c="
+
+	reqChan <- &transportHelpers.ReceivedRequest{Request: &protocol.Request{
+		RequestType:     protocol.Request_VERIFYPASSWORD,
+		ResponseKey:     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		Password:        []byte("abc"),
+		LegacyPassword:  []byte("password"),
+		Hash:            legacyHash,
+		ExpiryTimestamp: math.MaxInt64,
+	}}
+
+	select {
+	case res := <-doneChan:
+		assert.True(t, res.IsValid, "Legacy hash should validate with the legacy password")
+		assert.Empty(t, res.Hash, "No new hash should be returned for verify requests")
+	case <-time.After(10 * time.Second):
+		assert.Fail(t, "Didn't receive a response within a reasonable time")
+	}
+
+	reqChan <- &transportHelpers.ReceivedRequest{Request: &protocol.Request{
+		RequestType:     protocol.Request_VERIFYPASSWORDANDREHASH,
+		ResponseKey:     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		Password:        []byte("abc"),
+		LegacyPassword:  []byte("password"),
+		Hash:            legacyHash,
+		Cost:            int32(bcrypt.MinCost),
+		ExpiryTimestamp: math.MaxInt64,
+	}}
+
+	select {
+	case res := <-doneChan:
+		assert.True(t, res.IsValid, "Legacy hash should validate with the legacy password")
+		assert.Nil(t, bcrypt.CompareHashAndPassword([]byte(res.Hash), []byte("abc")),
+			"Legacy hash should be replaced with a native hash of the password")
+	case <-time.After(10 * time.Second):
+		assert.Fail(t, "Didn't receive a response within a reasonable time")
+	}
+}
+
 func TestRequestWorkerShouldHandleVerifyRequestsWithInvalidHash(t *testing.T) {
 	t.Parallel()
-	pool := redisHelpers.NewMockPool()
+	pool := transportHelpers.NewMockPool()
+
+	doneChan := make(chan *protocol.Response)
 
 	pool.Conn.GenericCommand("PUBLISH").Handle(func(args []interface{}) (interface{}, error) {
-		assert.Fail(t, "Should not publish a response when an invalid hash is provided")
-		return nil, nil
+		resBytes, ok := args[1].([]byte)
+		assert.True(t, ok, "Response should be a byte array")
+
+		res := &protocol.Response{}
+		assert.Nil(t, proto.Unmarshal(resBytes, res), "Unmarshalling of response should succeed")
+
+		defer func() {
+			doneChan <- res
+		}()
+		return int64(1), nil
 	})
 
 	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
 	logBuffer := &bytes.Buffer{}
-	logger := log.New(logBuffer, "", 0)
+	logger := slog.New(slog.NewTextHandler(logBuffer, nil))
 
-	reqChan := make(chan *protocol.Request)
+	reqChan := make(chan *transportHelpers.ReceivedRequest)
 
-	StartMany(ctx, reqChan, pool, 1, logger)
+	StartMany(ctx, reqChan, redisTransport.New(pool), testConfig(), nil, logger)
 
-	reqChan <- &protocol.Request{
+	reqChan <- &transportHelpers.ReceivedRequest{Request: &protocol.Request{
 		RequestType:     protocol.Request_VERIFYPASSWORD,
 		ResponseKey:     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
 		Password:        []byte("Abc"),
 		Hash:            "$2y",
 		ExpiryTimestamp: math.MaxInt64,
-	}
+	}}
 
-	time.Sleep(2 * time.Second)
-	cancel()
+	select {
+	case res := <-doneChan:
+		assert.Equal(t, protocol.Response_INVALID_HASH, res.ErrorCode, "Should publish an invalid hash error")
+		assert.NotEmpty(t, res.ErrorMessage, "Should publish the reason for the error")
+		assert.False(t, res.IsValid, "Password should not be valid when an invalid hash is provided")
+	case <-time.After(10 * time.Second):
+		assert.Fail(t, "Didn't receive a response within a reasonable time")
+	}
 
 	assert.NotZero(t, logBuffer.Len(), "There should be some logs due to the simulated errors.")
 }
 
 func TestRequestWorkerShouldAttemptToPublishTheCorrectAmountOfTimes(t *testing.T) {
 	t.Parallel()
-	pool := redisHelpers.NewMockPool()
+	pool := transportHelpers.NewMockPool()
 
 	attempts := 0
 	pool.Conn.GenericCommand("PUBLISH").Handle(func(args []interface{}) (interface{}, error) {
@@ -282,31 +546,66 @@ func TestRequestWorkerShouldAttemptToPublishTheCorrectAmountOfTimes(t *testing.T
 	ctx, cancel := context.WithCancel(context.Background())
 
 	logBuffer := &bytes.Buffer{}
-	logger := log.New(logBuffer, "", 0)
+	logger := slog.New(slog.NewTextHandler(logBuffer, nil))
 
-	reqChan := make(chan *protocol.Request)
+	reqChan := make(chan *transportHelpers.ReceivedRequest)
 	doneChan := make(chan struct{})
 
 	go func() {
-		requestWorker(ctx, reqChan, pool, logger)
+		requestWorker(ctx, reqChan, redisTransport.New(pool), testConfig(), nil, logger)
 		doneChan <- struct{}{}
 	}()
 
-	reqChan <- &protocol.Request{
+	reqChan <- &transportHelpers.ReceivedRequest{Request: &protocol.Request{
 		RequestType:     protocol.Request_HASHPASSWORD,
 		ResponseKey:     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
 		Password:        []byte("abc"),
 		Cost:            int32(bcrypt.MinCost),
 		ExpiryTimestamp: math.MaxInt64,
-	}
+	}}
 
 	cancel()
 
 	select {
 	case <-doneChan:
 		assert.NotZero(t, logBuffer.Len(), "There should be some logs due to the simulated errors.")
-		assert.Equal(t, config.PublishAttempts, attempts, "Didn't publish the correct amount of times.")
-	case <-time.After((config.PublishAttempts + 1) * config.ErrorRetryTime):
+		assert.Equal(t, config.DefaultPublishAttempts, attempts, "Didn't publish the correct amount of times.")
+	case <-time.After((config.DefaultPublishAttempts + 1) * config.DefaultErrorRetryTime):
 		assert.Fail(t, "Didn't receive a response within a reasonable time.")
 	}
 }
+
+func TestRequestWorkerShouldCountBusyWorkers(t *testing.T) {
+	t.Parallel()
+	pool := transportHelpers.NewMockPool()
+	load := &Load{}
+
+	busyWhilePublishing := make(chan int, 1)
+	pool.Conn.GenericCommand("PUBLISH").Handle(func(args []interface{}) (interface{}, error) {
+		busyWhilePublishing <- load.Busy()
+		return int64(1), nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reqChan := make(chan *transportHelpers.ReceivedRequest)
+	done := StartMany(ctx, reqChan, redisTransport.New(pool), testConfig(), load,
+		slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)))
+
+	reqChan <- &transportHelpers.ReceivedRequest{Request: &protocol.Request{
+		RequestType:     protocol.Request_HASHPASSWORD,
+		ResponseKey:     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		Password:        []byte("abc"),
+		Cost:            int32(bcrypt.MinCost),
+		ExpiryTimestamp: math.MaxInt64,
+	}}
+
+	select {
+	case busy := <-busyWhilePublishing:
+		assert.Equal(t, 1, busy, "The worker should be counted as busy while it handles the request")
+	case <-time.After(10 * time.Second):
+		assert.Fail(t, "Didn't receive a response within a reasonable time")
+	}
+	cancel()
+	<-done
+	assert.Equal(t, 0, load.Busy(), "The worker should be counted as idle once it's handled the request")
+}
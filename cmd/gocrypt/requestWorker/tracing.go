@@ -0,0 +1,59 @@
+package requestWorker
+
+import (
+	"context"
+
+	"github.com/rsheasby/gocrypt/gocrypt/config"
+	"github.com/rsheasby/gocrypt/gocrypt/metrics"
+	"github.com/rsheasby/gocrypt/protocol"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName is the name of the tracer used for the request spans.
+const instrumentationName = "github.com/rsheasby/gocrypt/gocrypt"
+
+// startSpan starts a span for handling the request. If the client sent its trace context with the request, the span is
+// a child of the client's span.
+func startSpan(req *protocol.Request, cfg *config.Config) (span trace.Span) {
+	tracerProvider := cfg.TracerProvider
+	if tracerProvider == nil {
+		tracerProvider = trace.NewNoopTracerProvider()
+	}
+	ctx := propagation.TraceContext{}.Extract(context.Background(), traceCarrier(req.TraceContext))
+	_, span = tracerProvider.Tracer(instrumentationName).Start(ctx, "gocrypt "+req.RequestType.String(),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("gocrypt.request_type", req.RequestType.String())),
+	)
+	return span
+}
+
+// endSpan records the outcome of the request on the span, and ends it.
+func endSpan(span trace.Span, outcome metrics.Outcome) {
+	span.SetAttributes(attribute.String("gocrypt.outcome", string(outcome)))
+	if outcome != metrics.OutcomeSuccess {
+		span.SetStatus(codes.Error, string(outcome))
+	}
+	span.End()
+}
+
+// traceCarrier reads the trace context sent by the client.
+type traceCarrier map[string]string
+
+func (c traceCarrier) Get(key string) string {
+	return c[key]
+}
+
+func (c traceCarrier) Set(key string, value string) {
+	c[key] = value
+}
+
+func (c traceCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,473 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rsheasby/gocrypt/envelope"
+	"github.com/rsheasby/gocrypt/gocrypt/config"
+	"github.com/rsheasby/gocrypt/gocrypt/logging"
+	"github.com/rsheasby/gocrypt/hashAlgorithms"
+)
+
+const (
+	// TransportList uses a redis list for requests, and pub/sub for responses.
+	TransportList = "list"
+	// TransportStreams uses a redis stream for requests, and a list per request for responses.
+	TransportStreams = "streams"
+)
+
+// settings holds everything the service can be configured with. Each setting starts as the default, and can be
+// overridden by the config file, then by an environment variable, and finally by a flag.
+type settings struct {
+	cfg          config.Config
+	transport    string
+	namespaces   string
+	logFormat    string
+	logLevel     string
+	pepperID     string
+	pepperKeys   string
+	envelopeID   string
+	envelopeKeys string
+	// configFile and printConfig can only be set using flags, or CONFIG_FILE for the config file.
+	configFile  string
+	printConfig bool
+}
+
+// option is a single setting, which can be set using its key in the config file, its environment variable, or its
+// flag.
+type option struct {
+	// key is the setting's key in the config file, with nested sections separated by dots.
+	key string
+	// env is the environment variable which sets the option.
+	env   string
+	usage string
+	// secret options are redacted when the config is printed.
+	secret bool
+	value  flag.Value
+}
+
+// newOption returns an option with the environment variable derived from the key, such as REDIS_HOST for redis.host.
+func newOption(key string, usage string, value flag.Value) (o *option) {
+	return &option{
+		key:   key,
+		env:   strings.ToUpper(strings.ReplaceAll(key, ".", "_")),
+		usage: usage,
+		value: value,
+	}
+}
+
+// flagName returns the name of the option's flag, such as redis-keys-request-queue for redis.keys.request_queue.
+func (o *option) flagName() string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(o.key)
+}
+
+// isBool reports whether the option is a switch, which is enabled by an empty environment variable or a flag without a
+// value.
+func (o *option) isBool() bool {
+	_, ok := o.value.(boolValue)
+	return ok
+}
+
+// options returns every option, bound to the settings.
+func (s *settings) options() (options []*option) {
+	cfg := &s.cfg
+	secret := func(o *option) *option {
+		o.secret = true
+		return o
+	}
+	return []*option{
+		newOption("transport", `transport used for requests and responses, either "list" or "streams"`,
+			stringValue{&s.transport}),
+		newOption("namespaces", `namespaces to serve, as a comma-separated list of "name:weight", where the weight is `+
+			`optional, and ":weight" is the default namespace`, stringValue{&s.namespaces}),
+		newOption("durable", "keep retrying the initial redis connection instead of exiting on failure",
+			boolValue{&cfg.Durable}),
+		newOption("reliable_queue", "keep requests in a per-agent processing list until they're handled",
+			boolValue{&cfg.Redis.ReliableQueue}),
+		newOption("agent_id", "unique ID of this agent, generated from the hostname and process ID if empty",
+			stringValue{&cfg.Redis.AgentID}),
+		newOption("threads", "amount of worker threads", intValue{&cfg.Threads}),
+		newOption("publish_attempts", "maximum amount of times a response is published",
+			intValue{&cfg.PublishAttempts}),
+		newOption("error_retry_time", "how long to wait before retrying after a redis error",
+			durationValue{&cfg.ErrorRetryTime}),
+		newOption("min_response_key_length", "minimum length of the response key of requests",
+			intValue{&cfg.MinResponseKeyLength}),
+		newOption("shutdown_grace_period", "how long received requests are given to be handled when stopping",
+			durationValue{&cfg.ShutdownGracePeriod}),
+		newOption("metrics_address", "address to serve Prometheus metrics on, disabled if empty",
+			stringValue{&cfg.MetricsAddress}),
+		newOption("health_address", "address to serve the health checks on, disabled if empty",
+			stringValue{&cfg.HealthAddress}),
+		newOption("log.format", `log format, either "text" or "json"`, stringValue{&s.logFormat}),
+		newOption("log.level", "minimum log level, either debug, info, warn or error", stringValue{&s.logLevel}),
+		newOption("redis.host", "host and port of the redis server", stringValue{&cfg.Redis.Host}),
+		newOption("redis.tls", "use TLS for the redis connection", boolValue{&cfg.Redis.TLS}),
+		newOption("redis.username", "username for redis auth", stringValue{&cfg.Redis.Username}),
+		secret(newOption("redis.password", "password for redis auth", stringValue{&cfg.Redis.Password})),
+		newOption("redis.connection_timeout", "how long idle redis connections are kept",
+			durationValue{&cfg.Redis.ConnectionTimeout}),
+		newOption("redis.pop_timeout", "how long to block waiting for a request before checking for shutdown",
+			durationValue{&cfg.Redis.PopTimeout}),
+		newOption("redis.keys.request_queue", "key of the request queue", stringValue{&cfg.Redis.Keys.RequestQueue}),
+		newOption("redis.keys.bulk_request_queue", "key of the queue of bulk priority requests",
+			stringValue{&cfg.Redis.Keys.BulkRequestQueue}),
+		newOption("redis.keys.response_prefix", "prefix of the response keys",
+			stringValue{&cfg.Redis.Keys.ResponsePrefix}),
+		newOption("redis.keys.request_stream", "key of the request stream", stringValue{&cfg.Redis.Keys.RequestStream}),
+		newOption("redis.keys.bulk_request_stream", "key of the stream of bulk priority requests",
+			stringValue{&cfg.Redis.Keys.BulkRequestStream}),
+		newOption("redis.keys.consumer_group", "consumer group used to read the request stream",
+			stringValue{&cfg.Redis.Keys.ConsumerGroup}),
+		newOption("redis.keys.processing_list_prefix", "prefix of the processing lists used in reliable mode",
+			stringValue{&cfg.Redis.Keys.ProcessingListPrefix}),
+		newOption("redis.keys.agent_prefix", "prefix of the heartbeat keys which register each agent",
+			stringValue{&cfg.Redis.Keys.AgentPrefix}),
+		newOption("redis.keys.agent_registry", "key of the set of registered agent IDs",
+			stringValue{&cfg.Redis.Keys.AgentRegistry}),
+		newOption("pepper.id", "ID of the pepper key used for new hashes", stringValue{&s.pepperID}),
+		secret(newOption("pepper.keys", `pepper keys, as a comma-separated list of "id:base64key"`,
+			stringValue{&s.pepperKeys})),
+		newOption("envelope.id", "ID of the envelope key used for responses", stringValue{&s.envelopeID}),
+		secret(newOption("envelope.keys", `envelope keys, as a comma-separated list of "id:base64key"`,
+			stringValue{&s.envelopeKeys})),
+	}
+}
+
+// loadSettings reads the settings from the config file, the environment and the flags in args. Every problem with the
+// provided values is returned, rather than only the first. err is only returned if the flags can't be parsed, in
+// which case the problem and usage have already been written to output.
+func loadSettings(args []string, lookupEnv func(key string) (string, bool), output io.Writer) (s *settings,
+	problems []error, err error) {
+	return loadSettingsWithFlags(args, lookupEnv, output, nil)
+}
+
+// loadSettingsWithFlags is the same as loadSettings, but defineFlags is called to define a subcommand's own flags
+// alongside the settings, if it isn't nil.
+func loadSettingsWithFlags(args []string, lookupEnv func(key string) (string, bool), output io.Writer,
+	defineFlags func(flags *flag.FlagSet)) (s *settings, problems []error, err error) {
+	s = &settings{
+		cfg:       config.Default(),
+		transport: TransportList,
+		logFormat: config.DefaultLogFormat,
+		logLevel:  config.DefaultLogLevel,
+	}
+	options := s.options()
+
+	// Flags are only recorded while parsing, as they're applied last.
+	flagValues := make(map[string]string)
+	flags := flag.NewFlagSet("gocrypt", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.StringVar(&s.configFile, "config", "", "path of a YAML config file (env CONFIG_FILE)")
+	flags.BoolVar(&s.printConfig, "print-config", false, "print the config with secrets redacted, then exit")
+	for _, o := range options {
+		flags.Var(flagRecorder{o, flagValues}, o.flagName(), fmt.Sprintf("%s (env %s)", o.usage, o.env))
+	}
+	flags.Usage = func() {
+		fmt.Fprint(output, "Usage: gocrypt [flags]\n"+
+			"       gocrypt healthcheck [flags]\n"+
+			"       gocrypt agents [flags]\n\n"+
+			"Each setting can be set using a flag, an environment variable, or its key in the config file, which is\n"+
+			"the flag name with dots for sections and underscores for dashes, such as redis.keys.request_queue.\n"+
+			"Flags override environment variables, which override the config file.\n\n")
+		flags.PrintDefaults()
+	}
+	if defineFlags != nil {
+		defineFlags(flags)
+	}
+	err = flags.Parse(args)
+	if err != nil {
+		return nil, nil, err
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(output, "unexpected argument %q\n", flags.Arg(0))
+		flags.Usage()
+		return nil, nil, fmt.Errorf("unexpected argument %q", flags.Arg(0))
+	}
+
+	if s.configFile == "" {
+		s.configFile, _ = lookupEnv("CONFIG_FILE")
+	}
+	if s.configFile != "" {
+		problems = append(problems, s.applyConfigFile(options)...)
+	}
+	for _, o := range options {
+		value, ok := lookupEnv(o.env)
+		// Empty variables are ignored, other than switches, which are enabled by being set at all.
+		if !ok || (value == "" && !o.isBool()) {
+			continue
+		}
+		problems = appendProblem(problems, setOption(o, value, "environment variable "+o.env))
+	}
+	for _, o := range options {
+		if value, ok := flagValues[o.flagName()]; ok {
+			problems = appendProblem(problems, setOption(o, value, "flag -"+o.flagName()))
+		}
+	}
+	return s, problems, nil
+}
+
+// applyConfigFile sets the options found in the config file.
+func (s *settings) applyConfigFile(options []*option) (problems []error) {
+	values, err := readConfigFile(s.configFile)
+	if err != nil {
+		return []error{err}
+	}
+	optionsByKey := make(map[string]*option)
+	for _, o := range options {
+		optionsByKey[o.key] = o
+	}
+	// The keys are sorted so that problems are always reported in the same order.
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		o, ok := optionsByKey[key]
+		if !ok {
+			problems = append(problems, fmt.Errorf("unknown setting %q in %s", key, s.configFile))
+			continue
+		}
+		problems = appendProblem(problems, setOption(o, values[key], fmt.Sprintf("%s in %s", key, s.configFile)))
+	}
+	return problems
+}
+
+// setOption sets the option, and describes the problem along with where the value came from if it's invalid. Secret
+// values are left out of the problem.
+func setOption(o *option, value string, source string) (err error) {
+	err = o.value.Set(value)
+	if err == nil {
+		return nil
+	}
+	if o.secret {
+		return fmt.Errorf("invalid value for %s: %v", source, err)
+	}
+	return fmt.Errorf("invalid value %q for %s: %v", value, source, err)
+}
+
+func appendProblem(problems []error, err error) []error {
+	if err != nil {
+		return append(problems, err)
+	}
+	return problems
+}
+
+// agentConfig returns the agent config built from the settings. Every problem with the settings is returned, including
+// those found by config.Validate.
+func (s *settings) agentConfig() (cfg config.Config, problems []error) {
+	cfg = s.cfg
+
+	if s.transport != TransportList && s.transport != TransportStreams {
+		problems = append(problems, fmt.Errorf("transport should be either %q or %q, but is %q", TransportList,
+			TransportStreams, s.transport))
+	}
+	cfg.Redis.Streams = s.transport == TransportStreams
+
+	namespaces, err := parseNamespaces(s.namespaces)
+	cfg.Namespaces = namespaces
+	if err != nil {
+		problems = append(problems, err)
+	}
+
+	pepperKeys, err := parseKeys(s.pepperID, s.pepperKeys)
+	if err != nil {
+		problems = append(problems, fmt.Errorf("invalid pepper configuration: %v", err))
+	} else if pepperKeys != nil {
+		cfg.Peppers, err = hashAlgorithms.NewPeppers(s.pepperID, pepperKeys)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("invalid pepper configuration: %v", err))
+		}
+	}
+
+	envelopeKeys, err := parseKeys(s.envelopeID, s.envelopeKeys)
+	if err != nil {
+		problems = append(problems, fmt.Errorf("invalid envelope configuration: %v", err))
+	} else if envelopeKeys != nil {
+		cfg.Envelope, err = envelope.NewKeys(s.envelopeID, envelopeKeys)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("invalid envelope configuration: %v", err))
+		}
+	}
+
+	err = cfg.Validate()
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		problems = append(problems, joined.Unwrap()...)
+	} else if err != nil {
+		problems = append(problems, err)
+	}
+	return cfg, problems
+}
+
+// newLogger creates the logger configured by the settings.
+func (s *settings) newLogger(output io.Writer) (logger *slog.Logger, err error) {
+	level, err := logging.ParseLevel(s.logLevel)
+	if err != nil {
+		return nil, err
+	}
+	return logging.New(output, logging.Options{
+		Format:    s.logFormat,
+		Level:     level,
+		AddSource: config.VerboseLogging,
+		UTC:       config.UTCLogging,
+	})
+}
+
+// warnInsecure warns about settings which shouldn't be used in production.
+func warnInsecure(cfg config.Config, logger *slog.Logger) {
+	if !cfg.Redis.TLS {
+		logger.Warn("TLS not enabled. Remember to configure and use TLS for any production deployments!")
+	}
+	if cfg.Redis.Username == "" {
+		logger.Warn("Redis authentication not enabled. " +
+			"Remember to configure and use auth for any production deployments!")
+	}
+	if cfg.Envelope == nil {
+		logger.Warn("Envelopes not enabled. Password hashes will be readable by anyone with access to Redis.")
+	}
+}
+
+// parseNamespaces parses the comma-separated list of namespaces in "name:weight" format, where the weight is optional.
+// An empty name is the default namespace, so it must have a weight to tell it apart from a mistake. Nil is returned if
+// no namespaces are specified.
+func parseNamespaces(list string) (namespaces []config.Namespace, err error) {
+	if list == "" {
+		return nil, nil
+	}
+	for _, entry := range strings.Split(list, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 2)
+		namespace := config.Namespace{
+			Name:   parts[0],
+			Weight: 1,
+		}
+		if len(parts) == 1 && namespace.Name == "" {
+			return nil, errors.New(`namespaces can't be empty - use ":1" for the default namespace`)
+		}
+		if len(parts) == 2 {
+			namespace.Weight, err = strconv.Atoi(parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("weight of namespace %q should be a whole number, but is %q", namespace.Name,
+					parts[1])
+			}
+		}
+		namespaces = append(namespaces, namespace)
+	}
+	return namespaces, nil
+}
+
+// parseKeys parses the comma-separated list of keys in "id:base64key" format. Nil is returned if no keys are specified.
+func parseKeys(id string, keyList string) (keys map[string][]byte, err error) {
+	if keyList == "" {
+		if id != "" {
+			return nil, fmt.Errorf("the key ID is set, but no keys are specified")
+		}
+		return nil, nil
+	}
+
+	keys = make(map[string][]byte)
+	for _, entry := range strings.Split(keyList, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf(`keys should be in "id:base64key" format`)
+		}
+		key, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("key %q isn't valid base64: %v", parts[0], err)
+		}
+		keys[parts[0]] = key
+	}
+	return keys, nil
+}
+
+// flagRecorder records the value of a flag, so that it can be applied after the config file and environment.
+type flagRecorder struct {
+	option *option
+	values map[string]string
+}
+
+func (f flagRecorder) Set(value string) error {
+	f.values[f.option.flagName()] = value
+	return nil
+}
+
+func (f flagRecorder) String() string {
+	// The flag package calls String on a zero value to check whether the default is empty.
+	if f.option == nil || f.option.secret {
+		return ""
+	}
+	return f.option.value.String()
+}
+
+func (f flagRecorder) IsBoolFlag() bool {
+	return f.option != nil && f.option.isBool()
+}
+
+type stringValue struct{ p *string }
+
+func (v stringValue) Set(value string) error {
+	*v.p = value
+	return nil
+}
+
+func (v stringValue) String() string {
+	return *v.p
+}
+
+type intValue struct{ p *int }
+
+func (v intValue) Set(value string) error {
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return errors.New("should be a whole number")
+	}
+	*v.p = i
+	return nil
+}
+
+func (v intValue) String() string {
+	return strconv.Itoa(*v.p)
+}
+
+type boolValue struct{ p *bool }
+
+func (v boolValue) Set(value string) error {
+	if value == "" {
+		*v.p = true
+		return nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return errors.New("should be true or false")
+	}
+	*v.p = b
+	return nil
+}
+
+func (v boolValue) String() string {
+	return strconv.FormatBool(*v.p)
+}
+
+type durationValue struct{ p *time.Duration }
+
+func (v durationValue) Set(value string) error {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return errors.New(`should be a duration, such as "10s"`)
+	}
+	*v.p = d
+	return nil
+}
+
+func (v durationValue) String() string {
+	return v.p.String()
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rsheasby/gocrypt/gocrypt/config"
+	"github.com/rsheasby/gocrypt/gocrypt/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+// testEnv returns a lookup function for the environment variables.
+func testEnv(vars map[string]string) func(key string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := vars[key]
+		return value, ok
+	}
+}
+
+// writeConfigFile writes the contents to a config file in a temporary directory, and returns its path.
+func writeConfigFile(t *testing.T, contents string) (path string) {
+	path = filepath.Join(t.TempDir(), "gocrypt.yaml")
+	err := ioutil.WriteFile(path, []byte(contents), 0600)
+	assert.Nil(t, err, "The config file should be written")
+	return path
+}
+
+func TestLoadSettingsShouldApplyPrecedence(t *testing.T) {
+	path := writeConfigFile(t, `
+threads: 2
+publish_attempts: 3
+min_response_key_length: 8
+redis:
+  host: file:6379
+  pop_timeout: 5s
+  keys:
+    request_queue: file-queue
+pepper:
+  id: a
+  keys:
+    - a:YWFhYWFhYWFhYWFhYWFhYQ==
+    - b:YmJiYmJiYmJiYmJiYmJiYg==
+`)
+	env := map[string]string{
+		"CONFIG_FILE":      path,
+		"THREADS":          "4",
+		"REDIS_HOST":       "env:6379",
+		"REDIS_TLS":        "",
+		"PUBLISH_ATTEMPTS": "",
+	}
+	s, problems, err := loadSettings([]string{"-threads", "6", "-redis-keys-request-queue", "flag-queue"},
+		testEnv(env), ioutil.Discard)
+	assert.Nil(t, err, "The flags should be parsed")
+	assert.Empty(t, problems, "No problems should be found")
+
+	cfg, problems := s.agentConfig()
+	assert.Empty(t, problems, "No problems should be found")
+	assert.Equal(t, 6, cfg.Threads, "Flags should override the environment and the config file")
+	assert.Equal(t, "flag-queue", cfg.Redis.Keys.RequestQueue, "Flags should override the config file")
+	assert.Equal(t, "env:6379", cfg.Redis.Host, "The environment should override the config file")
+	assert.Equal(t, 3, cfg.PublishAttempts, "Empty environment variables should be ignored")
+	assert.True(t, cfg.Redis.TLS, "Empty environment variables should enable switches")
+	assert.Equal(t, 8, cfg.MinResponseKeyLength, "The config file should override the defaults")
+	assert.Equal(t, 5*time.Second, cfg.Redis.PopTimeout, "Durations should be read from the config file")
+	assert.NotNil(t, cfg.Peppers, "Lists in the config file should be read as comma-separated keys")
+	assert.Equal(t, config.Default().Redis.Keys.ResponsePrefix, cfg.Redis.Keys.ResponsePrefix,
+		"Unspecified settings should be left as the default")
+}
+
+func TestLoadSettingsShouldReportEveryProblem(t *testing.T) {
+	path := writeConfigFile(t, `
+threads: lots
+unknown: true
+redis:
+  pop_timeout: 100ms
+`)
+	s, problems, err := loadSettings([]string{"-config", path, "-publish-attempts", "0"},
+		testEnv(map[string]string{"TRANSPORT": "carrier-pigeon", "PEPPER_ID": "a", "REDIS_PASSWORD": "hunter2"}),
+		ioutil.Discard)
+	assert.Nil(t, err, "The flags should be parsed")
+	_, configProblems := s.agentConfig()
+	problems = append(problems, configProblems...)
+
+	var messages []string
+	for _, problem := range problems {
+		messages = append(messages, problem.Error())
+	}
+	all := strings.Join(messages, "\n")
+	for _, expected := range []string{`invalid value "lots" for threads`, `unknown setting "unknown"`,
+		"carrier-pigeon", "pepper", "redis host", "pop timeout", "publish attempts"} {
+		assert.Contains(t, all, expected, "Every problem should be reported")
+	}
+
+	_, problems, _ = loadSettings(nil, testEnv(map[string]string{"DURABLE": "maybe"}), ioutil.Discard)
+	assert.Len(t, problems, 1, "Invalid switches should be reported")
+}
+
+func TestLoadSettingsShouldRefuseInvalidFlags(t *testing.T) {
+	output := &bytes.Buffer{}
+	_, _, err := loadSettings([]string{"-no-such-flag"}, testEnv(nil), output)
+	assert.NotNil(t, err, "Unknown flags should be refused")
+	assert.Contains(t, output.String(), "Usage:", "The usage should be output")
+
+	_, _, err = loadSettings([]string{"extra"}, testEnv(nil), ioutil.Discard)
+	assert.NotNil(t, err, "Unexpected arguments should be refused")
+
+	_, _, err = loadSettings([]string{"-config", "/does/not/exist"}, testEnv(nil), ioutil.Discard)
+	assert.Nil(t, err, "Missing config files should be reported as a problem, rather than a usage error")
+}
+
+func TestPrintConfigShouldRedactSecrets(t *testing.T) {
+	s, _, _ := loadSettings([]string{"-redis-password", "hunter2", "-redis-host", "redis:6379"}, testEnv(nil),
+		ioutil.Discard)
+	output := &bytes.Buffer{}
+	assert.Nil(t, printConfig(output, s.options()), "The config should be printed")
+
+	assert.NotContains(t, output.String(), "hunter2", "Secrets should never be printed")
+	assert.Contains(t, output.String(), "password: '"+logging.Redacted+"'", "Secrets which are set should be redacted")
+	assert.Contains(t, output.String(), "host: redis:6379", "Settings should be printed")
+
+	// The printed config should be usable as a config file.
+	path := writeConfigFile(t, output.String())
+	reloaded, problems, _ := loadSettings([]string{"-config", path}, testEnv(nil), ioutil.Discard)
+	assert.Len(t, problems, 0, "The printed config should be a valid config file")
+	assert.Equal(t, s.cfg.Redis.Keys, reloaded.cfg.Redis.Keys, "The printed config should reload the same settings")
+}
+
+func TestParseNamespacesShouldParseWeights(t *testing.T) {
+	namespaces, err := parseNamespaces("staging, production:3, :2")
+	assert.Nil(t, err, "Valid namespaces should be parsed")
+	assert.Equal(t, []config.Namespace{{Name: "staging", Weight: 1}, {Name: "production", Weight: 3}, {Weight: 2}},
+		namespaces, "Weights should default to 1, and the empty name should be the default namespace")
+
+	_, err = parseNamespaces("staging,,production")
+	assert.NotNil(t, err, "Empty namespaces should be refused")
+	_, err = parseNamespaces("staging:heavy")
+	assert.NotNil(t, err, "Invalid weights should be refused")
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/rsheasby/gocrypt/gocrypt/config"
+	"github.com/rsheasby/gocrypt/gocrypt/logging"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+)
+
+// tracingShutdownTimeout specifies how long to wait for the remaining spans to be exported when the agent stops.
+const tracingShutdownTimeout = 5 * time.Second
+
+// startTracing sets up the agent to export its spans using OTLP over HTTP, if an endpoint is configured using the
+// standard OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT environment variables. The exporter reads
+// the rest of the OTEL_EXPORTER_OTLP_* variables itself. The returned function exports any remaining spans, and should
+// be called before exiting. The process exits if the exporter can't be created.
+func startTracing(cfg *config.Config, logger *slog.Logger) (stop func()) {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func() {}
+	}
+
+	ctx := context.Background()
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		fatal(logger, "Couldn't create trace exporter.", logging.Err(err))
+	}
+	// The service name can be overridden using OTEL_SERVICE_NAME or OTEL_RESOURCE_ATTRIBUTES.
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceNameKey.String("gocrypt")),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		fatal(logger, "Invalid trace resource attributes.", logging.Err(err))
+	}
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	cfg.TracerProvider = tracerProvider
+	logger.Info("Exporting traces using OTLP.")
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+		err := tracerProvider.Shutdown(ctx)
+		if err != nil {
+			logger.Warn("Couldn't export remaining traces.", logging.Err(err))
+		}
+	}
+}
@@ -1,10 +1,11 @@
-package redisHelpers
+package transportHelpers
 
 import (
 	"github.com/gomodule/redigo/redis"
 	"github.com/rafaeljusto/redigomock"
 )
 
+// MockPool is a redis pool using a mocked connection, which is used to test the agent with the redis transport.
 type MockPool struct {
 	Conn *redigomock.Conn
 }
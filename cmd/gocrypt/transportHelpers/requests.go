@@ -0,0 +1,89 @@
+package transportHelpers
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/rsheasby/gocrypt/gocrypt/config"
+	"github.com/rsheasby/gocrypt/gocrypt/logging"
+	"github.com/rsheasby/gocrypt/protocol"
+	"github.com/rsheasby/gocrypt/requestHandler"
+	"github.com/rsheasby/gocrypt/transport"
+)
+
+// ReceivedRequest is a request received from the transport. Depending on the transport, the request may be redelivered
+// to another agent if this agent dies before it's acknowledged using Ack.
+type ReceivedRequest struct {
+	*protocol.Request
+	delivery  transport.Delivery
+	transport transport.Transport
+}
+
+// Transport returns the transport which the request was received from, which the response must be published through,
+// as the agent may serve several namespaces. The fallback is returned if the request wasn't received from a transport.
+func (r *ReceivedRequest) Transport(fallback transport.Transport) (t transport.Transport) {
+	if r.transport == nil {
+		return fallback
+	}
+	return r.transport
+}
+
+// Ack acknowledges that the request has been handled. This does nothing if the request wasn't received from a
+// transport.
+func (r *ReceivedRequest) Ack(logger *slog.Logger) {
+	if r.delivery == nil {
+		return
+	}
+	err := r.delivery.Ack()
+	if err != nil {
+		// The request will be handled again if it's redelivered, which is acceptable as requests are handled at least
+		// once.
+		logger.Warn("Failed to acknowledge request.", logging.ResponseKey(r.ResponseKey), logging.Err(err))
+	}
+}
+
+// Requeue puts the request back onto the queue without handling it, so that it's received again by this or another
+// agent. This does nothing if the request wasn't received from a transport.
+func (r *ReceivedRequest) Requeue(logger *slog.Logger) {
+	if r.delivery == nil {
+		return
+	}
+	err := r.delivery.Requeue()
+	if err != nil {
+		// The request can still be redelivered if the transport supports it, otherwise the client will time out.
+		logger.Warn("Failed to requeue request.", logging.ResponseKey(r.ResponseKey), logging.Err(err))
+	}
+}
+
+// GetRequest retrieves a hash request from the transport. If no requests are currently in the queue, it blocks until
+// one is available. If the transport returns an error, it's logged and returned straight away, so the caller should
+// wait before retrying.
+func GetRequest(ctx context.Context, t transport.Transport, cfg *config.Config, logger *slog.Logger) (
+	request *ReceivedRequest, err error) {
+	for {
+		// A request can still be received after the context is cancelled, as some transports can't stop a receive
+		// that's already in progress. It's returned anyway, so that the caller can requeue it instead of it being lost.
+		delivery, err := t.ReceiveRequest(ctx)
+		if err != nil && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			logger.Error("Error receiving request.", logging.Err(err))
+			return nil, err
+		}
+		request = &ReceivedRequest{
+			Request:   &protocol.Request{},
+			delivery:  delivery,
+			transport: t,
+		}
+		req, err := requestHandler.OpenRequest(delivery.Body(), cfg.Envelope)
+		// The response key is inside the request, so there's no way to tell the client about this.
+		if err != nil {
+			logger.Warn("Refused request.", logging.Err(err))
+			request.Ack(logger)
+			continue
+		}
+		request.Request = req
+		return request, nil
+	}
+}
@@ -0,0 +1,77 @@
+package transportHelpers
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"math"
+	"testing"
+
+	"github.com/rsheasby/gocrypt/envelope"
+	"github.com/rsheasby/gocrypt/gocrypt/config"
+	"github.com/rsheasby/gocrypt/protocol"
+	"github.com/rsheasby/gocrypt/transport/memoryTransport"
+	"github.com/rsheasby/gocrypt/transport/redisTransport"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestGetRequestShouldOpenEnvelopesAndRefusePlainRequests(t *testing.T) {
+	keys, _ := envelope.NewKeys("1", map[string][]byte{"1": []byte("0123456789abcdef0123456789abcdef")})
+	cfg := config.Default()
+	cfg.Envelope = keys
+
+	req := &protocol.Request{
+		RequestType:     protocol.Request_HASHPASSWORD,
+		ResponseKey:     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		Password:        []byte("abc"),
+		Cost:            10,
+		ExpiryTimestamp: math.MaxInt64,
+	}
+	plainBytes, _ := proto.Marshal(req)
+	sealedBytes, _ := keys.SealRequest(plainBytes)
+
+	tr := memoryTransport.New()
+	_ = tr.SubmitRequest(plainBytes)
+	_ = tr.SubmitRequest(sealedBytes)
+
+	logBuffer := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(logBuffer, nil))
+
+	received, err := GetRequest(context.Background(), tr, &cfg, logger)
+	assert.Nil(t, err, "No error should be returned when receiving a sealed request")
+	assert.EqualValues(t, req.String(), received.String(), "Received request should be equal to the sealed request")
+	assert.Contains(t, logBuffer.String(), "Refused request", "Should log when a plain request is refused")
+}
+
+func TestGetRequestShouldAcknowledgeInvalidRequests(t *testing.T) {
+	req := &protocol.Request{
+		RequestType:     protocol.Request_HASHPASSWORD,
+		ResponseKey:     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		Password:        []byte("abc"),
+		Cost:            10,
+		ExpiryTimestamp: math.MaxInt64,
+	}
+	reqBytes, _ := proto.Marshal(req)
+	invalidBytes := []byte{0xff}
+
+	pool := NewMockPool()
+	tr := redisTransport.New(pool, redisTransport.WithReliableQueue(), redisTransport.WithAgentID("agent"))
+	pool.Conn.Command("LMOVE", redisTransport.RequestQueueKey, tr.ProcessingListKey("agent"), "RIGHT",
+		"LEFT").Expect(invalidBytes).Expect(reqBytes)
+	invalidAck := pool.Conn.Command("LREM", tr.ProcessingListKey("agent"), 1, invalidBytes).Expect(int64(1))
+	reqAck := pool.Conn.Command("LREM", tr.ProcessingListKey("agent"), 1, reqBytes).Expect(int64(1))
+	cfg := config.Default()
+
+	logBuffer := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(logBuffer, nil))
+
+	received, err := GetRequest(context.Background(), tr, &cfg, logger)
+	assert.Nil(t, err, "No error should be returned when receiving a request")
+	assert.EqualValues(t, req.String(), received.String(), "Received request should be equal to the queued request")
+	assert.True(t, invalidAck.Called, "Invalid requests should be acknowledged straight away")
+	assert.False(t, reqAck.Called, "Valid requests shouldn't be acknowledged until they've been handled")
+
+	received.Ack(logger)
+	assert.True(t, reqAck.Called, "Requests should be removed from the processing list once they're acknowledged")
+}
@@ -0,0 +1,70 @@
+package transportHelpers
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/rsheasby/gocrypt/gocrypt/config"
+	"github.com/rsheasby/gocrypt/gocrypt/logging"
+	"github.com/rsheasby/gocrypt/protocol"
+	"github.com/rsheasby/gocrypt/requestHandler"
+	"github.com/rsheasby/gocrypt/transport"
+)
+
+// PublishResponse publishes the provided response to the request via the transport, including automatic retry.
+func PublishResponse(res *protocol.Response, req *protocol.Request, t transport.Transport, cfg *config.Config,
+	logger *slog.Logger) {
+	publishResponse(res, req, cfg.PublishAttempts, t, cfg, logger)
+}
+
+// PublishError publishes an error response with the provided error code and message, so that the client doesn't have to
+// wait for its timeout to find out that its request failed. The response is published at most the specified amount of
+// times.
+func PublishError(code protocol.Response_ErrorCode, message string, req *protocol.Request, attempts int,
+	t transport.Transport, cfg *config.Config, logger *slog.Logger) {
+	res := &protocol.Response{
+		ErrorCode:    code,
+		ErrorMessage: message,
+	}
+	publishResponse(res, req, attempts, t, cfg, logger)
+}
+
+// publishResponse publishes the response on the request's response key, or wrapped on its response channel if it has
+// one.
+func publishResponse(res *protocol.Response, req *protocol.Request, attempts int, t transport.Transport,
+	cfg *config.Config, logger *slog.Logger) {
+	logger = logger.With(logging.ResponseKey(req.ResponseKey))
+	responseKey, resBytes, err := requestHandler.EncodeResponse(res, req, cfg.Envelope)
+	// This should never happen, but we'll check it for safety anyway
+	if err != nil {
+		logger.Error("Error publishing response: Failed to encode response.", logging.Err(err))
+		return
+	}
+
+	for i := 1; i <= attempts; i++ {
+		if i > 1 {
+			cfg.Metrics.PublishRetried()
+		}
+		delivered, err := t.PublishResponse(responseKey, resBytes)
+		if err != nil {
+			logger.Warn("Error publishing response.", logging.Err(err), logging.Attempt(i),
+				slog.Int("attempts", attempts))
+			if i < attempts {
+				time.Sleep(cfg.ErrorRetryTime)
+			}
+			continue
+		}
+		if !delivered {
+			cfg.Metrics.PublishUndelivered()
+			logger.Warn("Error publishing response: Published response wasn't received by any clients.",
+				logging.Attempt(i), slog.Int("attempts", attempts))
+			if i < attempts {
+				time.Sleep(cfg.ErrorRetryTime)
+			}
+			continue
+		}
+		return
+	}
+	logger.Error("Error publishing response: Unable to successfully publish response. Giving up.",
+		slog.Int("attempts", attempts))
+}
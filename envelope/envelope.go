@@ -0,0 +1,124 @@
+// Package envelope implements the optional encrypted envelope used between gocrypt clients and agents. Requests and
+// responses are encrypted and authenticated using XChaCha20-Poly1305 with a shared key, so that they can't be read or
+// forged by anyone with access to redis, regardless of whether redis TLS is used.
+//
+// Responses are authenticated together with their response key, so a response can't be replayed in reply to a
+// different request.
+package envelope
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/rsheasby/gocrypt/protocol"
+	"golang.org/x/crypto/chacha20poly1305"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// KeyLength is the required length of an envelope key in bytes.
+	KeyLength = chacha20poly1305.KeySize
+	// MaxKeyIDLength is the maximum length of an envelope key ID.
+	MaxKeyIDLength = 32
+
+	requestPrefix  = "gocrypt:request:"
+	responsePrefix = "gocrypt:response:"
+)
+
+// ErrUnverified is returned when an envelope can't be opened, either because it's been tampered with, it was sealed
+// with an unknown key, or it isn't an envelope at all.
+var ErrUnverified = errors.New("envelope couldn't be verified")
+
+// Keys holds the shared keys used to seal and open envelopes, identified by key IDs. New envelopes are sealed using the
+// current key, and envelopes are opened using the key matching the key ID in the envelope.
+type Keys struct {
+	currentID string
+	aeads     map[string]cipher.AEAD
+}
+
+// NewKeys returns Keys which seal envelopes using the key identified by currentID. The other keys are only used to open
+// envelopes. To rotate keys, add the new key to every client and agent first, and only then make it the current key.
+func NewKeys(currentID string, keys map[string][]byte) (k *Keys, err error) {
+	if _, ok := keys[currentID]; !ok {
+		return nil, fmt.Errorf("current envelope key ID %q doesn't match any of the provided keys", currentID)
+	}
+
+	k = &Keys{
+		currentID: currentID,
+		aeads:     make(map[string]cipher.AEAD, len(keys)),
+	}
+	for id, key := range keys {
+		if len(id) == 0 || len(id) > MaxKeyIDLength {
+			return nil, fmt.Errorf("envelope key ID %q is invalid - length must be between 1 and %d", id,
+				MaxKeyIDLength)
+		}
+		if len(key) != KeyLength {
+			return nil, fmt.Errorf("envelope key %q is invalid - should be %d bytes, but was %d bytes", id, KeyLength,
+				len(key))
+		}
+		k.aeads[id], err = chacha20poly1305.NewX(key)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't initialise envelope key %q: %v", id, err)
+		}
+	}
+	return k, nil
+}
+
+func (k *Keys) seal(data []byte, additionalData string) (sealed []byte, err error) {
+	nonce := make([]byte, chacha20poly1305.NonceSizeX)
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't generate envelope nonce: %v", err)
+	}
+
+	env := &protocol.Envelope{
+		KeyId:      k.currentID,
+		Nonce:      nonce,
+		Ciphertext: k.aeads[k.currentID].Seal(nil, nonce, data, []byte(additionalData+k.currentID)),
+	}
+	return proto.Marshal(env)
+}
+
+func (k *Keys) open(sealed []byte, additionalData string) (data []byte, err error) {
+	env := &protocol.Envelope{}
+	err = proto.Unmarshal(sealed, env)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnverified, err)
+	}
+
+	aead, ok := k.aeads[env.KeyId]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key ID %q", ErrUnverified, env.KeyId)
+	}
+	if len(env.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("%w: invalid nonce", ErrUnverified)
+	}
+	data, err = aead.Open(nil, env.Nonce, env.Ciphertext, []byte(additionalData+env.KeyId))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnverified, err)
+	}
+	return data, nil
+}
+
+// SealRequest encrypts a marshalled request using the current key.
+func (k *Keys) SealRequest(req []byte) (sealed []byte, err error) {
+	return k.seal(req, requestPrefix)
+}
+
+// OpenRequest decrypts and verifies a request sealed using SealRequest.
+func (k *Keys) OpenRequest(sealed []byte) (req []byte, err error) {
+	return k.open(sealed, requestPrefix)
+}
+
+// SealResponse encrypts a marshalled response using the current key. The response is bound to the response key, so it
+// can only be opened by the client waiting for that response key.
+func (k *Keys) SealResponse(responseKey string, res []byte) (sealed []byte, err error) {
+	return k.seal(res, responsePrefix+responseKey+":")
+}
+
+// OpenResponse decrypts and verifies a response sealed using SealResponse with the same response key.
+func (k *Keys) OpenResponse(responseKey string, sealed []byte) (res []byte, err error) {
+	return k.open(sealed, responsePrefix+responseKey+":")
+}
@@ -0,0 +1,89 @@
+package envelope
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	oldKey = []byte("0123456789abcdef0123456789abcdef")
+	newKey = []byte("fedcba9876543210fedcba9876543210")
+)
+
+func TestNewKeysShouldValidateKeys(t *testing.T) {
+	_, err := NewKeys("new", map[string][]byte{"old": oldKey})
+	assert.NotNil(t, err, "Should return an error when the current key ID doesn't exist")
+
+	_, err = NewKeys("new", map[string][]byte{"new": []byte("short")})
+	assert.NotNil(t, err, "Should return an error when a key is the wrong length")
+
+	_, err = NewKeys("", map[string][]byte{"": newKey})
+	assert.NotNil(t, err, "Should return an error when a key ID is empty")
+
+	_, err = NewKeys("new", map[string][]byte{"old": oldKey, "new": newKey})
+	assert.NoError(t, err, "Should not return an error with valid keys")
+}
+
+func TestKeysShouldSealAndOpenEnvelopes(t *testing.T) {
+	keys, _ := NewKeys("new", map[string][]byte{"new": newKey})
+	data := []byte("password")
+
+	sealed, err := keys.SealRequest(data)
+	assert.NoError(t, err, "Sealing a request shouldn't fail")
+	assert.NotContains(t, string(sealed), string(data), "Sealed request shouldn't contain the plaintext")
+
+	opened, err := keys.OpenRequest(sealed)
+	assert.NoError(t, err, "Opening a sealed request shouldn't fail")
+	assert.Equal(t, data, opened, "Opened request should match the original")
+
+	sealed, err = keys.SealResponse("responseKey", data)
+	assert.NoError(t, err, "Sealing a response shouldn't fail")
+
+	opened, err = keys.OpenResponse("responseKey", sealed)
+	assert.NoError(t, err, "Opening a sealed response shouldn't fail")
+	assert.Equal(t, data, opened, "Opened response should match the original")
+}
+
+func TestKeysShouldRefuseUnverifiedEnvelopes(t *testing.T) {
+	keys, _ := NewKeys("new", map[string][]byte{"new": newKey})
+	otherKeys, _ := NewKeys("new", map[string][]byte{"new": oldKey})
+	data := []byte("password")
+
+	sealedResponse, _ := keys.SealResponse("responseKey", data)
+	_, err := keys.OpenResponse("otherResponseKey", sealedResponse)
+	assert.True(t, errors.Is(err, ErrUnverified), "Responses shouldn't open with a different response key")
+
+	_, err = keys.OpenRequest(sealedResponse)
+	assert.True(t, errors.Is(err, ErrUnverified), "Responses shouldn't open as requests")
+
+	_, err = otherKeys.OpenResponse("responseKey", sealedResponse)
+	assert.True(t, errors.Is(err, ErrUnverified), "Responses shouldn't open with a different key")
+
+	tampered := append([]byte(nil), sealedResponse...)
+	tampered[len(tampered)-1] ^= 1
+	_, err = keys.OpenResponse("responseKey", tampered)
+	assert.True(t, errors.Is(err, ErrUnverified), "Tampered responses shouldn't open")
+
+	_, err = keys.OpenResponse("responseKey", data)
+	assert.True(t, errors.Is(err, ErrUnverified), "Plain responses shouldn't open")
+
+	unknownKeys, _ := NewKeys("unknown", map[string][]byte{"unknown": newKey})
+	sealedRequest, _ := unknownKeys.SealRequest(data)
+	_, err = keys.OpenRequest(sealedRequest)
+	assert.True(t, errors.Is(err, ErrUnverified), "Requests sealed with an unknown key ID shouldn't open")
+}
+
+func TestKeysShouldSupportRotation(t *testing.T) {
+	oldKeys, _ := NewKeys("old", map[string][]byte{"old": oldKey, "new": newKey})
+	newKeys, _ := NewKeys("new", map[string][]byte{"old": oldKey, "new": newKey})
+
+	sealed, _ := oldKeys.SealRequest([]byte("request"))
+	_, err := newKeys.OpenRequest(sealed)
+	assert.NoError(t, err, "Requests sealed with the old key should open once the key has been rotated")
+
+	sealed, _ = newKeys.SealResponse("responseKey", []byte("response"))
+	_, err = oldKeys.OpenResponse("responseKey", sealed)
+	assert.NoError(t, err, "Responses sealed with the new key should open before the key has been rotated")
+}
@@ -0,0 +1,126 @@
+package fallbackPasswordHasher
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rsheasby/gocrypt/remotePasswordHasher"
+)
+
+// State is the state of the circuit breaker which decides whether calls are sent to the agents.
+type State int
+
+const (
+	// StateClosed means that the agents are available, so calls are sent to them.
+	StateClosed State = iota
+	// StateOpen means that the agents are unavailable, so calls are handled locally until the cooldown has passed.
+	StateOpen
+	// StateHalfOpen means that the cooldown has passed, so a single trial call is sent to the agents to check whether
+	// they're back, while the rest are still handled locally.
+	StateHalfOpen
+)
+
+// String returns the name of the state, such as "open".
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+// breaker is a circuit breaker which opens once enough calls in a row have failed because the agents seem to be
+// unavailable.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+	observer  Observer
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	// trialRunning is set while the trial call is being made in the half open state.
+	trialRunning bool
+}
+
+// allow returns whether the call should be sent to the agents.
+func (b *breaker) allow() (allowed bool) {
+	b.mu.Lock()
+	previous := b.state
+	switch b.state {
+	case StateClosed:
+		allowed = true
+	case StateOpen:
+		if time.Since(b.openedAt) >= b.cooldown {
+			b.state = StateHalfOpen
+			b.trialRunning = true
+			allowed = true
+		}
+	case StateHalfOpen:
+		if !b.trialRunning {
+			b.trialRunning = true
+			allowed = true
+		}
+	}
+	state := b.state
+	b.mu.Unlock()
+
+	if state != previous {
+		b.observer.BreakerChanged(state)
+	}
+	return allowed
+}
+
+// record updates the breaker with the result of a call which was sent to the agents. Calls which were cancelled by the
+// caller say nothing about the agents, so they're ignored, apart from letting another trial call through.
+func (b *breaker) record(ctx context.Context, err error) {
+	b.mu.Lock()
+	previous := b.state
+	if b.state == StateHalfOpen {
+		b.trialRunning = false
+	}
+	switch {
+	case ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+	case isUnavailable(err):
+		b.failures++
+		if b.state == StateHalfOpen || b.failures >= b.threshold {
+			b.state = StateOpen
+			b.openedAt = time.Now()
+		}
+	default:
+		b.failures = 0
+		b.state = StateClosed
+	}
+	state := b.state
+	b.mu.Unlock()
+
+	if state != previous {
+		b.observer.BreakerChanged(state)
+	}
+}
+
+// currentState returns the state of the breaker.
+func (b *breaker) currentState() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// isUnavailable returns whether the error means that the agents may be unavailable, such as a timeout or a transport
+// error, rather than a problem with the request itself.
+func isUnavailable(err error) bool {
+	switch remotePasswordHasher.Outcome(err) {
+	case "timeout", "expired", "error":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,164 @@
+// Package fallbackPasswordHasher provides a PasswordHasher which uses the gocrypt agents while they're available, and
+// falls back to hashing locally while they aren't, so that logins keep working during an agent outage.
+package fallbackPasswordHasher
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/rsheasby/gocrypt"
+)
+
+const (
+	// DefaultFailureThreshold specifies how many calls in a row must fail because the agents seem to be unavailable
+	// before the circuit breaker opens.
+	DefaultFailureThreshold = 3
+	// DefaultCooldown specifies how long the circuit breaker stays open before checking whether the agents are back.
+	DefaultCooldown = 10 * time.Second
+)
+
+// Hasher is a password hasher which can be wrapped by the FallbackPasswordHasher. It's implemented by both the
+// RemotePasswordHasher and the LocalPasswordHasher.
+type Hasher interface {
+	gocrypt.PasswordHasherContext
+	// ValidateAndRehashContext is the same as ValidateAndRehash, but honours the context's deadline and cancellation.
+	ValidateAndRehashContext(ctx context.Context, password string, hash string) (isValid bool, newHash string,
+		err error)
+}
+
+// FallbackPasswordHasher sends calls to a remote hasher, and handles them using a local hasher instead while the agents
+// seem to be unavailable. A call which fails because of a timeout or transport error is retried locally straight away.
+// Once enough calls in a row have failed, a circuit breaker opens, and calls are handled locally without trying the
+// agents, until a trial call after the cooldown succeeds.
+type FallbackPasswordHasher struct {
+	remote     Hasher
+	local      Hasher
+	breaker    *breaker
+	localLimit int
+	localSlots chan struct{}
+	observer   Observer
+}
+
+var (
+	_ gocrypt.PasswordRehasher      = (*FallbackPasswordHasher)(nil)
+	_ gocrypt.PasswordHasherContext = (*FallbackPasswordHasher)(nil)
+)
+
+// New returns a FallbackPasswordHasher which uses the remote hasher while the agents are available, and the local
+// hasher otherwise. Hashes made by either hasher must be interchangeable, so the local hasher must use the same
+// algorithm and parameters as the remote hasher, as well as the agents' peppers if they have any.
+func New(remote Hasher, local Hasher, opts ...Option) (f *FallbackPasswordHasher, err error) {
+	if remote == nil || local == nil {
+		return nil, fmt.Errorf("remote and local hashers cannot be nil")
+	}
+	f = &FallbackPasswordHasher{
+		remote: remote,
+		local:  local,
+		breaker: &breaker{
+			threshold: DefaultFailureThreshold,
+			cooldown:  DefaultCooldown,
+		},
+		localLimit: runtime.NumCPU(),
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	if f.breaker.threshold < 1 {
+		return nil, fmt.Errorf("failure threshold must be at least 1")
+	}
+	if f.localLimit < 1 {
+		return nil, fmt.Errorf("local concurrency must be at least 1")
+	}
+	if f.observer == nil {
+		f.observer = nopObserver{}
+	}
+	f.breaker.observer = f.observer
+	f.localSlots = make(chan struct{}, f.localLimit)
+	return f, nil
+}
+
+// State returns the state of the circuit breaker.
+func (f *FallbackPasswordHasher) State() State {
+	return f.breaker.currentState()
+}
+
+// call makes the call using the remote hasher, unless the circuit breaker is open, and falls back to the local hasher
+// if the agents seem to be unavailable. The path which served the call is reported to the observer.
+func (f *FallbackPasswordHasher) call(ctx context.Context, fn func(h Hasher) error) (err error) {
+	path := PathRemote
+	defer func() {
+		f.observer.CallServed(ctx, path, err)
+	}()
+
+	if f.breaker.allow() {
+		err = fn(f.remote)
+		f.breaker.record(ctx, err)
+		if !isUnavailable(err) || ctx.Err() != nil {
+			return err
+		}
+	}
+	path = PathLocal
+	return f.callLocally(ctx, fn)
+}
+
+// callLocally makes the call using the local hasher, once fewer than the local concurrency limit are running.
+func (f *FallbackPasswordHasher) callLocally(ctx context.Context, fn func(h Hasher) error) (err error) {
+	select {
+	case f.localSlots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() {
+		<-f.localSlots
+	}()
+	return fn(f.local)
+}
+
+// HashPassword hashes the provided password using the agents, or locally if they're unavailable.
+func (f *FallbackPasswordHasher) HashPassword(password string) (hash string, err error) {
+	return f.HashPasswordContext(context.Background(), password)
+}
+
+// HashPasswordContext is the same as HashPassword, but honours the context's deadline and cancellation.
+func (f *FallbackPasswordHasher) HashPasswordContext(ctx context.Context, password string) (hash string, err error) {
+	err = f.call(ctx, func(h Hasher) (err error) {
+		hash, err = h.HashPasswordContext(ctx, password)
+		return err
+	})
+	return hash, err
+}
+
+// ValidatePassword validates the password against the provided password hash using the agents, or locally if they're
+// unavailable.
+func (f *FallbackPasswordHasher) ValidatePassword(password string, hash string) (isValid bool, err error) {
+	return f.ValidatePasswordContext(context.Background(), password, hash)
+}
+
+// ValidatePasswordContext is the same as ValidatePassword, but honours the context's deadline and cancellation.
+func (f *FallbackPasswordHasher) ValidatePasswordContext(ctx context.Context, password string, hash string) (
+	isValid bool, err error) {
+	err = f.call(ctx, func(h Hasher) (err error) {
+		isValid, err = h.ValidatePasswordContext(ctx, password, hash)
+		return err
+	})
+	return isValid, err
+}
+
+// ValidateAndRehash validates the password against the provided password hash, and returns a new hash if it's outdated,
+// using the agents, or locally if they're unavailable.
+func (f *FallbackPasswordHasher) ValidateAndRehash(password string, hash string) (isValid bool, newHash string,
+	err error) {
+	return f.ValidateAndRehashContext(context.Background(), password, hash)
+}
+
+// ValidateAndRehashContext is the same as ValidateAndRehash, but honours the context's deadline and cancellation.
+func (f *FallbackPasswordHasher) ValidateAndRehashContext(ctx context.Context, password string, hash string) (
+	isValid bool, newHash string, err error) {
+	err = f.call(ctx, func(h Hasher) (err error) {
+		isValid, newHash, err = h.ValidateAndRehashContext(ctx, password, hash)
+		return err
+	})
+	return isValid, newHash, err
+}
@@ -0,0 +1,204 @@
+package fallbackPasswordHasher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rsheasby/gocrypt/localPasswordHasher"
+	"github.com/rsheasby/gocrypt/remotePasswordHasher"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	_ Hasher = (*remotePasswordHasher.RemotePasswordHasher)(nil)
+	_ Hasher = (*localPasswordHasher.LocalPasswordHasher)(nil)
+)
+
+// fakeHasher hashes locally, but fails with err if it's set, and blocks until release is closed if it's set, so that
+// it can stand in for either hasher.
+type fakeHasher struct {
+	*localPasswordHasher.LocalPasswordHasher
+	release chan struct{}
+
+	mu    sync.Mutex
+	err   error
+	calls int
+}
+
+func newFakeHasher() (h *fakeHasher) {
+	lph, _ := localPasswordHasher.New(bcrypt.MinCost)
+	return &fakeHasher{LocalPasswordHasher: lph}
+}
+
+func (h *fakeHasher) setErr(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.err = err
+}
+
+func (h *fakeHasher) callCount() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.calls
+}
+
+func (h *fakeHasher) HashPasswordContext(ctx context.Context, password string) (hash string, err error) {
+	h.mu.Lock()
+	h.calls++
+	err = h.err
+	h.mu.Unlock()
+	if h.release != nil {
+		<-h.release
+	}
+	if err != nil {
+		return "", err
+	}
+	return h.LocalPasswordHasher.HashPasswordContext(ctx, password)
+}
+
+// recordingObserver records the paths and breaker states it's told about.
+type recordingObserver struct {
+	mu     sync.Mutex
+	paths  []Path
+	states []State
+}
+
+func (o *recordingObserver) CallServed(_ context.Context, path Path, _ error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.paths = append(o.paths, path)
+}
+
+func (o *recordingObserver) BreakerChanged(state State) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.states = append(o.states, state)
+}
+
+func TestFallbackPasswordHasherShouldUseTheAgentsWhileTheyAreAvailable(t *testing.T) {
+	remote, local := newFakeHasher(), newFakeHasher()
+	observer := &recordingObserver{}
+	fph, err := New(remote, local, WithObserver(observer))
+	assert.Nil(t, err, "No error should be returned when creating a fallback hasher")
+
+	hash, err := fph.HashPassword("abc")
+	assert.Nil(t, err, "No error should be returned when the agents are available")
+	isValid, err := fph.ValidatePassword("abc", hash)
+	assert.Nil(t, err, "No error should be returned when validating")
+	assert.True(t, isValid, "The hash should be valid")
+
+	assert.Equal(t, 1, remote.callCount(), "The remote hasher should be used")
+	assert.Equal(t, 0, local.callCount(), "The local hasher shouldn't be used")
+	assert.Equal(t, []Path{PathRemote, PathRemote}, observer.paths, "Both calls should be reported as remote")
+	assert.Equal(t, StateClosed, fph.State(), "The breaker should stay closed")
+
+	// Errors about the request itself are returned as they are, and don't count as the agents being unavailable.
+	remote.setErr(fmt.Errorf("%w: bad hash", remotePasswordHasher.ErrInvalidHash))
+	for i := 0; i < DefaultFailureThreshold; i++ {
+		_, err = fph.HashPassword("abc")
+		assert.True(t, errors.Is(err, remotePasswordHasher.ErrInvalidHash), "Request errors should be returned")
+	}
+	assert.Equal(t, 0, local.callCount(), "Request errors shouldn't fall back to the local hasher")
+	assert.Equal(t, StateClosed, fph.State(), "Request errors shouldn't open the breaker")
+}
+
+func TestFallbackPasswordHasherShouldFallBackAndRecoverWhenTheAgentsAreUnavailable(t *testing.T) {
+	remote, local := newFakeHasher(), newFakeHasher()
+	observer := &recordingObserver{}
+	fph, _ := New(remote, local, WithObserver(observer), WithFailureThreshold(2), WithCooldown(100*time.Millisecond))
+
+	remote.setErr(remotePasswordHasher.ErrTimeout)
+	for i := 0; i < 4; i++ {
+		hash, err := fph.HashPassword("abc")
+		assert.Nil(t, err, "Calls should be handled locally when the agents time out")
+		isValid, _ := local.ValidatePassword("abc", hash)
+		assert.True(t, isValid, "The locally made hash should be valid")
+	}
+	assert.Equal(t, 2, remote.callCount(), "The agents shouldn't be tried once the breaker is open")
+	assert.Equal(t, 4, local.callCount(), "Every call should be handled locally")
+	assert.Equal(t, StateOpen, fph.State(), "The breaker should open after the failure threshold")
+	assert.Equal(t, []Path{PathLocal, PathLocal, PathLocal, PathLocal}, observer.paths,
+		"Every call should be reported as local")
+
+	// After the cooldown, a failed trial call opens the breaker again.
+	time.Sleep(150 * time.Millisecond)
+	_, _ = fph.HashPassword("abc")
+	assert.Equal(t, 3, remote.callCount(), "A trial call should be sent to the agents after the cooldown")
+	assert.Equal(t, StateOpen, fph.State(), "A failed trial call should open the breaker again")
+
+	// Once the agents are back, a successful trial call closes the breaker.
+	remote.setErr(nil)
+	time.Sleep(150 * time.Millisecond)
+	_, err := fph.HashPassword("abc")
+	assert.Nil(t, err, "No error should be returned once the agents are back")
+	assert.Equal(t, 4, remote.callCount(), "A trial call should be sent to the agents after the cooldown")
+	assert.Equal(t, StateClosed, fph.State(), "A successful trial call should close the breaker")
+	assert.Equal(t, []State{StateOpen, StateHalfOpen, StateOpen, StateHalfOpen, StateClosed}, observer.states,
+		"Every state change should be reported")
+}
+
+func TestFallbackPasswordHasherShouldOnlySendOneTrialCall(t *testing.T) {
+	remote, local := newFakeHasher(), newFakeHasher()
+	remote.release = make(chan struct{})
+	fph, _ := New(remote, local, WithFailureThreshold(1), WithCooldown(0))
+	fph.breaker.state = StateOpen
+
+	trialDone := make(chan struct{})
+	go func() {
+		_, _ = fph.HashPassword("abc")
+		close(trialDone)
+	}()
+	for remote.callCount() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	_, err := fph.HashPassword("abc")
+	assert.Nil(t, err, "Calls made during the trial call should be handled locally")
+	assert.Equal(t, 1, local.callCount(), "Calls made during the trial call should be handled locally")
+	assert.Equal(t, StateHalfOpen, fph.State(), "The breaker should be half open during the trial call")
+
+	close(remote.release)
+	<-trialDone
+	assert.Equal(t, StateClosed, fph.State(), "A successful trial call should close the breaker")
+}
+
+func TestFallbackPasswordHasherShouldLimitLocalConcurrency(t *testing.T) {
+	remote, local := newFakeHasher(), newFakeHasher()
+	remote.setErr(errors.New("connection refused"))
+	local.release = make(chan struct{})
+	fph, _ := New(remote, local, WithLocalConcurrency(1))
+
+	done := make(chan struct{})
+	go func() {
+		_, _ = fph.HashPassword("abc")
+		close(done)
+	}()
+	for local.callCount() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := fph.HashPasswordContext(ctx, "abc")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded),
+		"Calls should wait for a local slot until the context is done")
+	assert.Equal(t, 1, local.callCount(), "Only one call should be handled locally at once")
+
+	close(local.release)
+	<-done
+}
+
+func TestNewShouldRefuseInvalidOptions(t *testing.T) {
+	remote, local := newFakeHasher(), newFakeHasher()
+	_, err := New(nil, local)
+	assert.Error(t, err, "A nil remote hasher should be refused")
+	_, err = New(remote, local, WithFailureThreshold(0))
+	assert.Error(t, err, "A failure threshold below 1 should be refused")
+	_, err = New(remote, local, WithLocalConcurrency(0))
+	assert.Error(t, err, "A local concurrency below 1 should be refused")
+}
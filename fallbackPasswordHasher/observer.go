@@ -0,0 +1,31 @@
+package fallbackPasswordHasher
+
+import "context"
+
+// Path identifies which hasher served a call.
+type Path string
+
+const (
+	// PathRemote means that the call was served by the agents, using the remote hasher.
+	PathRemote Path = "remote"
+	// PathLocal means that the call was served by the local hasher, as the agents seemed to be unavailable.
+	PathLocal Path = "local"
+)
+
+// Observer is told which path served each call, and when the circuit breaker changes state, so that fallbacks can be
+// recorded in metrics or logged. The methods are called on the goroutine making the call, so they shouldn't block.
+type Observer interface {
+	// CallServed is called once each call has finished, with the path which served it and the error returned to the
+	// caller, if any. Calls which fell back to the local hasher after the agents failed are reported as PathLocal.
+	CallServed(ctx context.Context, path Path, err error)
+	// BreakerChanged is called whenever the circuit breaker changes state, such as when it opens because the agents
+	// seem to be unavailable.
+	BreakerChanged(state State)
+}
+
+// nopObserver is used when no Observer is specified.
+type nopObserver struct{}
+
+func (nopObserver) CallServed(context.Context, Path, error) {}
+
+func (nopObserver) BreakerChanged(State) {}
@@ -0,0 +1,37 @@
+package fallbackPasswordHasher
+
+import "time"
+
+// Option configures optional settings for a FallbackPasswordHasher.
+type Option func(f *FallbackPasswordHasher)
+
+// WithFailureThreshold sets how many calls in a row must fail because the agents seem to be unavailable before the
+// circuit breaker opens, after which calls are handled locally straight away. The default is DefaultFailureThreshold.
+func WithFailureThreshold(threshold int) Option {
+	return func(f *FallbackPasswordHasher) {
+		f.breaker.threshold = threshold
+	}
+}
+
+// WithCooldown sets how long the circuit breaker stays open before a trial call is sent to the agents to check whether
+// they're back. The default is DefaultCooldown.
+func WithCooldown(cooldown time.Duration) Option {
+	return func(f *FallbackPasswordHasher) {
+		f.breaker.cooldown = cooldown
+	}
+}
+
+// WithLocalConcurrency sets how many calls can be handled locally at once. Further calls wait until one of them is
+// done, so that an agent outage doesn't use up every CPU of the backend. The default is the number of CPUs.
+func WithLocalConcurrency(limit int) Option {
+	return func(f *FallbackPasswordHasher) {
+		f.localLimit = limit
+	}
+}
+
+// WithObserver sets an Observer which is told which path served each call, and when the circuit breaker changes state.
+func WithObserver(o Observer) Option {
+	return func(f *FallbackPasswordHasher) {
+		f.observer = o
+	}
+}
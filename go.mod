@@ -5,7 +5,11 @@ go 1.15
 require (
 	github.com/gomodule/redigo v1.8.3
 	github.com/google/uuid v1.1.2
-	github.com/stretchr/testify v1.5.1
+	github.com/rafaeljusto/redigomock v2.4.0+incompatible
+	github.com/stretchr/testify v1.7.0
+	go.opentelemetry.io/otel v1.0.1
+	go.opentelemetry.io/otel/sdk v1.0.1
+	go.opentelemetry.io/otel/trace v1.0.1
 	golang.org/x/crypto v0.0.0-20190308221718-c2843e01d9a2
 	google.golang.org/protobuf v1.25.0
 )
@@ -0,0 +1,64 @@
+package hashAlgorithms
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const argon2idPrefix = "$argon2id$"
+
+func hashArgon2id(password []byte, params Params) (hash string, err error) {
+	salt, err := generateSalt()
+	if err != nil {
+		return "", err
+	}
+	key := argon2.IDKey(password, salt, params.Iterations, params.Memory, uint8(params.Parallelism), keyLength)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version, params.Memory,
+		params.Iterations, params.Parallelism, phcEncoding.EncodeToString(salt), phcEncoding.EncodeToString(key)), nil
+}
+
+func compareArgon2id(password []byte, hash string) (isValid bool, err error) {
+	params, salt, key, err := parseArgon2id(hash)
+	if err != nil {
+		return false, err
+	}
+	otherKey := argon2.IDKey(password, salt, params.Iterations, params.Memory, uint8(params.Parallelism),
+		uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, otherKey) == 1, nil
+}
+
+// parseArgon2id parses a hash in the "$argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>" format.
+func parseArgon2id(hash string) (params Params, salt []byte, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return Params{}, nil, nil, fmt.Errorf("%w: argon2id hash should have 6 sections", ErrInvalidHash)
+	}
+	if parts[2] != fmt.Sprintf("v=%d", argon2.Version) {
+		return Params{}, nil, nil, fmt.Errorf("%w: unsupported argon2id version %q", ErrInvalidHash, parts[2])
+	}
+
+	values, err := parsePHCParams(parts[3], "m", "t", "p")
+	if err != nil {
+		return Params{}, nil, nil, err
+	}
+	params = Params{
+		Algorithm:   Argon2id,
+		Memory:      values["m"],
+		Iterations:  values["t"],
+		Parallelism: values["p"],
+	}
+	// Validating the parameters stops a malicious hash from using excessive resources.
+	err = params.Validate()
+	if err != nil {
+		return Params{}, nil, nil, fmt.Errorf("%w: %v", ErrInvalidHash, err)
+	}
+
+	salt, key, err = decodeSaltAndKey(parts[4], parts[5])
+	if err != nil {
+		return Params{}, nil, nil, err
+	}
+	return params, salt, key, nil
+}
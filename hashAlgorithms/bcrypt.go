@@ -0,0 +1,31 @@
+package hashAlgorithms
+
+import (
+	"golang.org/x/crypto/bcrypt"
+)
+
+func hashBcrypt(password []byte, params Params) (hash string, err error) {
+	hashBytes, err := bcrypt.GenerateFromPassword(password, params.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashBytes), nil
+}
+
+func compareBcrypt(password []byte, hash string) (isValid bool, err error) {
+	err = bcrypt.CompareHashAndPassword([]byte(hash), password)
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func parseBcrypt(hash string) (params Params, err error) {
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return Params{}, err
+	}
+	return Params{Algorithm: Bcrypt, Cost: cost}, nil
+}
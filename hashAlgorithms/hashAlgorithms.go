@@ -0,0 +1,110 @@
+// Package hashAlgorithms implements the password hashing algorithms supported by gocrypt, and is shared by the local
+// hasher and the gocrypt agent so that both produce and accept exactly the same hashes.
+//
+// The password provided to these functions should already be pre-hashed with SHA-512, which is how gocrypt allows
+// arbitrary password lengths regardless of the algorithm.
+package hashAlgorithms
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrInvalidHash is returned when a hash can't be parsed.
+var ErrInvalidHash = errors.New("invalid hash")
+
+// Hash hashes the password using the algorithm and parameters specified. Bcrypt hashes are returned in unix "$2a"
+// encoding, and Argon2id and scrypt hashes are returned in PHC string format.
+func Hash(password []byte, params Params) (hash string, err error) {
+	err = params.Validate()
+	if err != nil {
+		return "", err
+	}
+
+	switch params.Algorithm {
+	case Argon2id:
+		return hashArgon2id(password, params)
+	case Scrypt:
+		return hashScrypt(password, params)
+	default:
+		return hashBcrypt(password, params)
+	}
+}
+
+// Compare takes a password and a hash, and returns if the password is valid. The algorithm is detected from the hash's
+// prefix, so hashes from any of the supported algorithms can be validated.
+func Compare(password []byte, hash string) (isValid bool, err error) {
+	algorithm, err := Identify(hash)
+	if err != nil {
+		return false, err
+	}
+
+	switch algorithm {
+	case Argon2id:
+		return compareArgon2id(password, hash)
+	case Scrypt:
+		return compareScrypt(password, hash)
+	default:
+		return compareBcrypt(password, hash)
+	}
+}
+
+// NeedsRehash takes a hash and the desired parameters, and returns if the hash should be replaced. This is the case if
+// the hash uses a different algorithm or a legacy format, or any of its parameters are lower than the desired
+// parameters.
+func NeedsRehash(hash string, params Params) (needsRehash bool, err error) {
+	if IsLegacy(hash) {
+		return true, nil
+	}
+	hashParams, err := ParseParams(hash)
+	if err != nil {
+		return false, err
+	}
+	if hashParams.Algorithm != params.Algorithm {
+		return true, nil
+	}
+
+	switch params.Algorithm {
+	case Argon2id:
+		return hashParams.Memory < params.Memory || hashParams.Iterations < params.Iterations ||
+			hashParams.Parallelism < params.Parallelism, nil
+	case Scrypt:
+		return hashParams.Cost < params.Cost || hashParams.Parallelism < params.Parallelism, nil
+	default:
+		return hashParams.Cost < params.Cost, nil
+	}
+}
+
+// Identify returns the algorithm used by the hash, based on its prefix.
+func Identify(hash string) (algorithm Algorithm, err error) {
+	switch {
+	case strings.HasPrefix(hash, argon2idPrefix):
+		return Argon2id, nil
+	case strings.HasPrefix(hash, scryptPrefix):
+		return Scrypt, nil
+	case strings.HasPrefix(hash, "$2"):
+		return Bcrypt, nil
+	default:
+		return 0, ErrInvalidHash
+	}
+}
+
+// ParseParams returns the algorithm and parameters used by the hash. Peppered hashes are parsed using their underlying
+// hash, as peppering doesn't change the parameters.
+func ParseParams(hash string) (params Params, err error) {
+	_, hash, _ = splitPeppered(hash)
+	algorithm, err := Identify(hash)
+	if err != nil {
+		return Params{}, err
+	}
+
+	switch algorithm {
+	case Argon2id:
+		params, _, _, err = parseArgon2id(hash)
+	case Scrypt:
+		params, _, _, err = parseScrypt(hash)
+	default:
+		params, err = parseBcrypt(hash)
+	}
+	return params, err
+}
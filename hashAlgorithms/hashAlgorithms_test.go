@@ -0,0 +1,148 @@
+package hashAlgorithms
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testParams = []Params{
+	{Algorithm: Bcrypt, Cost: 4},
+	{Algorithm: Argon2id, Memory: 64, Iterations: 1, Parallelism: 2},
+	{Algorithm: Scrypt, Cost: MinScryptCost, Parallelism: 1},
+}
+
+func TestHashShouldProduceHashesThatValidate(t *testing.T) {
+	for _, params := range testParams {
+		hash, err := Hash([]byte("password"), params)
+		assert.NoError(t, err, "Hashing shouldn't fail with valid parameters for %s", params.Algorithm)
+
+		algorithm, err := Identify(hash)
+		assert.NoError(t, err, "Generated %s hash should be identified", params.Algorithm)
+		assert.Equal(t, params.Algorithm, algorithm, "Generated hash should be identified as %s", params.Algorithm)
+
+		isValid, err := Compare([]byte("password"), hash)
+		assert.NoError(t, err, "Comparing a valid %s hash shouldn't fail", params.Algorithm)
+		assert.True(t, isValid, "Correct password should validate with %s", params.Algorithm)
+
+		isValid, err = Compare([]byte("Password"), hash)
+		assert.NoError(t, err, "Comparing a valid %s hash shouldn't fail", params.Algorithm)
+		assert.False(t, isValid, "Incorrect password shouldn't validate with %s", params.Algorithm)
+
+		parsedParams, err := ParseParams(hash)
+		assert.NoError(t, err, "Parsing a valid %s hash shouldn't fail", params.Algorithm)
+		assert.Equal(t, params, parsedParams, "Parsed parameters should match the parameters used for %s", params.Algorithm)
+	}
+}
+
+func TestHashShouldProducePHCStrings(t *testing.T) {
+	hash, _ := Hash([]byte("password"), Params{Algorithm: Argon2id, Memory: 64, Iterations: 1, Parallelism: 2})
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=2$"), "Argon2id hash should be in PHC format")
+
+	hash, _ = Hash([]byte("password"), Params{Algorithm: Scrypt, Cost: MinScryptCost, Parallelism: 1})
+	assert.True(t, strings.HasPrefix(hash, "$scrypt$ln=10,r=8,p=1$"), "Scrypt hash should be in PHC format")
+}
+
+func TestCompareShouldValidateKnownHashes(t *testing.T) {
+	// Test vector from the argon2 reference implementation
+	isValid, err := Compare([]byte("password"), "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc")
+	assert.NoError(t, err, "Comparing a valid argon2id hash shouldn't fail")
+	assert.True(t, isValid, "Correct password should validate with a known argon2id hash")
+
+	isValid, err = Compare([]byte("password"), "$2y$10$z3QlTH2S0HFcX0bXY6B.8OQ.jj4mbdYPho4PnhEgM0qk2kbFNnw92")
+	assert.NoError(t, err, "Comparing a valid bcrypt hash shouldn't fail")
+	assert.True(t, isValid, "Correct password should validate with a known bcrypt hash")
+}
+
+func TestCompareShouldErrorWithInvalidHashes(t *testing.T) {
+	invalidHashes := []string{
+		"",
+		"abc",
+		"$argon2id$v=19$m=64,t=1,p=2$c29tZXNhbHQ",
+		"$argon2id$v=16$m=64,t=1,p=2$c29tZXNhbHQ$c29tZXNhbHQ",
+		"$argon2id$v=19$m=64,t=1$c29tZXNhbHQ$c29tZXNhbHQ",
+		"$argon2id$v=19$m=64,t=1,p=2,x=1$c29tZXNhbHQ$c29tZXNhbHQ",
+		"$argon2id$v=19$m=99999999,t=1,p=2$c29tZXNhbHQ$c29tZXNhbHQ",
+		"$argon2id$v=19$m=64,t=1,p=2$!!!$c29tZXNhbHQ",
+		"$scrypt$ln=10,r=8,p=1$c29tZXNhbHQ",
+		"$scrypt$ln=10,r=16,p=1$c29tZXNhbHQ$c29tZXNhbHQ",
+		"$scrypt$ln=40,r=8,p=1$c29tZXNhbHQ$c29tZXNhbHQ",
+		"$scrypt$ln=abc,r=8,p=1$c29tZXNhbHQ$c29tZXNhbHQ",
+	}
+	for _, hash := range invalidHashes {
+		isValid, err := Compare([]byte("password"), hash)
+		assert.True(t, errors.Is(err, ErrInvalidHash), "Comparing %q should return ErrInvalidHash", hash)
+		assert.False(t, isValid, "Password shouldn't validate with invalid hash %q", hash)
+	}
+
+	// Bcrypt errors come straight from the bcrypt package
+	isValid, err := Compare([]byte("password"), "$2y$32$z3QlTH2S0HFcX0bXY6B.8OQ.jj4mbdYPho4PnhEgM0qk2kbFNnw92")
+	assert.Error(t, err, "Comparing an invalid bcrypt hash should fail")
+	assert.False(t, isValid, "Password shouldn't validate with an invalid bcrypt hash")
+}
+
+func TestNeedsRehashShouldDetectOutdatedHashes(t *testing.T) {
+	bcryptHash := "$2y$10$z3QlTH2S0HFcX0bXY6B.8OQ.jj4mbdYPho4PnhEgM0qk2kbFNnw92"
+	argon2idHash := "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"
+
+	testCases := []struct {
+		hash        string
+		params      Params
+		needsRehash bool
+	}{
+		{bcryptHash, Params{Algorithm: Bcrypt, Cost: 10}, false},
+		{bcryptHash, Params{Algorithm: Bcrypt, Cost: 4}, false},
+		{bcryptHash, Params{Algorithm: Bcrypt, Cost: 12}, true},
+		{bcryptHash, Params{Algorithm: Argon2id, Memory: 64, Iterations: 2, Parallelism: 1}, true},
+		{argon2idHash, Params{Algorithm: Argon2id, Memory: 65536, Iterations: 2, Parallelism: 1}, false},
+		{argon2idHash, Params{Algorithm: Argon2id, Memory: 32768, Iterations: 1, Parallelism: 1}, false},
+		{argon2idHash, Params{Algorithm: Argon2id, Memory: 131072, Iterations: 2, Parallelism: 1}, true},
+		{argon2idHash, Params{Algorithm: Argon2id, Memory: 65536, Iterations: 3, Parallelism: 1}, true},
+		{argon2idHash, Params{Algorithm: Argon2id, Memory: 65536, Iterations: 2, Parallelism: 2}, true},
+		{argon2idHash, Params{Algorithm: Bcrypt, Cost: 10}, true},
+	}
+	for _, testCase := range testCases {
+		needsRehash, err := NeedsRehash(testCase.hash, testCase.params)
+		assert.NoError(t, err, "Checking a valid hash shouldn't fail")
+		assert.Equal(t, testCase.needsRehash, needsRehash, "Incorrect result for %q with %+v", testCase.hash, testCase.params)
+	}
+
+	_, err := NeedsRehash("abc", Params{Algorithm: Bcrypt, Cost: 10})
+	assert.Error(t, err, "Checking an invalid hash should fail")
+}
+
+func TestParamsShouldBeValidated(t *testing.T) {
+	invalidParams := []Params{
+		{Algorithm: Bcrypt, Cost: 3},
+		{Algorithm: Bcrypt, Cost: 32},
+		{Algorithm: Argon2id, Memory: 64, Iterations: 1, Parallelism: 0},
+		{Algorithm: Argon2id, Memory: 64, Iterations: 1, Parallelism: 256},
+		{Algorithm: Argon2id, Memory: 8, Iterations: 1, Parallelism: 2},
+		{Algorithm: Argon2id, Memory: MaxArgon2idMemory + 1, Iterations: 1, Parallelism: 1},
+		{Algorithm: Argon2id, Memory: 64, Iterations: 0, Parallelism: 1},
+		{Algorithm: Scrypt, Cost: MinScryptCost - 1, Parallelism: 1},
+		{Algorithm: Scrypt, Cost: MaxScryptCost + 1, Parallelism: 1},
+		{Algorithm: Scrypt, Cost: MinScryptCost, Parallelism: 0},
+		{Algorithm: 3},
+	}
+	for _, params := range invalidParams {
+		assert.Error(t, params.Validate(), "Parameters %+v should be invalid", params)
+		_, err := Hash([]byte("password"), params)
+		assert.Error(t, err, "Hashing with parameters %+v should fail", params)
+	}
+
+	for _, params := range testParams {
+		assert.NoError(t, params.Validate(), "Parameters %+v should be valid", params)
+	}
+}
+
+func TestParamsShouldDescribeTheirCost(t *testing.T) {
+	assert.Equal(t, "12", Params{Algorithm: Bcrypt, Cost: 12}.CostString(), "Bcrypt should only include the cost")
+	assert.Equal(t, "ln=15,p=2", Params{Algorithm: Scrypt, Cost: 15, Parallelism: 2}.CostString(),
+		"Scrypt should include the cost and parallelism")
+	assert.Equal(t, "m=65536,t=3,p=4",
+		Params{Algorithm: Argon2id, Memory: 65536, Iterations: 3, Parallelism: 4}.CostString(),
+		"Argon2id should include the memory, iterations and parallelism")
+}
@@ -0,0 +1,58 @@
+package hashAlgorithms
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrRawPasswordRequired is returned when a legacy hash is validated without the raw password.
+var ErrRawPasswordRequired = errors.New("the raw password is required to validate legacy hashes")
+
+// IsLegacy returns if the hash is in one of the legacy formats supported for migrations from other systems, which are
+// PBKDF2 in Django or passlib format, and "$6$" SHA-512 crypt. Plain bcrypt hashes aren't included, as they can't be
+// distinguished from gocrypt's own bcrypt hashes.
+func IsLegacy(hash string) bool {
+	return isPBKDF2(hash) || strings.HasPrefix(hash, sha512CryptPrefix)
+}
+
+// CompareLegacy takes a raw password and a hash from another system, and returns if the password is valid. Unlike
+// Compare, the password must not be pre-hashed with SHA-512, as the other systems don't do this. Bcrypt hashes are
+// treated as plain bcrypt hashes of the raw password.
+func CompareLegacy(rawPassword []byte, hash string) (isValid bool, err error) {
+	switch {
+	case isPBKDF2(hash):
+		return comparePBKDF2(rawPassword, hash)
+	case strings.HasPrefix(hash, sha512CryptPrefix):
+		return compareSHA512Crypt(rawPassword, hash)
+	case strings.HasPrefix(hash, "$2"):
+		return compareBcrypt(rawPassword, hash)
+	default:
+		return false, ErrInvalidHash
+	}
+}
+
+// CompareWithLegacy takes the pre-hashed and raw versions of a password, and returns if the password is valid. Legacy
+// hashes are validated using the raw password. If the raw password is provided, bcrypt hashes which don't match the
+// pre-hashed password are also checked as plain bcrypt hashes of the raw password. isLegacy is true if the password
+// matched a legacy hash, which should be replaced with a native hash.
+func CompareWithLegacy(password []byte, rawPassword []byte, hash string) (isValid bool, isLegacy bool, err error) {
+	return compareWithLegacy(Compare, password, rawPassword, hash)
+}
+
+func compareWithLegacy(compare func(password []byte, hash string) (bool, error), password []byte, rawPassword []byte,
+	hash string) (isValid bool, isLegacy bool, err error) {
+	if IsLegacy(hash) {
+		if len(rawPassword) == 0 {
+			return false, false, ErrRawPasswordRequired
+		}
+		isValid, err = CompareLegacy(rawPassword, hash)
+		return isValid, isValid, err
+	}
+
+	isValid, err = compare(password, hash)
+	if err != nil || isValid || len(rawPassword) == 0 || !strings.HasPrefix(hash, "$2") {
+		return isValid, false, err
+	}
+	isValid, err = CompareLegacy(rawPassword, hash)
+	return isValid, isValid, err
+}
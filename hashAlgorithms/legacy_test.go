@@ -0,0 +1,103 @@
+package hashAlgorithms
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// These hashes were generated using Python's hashlib and crypt modules, and the SHA-512 crypt test vectors are from
+// the specification.
+var legacyHashes = map[string]string{
+	"pbkdf2_sha256$1000$seasalt$YIWkt6M1JFXrHg5s0jZjBSc7C2Cz6QvchSJ0h8Y+i7c=":                                                           "password",
+	"pbkdf2_sha1$1000$seasalt$C8KvRfPW529R7JpDHEDOP35Xr0g=":                                                                             "password",
+	"$pbkdf2-sha256$1000$AAECAwQFBgcICQoLDA0ODw$JeuGrMduQwGPGLmo.Qwv7UYtHHmeg9SK49fGkEamC2c":                                            "password",
+	"$pbkdf2-sha512$1000$AAECAwQFBgcICQoLDA0ODw$x05AgND7tB/uWGjA/2D9dayuJjghWYfl/1T46uIRM5ta0a9uOHvBLdOnC7blqQEIFBxfCONToumEQ5pDM8Qtbg": "password",
+	"$pbkdf2$1000$AAECAwQFBgcICQoLDA0ODw$Awni/k4L3.fQ/kgo1BwjRBbi2b8":                                                                   "password",
+	"$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1":                              "Hello world!",
+	"$6$rounds=10000$saltstringsaltst$OW1/O6BYHV6BcXZu8QVeXbDWra3Oeqh0sbHbbMCVNSnCM/UrjmM0Dp8vOuZeHBy/YTBmSK6H9qs/y3RnOaw5v.":           "Hello world!",
+	"$6$rounds=1000$abcdefgh$wuAp2XWwaaguzVxZjeM2bd1yLSqbC/I9sr9DFeOfIPoAZiIj3ecL6rf9ibuAg8RDmh1vqbaeL0NSLJtGPF7b60":                    "password",
+}
+
+func TestCompareLegacyShouldValidateKnownHashes(t *testing.T) {
+	for hash, password := range legacyHashes {
+		assert.True(t, IsLegacy(hash), "%q should be detected as a legacy hash", hash)
+
+		isValid, err := CompareLegacy([]byte(password), hash)
+		assert.NoError(t, err, "Comparing %q shouldn't fail", hash)
+		assert.True(t, isValid, "Correct password should validate with %q", hash)
+
+		isValid, err = CompareLegacy([]byte(password+"1"), hash)
+		assert.NoError(t, err, "Comparing %q shouldn't fail", hash)
+		assert.False(t, isValid, "Incorrect password shouldn't validate with %q", hash)
+
+		needsRehash, err := NeedsRehash(hash, Params{Algorithm: Bcrypt, Cost: 10})
+		assert.NoError(t, err, "Checking %q shouldn't fail", hash)
+		assert.True(t, needsRehash, "Legacy hash %q should always need a rehash", hash)
+	}
+
+	// Plain bcrypt
+	isValid, err := CompareLegacy([]byte("password"), "$2y$10$z3QlTH2S0HFcX0bXY6B.8OQ.jj4mbdYPho4PnhEgM0qk2kbFNnw92")
+	assert.NoError(t, err, "Comparing a plain bcrypt hash shouldn't fail")
+	assert.True(t, isValid, "Correct password should validate with a plain bcrypt hash")
+	assert.False(t, IsLegacy("$2y$10$z3QlTH2S0HFcX0bXY6B.8OQ.jj4mbdYPho4PnhEgM0qk2kbFNnw92"), "Bcrypt hashes can't be detected as legacy hashes")
+}
+
+func TestCompareLegacyShouldErrorWithInvalidHashes(t *testing.T) {
+	invalidHashes := []string{
+		"abc",
+		"pbkdf2_sha256$1000$seasalt",
+		"pbkdf2_md5$1000$seasalt$YIWkt6M1JFXrHg5s0jZjBSc7C2Cz6QvchSJ0h8Y+i7c=",
+		"pbkdf2_sha256$0$seasalt$YIWkt6M1JFXrHg5s0jZjBSc7C2Cz6QvchSJ0h8Y+i7c=",
+		"pbkdf2_sha256$99999999$seasalt$YIWkt6M1JFXrHg5s0jZjBSc7C2Cz6QvchSJ0h8Y+i7c=",
+		"pbkdf2_sha256$1000$seasalt$!!!",
+		"$pbkdf2-sha256$1000$!!!$JeuGrMduQwGPGLmo.Qwv7UYtHHmeg9SK49fGkEamC2c",
+		"$6$saltstring",
+		"$6$saltstring$abc",
+		"$6$rounds=abc$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1",
+		"$6$rounds=999999999$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1",
+	}
+	for _, hash := range invalidHashes {
+		isValid, err := CompareLegacy([]byte("password"), hash)
+		assert.True(t, errors.Is(err, ErrInvalidHash), "Comparing %q should return ErrInvalidHash", hash)
+		assert.False(t, isValid, "Password shouldn't validate with invalid hash %q", hash)
+	}
+}
+
+func TestCompareWithLegacyShouldHandleAllFormats(t *testing.T) {
+	password := []byte("password")
+	preHashed := []byte("prehashed password")
+	nativeHash, _ := Hash(preHashed, Params{Algorithm: Bcrypt, Cost: 4})
+	plainBcryptHash := "$2y$10$z3QlTH2S0HFcX0bXY6B.8OQ.jj4mbdYPho4PnhEgM0qk2kbFNnw92"
+	pbkdf2Hash := "pbkdf2_sha256$1000$seasalt$YIWkt6M1JFXrHg5s0jZjBSc7C2Cz6QvchSJ0h8Y+i7c="
+
+	isValid, isLegacy, err := CompareWithLegacy(preHashed, password, nativeHash)
+	assert.NoError(t, err, "Comparing a native hash shouldn't fail")
+	assert.True(t, isValid, "Native hash should validate with the pre-hashed password")
+	assert.False(t, isLegacy, "Native hash shouldn't be reported as legacy")
+
+	isValid, isLegacy, err = CompareWithLegacy(preHashed, password, plainBcryptHash)
+	assert.NoError(t, err, "Comparing a plain bcrypt hash shouldn't fail")
+	assert.True(t, isValid, "Plain bcrypt hash should validate with the raw password")
+	assert.True(t, isLegacy, "Plain bcrypt hash should be reported as legacy")
+
+	isValid, isLegacy, err = CompareWithLegacy(preHashed, nil, plainBcryptHash)
+	assert.NoError(t, err, "Comparing a plain bcrypt hash shouldn't fail")
+	assert.False(t, isValid, "Plain bcrypt hash shouldn't be checked without the raw password")
+	assert.False(t, isLegacy, "Unmatched hash shouldn't be reported as legacy")
+
+	isValid, isLegacy, err = CompareWithLegacy(preHashed, password, pbkdf2Hash)
+	assert.NoError(t, err, "Comparing a pbkdf2 hash shouldn't fail")
+	assert.True(t, isValid, "Pbkdf2 hash should validate with the raw password")
+	assert.True(t, isLegacy, "Pbkdf2 hash should be reported as legacy")
+
+	isValid, isLegacy, err = CompareWithLegacy(preHashed, []byte("wrong"), pbkdf2Hash)
+	assert.NoError(t, err, "Comparing a pbkdf2 hash shouldn't fail")
+	assert.False(t, isValid, "Pbkdf2 hash shouldn't validate with the wrong password")
+	assert.False(t, isLegacy, "Unmatched hash shouldn't be reported as legacy")
+
+	isValid, _, err = CompareWithLegacy(preHashed, nil, pbkdf2Hash)
+	assert.True(t, errors.Is(err, ErrRawPasswordRequired), "Legacy hashes should require the raw password")
+	assert.False(t, isValid, "Legacy hash shouldn't validate without the raw password")
+}
@@ -0,0 +1,114 @@
+package hashAlgorithms
+
+import (
+	"fmt"
+	"strconv"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Algorithm specifies a password hashing algorithm. The values match the Algorithm enum in the protocol.
+type Algorithm int32
+
+const (
+	// Bcrypt uses the Cost parameter only.
+	Bcrypt Algorithm = 0
+	// Argon2id uses the Memory, Iterations and Parallelism parameters.
+	Argon2id Algorithm = 1
+	// Scrypt uses the Cost parameter as the base 2 logarithm of N, and the Parallelism parameter as p.
+	Scrypt Algorithm = 2
+)
+
+const (
+	// MaxArgon2idMemory is the maximum amount of memory in KiB that Argon2id can be configured to use, which is 4GiB.
+	MaxArgon2idMemory = 4 * 1024 * 1024
+	// MaxArgon2idIterations is the maximum amount of Argon2id iterations.
+	MaxArgon2idIterations = 1024
+	// MinScryptCost is the minimum base 2 logarithm of the scrypt N parameter.
+	MinScryptCost = 10
+	// MaxScryptCost is the maximum base 2 logarithm of the scrypt N parameter, which corresponds to 4GiB of memory.
+	MaxScryptCost = 22
+	// MaxParallelism is the maximum parallelism for both Argon2id and scrypt.
+	MaxParallelism = 255
+)
+
+// Algorithms lists every algorithm which can be used for new hashes.
+var Algorithms = []Algorithm{Bcrypt, Argon2id, Scrypt}
+
+// String returns the name of the algorithm, as used in hash prefixes.
+func (a Algorithm) String() string {
+	switch a {
+	case Bcrypt:
+		return "bcrypt"
+	case Argon2id:
+		return "argon2id"
+	case Scrypt:
+		return "scrypt"
+	default:
+		return fmt.Sprintf("unknown(%d)", int32(a))
+	}
+}
+
+// Params specifies a password hashing algorithm and its parameters. Parameters which aren't used by the algorithm are
+// ignored.
+type Params struct {
+	Algorithm Algorithm
+	// Cost is the bcrypt cost, or the base 2 logarithm of the scrypt N parameter.
+	Cost int
+	// Memory is the amount of memory used by Argon2id in KiB.
+	Memory uint32
+	// Iterations is the amount of passes over the memory made by Argon2id.
+	Iterations uint32
+	// Parallelism is the amount of threads used by Argon2id, or the scrypt p parameter.
+	Parallelism uint32
+}
+
+// Validate checks that the algorithm is supported and that its parameters are within acceptable bounds.
+func (p Params) Validate() (err error) {
+	switch p.Algorithm {
+	case Bcrypt:
+		if p.Cost < bcrypt.MinCost || p.Cost > bcrypt.MaxCost {
+			return fmt.Errorf("cost of %d is invalid - cost must be between %d and %d", p.Cost, bcrypt.MinCost,
+				bcrypt.MaxCost)
+		}
+	case Argon2id:
+		if p.Parallelism < 1 || p.Parallelism > MaxParallelism {
+			return fmt.Errorf("parallelism of %d is invalid - parallelism must be between 1 and %d", p.Parallelism,
+				MaxParallelism)
+		}
+		// Argon2 requires at least 8KiB of memory per thread
+		if p.Memory < 8*p.Parallelism || p.Memory > MaxArgon2idMemory {
+			return fmt.Errorf("memory of %dKiB is invalid - memory must be between %dKiB and %dKiB", p.Memory,
+				8*p.Parallelism, MaxArgon2idMemory)
+		}
+		if p.Iterations < 1 || p.Iterations > MaxArgon2idIterations {
+			return fmt.Errorf("iterations of %d is invalid - iterations must be between 1 and %d", p.Iterations,
+				MaxArgon2idIterations)
+		}
+	case Scrypt:
+		if p.Cost < MinScryptCost || p.Cost > MaxScryptCost {
+			return fmt.Errorf("cost of %d is invalid - cost must be between %d and %d", p.Cost, MinScryptCost,
+				MaxScryptCost)
+		}
+		if p.Parallelism < 1 || p.Parallelism > MaxParallelism {
+			return fmt.Errorf("parallelism of %d is invalid - parallelism must be between 1 and %d", p.Parallelism,
+				MaxParallelism)
+		}
+	default:
+		return fmt.Errorf("algorithm %s is not supported", p.Algorithm)
+	}
+	return nil
+}
+
+// CostString returns the parameters which apply to the algorithm, such as "12" for bcrypt, "ln=15,p=1" for scrypt or
+// "m=65536,t=3,p=4" for Argon2id, for use in logs.
+func (p Params) CostString() (cost string) {
+	switch p.Algorithm {
+	case Argon2id:
+		return fmt.Sprintf("m=%d,t=%d,p=%d", p.Memory, p.Iterations, p.Parallelism)
+	case Scrypt:
+		return fmt.Sprintf("ln=%d,p=%d", p.Cost, p.Parallelism)
+	default:
+		return strconv.Itoa(p.Cost)
+	}
+}
@@ -0,0 +1,89 @@
+package hashAlgorithms
+
+import (
+	"crypto/sha1" // #nosec G505 - SHA-1 is only used to validate legacy hashes.
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// MaxPBKDF2Iterations is the maximum amount of PBKDF2 iterations accepted when validating legacy hashes.
+const MaxPBKDF2Iterations = 10000000
+
+// passlibEncoding is the adapted base64 encoding used by passlib, which is standard base64 with "." instead of "+",
+// and without padding.
+var passlibEncoding = base64.NewEncoding("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789./").
+	WithPadding(base64.NoPadding)
+
+// pbkdf2Digests maps the hash prefixes used by Django and passlib to the digest used by PBKDF2.
+var pbkdf2Digests = map[string]func() hash.Hash{
+	"pbkdf2_sha256$":  sha256.New,
+	"pbkdf2_sha1$":    sha1.New,
+	"$pbkdf2-sha512$": sha512.New,
+	"$pbkdf2-sha256$": sha256.New,
+	"$pbkdf2$":        sha1.New,
+}
+
+func isPBKDF2(hash string) bool {
+	for prefix := range pbkdf2Digests {
+		if strings.HasPrefix(hash, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func comparePBKDF2(rawPassword []byte, hash string) (isValid bool, err error) {
+	digest, iterations, salt, key, err := parsePBKDF2(hash)
+	if err != nil {
+		return false, err
+	}
+	otherKey := pbkdf2.Key(rawPassword, salt, iterations, len(key), digest)
+	return subtle.ConstantTimeCompare(key, otherKey) == 1, nil
+}
+
+// parsePBKDF2 parses a hash in either the Django "pbkdf2_sha256$<iterations>$<salt>$<key>" format, where the salt is
+// plain text and the key is standard base64, or the passlib "$pbkdf2-sha256$<iterations>$<salt>$<key>" format, where
+// both the salt and key use passlib's base64 encoding.
+func parsePBKDF2(hash string) (digest func() hash.Hash, iterations int, salt []byte, key []byte, err error) {
+	isDjango := !strings.HasPrefix(hash, "$")
+	parts := strings.Split(strings.TrimPrefix(hash, "$"), "$")
+	if len(parts) != 4 {
+		return nil, 0, nil, nil, fmt.Errorf("%w: pbkdf2 hash should have 4 sections", ErrInvalidHash)
+	}
+	prefix := parts[0] + "$"
+	if !isDjango {
+		prefix = "$" + prefix
+	}
+	digest, ok := pbkdf2Digests[prefix]
+	if !ok {
+		return nil, 0, nil, nil, fmt.Errorf("%w: unsupported pbkdf2 digest %q", ErrInvalidHash, parts[0])
+	}
+
+	iterations, err = strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 || iterations > MaxPBKDF2Iterations {
+		return nil, 0, nil, nil, fmt.Errorf("%w: iterations must be between 1 and %d", ErrInvalidHash,
+			MaxPBKDF2Iterations)
+	}
+
+	if isDjango {
+		salt = []byte(parts[2])
+		key, err = base64.StdEncoding.DecodeString(parts[3])
+	} else {
+		salt, err = passlibEncoding.DecodeString(parts[2])
+		if err == nil {
+			key, err = passlibEncoding.DecodeString(parts[3])
+		}
+	}
+	if err != nil || len(salt) == 0 || len(key) == 0 {
+		return nil, 0, nil, nil, fmt.Errorf("%w: malformed salt or key", ErrInvalidHash)
+	}
+	return digest, iterations, salt, key, nil
+}
@@ -0,0 +1,147 @@
+package hashAlgorithms
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	pepperPrefix = "$pepper$"
+	// MinPepperLength is the minimum length of a pepper key in bytes.
+	MinPepperLength = 16
+	// MaxPepperIDLength is the maximum length of a pepper key ID.
+	MaxPepperIDLength = 32
+)
+
+// ErrUnknownPepper is returned when a hash was peppered with a key ID which isn't configured.
+var ErrUnknownPepper = errors.New("unknown pepper key ID")
+
+// Peppers holds the secret keys used to pepper passwords, identified by key IDs. Peppered passwords are keyed with
+// HMAC-SHA-512 before hashing, and the key ID is embedded in the hash (e.g. "$pepper$2021$2a$10$...") so that the
+// correct key can be used to validate it. A nil *Peppers is valid, and doesn't pepper new hashes.
+type Peppers struct {
+	currentID string
+	keys      map[string][]byte
+}
+
+// NewPeppers returns Peppers which pepper new hashes using the key identified by currentID. The other keys are only
+// used to validate existing hashes, so that old keys can be retired once their hashes have been replaced. Key IDs may
+// only contain letters, digits, dashes and underscores.
+func NewPeppers(currentID string, keys map[string][]byte) (peppers *Peppers, err error) {
+	if _, ok := keys[currentID]; !ok {
+		return nil, fmt.Errorf("current pepper key ID %q doesn't match any of the provided keys", currentID)
+	}
+
+	peppers = &Peppers{
+		currentID: currentID,
+		keys:      make(map[string][]byte, len(keys)),
+	}
+	for id, key := range keys {
+		err = validatePepperID(id)
+		if err != nil {
+			return nil, err
+		}
+		if len(key) < MinPepperLength {
+			return nil, fmt.Errorf("pepper key %q is too short - should be %d bytes at a minimum, but was %d bytes", id,
+				MinPepperLength, len(key))
+		}
+		peppers.keys[id] = append([]byte(nil), key...)
+	}
+	return peppers, nil
+}
+
+func validatePepperID(id string) (err error) {
+	if len(id) == 0 || len(id) > MaxPepperIDLength {
+		return fmt.Errorf("pepper key ID %q is invalid - length must be between 1 and %d", id, MaxPepperIDLength)
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return fmt.Errorf("pepper key ID %q is invalid - only letters, digits, dashes and underscores are allowed",
+				id)
+		}
+	}
+	return nil
+}
+
+// CurrentID returns the ID of the key used to pepper new hashes, or an empty string if p is nil.
+func (p *Peppers) CurrentID() string {
+	if p == nil {
+		return ""
+	}
+	return p.currentID
+}
+
+// splitPeppered returns the key ID and the underlying hash of a peppered hash. ok is false if the hash isn't peppered.
+func splitPeppered(hash string) (id string, innerHash string, ok bool) {
+	if !strings.HasPrefix(hash, pepperPrefix) {
+		return "", hash, false
+	}
+	rest := hash[len(pepperPrefix):]
+	i := strings.IndexByte(rest, '$')
+	if i < 0 {
+		return "", hash, false
+	}
+	return rest[:i], rest[i:], true
+}
+
+func pepper(key []byte, password []byte) []byte {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(password) //nolint
+	return mac.Sum(nil)
+}
+
+// Hash is the same as the package-level Hash, but the password is peppered with the current key first.
+func (p *Peppers) Hash(password []byte, params Params) (hash string, err error) {
+	if p == nil {
+		return Hash(password, params)
+	}
+
+	hash, err = Hash(pepper(p.keys[p.currentID], password), params)
+	if err != nil {
+		return "", err
+	}
+	return pepperPrefix + p.currentID + hash, nil
+}
+
+// Compare is the same as the package-level Compare, but peppered hashes are validated using the key matching their key
+// ID. Hashes which aren't peppered are still accepted, so that they can be replaced with peppered hashes.
+func (p *Peppers) Compare(password []byte, hash string) (isValid bool, err error) {
+	id, innerHash, ok := splitPeppered(hash)
+	if !ok {
+		if strings.HasPrefix(hash, pepperPrefix) {
+			return false, ErrInvalidHash
+		}
+		return Compare(password, hash)
+	}
+
+	var key []byte
+	if p != nil {
+		key = p.keys[id]
+	}
+	if key == nil {
+		return false, fmt.Errorf("%w: %q", ErrUnknownPepper, id)
+	}
+	return Compare(pepper(key, password), innerHash)
+}
+
+// CompareWithLegacy is the same as the package-level CompareWithLegacy, but native hashes are validated using Compare.
+func (p *Peppers) CompareWithLegacy(password []byte, rawPassword []byte, hash string) (isValid bool, isLegacy bool,
+	err error) {
+	return compareWithLegacy(p.Compare, password, rawPassword, hash)
+}
+
+// NeedsRehash is the same as the package-level NeedsRehash, but hashes which aren't peppered with the current key also
+// need to be replaced.
+func (p *Peppers) NeedsRehash(hash string, params Params) (needsRehash bool, err error) {
+	if IsLegacy(hash) {
+		return true, nil
+	}
+	id, innerHash, _ := splitPeppered(hash)
+	if id != p.CurrentID() {
+		return true, nil
+	}
+	return NeedsRehash(innerHash, params)
+}
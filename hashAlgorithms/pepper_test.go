@@ -0,0 +1,105 @@
+package hashAlgorithms
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	oldPepperKey = []byte("0123456789abcdef")
+	newPepperKey = []byte("fedcba9876543210")
+)
+
+func TestNewPeppersShouldValidateKeys(t *testing.T) {
+	_, err := NewPeppers("new", map[string][]byte{"old": oldPepperKey})
+	assert.NotNil(t, err, "Should return an error when the current key ID doesn't exist")
+
+	_, err = NewPeppers("new", map[string][]byte{"new": []byte("short")})
+	assert.NotNil(t, err, "Should return an error when a key is too short")
+
+	_, err = NewPeppers("new$", map[string][]byte{"new$": newPepperKey})
+	assert.NotNil(t, err, "Should return an error when a key ID has invalid characters")
+
+	_, err = NewPeppers(strings.Repeat("a", MaxPepperIDLength+1), map[string][]byte{
+		strings.Repeat("a", MaxPepperIDLength+1): newPepperKey,
+	})
+	assert.NotNil(t, err, "Should return an error when a key ID is too long")
+
+	peppers, err := NewPeppers("new", map[string][]byte{"old": oldPepperKey, "new": newPepperKey})
+	assert.NoError(t, err, "Should not return an error with valid keys")
+	assert.Equal(t, "new", peppers.CurrentID(), "Current ID should match the provided ID")
+}
+
+func TestPeppersShouldHashAndValidatePasswords(t *testing.T) {
+	params := Params{Algorithm: Bcrypt, Cost: 4}
+	password := []byte("password")
+	peppers, _ := NewPeppers("new", map[string][]byte{"old": oldPepperKey, "new": newPepperKey})
+
+	hash, err := peppers.Hash(password, params)
+	assert.NoError(t, err, "Hashing shouldn't fail")
+	assert.True(t, strings.HasPrefix(hash, "$pepper$new$2a$04$"), "Hash should include the current key ID")
+
+	isValid, err := peppers.Compare(password, hash)
+	assert.NoError(t, err, "Validating a peppered hash shouldn't fail")
+	assert.True(t, isValid, "Correct password should validate with a peppered hash")
+
+	isValid, err = peppers.Compare([]byte("password1"), hash)
+	assert.NoError(t, err, "Validating a peppered hash shouldn't fail")
+	assert.False(t, isValid, "Incorrect password shouldn't validate with a peppered hash")
+
+	isValid, _ = Compare(password, strings.TrimPrefix(hash, "$pepper$new"))
+	assert.False(t, isValid, "Password shouldn't validate without the pepper")
+
+	parsedParams, err := ParseParams(hash)
+	assert.NoError(t, err, "Parsing a peppered hash shouldn't fail")
+	assert.Equal(t, params, parsedParams, "Params of a peppered hash should match the underlying hash")
+
+	var noPeppers *Peppers
+	_, err = noPeppers.Compare(password, hash)
+	assert.True(t, errors.Is(err, ErrUnknownPepper), "Validating without peppers should return ErrUnknownPepper")
+
+	_, err = peppers.Compare(password, "$pepper$other"+strings.TrimPrefix(hash, "$pepper$new"))
+	assert.True(t, errors.Is(err, ErrUnknownPepper), "Validating with an unknown key ID should return ErrUnknownPepper")
+
+	_, err = peppers.Compare(password, "$pepper$new")
+	assert.True(t, errors.Is(err, ErrInvalidHash), "Validating a truncated peppered hash should return ErrInvalidHash")
+
+	unpepperedHash, _ := noPeppers.Hash(password, params)
+	assert.True(t, strings.HasPrefix(unpepperedHash, "$2a$04$"), "Hashing without peppers shouldn't pepper the hash")
+	isValid, err = peppers.Compare(password, unpepperedHash)
+	assert.NoError(t, err, "Validating an unpeppered hash shouldn't fail")
+	assert.True(t, isValid, "Unpeppered hashes should still validate")
+}
+
+func TestPeppersShouldRehashOnKeyRotation(t *testing.T) {
+	params := Params{Algorithm: Bcrypt, Cost: 4}
+	password := []byte("password")
+	oldPeppers, _ := NewPeppers("old", map[string][]byte{"old": oldPepperKey})
+	newPeppers, _ := NewPeppers("new", map[string][]byte{"old": oldPepperKey, "new": newPepperKey})
+
+	oldHash, _ := oldPeppers.Hash(password, params)
+	isValid, err := newPeppers.Compare(password, oldHash)
+	assert.NoError(t, err, "Validating a hash peppered with an old key shouldn't fail")
+	assert.True(t, isValid, "Hashes peppered with an old key should still validate")
+
+	needsRehash, err := newPeppers.NeedsRehash(oldHash, params)
+	assert.NoError(t, err, "Checking a hash peppered with an old key shouldn't fail")
+	assert.True(t, needsRehash, "Hashes peppered with an old key should be replaced")
+
+	newHash, _ := newPeppers.Hash(password, params)
+	needsRehash, _ = newPeppers.NeedsRehash(newHash, params)
+	assert.False(t, needsRehash, "Hashes peppered with the current key shouldn't be replaced")
+	needsRehash, _ = newPeppers.NeedsRehash(newHash, Params{Algorithm: Bcrypt, Cost: 5})
+	assert.True(t, needsRehash, "Hashes with a lower cost should be replaced")
+
+	unpepperedHash, _ := Hash(password, params)
+	needsRehash, _ = newPeppers.NeedsRehash(unpepperedHash, params)
+	assert.True(t, needsRehash, "Unpeppered hashes should be replaced once peppers are configured")
+
+	var noPeppers *Peppers
+	needsRehash, _ = noPeppers.NeedsRehash(unpepperedHash, params)
+	assert.False(t, needsRehash, "Unpeppered hashes shouldn't be replaced without peppers")
+}
@@ -0,0 +1,67 @@
+package hashAlgorithms
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// saltLength is the length in bytes of the random salts generated for Argon2id and scrypt.
+	saltLength = 16
+	// keyLength is the length in bytes of the keys derived by Argon2id and scrypt.
+	keyLength = 32
+)
+
+// phcEncoding is the base64 encoding used by the PHC string format, which is standard base64 without padding.
+var phcEncoding = base64.RawStdEncoding
+
+func generateSalt() (salt []byte, err error) {
+	salt = make([]byte, saltLength)
+	_, err = rand.Read(salt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %v", err)
+	}
+	return salt, nil
+}
+
+// parsePHCParams parses a PHC parameter string like "m=65536,t=3,p=4" into a map of parameter names and values. All
+// of the expected parameters must be present, and no others.
+func parsePHCParams(paramString string, expected ...string) (values map[string]uint32, err error) {
+	values = make(map[string]uint32, len(expected))
+	for _, pair := range strings.Split(paramString, ",") {
+		nameValue := strings.SplitN(pair, "=", 2)
+		if len(nameValue) != 2 {
+			return nil, fmt.Errorf("%w: malformed parameter %q", ErrInvalidHash, pair)
+		}
+		value, err := strconv.ParseUint(nameValue[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%w: malformed parameter %q", ErrInvalidHash, pair)
+		}
+		values[nameValue[0]] = uint32(value)
+	}
+	if len(values) != len(expected) {
+		return nil, fmt.Errorf("%w: expected parameters %s", ErrInvalidHash, strings.Join(expected, ","))
+	}
+	for _, name := range expected {
+		if _, ok := values[name]; !ok {
+			return nil, fmt.Errorf("%w: missing parameter %q", ErrInvalidHash, name)
+		}
+	}
+	return values, nil
+}
+
+// decodeSaltAndKey decodes the base64 salt and key at the end of a PHC string.
+func decodeSaltAndKey(encodedSalt string, encodedKey string) (salt []byte, key []byte, err error) {
+	salt, err = phcEncoding.DecodeString(encodedSalt)
+	if err != nil || len(salt) == 0 {
+		return nil, nil, fmt.Errorf("%w: malformed salt", ErrInvalidHash)
+	}
+	key, err = phcEncoding.DecodeString(encodedKey)
+	if err != nil || len(key) == 0 {
+		return nil, nil, fmt.Errorf("%w: malformed key", ErrInvalidHash)
+	}
+	return salt, key, nil
+}
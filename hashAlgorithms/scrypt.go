@@ -0,0 +1,73 @@
+package hashAlgorithms
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	scryptPrefix = "$scrypt$"
+	// scryptBlockSize is the scrypt r parameter. 8 is the commonly recommended value, so it isn't configurable.
+	scryptBlockSize = 8
+)
+
+func hashScrypt(password []byte, params Params) (hash string, err error) {
+	salt, err := generateSalt()
+	if err != nil {
+		return "", err
+	}
+	key, err := scrypt.Key(password, salt, 1<<uint(params.Cost), scryptBlockSize, int(params.Parallelism), keyLength)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%sln=%d,r=%d,p=%d$%s$%s", scryptPrefix, params.Cost, scryptBlockSize, params.Parallelism,
+		phcEncoding.EncodeToString(salt), phcEncoding.EncodeToString(key)), nil
+}
+
+func compareScrypt(password []byte, hash string) (isValid bool, err error) {
+	params, salt, key, err := parseScrypt(hash)
+	if err != nil {
+		return false, err
+	}
+	otherKey, err := scrypt.Key(password, salt, 1<<uint(params.Cost), scryptBlockSize, int(params.Parallelism),
+		len(key))
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(key, otherKey) == 1, nil
+}
+
+// parseScrypt parses a hash in the "$scrypt$ln=16,r=8,p=1$<salt>$<key>" format.
+func parseScrypt(hash string) (params Params, salt []byte, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 5 {
+		return Params{}, nil, nil, fmt.Errorf("%w: scrypt hash should have 5 sections", ErrInvalidHash)
+	}
+
+	values, err := parsePHCParams(parts[2], "ln", "r", "p")
+	if err != nil {
+		return Params{}, nil, nil, err
+	}
+	if values["r"] != scryptBlockSize {
+		return Params{}, nil, nil, fmt.Errorf("%w: unsupported scrypt block size %d", ErrInvalidHash, values["r"])
+	}
+	params = Params{
+		Algorithm:   Scrypt,
+		Cost:        int(values["ln"]),
+		Parallelism: values["p"],
+	}
+	// Validating the parameters stops a malicious hash from using excessive resources.
+	err = params.Validate()
+	if err != nil {
+		return Params{}, nil, nil, fmt.Errorf("%w: %v", ErrInvalidHash, err)
+	}
+
+	salt, key, err = decodeSaltAndKey(parts[3], parts[4])
+	if err != nil {
+		return Params{}, nil, nil, err
+	}
+	return params, salt, key, nil
+}
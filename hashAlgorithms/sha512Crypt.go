@@ -0,0 +1,161 @@
+package hashAlgorithms
+
+import (
+	"crypto/sha512"
+	"crypto/subtle"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MaxSHA512CryptRounds is the maximum amount of rounds accepted when validating legacy SHA-512 crypt hashes. The
+// reference implementation allows up to 999999999 rounds, which would take minutes to validate.
+const MaxSHA512CryptRounds = 5000000
+
+const (
+	sha512CryptPrefix        = "$6$"
+	sha512CryptRoundsPrefix  = "rounds="
+	sha512CryptDefaultRounds = 5000
+	sha512CryptMinRounds     = 1000
+	sha512CryptMaxSaltLength = 16
+	// cryptAlphabet is the base64 alphabet used by crypt.
+	cryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+)
+
+// sha512CryptByteOrder is the order in which the digest bytes are encoded, in groups of 3.
+var sha512CryptByteOrder = [...]int{
+	0, 21, 42, 22, 43, 1, 44, 2, 23, 3, 24, 45, 25, 46, 4, 47, 5, 26, 6, 27, 48, 28, 49, 7, 50, 8, 29, 9, 30, 51, 31,
+	52, 10, 53, 11, 32, 12, 33, 54, 34, 55, 13, 56, 14, 35, 15, 36, 57, 37, 58, 16, 59, 17, 38, 18, 39, 60, 40, 61,
+	19, 62, 20, 41,
+}
+
+func compareSHA512Crypt(rawPassword []byte, hash string) (isValid bool, err error) {
+	rounds, roundsSpecified, salt, err := parseSHA512Crypt(hash)
+	if err != nil {
+		return false, err
+	}
+	otherHash := sha512Crypt(rawPassword, salt, rounds, roundsSpecified)
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(otherHash)) == 1, nil
+}
+
+// parseSHA512Crypt parses a hash in the "$6$[rounds=<rounds>$]<salt>$<hash>" format.
+func parseSHA512Crypt(hash string) (rounds int, roundsSpecified bool, salt []byte, err error) {
+	parts := strings.Split(strings.TrimPrefix(hash, sha512CryptPrefix), "$")
+	rounds = sha512CryptDefaultRounds
+	if len(parts) == 3 && strings.HasPrefix(parts[0], sha512CryptRoundsPrefix) {
+		rounds, err = strconv.Atoi(strings.TrimPrefix(parts[0], sha512CryptRoundsPrefix))
+		if err != nil {
+			return 0, false, nil, fmt.Errorf("%w: malformed rounds", ErrInvalidHash)
+		}
+		// Too few rounds are clamped rather than rejected, as specified by the reference implementation. Too many are
+		// rejected, so that a single hash can't keep the agent busy for minutes.
+		if rounds > MaxSHA512CryptRounds {
+			return 0, false, nil, fmt.Errorf("%w: rounds must be at most %d", ErrInvalidHash, MaxSHA512CryptRounds)
+		}
+		if rounds < sha512CryptMinRounds {
+			rounds = sha512CryptMinRounds
+		}
+		roundsSpecified = true
+		parts = parts[1:]
+	}
+	if len(parts) != 2 || len(parts[0]) > sha512CryptMaxSaltLength || len(parts[1]) != 86 {
+		return 0, false, nil, fmt.Errorf("%w: malformed sha512 crypt hash", ErrInvalidHash)
+	}
+	return rounds, roundsSpecified, []byte(parts[0]), nil
+}
+
+// sha512Crypt implements the SHA-512 based crypt algorithm as specified at https://akkadia.org/drepper/SHA-crypt.txt
+func sha512Crypt(password []byte, salt []byte, rounds int, roundsSpecified bool) (hash string) {
+	alternate := sha512.New()
+	alternate.Write(password)
+	alternate.Write(salt)
+	alternate.Write(password)
+	alternateSum := alternate.Sum(nil)
+
+	intermediate := sha512.New()
+	intermediate.Write(password)
+	intermediate.Write(salt)
+	for i := len(password); i > 0; i -= sha512.Size {
+		if i > sha512.Size {
+			intermediate.Write(alternateSum)
+		} else {
+			intermediate.Write(alternateSum[:i])
+		}
+	}
+	for i := len(password); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			intermediate.Write(alternateSum)
+		} else {
+			intermediate.Write(password)
+		}
+	}
+	intermediateSum := intermediate.Sum(nil)
+
+	passwordDigest := sha512.New()
+	for i := 0; i < len(password); i++ {
+		passwordDigest.Write(password)
+	}
+	passwordSequence := repeatToLength(passwordDigest.Sum(nil), len(password))
+
+	saltDigest := sha512.New()
+	for i := 0; i < 16+int(intermediateSum[0]); i++ {
+		saltDigest.Write(salt)
+	}
+	saltSequence := repeatToLength(saltDigest.Sum(nil), len(salt))
+
+	sum := intermediateSum
+	for i := 0; i < rounds; i++ {
+		round := sha512.New()
+		if i&1 != 0 {
+			round.Write(passwordSequence)
+		} else {
+			round.Write(sum)
+		}
+		if i%3 != 0 {
+			round.Write(saltSequence)
+		}
+		if i%7 != 0 {
+			round.Write(passwordSequence)
+		}
+		if i&1 != 0 {
+			round.Write(sum)
+		} else {
+			round.Write(passwordSequence)
+		}
+		sum = round.Sum(nil)
+	}
+
+	builder := strings.Builder{}
+	builder.WriteString(sha512CryptPrefix)
+	if roundsSpecified {
+		builder.WriteString(fmt.Sprintf("%s%d$", sha512CryptRoundsPrefix, rounds))
+	}
+	builder.Write(salt)
+	builder.WriteString("$")
+	for i := 0; i < len(sha512CryptByteOrder); i += 3 {
+		writeCryptBase64(&builder, uint(sum[sha512CryptByteOrder[i]])<<16|
+			uint(sum[sha512CryptByteOrder[i+1]])<<8|uint(sum[sha512CryptByteOrder[i+2]]), 4)
+	}
+	writeCryptBase64(&builder, uint(sum[63]), 2)
+	return builder.String()
+}
+
+// repeatToLength repeats the bytes until the specified length is reached.
+func repeatToLength(b []byte, length int) (repeated []byte) {
+	repeated = make([]byte, 0, length)
+	for len(repeated) < length {
+		remaining := length - len(repeated)
+		if remaining > len(b) {
+			remaining = len(b)
+		}
+		repeated = append(repeated, b[:remaining]...)
+	}
+	return repeated
+}
+
+func writeCryptBase64(builder *strings.Builder, value uint, length int) {
+	for i := 0; i < length; i++ {
+		builder.WriteByte(cryptAlphabet[value&0x3f])
+		value >>= 6
+	}
+}
@@ -0,0 +1,41 @@
+package localPasswordHasher
+
+import (
+	"context"
+
+	"github.com/rsheasby/gocrypt"
+)
+
+var _ gocrypt.BatchPasswordHasher = (*LocalPasswordHasher)(nil)
+
+// HashPasswords hashes each of the passwords locally, one at a time. Each result has its own error, and err is always
+// nil.
+func (l *LocalPasswordHasher) HashPasswords(passwords []string) (results []gocrypt.HashResult, err error) {
+	return l.HashPasswordsContext(context.Background(), passwords)
+}
+
+// HashPasswordsContext is the same as HashPasswords, but the context is checked before hashing each password. Once it's
+// cancelled, the remaining results have the context's error.
+func (l *LocalPasswordHasher) HashPasswordsContext(ctx context.Context, passwords []string) (results []gocrypt.HashResult, err error) {
+	results = make([]gocrypt.HashResult, len(passwords))
+	for i, password := range passwords {
+		results[i].Hash, results[i].Err = l.HashPasswordContext(ctx, password)
+	}
+	return results, nil
+}
+
+// ValidatePasswords validates the password of each pair against its hash locally, one at a time. Each result has its
+// own error, and err is always nil.
+func (l *LocalPasswordHasher) ValidatePasswords(pairs []gocrypt.Pair) (results []gocrypt.ValidateResult, err error) {
+	return l.ValidatePasswordsContext(context.Background(), pairs)
+}
+
+// ValidatePasswordsContext is the same as ValidatePasswords, but the context is handled the same way as in
+// HashPasswordsContext.
+func (l *LocalPasswordHasher) ValidatePasswordsContext(ctx context.Context, pairs []gocrypt.Pair) (results []gocrypt.ValidateResult, err error) {
+	results = make([]gocrypt.ValidateResult, len(pairs))
+	for i, pair := range pairs {
+		results[i].IsValid, results[i].Err = l.ValidatePasswordContext(ctx, pair.Password, pair.Hash)
+	}
+	return results, nil
+}
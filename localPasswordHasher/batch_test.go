@@ -0,0 +1,35 @@
+package localPasswordHasher
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rsheasby/gocrypt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordHasherShouldHashAndValidateBatches(t *testing.T) {
+	ph, _ := New(4)
+
+	results, err := ph.HashPasswords([]string{"abc", "", "def"})
+	assert.Nil(t, err, "Batch hashing shouldn't return an error.")
+	assert.Len(t, results, 3, "There should be a result for each password.")
+	assert.Nil(t, results[0].Err, "Valid passwords should be hashed without an error.")
+	assert.NotNil(t, results[1].Err, "Empty passwords should only fail their own result.")
+	assert.Nil(t, results[2].Err, "Passwords after a failed one should still be hashed.")
+
+	validations, err := ph.ValidatePasswords([]gocrypt.Pair{
+		{Password: "abc", Hash: results[0].Hash},
+		{Password: "abc", Hash: results[2].Hash},
+		{Password: "abc", Hash: "invalid"},
+	})
+	assert.Nil(t, err, "Batch validation shouldn't return an error.")
+	assert.True(t, validations[0].IsValid, "Matching passwords should validate as correct.")
+	assert.False(t, validations[1].IsValid, "Passwords should only match their own hash.")
+	assert.NotNil(t, validations[2].Err, "Invalid hashes should only fail their own result.")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, _ = ph.HashPasswordsContext(ctx, []string{"abc"})
+	assert.Equal(t, context.Canceled, results[0].Err, "Results should have the context error once it's cancelled.")
+}
@@ -1,24 +1,38 @@
 package localPasswordHasher
 
 import (
+	"context"
 	"crypto/sha512"
 	"fmt"
 
-	"golang.org/x/crypto/bcrypt"
+	"github.com/rsheasby/gocrypt/hashAlgorithms"
 )
 
 // LocalPasswordHasher performs password hashing locally, without requiring a remote gocrypt agent.
 type LocalPasswordHasher struct {
-	cost int
+	params       hashAlgorithms.Params
+	legacyBcrypt bool
+	peppers      *hashAlgorithms.Peppers
 }
 
-// New creates a new LocalPasswordHasher instance. This fails if the cost is not within acceptable bounds.
-func New(cost int) (lph *LocalPasswordHasher, err error) {
-	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
-		return nil, fmt.Errorf(`cost %d is invalid - cost must be between %d and %d`, cost, bcrypt.MinCost,
-			bcrypt.MaxCost)
+// New creates a new LocalPasswordHasher instance. Bcrypt is used with the provided cost unless a different algorithm is
+// specified using the options. This fails if the cost or other parameters are not within acceptable bounds.
+func New(cost int, opts ...Option) (lph *LocalPasswordHasher, err error) {
+	lph = &LocalPasswordHasher{
+		params: hashAlgorithms.Params{
+			Algorithm: hashAlgorithms.Bcrypt,
+			Cost:      cost,
+		},
 	}
-	return &LocalPasswordHasher{cost: cost}, nil
+	for _, opt := range opts {
+		opt(lph)
+	}
+
+	err = lph.params.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return lph, nil
 }
 
 // HashPassword hashes the provided password locally.
@@ -28,27 +42,82 @@ func (l *LocalPasswordHasher) HashPassword(password string) (hash string, err er
 	}
 
 	shaBytes := sha512.Sum512([]byte(password))
-	hashBytes, err := bcrypt.GenerateFromPassword(shaBytes[:], l.cost)
-	if err != nil {
-		// This should never fail, except for maybe OOM errors. May as well return the error just in-case anyway though.
-		return "", err
+	// This should never fail, except for maybe OOM errors. May as well return the error just in-case anyway though.
+	return l.peppers.Hash(shaBytes[:], l.params)
+}
+
+// HashPasswordContext hashes the provided password locally. Hashing can't be interrupted once it's started, so the
+// context is only checked before hashing.
+func (l *LocalPasswordHasher) HashPasswordContext(ctx context.Context, password string) (hash string, err error) {
+	if ctx.Err() != nil {
+		return "", ctx.Err()
 	}
-	return string(hashBytes), nil
+	return l.HashPassword(password)
 }
 
-// ValidatePassword validates the password against the provided password hash.
+// ValidatePassword validates the password against the provided password hash. The hashing algorithm is detected from
+// the hash, so hashes from any supported algorithm can be validated, including legacy PBKDF2 and SHA-512 crypt hashes.
 func (l *LocalPasswordHasher) ValidatePassword(password string, hash string) (isValid bool, err error) {
+	isValid, _, err = l.validate(password, hash)
+	return isValid, err
+}
+
+// validate validates the password against the provided hash, and also returns if the hash is a legacy hash which should
+// be replaced.
+func (l *LocalPasswordHasher) validate(password string, hash string) (isValid bool, isLegacy bool, err error) {
 	if len(password) == 0 {
-		return false, fmt.Errorf("password cannot be empty")
+		return false, false, fmt.Errorf("password cannot be empty")
 	}
 
 	pwdHash := sha512.Sum512([]byte(password))
-	err = bcrypt.CompareHashAndPassword([]byte(hash), pwdHash[:])
-	if err == nil {
-		return true, nil
-	} else if err == bcrypt.ErrMismatchedHashAndPassword {
-		return false, nil
-	} else {
-		return false, err
+	var rawPassword []byte
+	if l.legacyBcrypt || hashAlgorithms.IsLegacy(hash) {
+		rawPassword = []byte(password)
+	}
+	return l.peppers.CompareWithLegacy(pwdHash[:], rawPassword, hash)
+}
+
+// ValidatePasswordContext validates the password against the provided password hash. As with HashPasswordContext, the
+// context is only checked before validating.
+func (l *LocalPasswordHasher) ValidatePasswordContext(ctx context.Context, password string, hash string) (isValid bool, err error) {
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	return l.ValidatePassword(password, hash)
+}
+
+// ValidateAndRehash validates the password against the provided password hash, and returns a new hash if the password
+// is valid but the provided hash uses a different algorithm or lower parameters than this LocalPasswordHasher, or isn't
+// peppered with the current pepper key. Legacy hashes are always replaced with a native hash.
+func (l *LocalPasswordHasher) ValidateAndRehash(password string, hash string) (isValid bool, newHash string, err error) {
+	isValid, isLegacy, err := l.validate(password, hash)
+	if err != nil || !isValid {
+		return isValid, "", err
+	}
+
+	needsRehash := isLegacy
+	if !needsRehash {
+		needsRehash, err = l.peppers.NeedsRehash(hash, l.params)
+		if err != nil {
+			return false, "", err
+		}
+	}
+	if !needsRehash {
+		return true, "", nil
+	}
+
+	newHash, err = l.HashPassword(password)
+	if err != nil {
+		return false, "", err
+	}
+	return true, newHash, nil
+}
+
+// ValidateAndRehashContext is the same as ValidateAndRehash, but as with HashPasswordContext, the context is only
+// checked before validating.
+func (l *LocalPasswordHasher) ValidateAndRehashContext(ctx context.Context, password string, hash string) (isValid bool, newHash string, err error) {
+	if ctx.Err() != nil {
+		return false, "", ctx.Err()
 	}
+	return l.ValidateAndRehash(password, hash)
 }
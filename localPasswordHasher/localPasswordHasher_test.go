@@ -1,9 +1,12 @@
 package localPasswordHasher
 
 import (
+	"context"
 	"crypto/sha512"
+	"strings"
 	"testing"
 
+	"github.com/rsheasby/gocrypt/hashAlgorithms"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -73,3 +76,179 @@ func TestPasswordHasherShouldValidatePasswordsCorrectly(t *testing.T) {
 	assert.NotNil(t, err, "Password validation should return an error with an empty password provided.")
 	assert.False(t, isValid, "Password should validate as incorrect with an empty password provided.")
 }
+
+func TestPasswordHasherShouldRehashPasswordsCorrectly(t *testing.T) {
+	cost := 5
+	ph, _ := New(cost)
+
+	pwd := "abc"
+	pwdSha := sha512.Sum512([]byte(pwd))
+	oldHash, _ := bcrypt.GenerateFromPassword(pwdSha[:], cost-1)
+	currentHash, _ := bcrypt.GenerateFromPassword(pwdSha[:], cost)
+
+	isValid, newHash, err := ph.ValidateAndRehash(pwd, string(oldHash))
+	assert.Nil(t, err, "Rehashing shouldn't return an error with a valid hash.")
+	assert.True(t, isValid, "Password should validate as correct.")
+	assert.Nil(t, bcrypt.CompareHashAndPassword([]byte(newHash), pwdSha[:]), "New hash should validate with the provided password.")
+	newCost, _ := bcrypt.Cost([]byte(newHash))
+	assert.Equal(t, cost, newCost, "New hash should use the hasher's cost.")
+
+	isValid, newHash, err = ph.ValidateAndRehash(pwd, string(currentHash))
+	assert.Nil(t, err, "Rehashing shouldn't return an error with a valid hash.")
+	assert.True(t, isValid, "Password should validate as correct.")
+	assert.Empty(t, newHash, "No new hash should be returned when the hash cost is sufficient.")
+
+	isValid, newHash, err = ph.ValidateAndRehash("ABC", string(oldHash))
+	assert.Nil(t, err, "Rehashing shouldn't return an error with a valid hash.")
+	assert.False(t, isValid, "Password should validate as incorrect.")
+	assert.Empty(t, newHash, "No new hash should be returned when the password is incorrect.")
+
+	isValid, newHash, err = ph.ValidateAndRehash(pwd, string(oldHash[:32]))
+	assert.NotNil(t, err, "Rehashing should return an error with an invalid hash.")
+	assert.False(t, isValid, "Password should validate as incorrect with an invalid hash.")
+	assert.Empty(t, newHash, "No new hash should be returned with an invalid hash.")
+}
+
+func TestPasswordHasherShouldHonorContextCancellation(t *testing.T) {
+	cost := 4
+	ph, _ := New(cost)
+
+	hash, err := ph.HashPasswordContext(context.Background(), "abc")
+	assert.Nil(t, err, "Password hashing shouldn't return an err.")
+
+	isValid, err := ph.ValidatePasswordContext(context.Background(), "abc", hash)
+	assert.Nil(t, err, "Password validation shouldn't return an error with a valid hash.")
+	assert.True(t, isValid, "Password should validate as correct.")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = ph.HashPasswordContext(ctx, "abc")
+	assert.Equal(t, context.Canceled, err, "Password hashing should return the context error when it's cancelled.")
+
+	isValid, err = ph.ValidatePasswordContext(ctx, "abc", hash)
+	assert.Equal(t, context.Canceled, err, "Password validation should return the context error when it's cancelled.")
+	assert.False(t, isValid, "Password should validate as incorrect when the context is cancelled.")
+
+	isValid, newHash, err := ph.ValidateAndRehashContext(ctx, "abc", hash)
+	assert.Equal(t, context.Canceled, err, "Rehashing should return the context error when it's cancelled.")
+	assert.False(t, isValid, "Password should validate as incorrect when the context is cancelled.")
+	assert.Empty(t, newHash, "No new hash should be returned when the context is cancelled.")
+}
+
+func TestPasswordHasherShouldSupportOtherAlgorithms(t *testing.T) {
+	bcryptPh, _ := New(4)
+	argon2idPh, err := New(0, WithArgon2id(64, 1, 1))
+	assert.Nil(t, err, "New shouldn't return any errors with valid argon2id parameters.")
+	scryptPh, err := New(hashAlgorithms.MinScryptCost, WithScrypt(1))
+	assert.Nil(t, err, "New shouldn't return any errors with valid scrypt parameters.")
+
+	_, err = New(0, WithArgon2id(0, 1, 1))
+	assert.NotNil(t, err, "New should return an error with invalid argon2id parameters.")
+	_, err = New(4, WithScrypt(1))
+	assert.NotNil(t, err, "New should return an error with invalid scrypt parameters.")
+
+	pwd := "abc"
+	argon2idHash, err := argon2idPh.HashPassword(pwd)
+	assert.Nil(t, err, "Password hashing shouldn't return an err.")
+	assert.True(t, strings.HasPrefix(argon2idHash, "$argon2id$"), "Argon2id hasher should produce argon2id hashes.")
+	scryptHash, err := scryptPh.HashPassword(pwd)
+	assert.Nil(t, err, "Password hashing shouldn't return an err.")
+	assert.True(t, strings.HasPrefix(scryptHash, "$scrypt$"), "Scrypt hasher should produce scrypt hashes.")
+
+	// Any hasher should be able to validate hashes from any algorithm
+	for _, hash := range []string{argon2idHash, scryptHash} {
+		isValid, err := bcryptPh.ValidatePassword(pwd, hash)
+		assert.Nil(t, err, "Password validation shouldn't return an error with a valid hash.")
+		assert.True(t, isValid, "Password should validate as correct.")
+
+		isValid, err = bcryptPh.ValidatePassword("ABC", hash)
+		assert.Nil(t, err, "Password validation shouldn't return an error with a valid hash.")
+		assert.False(t, isValid, "Password should validate as incorrect.")
+	}
+
+	// Rehashing should move hashes to the hasher's algorithm
+	bcryptHash, _ := bcryptPh.HashPassword(pwd)
+	isValid, newHash, err := argon2idPh.ValidateAndRehash(pwd, bcryptHash)
+	assert.Nil(t, err, "Rehashing shouldn't return an error with a valid hash.")
+	assert.True(t, isValid, "Password should validate as correct.")
+	assert.True(t, strings.HasPrefix(newHash, "$argon2id$"), "Bcrypt hash should be rehashed with argon2id.")
+
+	isValid, newHash, err = argon2idPh.ValidateAndRehash(pwd, argon2idHash)
+	assert.Nil(t, err, "Rehashing shouldn't return an error with a valid hash.")
+	assert.True(t, isValid, "Password should validate as correct.")
+	assert.Empty(t, newHash, "No new hash should be returned when the hash already uses the hasher's parameters.")
+}
+
+func TestPasswordHasherShouldValidateAndMigrateLegacyHashes(t *testing.T) {
+	ph, _ := New(4)
+	legacyBcryptPh, _ := New(4, WithLegacyBcrypt())
+
+	pbkdf2Hash := "pbkdf2_sha256$1000$seasalt$YIWkt6M1JFXrHg5s0jZjBSc7C2Cz6QvchSJ0h8Y+i7c="
+	cryptHash := "$6$rounds=1000$abcdefgh$wuAp2XWwaaguzVxZjeM2bd1yLSqbC/I9sr9DFeOfIPoAZiIj3ecL6rf9ibuAg8RDmh1vqbaeL0NSLJtGPF7b60"
+	plainBcryptHash := "$2y$10$z3QlTH2S0HFcX0bXY6B.8OQ.jj4mbdYPho4PnhEgM0qk2kbFNnw92"
+
+	for _, hash := range []string{pbkdf2Hash, cryptHash} {
+		isValid, err := ph.ValidatePassword("password", hash)
+		assert.Nil(t, err, "Validating legacy hash %q shouldn't fail", hash)
+		assert.True(t, isValid, "Correct password should validate with legacy hash %q", hash)
+
+		isValid, err = ph.ValidatePassword("wrong", hash)
+		assert.Nil(t, err, "Validating legacy hash %q shouldn't fail", hash)
+		assert.False(t, isValid, "Incorrect password shouldn't validate with legacy hash %q", hash)
+
+		isValid, newHash, err := ph.ValidateAndRehash("password", hash)
+		assert.Nil(t, err, "Rehashing legacy hash %q shouldn't fail", hash)
+		assert.True(t, isValid, "Correct password should validate with legacy hash %q", hash)
+		assert.True(t, strings.HasPrefix(newHash, "$2a$04$"), "Legacy hash %q should be replaced with a native hash", hash)
+		isValid, _ = ph.ValidatePassword("password", newHash)
+		assert.True(t, isValid, "Replacement hash should validate with the correct password")
+	}
+
+	isValid, _ := ph.ValidatePassword("password", plainBcryptHash)
+	assert.False(t, isValid, "Plain bcrypt hashes shouldn't validate without WithLegacyBcrypt")
+
+	isValid, newHash, err := legacyBcryptPh.ValidateAndRehash("password", plainBcryptHash)
+	assert.Nil(t, err, "Rehashing a plain bcrypt hash shouldn't fail")
+	assert.True(t, isValid, "Plain bcrypt hashes should validate with WithLegacyBcrypt")
+	assert.NotEqual(t, "", newHash, "Plain bcrypt hashes should be replaced even if the cost is sufficient")
+	isValid, _ = ph.ValidatePassword("password", newHash)
+	assert.True(t, isValid, "Replacement hash should be a native hash")
+
+	nativeHash, _ := legacyBcryptPh.HashPassword("password")
+	isValid, newHash, _ = legacyBcryptPh.ValidateAndRehash("password", nativeHash)
+	assert.True(t, isValid, "Native hashes should still validate with WithLegacyBcrypt")
+	assert.Equal(t, "", newHash, "Native hashes shouldn't be replaced with WithLegacyBcrypt")
+}
+
+func TestPasswordHasherShouldPepperAndRotateKeys(t *testing.T) {
+	oldPeppers, _ := hashAlgorithms.NewPeppers("old", map[string][]byte{"old": []byte("0123456789abcdef")})
+	newPeppers, _ := hashAlgorithms.NewPeppers("new", map[string][]byte{
+		"old": []byte("0123456789abcdef"),
+		"new": []byte("fedcba9876543210"),
+	})
+	ph, _ := New(4)
+	oldPh, _ := New(4, WithPeppers(oldPeppers))
+	newPh, _ := New(4, WithPeppers(newPeppers))
+
+	oldHash, err := oldPh.HashPassword("password")
+	assert.Nil(t, err, "Hashing with a pepper shouldn't fail")
+	assert.True(t, strings.HasPrefix(oldHash, "$pepper$old$"), "Hash should be peppered with the current key")
+
+	_, err = ph.ValidatePassword("password", oldHash)
+	assert.NotNil(t, err, "Validating a peppered hash without the pepper should fail")
+
+	isValid, newHash, err := newPh.ValidateAndRehash("password", oldHash)
+	assert.Nil(t, err, "Validating a hash peppered with an old key shouldn't fail")
+	assert.True(t, isValid, "Hash peppered with an old key should validate")
+	assert.True(t, strings.HasPrefix(newHash, "$pepper$new$"), "Hash should be moved onto the current key")
+
+	isValid, newHash, _ = newPh.ValidateAndRehash("password", newHash)
+	assert.True(t, isValid, "Hash peppered with the current key should validate")
+	assert.Equal(t, "", newHash, "Hash peppered with the current key shouldn't be replaced")
+
+	unpepperedHash, _ := ph.HashPassword("password")
+	isValid, newHash, _ = newPh.ValidateAndRehash("password", unpepperedHash)
+	assert.True(t, isValid, "Unpeppered hash should still validate")
+	assert.True(t, strings.HasPrefix(newHash, "$pepper$new$"), "Unpeppered hash should be replaced with a peppered hash")
+}
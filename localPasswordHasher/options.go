@@ -0,0 +1,44 @@
+package localPasswordHasher
+
+import "github.com/rsheasby/gocrypt/hashAlgorithms"
+
+// Option configures optional settings for a LocalPasswordHasher.
+type Option func(l *LocalPasswordHasher)
+
+// WithArgon2id makes the hasher use Argon2id instead of bcrypt, with the specified memory in KiB, iterations and
+// parallelism. The cost provided to New is ignored.
+func WithArgon2id(memory uint32, iterations uint32, parallelism uint32) Option {
+	return func(l *LocalPasswordHasher) {
+		l.params.Algorithm = hashAlgorithms.Argon2id
+		l.params.Memory = memory
+		l.params.Iterations = iterations
+		l.params.Parallelism = parallelism
+	}
+}
+
+// WithScrypt makes the hasher use scrypt instead of bcrypt. The cost provided to New is used as the base 2 logarithm of
+// the scrypt N parameter, and parallelism is used as the p parameter.
+func WithScrypt(parallelism uint32) Option {
+	return func(l *LocalPasswordHasher) {
+		l.params.Algorithm = hashAlgorithms.Scrypt
+		l.params.Parallelism = parallelism
+	}
+}
+
+// WithLegacyBcrypt makes the hasher also accept plain bcrypt hashes of the raw password, such as those imported from
+// other systems, which would otherwise fail to validate as they aren't pre-hashed with SHA-512. ValidateAndRehash
+// replaces these with native hashes.
+func WithLegacyBcrypt() Option {
+	return func(l *LocalPasswordHasher) {
+		l.legacyBcrypt = true
+	}
+}
+
+// WithPeppers makes the hasher pepper new hashes with the current key of the provided peppers, and validate peppered
+// hashes using the key matching their key ID. Unpeppered hashes are still accepted, and ValidateAndRehash replaces
+// them, as well as hashes peppered with old keys.
+func WithPeppers(peppers *hashAlgorithms.Peppers) Option {
+	return func(l *LocalPasswordHasher) {
+		l.peppers = peppers
+	}
+}
@@ -0,0 +1,119 @@
+// Package loopbackAgent implements a minimal gocrypt agent which runs inside the client's process, using the in-memory
+// transport. It validates and handles requests using the same requestHandler package as the gocrypt agent, but one at a
+// time, and without metrics, logging or retries, so that the RemotePasswordHasher can be used and tested without redis
+// or a separately running agent.
+package loopbackAgent
+
+import (
+	"context"
+
+	"github.com/rsheasby/gocrypt/envelope"
+	"github.com/rsheasby/gocrypt/hashAlgorithms"
+	"github.com/rsheasby/gocrypt/protocol"
+	"github.com/rsheasby/gocrypt/requestHandler"
+	"github.com/rsheasby/gocrypt/transport"
+	"github.com/rsheasby/gocrypt/transport/memoryTransport"
+)
+
+// Option configures optional settings for the loopback agent.
+type Option func(a *agent)
+
+// WithPeppers makes the agent pepper new hashes with the current pepper key, and validate peppered hashes using the key
+// matching their key ID, like the agent's PEPPER_KEYS setting.
+func WithPeppers(peppers *hashAlgorithms.Peppers) Option {
+	return func(a *agent) {
+		a.peppers = peppers
+	}
+}
+
+// WithEnvelope makes the agent open requests and seal responses using the envelope keys, like the agent's
+// ENVELOPE_KEYS setting. Requests which aren't sealed are refused.
+func WithEnvelope(keys *envelope.Keys) Option {
+	return func(a *agent) {
+		a.envelope = keys
+	}
+}
+
+// agent handles the requests received from its transport.
+type agent struct {
+	transport transport.Transport
+	peppers   *hashAlgorithms.Peppers
+	envelope  *envelope.Keys
+}
+
+// Start runs an agent using a new in-memory transport, and returns the transport. A RemotePasswordHasher created using
+// remotePasswordHasher.WithTransport with this transport sends its requests to the agent. The agent stops when the
+// context is cancelled.
+func Start(ctx context.Context, opts ...Option) (t *memoryTransport.Transport) {
+	t = memoryTransport.New()
+	a := &agent{
+		transport: t,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	go a.run(ctx)
+	return t
+}
+
+// run handles requests until the context is cancelled.
+func (a *agent) run(ctx context.Context) {
+	for {
+		delivery, err := a.transport.ReceiveRequest(ctx)
+		if err != nil {
+			return
+		}
+		a.handle(delivery.Body())
+		_ = delivery.Ack()
+	}
+}
+
+// handle handles a single request and publishes the response, using the same validation and handling as the gocrypt
+// agent with the default settings. Requests which can't be opened or unmarshalled, or which don't have a response key,
+// can't be responded to, so they're dropped.
+func (a *agent) handle(reqBytes []byte) {
+	req, err := requestHandler.OpenRequest(reqBytes, a.envelope)
+	if err != nil {
+		return
+	}
+	err = requestHandler.ValidateRequest(req, requestHandler.DefaultMinResponseKeyLength)
+	if err != nil {
+		if len(req.ResponseKey) != 0 {
+			a.publishError(protocol.Response_INVALID_REQUEST, err, req)
+		}
+		return
+	}
+	serverTime, _ := a.transport.ServerTime()
+	_, err = requestHandler.CheckExpiry(req, serverTime)
+	if err != nil {
+		a.publishError(protocol.Response_EXPIRED, err, req)
+		return
+	}
+
+	handler := &requestHandler.Handler{
+		Peppers: a.peppers,
+	}
+	res, err := handler.Handle(req)
+	if err != nil {
+		a.publishError(protocol.Response_INVALID_HASH, err, req)
+		return
+	}
+	a.publish(res, req)
+}
+
+// publishError publishes an error response with the provided error code and the error's message.
+func (a *agent) publishError(code protocol.Response_ErrorCode, err error, req *protocol.Request) {
+	a.publish(&protocol.Response{
+		ErrorCode:    code,
+		ErrorMessage: err.Error(),
+	}, req)
+}
+
+// publish publishes the response to the request. The response is only published once.
+func (a *agent) publish(res *protocol.Response, req *protocol.Request) {
+	responseKey, resBytes, err := requestHandler.EncodeResponse(res, req, a.envelope)
+	if err != nil {
+		return
+	}
+	_, _ = a.transport.PublishResponse(responseKey, resBytes)
+}
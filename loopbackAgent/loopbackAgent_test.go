@@ -0,0 +1,96 @@
+package loopbackAgent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rsheasby/gocrypt/envelope"
+	"github.com/rsheasby/gocrypt/protocol"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+// responseKey is long enough to be accepted by the agent.
+const responseKey = "0123456789abcdef"
+
+func TestLoopbackAgentShouldHandleRequests(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tr := Start(ctx)
+
+	req := &protocol.Request{
+		RequestType:     protocol.Request_HASHPASSWORD,
+		ResponseKey:     responseKey,
+		Password:        []byte("password"),
+		Cost:            4,
+		ExpiryTimestamp: time.Now().Add(time.Second).UnixNano(),
+	}
+	reqBytes, _ := proto.Marshal(req)
+	awaiter, _ := tr.AwaitResponse(responseKey)
+	defer awaiter.Close()
+	_ = tr.SubmitRequest(reqBytes)
+	resBytes, err := awaiter.Wait(ctx, time.Second)
+	assert.Nil(t, err, "The response should be published on the response key")
+	res := &protocol.Response{}
+	_ = proto.Unmarshal(resBytes, res)
+	assert.NotEmpty(t, res.Hash, "The password should be hashed")
+
+	// Responses to requests with a response channel are wrapped, so that the client can route them.
+	req.ResponseChannel = "channel"
+	req.Cost = 0
+	reqBytes, _ = proto.Marshal(req)
+	channelAwaiter, _ := tr.AwaitResponse("channel")
+	defer channelAwaiter.Close()
+	_ = tr.SubmitRequest(reqBytes)
+	resBytes, err = channelAwaiter.Wait(ctx, time.Second)
+	assert.Nil(t, err, "The response should be published on the response channel")
+	channelRes := &protocol.ChannelResponse{}
+	_ = proto.Unmarshal(resBytes, channelRes)
+	assert.Equal(t, responseKey, channelRes.ResponseKey, "The response should be wrapped with its response key")
+	_ = proto.Unmarshal(channelRes.Response, res)
+	assert.Equal(t, protocol.Response_INVALID_REQUEST, res.ErrorCode,
+		"Requests with invalid parameters should be refused")
+
+	// Response keys are held to the same minimum length as the gocrypt agent's default.
+	req.ResponseKey = "key"
+	req.Cost = 4
+	reqBytes, _ = proto.Marshal(req)
+	_ = tr.SubmitRequest(reqBytes)
+	resBytes, err = channelAwaiter.Wait(ctx, time.Second)
+	assert.Nil(t, err, "The response should be published on the response channel")
+	_ = proto.Unmarshal(resBytes, channelRes)
+	_ = proto.Unmarshal(channelRes.Response, res)
+	assert.Equal(t, protocol.Response_INVALID_REQUEST, res.ErrorCode,
+		"Requests with short response keys should be refused")
+}
+
+func TestLoopbackAgentShouldRefuseRequestsWithoutAnEnvelope(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	keys, _ := envelope.NewKeys("1", map[string][]byte{"1": []byte("0123456789abcdef0123456789abcdef")})
+	tr := Start(ctx, WithEnvelope(keys))
+
+	reqBytes, _ := proto.Marshal(&protocol.Request{
+		RequestType:     protocol.Request_HASHPASSWORD,
+		ResponseKey:     responseKey,
+		Password:        []byte("password"),
+		Cost:            4,
+		ExpiryTimestamp: time.Now().Add(time.Second).UnixNano(),
+	})
+	awaiter, _ := tr.AwaitResponse(responseKey)
+	defer awaiter.Close()
+	_ = tr.SubmitRequest(reqBytes)
+	_, err := awaiter.Wait(ctx, 100*time.Millisecond)
+	assert.Error(t, err, "Requests which aren't sealed shouldn't be responded to")
+
+	sealed, _ := keys.SealRequest(reqBytes)
+	_ = tr.SubmitRequest(sealed)
+	resBytes, err := awaiter.Wait(ctx, time.Second)
+	assert.Nil(t, err, "Sealed requests should be responded to")
+	resBytes, err = keys.OpenResponse(responseKey, resBytes)
+	assert.Nil(t, err, "The response should be sealed")
+	res := &protocol.Response{}
+	_ = proto.Unmarshal(resBytes, res)
+	assert.NotEmpty(t, res.Hash, "The password should be hashed")
+}
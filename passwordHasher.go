@@ -1,8 +1,58 @@
 package gocrypt
 
+import "context"
+
 type PasswordHasher interface {
 	// HashPassword returns a hash of the provided password for storage in a database.
 	HashPassword(password string) (hash string, err error)
 	// ValidatePassword takes a password and the stored hash, and returns whether the password is valid.
 	ValidatePassword(password string, hash string) (isValid bool, err error)
 }
+
+// PasswordRehasher is a PasswordHasher that can also upgrade outdated hashes while validating them.
+type PasswordRehasher interface {
+	PasswordHasher
+	// ValidateAndRehash takes a password and the stored hash, and returns whether the password is valid. If the password
+	// is valid, but the stored hash uses a different algorithm or lower cost parameters than the hasher, a new hash is
+	// also returned to replace the stored hash. Otherwise, newHash is empty.
+	ValidateAndRehash(password string, hash string) (isValid bool, newHash string, err error)
+}
+
+// PasswordHasherContext is a PasswordHasher which also accepts a context, so that callers can pass on their own
+// deadlines, and stop waiting when the context is cancelled.
+type PasswordHasherContext interface {
+	PasswordHasher
+	// HashPasswordContext is the same as HashPassword, but honours the context's deadline and cancellation.
+	HashPasswordContext(ctx context.Context, password string) (hash string, err error)
+	// ValidatePasswordContext is the same as ValidatePassword, but honours the context's deadline and cancellation.
+	ValidatePasswordContext(ctx context.Context, password string, hash string) (isValid bool, err error)
+}
+
+// Pair is a password along with the stored hash to validate it against.
+type Pair struct {
+	Password string
+	Hash     string
+}
+
+// HashResult is the result of hashing one of the passwords in a batch.
+type HashResult struct {
+	Hash string
+	Err  error
+}
+
+// ValidateResult is the result of validating one of the pairs in a batch.
+type ValidateResult struct {
+	IsValid bool
+	Err     error
+}
+
+// BatchPasswordHasher is a PasswordHasher which can also hash or validate many passwords at once, such as when importing
+// or migrating users. The results are in the same order as the passwords or pairs, and each has its own error. err is
+// only returned if the batch couldn't be attempted at all, in which case results is nil.
+type BatchPasswordHasher interface {
+	PasswordHasher
+	// HashPasswords hashes each of the passwords.
+	HashPasswords(passwords []string) (results []HashResult, err error)
+	// ValidatePasswords validates the password of each pair against its hash.
+	ValidatePasswords(pairs []Pair) (results []ValidateResult, err error)
+}
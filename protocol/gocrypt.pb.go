@@ -69,6 +69,107 @@ func (Request_RequestType) EnumDescriptor() ([]byte, []int) {
 	return file_gocrypt_proto_rawDescGZIP(), []int{0, 0}
 }
 
+type Request_Algorithm int32
+
+const (
+	Request_BCRYPT   Request_Algorithm = 0
+	Request_ARGON2ID Request_Algorithm = 1
+	Request_SCRYPT   Request_Algorithm = 2
+)
+
+// Enum value maps for Request_Algorithm.
+var (
+	Request_Algorithm_name = map[int32]string{
+		0: "BCRYPT",
+		1: "ARGON2ID",
+		2: "SCRYPT",
+	}
+	Request_Algorithm_value = map[string]int32{
+		"BCRYPT":   0,
+		"ARGON2ID": 1,
+		"SCRYPT":   2,
+	}
+)
+
+func (x Request_Algorithm) Enum() *Request_Algorithm {
+	p := new(Request_Algorithm)
+	*p = x
+	return p
+}
+
+func (x Request_Algorithm) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Request_Algorithm) Descriptor() protoreflect.EnumDescriptor {
+	return file_gocrypt_proto_enumTypes[1].Descriptor()
+}
+
+func (Request_Algorithm) Type() protoreflect.EnumType {
+	return &file_gocrypt_proto_enumTypes[1]
+}
+
+func (x Request_Algorithm) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Request_Algorithm.Descriptor instead.
+func (Request_Algorithm) EnumDescriptor() ([]byte, []int) {
+	return file_gocrypt_proto_rawDescGZIP(), []int{0, 1}
+}
+
+type Response_ErrorCode int32
+
+const (
+	Response_NONE            Response_ErrorCode = 0
+	Response_INVALID_REQUEST Response_ErrorCode = 1
+	Response_EXPIRED         Response_ErrorCode = 2
+	Response_INVALID_HASH    Response_ErrorCode = 3
+)
+
+// Enum value maps for Response_ErrorCode.
+var (
+	Response_ErrorCode_name = map[int32]string{
+		0: "NONE",
+		1: "INVALID_REQUEST",
+		2: "EXPIRED",
+		3: "INVALID_HASH",
+	}
+	Response_ErrorCode_value = map[string]int32{
+		"NONE":            0,
+		"INVALID_REQUEST": 1,
+		"EXPIRED":         2,
+		"INVALID_HASH":    3,
+	}
+)
+
+func (x Response_ErrorCode) Enum() *Response_ErrorCode {
+	p := new(Response_ErrorCode)
+	*p = x
+	return p
+}
+
+func (x Response_ErrorCode) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Response_ErrorCode) Descriptor() protoreflect.EnumDescriptor {
+	return file_gocrypt_proto_enumTypes[2].Descriptor()
+}
+
+func (Response_ErrorCode) Type() protoreflect.EnumType {
+	return &file_gocrypt_proto_enumTypes[2]
+}
+
+func (x Response_ErrorCode) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Response_ErrorCode.Descriptor instead.
+func (Response_ErrorCode) EnumDescriptor() ([]byte, []int) {
+	return file_gocrypt_proto_rawDescGZIP(), []int{1, 0}
+}
+
 type Request struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -80,6 +181,13 @@ type Request struct {
 	Hash            string              `protobuf:"bytes,4,opt,name=hash,proto3" json:"hash,omitempty"`
 	Cost            int32               `protobuf:"varint,5,opt,name=cost,proto3" json:"cost,omitempty"`
 	ExpiryTimestamp int64               `protobuf:"varint,6,opt,name=expiryTimestamp,proto3" json:"expiryTimestamp,omitempty"`
+	Algorithm       Request_Algorithm   `protobuf:"varint,7,opt,name=algorithm,proto3,enum=gocrypt.Request_Algorithm" json:"algorithm,omitempty"`
+	Memory          uint32              `protobuf:"varint,8,opt,name=memory,proto3" json:"memory,omitempty"`
+	Iterations      uint32              `protobuf:"varint,9,opt,name=iterations,proto3" json:"iterations,omitempty"`
+	Parallelism     uint32              `protobuf:"varint,10,opt,name=parallelism,proto3" json:"parallelism,omitempty"`
+	LegacyPassword  []byte              `protobuf:"bytes,11,opt,name=legacy_password,json=legacyPassword,proto3" json:"legacy_password,omitempty"`
+	TraceContext    map[string]string   `protobuf:"bytes,12,rep,name=trace_context,json=traceContext,proto3" json:"trace_context,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	ResponseChannel string              `protobuf:"bytes,13,opt,name=response_channel,json=responseChannel,proto3" json:"response_channel,omitempty"`
 }
 
 func (x *Request) Reset() {
@@ -156,13 +264,64 @@ func (x *Request) GetExpiryTimestamp() int64 {
 	return 0
 }
 
+func (x *Request) GetAlgorithm() Request_Algorithm {
+	if x != nil {
+		return x.Algorithm
+	}
+	return Request_BCRYPT
+}
+
+func (x *Request) GetMemory() uint32 {
+	if x != nil {
+		return x.Memory
+	}
+	return 0
+}
+
+func (x *Request) GetIterations() uint32 {
+	if x != nil {
+		return x.Iterations
+	}
+	return 0
+}
+
+func (x *Request) GetParallelism() uint32 {
+	if x != nil {
+		return x.Parallelism
+	}
+	return 0
+}
+
+func (x *Request) GetLegacyPassword() []byte {
+	if x != nil {
+		return x.LegacyPassword
+	}
+	return nil
+}
+
+func (x *Request) GetTraceContext() map[string]string {
+	if x != nil {
+		return x.TraceContext
+	}
+	return nil
+}
+
+func (x *Request) GetResponseChannel() string {
+	if x != nil {
+		return x.ResponseChannel
+	}
+	return ""
+}
+
 type Response struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	IsValid bool   `protobuf:"varint,1,opt,name=is_valid,json=isValid,proto3" json:"is_valid,omitempty"`
-	Hash    string `protobuf:"bytes,2,opt,name=hash,proto3" json:"hash,omitempty"`
+	IsValid      bool               `protobuf:"varint,1,opt,name=is_valid,json=isValid,proto3" json:"is_valid,omitempty"`
+	Hash         string             `protobuf:"bytes,2,opt,name=hash,proto3" json:"hash,omitempty"`
+	ErrorCode    Response_ErrorCode `protobuf:"varint,3,opt,name=error_code,json=errorCode,proto3,enum=gocrypt.Response_ErrorCode" json:"error_code,omitempty"`
+	ErrorMessage string             `protobuf:"bytes,4,opt,name=error_message,json=errorMessage,proto3" json:"error_message,omitempty"`
 }
 
 func (x *Response) Reset() {
@@ -211,11 +370,143 @@ func (x *Response) GetHash() string {
 	return ""
 }
 
+func (x *Response) GetErrorCode() Response_ErrorCode {
+	if x != nil {
+		return x.ErrorCode
+	}
+	return Response_NONE
+}
+
+func (x *Response) GetErrorMessage() string {
+	if x != nil {
+		return x.ErrorMessage
+	}
+	return ""
+}
+
+type ChannelResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ResponseKey string `protobuf:"bytes,1,opt,name=response_key,json=responseKey,proto3" json:"response_key,omitempty"`
+	Response    []byte `protobuf:"bytes,2,opt,name=response,proto3" json:"response,omitempty"`
+}
+
+func (x *ChannelResponse) Reset() {
+	*x = ChannelResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gocrypt_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ChannelResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChannelResponse) ProtoMessage() {}
+
+func (x *ChannelResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gocrypt_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChannelResponse.ProtoReflect.Descriptor instead.
+func (*ChannelResponse) Descriptor() ([]byte, []int) {
+	return file_gocrypt_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ChannelResponse) GetResponseKey() string {
+	if x != nil {
+		return x.ResponseKey
+	}
+	return ""
+}
+
+func (x *ChannelResponse) GetResponse() []byte {
+	if x != nil {
+		return x.Response
+	}
+	return nil
+}
+
+type Envelope struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	KeyId      string `protobuf:"bytes,1,opt,name=key_id,json=keyId,proto3" json:"key_id,omitempty"`
+	Nonce      []byte `protobuf:"bytes,2,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	Ciphertext []byte `protobuf:"bytes,3,opt,name=ciphertext,proto3" json:"ciphertext,omitempty"`
+}
+
+func (x *Envelope) Reset() {
+	*x = Envelope{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gocrypt_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Envelope) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Envelope) ProtoMessage() {}
+
+func (x *Envelope) ProtoReflect() protoreflect.Message {
+	mi := &file_gocrypt_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Envelope.ProtoReflect.Descriptor instead.
+func (*Envelope) Descriptor() ([]byte, []int) {
+	return file_gocrypt_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Envelope) GetKeyId() string {
+	if x != nil {
+		return x.KeyId
+	}
+	return ""
+}
+
+func (x *Envelope) GetNonce() []byte {
+	if x != nil {
+		return x.Nonce
+	}
+	return nil
+}
+
+func (x *Envelope) GetCiphertext() []byte {
+	if x != nil {
+		return x.Ciphertext
+	}
+	return nil
+}
+
 var File_gocrypt_proto protoreflect.FileDescriptor
 
 var file_gocrypt_proto_rawDesc = []byte{
 	0x0a, 0x0d, 0x67, 0x6f, 0x63, 0x72, 0x79, 0x70, 0x74, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12,
-	0x07, 0x67, 0x6f, 0x63, 0x72, 0x79, 0x70, 0x74, 0x22, 0xad, 0x02, 0x0a, 0x07, 0x52, 0x65, 0x71,
+	0x07, 0x67, 0x6f, 0x63, 0x72, 0x79, 0x70, 0x74, 0x22, 0xd2, 0x05, 0x0a, 0x07, 0x52, 0x65, 0x71,
 	0x75, 0x65, 0x73, 0x74, 0x12, 0x3f, 0x0a, 0x0c, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x5f,
 	0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1c, 0x2e, 0x67, 0x6f, 0x63,
 	0x72, 0x79, 0x70, 0x74, 0x2e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x2e, 0x52, 0x65, 0x71,
@@ -229,17 +520,65 @@ var file_gocrypt_proto_rawDesc = []byte{
 	0x18, 0x05, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x63, 0x6f, 0x73, 0x74, 0x12, 0x28, 0x0a, 0x0f,
 	0x65, 0x78, 0x70, 0x69, 0x72, 0x79, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18,
 	0x06, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0f, 0x65, 0x78, 0x70, 0x69, 0x72, 0x79, 0x54, 0x69, 0x6d,
-	0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x22, 0x50, 0x0a, 0x0b, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
-	0x74, 0x54, 0x79, 0x70, 0x65, 0x12, 0x10, 0x0a, 0x0c, 0x48, 0x41, 0x53, 0x48, 0x50, 0x41, 0x53,
-	0x53, 0x57, 0x4f, 0x52, 0x44, 0x10, 0x00, 0x12, 0x12, 0x0a, 0x0e, 0x56, 0x45, 0x52, 0x49, 0x46,
-	0x59, 0x50, 0x41, 0x53, 0x53, 0x57, 0x4f, 0x52, 0x44, 0x10, 0x01, 0x12, 0x1b, 0x0a, 0x17, 0x56,
-	0x45, 0x52, 0x49, 0x46, 0x59, 0x50, 0x41, 0x53, 0x53, 0x57, 0x4f, 0x52, 0x44, 0x41, 0x4e, 0x44,
-	0x52, 0x45, 0x48, 0x41, 0x53, 0x48, 0x10, 0x02, 0x22, 0x39, 0x0a, 0x08, 0x52, 0x65, 0x73, 0x70,
-	0x6f, 0x6e, 0x73, 0x65, 0x12, 0x19, 0x0a, 0x08, 0x69, 0x73, 0x5f, 0x76, 0x61, 0x6c, 0x69, 0x64,
-	0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x69, 0x73, 0x56, 0x61, 0x6c, 0x69, 0x64, 0x12,
-	0x12, 0x0a, 0x04, 0x68, 0x61, 0x73, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x68,
-	0x61, 0x73, 0x68, 0x42, 0x0c, 0x5a, 0x0a, 0x2e, 0x3b, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f,
-	0x6c, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x12, 0x38, 0x0a, 0x09, 0x61, 0x6c, 0x67, 0x6f, 0x72, 0x69,
+	0x74, 0x68, 0x6d, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x63, 0x72,
+	0x79, 0x70, 0x74, 0x2e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x2e, 0x41, 0x6c, 0x67, 0x6f,
+	0x72, 0x69, 0x74, 0x68, 0x6d, 0x52, 0x09, 0x61, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d,
+	0x12, 0x16, 0x0a, 0x06, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0d,
+	0x52, 0x06, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x12, 0x1e, 0x0a, 0x0a, 0x69, 0x74, 0x65, 0x72,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0a, 0x69, 0x74,
+	0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x20, 0x0a, 0x0b, 0x70, 0x61, 0x72, 0x61,
+	0x6c, 0x6c, 0x65, 0x6c, 0x69, 0x73, 0x6d, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0b, 0x70,
+	0x61, 0x72, 0x61, 0x6c, 0x6c, 0x65, 0x6c, 0x69, 0x73, 0x6d, 0x12, 0x27, 0x0a, 0x0f, 0x6c, 0x65,
+	0x67, 0x61, 0x63, 0x79, 0x5f, 0x70, 0x61, 0x73, 0x73, 0x77, 0x6f, 0x72, 0x64, 0x18, 0x0b, 0x20,
+	0x01, 0x28, 0x0c, 0x52, 0x0e, 0x6c, 0x65, 0x67, 0x61, 0x63, 0x79, 0x50, 0x61, 0x73, 0x73, 0x77,
+	0x6f, 0x72, 0x64, 0x12, 0x47, 0x0a, 0x0d, 0x74, 0x72, 0x61, 0x63, 0x65, 0x5f, 0x63, 0x6f, 0x6e,
+	0x74, 0x65, 0x78, 0x74, 0x18, 0x0c, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x67, 0x6f, 0x63,
+	0x72, 0x79, 0x70, 0x74, 0x2e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x2e, 0x54, 0x72, 0x61,
+	0x63, 0x65, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x0c,
+	0x74, 0x72, 0x61, 0x63, 0x65, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x12, 0x29, 0x0a, 0x10,
+	0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x5f, 0x63, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c,
+	0x18, 0x0d, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0f, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x43, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x1a, 0x3f, 0x0a, 0x11, 0x54, 0x72, 0x61, 0x63, 0x65,
+	0x43, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03,
+	0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14,
+	0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x50, 0x0a, 0x0b, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x54, 0x79, 0x70, 0x65, 0x12, 0x10, 0x0a, 0x0c, 0x48, 0x41, 0x53, 0x48, 0x50,
+	0x41, 0x53, 0x53, 0x57, 0x4f, 0x52, 0x44, 0x10, 0x00, 0x12, 0x12, 0x0a, 0x0e, 0x56, 0x45, 0x52,
+	0x49, 0x46, 0x59, 0x50, 0x41, 0x53, 0x53, 0x57, 0x4f, 0x52, 0x44, 0x10, 0x01, 0x12, 0x1b, 0x0a,
+	0x17, 0x56, 0x45, 0x52, 0x49, 0x46, 0x59, 0x50, 0x41, 0x53, 0x53, 0x57, 0x4f, 0x52, 0x44, 0x41,
+	0x4e, 0x44, 0x52, 0x45, 0x48, 0x41, 0x53, 0x48, 0x10, 0x02, 0x22, 0x31, 0x0a, 0x09, 0x41, 0x6c,
+	0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x12, 0x0a, 0x0a, 0x06, 0x42, 0x43, 0x52, 0x59, 0x50,
+	0x54, 0x10, 0x00, 0x12, 0x0c, 0x0a, 0x08, 0x41, 0x52, 0x47, 0x4f, 0x4e, 0x32, 0x49, 0x44, 0x10,
+	0x01, 0x12, 0x0a, 0x0a, 0x06, 0x53, 0x43, 0x52, 0x59, 0x50, 0x54, 0x10, 0x02, 0x22, 0xe5, 0x01,
+	0x0a, 0x08, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x19, 0x0a, 0x08, 0x69, 0x73,
+	0x5f, 0x76, 0x61, 0x6c, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x69, 0x73,
+	0x56, 0x61, 0x6c, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x68, 0x61, 0x73, 0x68, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x04, 0x68, 0x61, 0x73, 0x68, 0x12, 0x3a, 0x0a, 0x0a, 0x65, 0x72, 0x72,
+	0x6f, 0x72, 0x5f, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1b, 0x2e,
+	0x67, 0x6f, 0x63, 0x72, 0x79, 0x70, 0x74, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x2e, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x43, 0x6f, 0x64, 0x65, 0x52, 0x09, 0x65, 0x72, 0x72, 0x6f,
+	0x72, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x23, 0x0a, 0x0d, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x5f, 0x6d,
+	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x65, 0x72,
+	0x72, 0x6f, 0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x49, 0x0a, 0x09, 0x45, 0x72,
+	0x72, 0x6f, 0x72, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x08, 0x0a, 0x04, 0x4e, 0x4f, 0x4e, 0x45, 0x10,
+	0x00, 0x12, 0x13, 0x0a, 0x0f, 0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49, 0x44, 0x5f, 0x52, 0x45, 0x51,
+	0x55, 0x45, 0x53, 0x54, 0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x45, 0x58, 0x50, 0x49, 0x52, 0x45,
+	0x44, 0x10, 0x02, 0x12, 0x10, 0x0a, 0x0c, 0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49, 0x44, 0x5f, 0x48,
+	0x41, 0x53, 0x48, 0x10, 0x03, 0x22, 0x50, 0x0a, 0x0f, 0x43, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x21, 0x0a, 0x0c, 0x72, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x5f, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b,
+	0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x4b, 0x65, 0x79, 0x12, 0x1a, 0x0a, 0x08, 0x72,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x08, 0x72,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x57, 0x0a, 0x08, 0x45, 0x6e, 0x76, 0x65, 0x6c,
+	0x6f, 0x70, 0x65, 0x12, 0x15, 0x0a, 0x06, 0x6b, 0x65, 0x79, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x05, 0x6b, 0x65, 0x79, 0x49, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x6e, 0x6f,
+	0x6e, 0x63, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x6e, 0x6f, 0x6e, 0x63, 0x65,
+	0x12, 0x1e, 0x0a, 0x0a, 0x63, 0x69, 0x70, 0x68, 0x65, 0x72, 0x74, 0x65, 0x78, 0x74, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x0a, 0x63, 0x69, 0x70, 0x68, 0x65, 0x72, 0x74, 0x65, 0x78, 0x74,
+	0x42, 0x0c, 0x5a, 0x0a, 0x2e, 0x3b, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x62, 0x06,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -254,20 +593,28 @@ func file_gocrypt_proto_rawDescGZIP() []byte {
 	return file_gocrypt_proto_rawDescData
 }
 
-var file_gocrypt_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_gocrypt_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_gocrypt_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
+var file_gocrypt_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
 var file_gocrypt_proto_goTypes = []interface{}{
 	(Request_RequestType)(0), // 0: gocrypt.Request.RequestType
-	(*Request)(nil),          // 1: gocrypt.Request
-	(*Response)(nil),         // 2: gocrypt.Response
+	(Request_Algorithm)(0),   // 1: gocrypt.Request.Algorithm
+	(Response_ErrorCode)(0),  // 2: gocrypt.Response.ErrorCode
+	(*Request)(nil),          // 3: gocrypt.Request
+	(*Response)(nil),         // 4: gocrypt.Response
+	(*ChannelResponse)(nil),  // 5: gocrypt.ChannelResponse
+	(*Envelope)(nil),         // 6: gocrypt.Envelope
+	nil,                      // 7: gocrypt.Request.TraceContextEntry
 }
 var file_gocrypt_proto_depIdxs = []int32{
 	0, // 0: gocrypt.Request.request_type:type_name -> gocrypt.Request.RequestType
-	1, // [1:1] is the sub-list for method output_type
-	1, // [1:1] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	1, // 1: gocrypt.Request.algorithm:type_name -> gocrypt.Request.Algorithm
+	7, // 2: gocrypt.Request.trace_context:type_name -> gocrypt.Request.TraceContextEntry
+	2, // 3: gocrypt.Response.error_code:type_name -> gocrypt.Response.ErrorCode
+	4, // [4:4] is the sub-list for method output_type
+	4, // [4:4] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
 }
 
 func init() { file_gocrypt_proto_init() }
@@ -300,14 +647,38 @@ func file_gocrypt_proto_init() {
 				return nil
 			}
 		}
+		file_gocrypt_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ChannelResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_gocrypt_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Envelope); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_gocrypt_proto_rawDesc,
-			NumEnums:      1,
-			NumMessages:   2,
+			NumEnums:      3,
+			NumMessages:   5,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -0,0 +1,22 @@
+package remotePasswordHasher
+
+import (
+	"github.com/rsheasby/gocrypt/transport"
+)
+
+// AgentInfo describes a running gocrypt agent, as registered by its heartbeat.
+type AgentInfo = transport.AgentInfo
+
+// ListAgents returns the gocrypt agents which are currently running, ordered by ID. An agent is listed until its
+// heartbeat expires, which happens shortly after it stops. transport.ErrNoAgentRegistry is returned if the transport
+// doesn't keep a registry of agents.
+func (r RemotePasswordHasher) ListAgents() (agents []AgentInfo, err error) {
+	return transport.ListAgents(r.transport)
+}
+
+// AgentsAvailable returns whether any gocrypt agents are currently running, which can be used to avoid submitting
+// requests which would only time out. transport.ErrNoAgentRegistry is returned if the transport doesn't keep a registry
+// of agents.
+func (r RemotePasswordHasher) AgentsAvailable() (available bool, err error) {
+	return transport.AgentsAvailable(r.transport)
+}
@@ -0,0 +1,39 @@
+//go:build integration
+// +build integration
+
+package remotePasswordHasher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemotePasswordHasherShouldListRunningRedisAgents(t *testing.T) {
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", "localhost:6379", redis.DialUseTLS(useTLS))
+		},
+	}
+
+	rph, _ := New(4, time.Second, pool)
+	agents, err := rph.ListAgents()
+	assert.Nil(t, err, "No error should be returned when listing agents")
+	if assert.NotEmpty(t, agents, "The running agents should be listed") {
+		assert.NotEmpty(t, agents[0].ID, "The agent's ID should be listed")
+		assert.True(t, agents[0].Threads > 0, "The agent's thread count should be listed")
+		assert.Contains(t, agents[0].Algorithms, "bcrypt", "The agent's algorithms should be listed")
+		assert.True(t, time.Since(agents[0].HeartbeatAt) < time.Minute, "The agent's heartbeat should be recent")
+	}
+	available, err := rph.AgentsAvailable()
+	assert.Nil(t, err, "No error should be returned when checking for agents")
+	assert.True(t, available, "Agents should be available")
+
+	// No agent serves this namespace.
+	unserved, _ := New(4, time.Second, pool, WithNamespace("unserved"))
+	available, err = unserved.AgentsAvailable()
+	assert.Nil(t, err, "No error should be returned when checking for agents")
+	assert.False(t, available, "No agents should be available in a namespace without agents")
+}
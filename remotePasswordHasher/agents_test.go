@@ -0,0 +1,52 @@
+package remotePasswordHasher
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rsheasby/gocrypt/transport"
+	"github.com/rsheasby/gocrypt/transport/memoryTransport"
+	"github.com/stretchr/testify/assert"
+)
+
+// registryTransport is an in-memory transport which keeps a fixed registry of agents.
+type registryTransport struct {
+	*memoryTransport.Transport
+	agents []transport.AgentInfo
+}
+
+func (r *registryTransport) ListAgents() (agents []transport.AgentInfo, err error) {
+	return r.agents, nil
+}
+
+func (r *registryTransport) AgentsAvailable() (available bool, err error) {
+	return len(r.agents) > 0, nil
+}
+
+func TestRemotePasswordHasherShouldListRunningAgents(t *testing.T) {
+	info := AgentInfo{ID: "agent", Threads: 4, Algorithms: []string{"bcrypt"}, HeartbeatAt: time.Now()}
+	rph, _ := New(4, time.Second, nil, WithTransport(&registryTransport{
+		Transport: memoryTransport.New(),
+		agents:    []transport.AgentInfo{info},
+	}))
+	agents, err := rph.ListAgents()
+	assert.Nil(t, err, "No error should be returned when listing agents")
+	assert.Equal(t, []AgentInfo{info}, agents, "The agents in the transport's registry should be listed")
+	available, err := rph.AgentsAvailable()
+	assert.Nil(t, err, "No error should be returned when checking for agents")
+	assert.True(t, available, "Agents should be available")
+
+	unserved, _ := New(4, time.Second, nil, WithTransport(&registryTransport{Transport: memoryTransport.New()}))
+	available, err = unserved.AgentsAvailable()
+	assert.Nil(t, err, "No error should be returned when checking for agents")
+	assert.False(t, available, "No agents should be available when none are registered")
+
+	other, _ := New(4, time.Second, nil, WithTransport(startLoopback(t)))
+	_, err = other.ListAgents()
+	assert.True(t, errors.Is(err, transport.ErrNoAgentRegistry),
+		"Should return ErrNoAgentRegistry when the transport doesn't keep a registry")
+	_, err = other.AgentsAvailable()
+	assert.True(t, errors.Is(err, transport.ErrNoAgentRegistry),
+		"Should return ErrNoAgentRegistry when the transport doesn't keep a registry")
+}
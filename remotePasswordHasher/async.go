@@ -0,0 +1,31 @@
+package remotePasswordHasher
+
+import (
+	"context"
+
+	"github.com/rsheasby/gocrypt"
+)
+
+// HashPasswordAsync hashes the password using a remote gocrypt agent in the background, and returns a channel which
+// receives the result once it's done. The context is handled the same way as in HashPasswordContext. Unless streams are
+// enabled, every request made by the hasher shares a single redis connection while waiting for its response, so many
+// requests can be in flight at once without using up the pool.
+func (r RemotePasswordHasher) HashPasswordAsync(ctx context.Context, password string) (result <-chan gocrypt.HashResult) {
+	results := make(chan gocrypt.HashResult, 1)
+	go func() {
+		hash, err := r.HashPasswordContext(ctx, password)
+		results <- gocrypt.HashResult{Hash: hash, Err: err}
+	}()
+	return results
+}
+
+// ValidatePasswordAsync validates the password against the provided password hash using a remote gocrypt agent in the
+// background, and returns a channel which receives the result once it's done, in the same way as HashPasswordAsync.
+func (r RemotePasswordHasher) ValidatePasswordAsync(ctx context.Context, password string, hash string) (result <-chan gocrypt.ValidateResult) {
+	results := make(chan gocrypt.ValidateResult, 1)
+	go func() {
+		isValid, err := r.ValidatePasswordContext(ctx, password, hash)
+		results <- gocrypt.ValidateResult{IsValid: isValid, Err: err}
+	}()
+	return results
+}
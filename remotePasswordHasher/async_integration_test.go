@@ -0,0 +1,109 @@
+//go:build integration
+// +build integration
+
+package remotePasswordHasher
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/rsheasby/gocrypt"
+	"github.com/rsheasby/gocrypt/protocol"
+	"github.com/rsheasby/gocrypt/transport"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestRemotePasswordHasherShouldShareOneConnectionWhileWaiting(t *testing.T) {
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", "localhost:6379", redis.DialUseTLS(useTLS))
+		},
+	}
+	// No agent serves this namespace, so the requests wait until they time out.
+	rph, _ := New(4, 500*time.Millisecond, pool, WithNamespace("unserved"))
+
+	results := make([]<-chan gocrypt.HashResult, 50)
+	for i := range results {
+		results[i] = rph.HashPasswordAsync(context.Background(), "abc")
+	}
+	time.Sleep(200 * time.Millisecond)
+	assert.LessOrEqual(t, pool.ActiveCount(), 2, "Requests waiting for responses should share a connection")
+	conn := pool.Get()
+	channels, _ := redis.Strings(conn.Do("PUBSUB", "CHANNELS", "gocrypt:unserved:Response:*"))
+	conn.Close()
+	assert.Equal(t, []string{"gocrypt:unserved:Response:" + transport.ResponseChannel(rph.transport)}, channels,
+		"Requests waiting for responses should share a single subscription to the response channel")
+	for _, result := range results {
+		assert.True(t, errors.Is((<-result).Err, ErrTimeout), "Requests without a response should time out")
+	}
+
+	assert.Nil(t, rph.Close(), "Closing the hasher should succeed")
+	assert.Equal(t, 0, pool.ActiveCount(), "The shared connection should be released once the hasher is closed")
+	_, err := rph.HashPassword("abc")
+	assert.Error(t, err, "Requests shouldn't be made once the hasher is closed")
+}
+
+func TestRemotePasswordHasherShouldResubscribeWhenTheConnectionIsLost(t *testing.T) {
+	var mu sync.Mutex
+	var conns []net.Conn
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", "localhost:6379", redis.DialUseTLS(useTLS),
+				redis.DialNetDial(func(network, addr string) (net.Conn, error) {
+					conn, err := net.Dial(network, addr)
+					if err == nil {
+						mu.Lock()
+						conns = append(conns, conn)
+						mu.Unlock()
+					}
+					return conn, err
+				}))
+		},
+	}
+	rph, err := New(4, time.Second, pool, WithClientID("resubscribe-test"))
+	assert.Nil(t, err, "No error should be returned with WithClientID")
+	defer rph.Close()
+	awaiter, err := rph.transport.AwaitResponse("resubscribe")
+	assert.Nil(t, err, "No error should be returned when waiting for a response")
+	defer awaiter.Close()
+
+	// Dropping every connection drops the subscriber's connection as well.
+	mu.Lock()
+	for _, conn := range conns {
+		conn.Close()
+	}
+	mu.Unlock()
+
+	// Responses published before the response channel is resubscribed to are lost, and a response which reached the
+	// old subscription just before it was dropped is counted as received, so it's published until the wait is over.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		publishConn, err := redis.Dial("tcp", "localhost:6379", redis.DialUseTLS(useTLS))
+		if err != nil {
+			return
+		}
+		defer publishConn.Close()
+		resBytes, _ := proto.Marshal(&protocol.ChannelResponse{ResponseKey: "resubscribe",
+			Response: []byte("response")})
+		for {
+			_, _ = publishConn.Do("PUBLISH", ResponseKeyPrefix+"Client:resubscribe-test", resBytes)
+			select {
+			case <-done:
+				return
+			case <-time.After(50 * time.Millisecond):
+			}
+		}
+	}()
+
+	res, err := awaiter.Wait(context.Background(), 5*time.Second)
+	assert.Nil(t, err, "No error should be returned once the response channel is resubscribed to")
+	assert.Equal(t, []byte("response"), res,
+		"The response should be received once the response channel is resubscribed to")
+}
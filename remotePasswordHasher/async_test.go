@@ -0,0 +1,31 @@
+package remotePasswordHasher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rsheasby/gocrypt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemotePasswordHasherShouldHashAndValidateAsynchronously(t *testing.T) {
+	rph, _ := New(4, 10*time.Second, nil, WithTransport(startLoopback(t)))
+	defer rph.Close()
+
+	hashes := make([]<-chan gocrypt.HashResult, 20)
+	for i := range hashes {
+		hashes[i] = rph.HashPasswordAsync(context.Background(), "abc")
+	}
+	validations := make([]<-chan gocrypt.ValidateResult, len(hashes))
+	for i, hash := range hashes {
+		result := <-hash
+		assert.Nil(t, result.Err, "No error should be returned when the password is hashed")
+		validations[i] = rph.ValidatePasswordAsync(context.Background(), "abc", result.Hash)
+	}
+	for _, validation := range validations {
+		result := <-validation
+		assert.Nil(t, result.Err, "No error should be returned when the password is validated")
+		assert.True(t, result.IsValid, "The password should validate against its own hash")
+	}
+}
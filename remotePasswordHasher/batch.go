@@ -0,0 +1,168 @@
+package remotePasswordHasher
+
+import (
+	"context"
+	"time"
+
+	"github.com/rsheasby/gocrypt"
+	"github.com/rsheasby/gocrypt/protocol"
+	"github.com/rsheasby/gocrypt/transport"
+)
+
+var _ gocrypt.BatchPasswordHasher = RemotePasswordHasher{}
+
+// HashPasswords hashes each of the passwords using remote gocrypt agents. The passwords are sent as a single batch,
+// which shares one server time lookup and one subscription, and submits every request in a single round trip. The
+// hasher's timeout applies to the whole batch, so hashers used for large batches need a longer timeout, and usually
+// WithPriority(PriorityBulk) as well.
+// Each result has its own error, such as ErrTimeout if its response wasn't received in time. err is only returned if
+// the batch couldn't be submitted.
+func (r RemotePasswordHasher) HashPasswords(passwords []string) (results []gocrypt.HashResult, err error) {
+	return r.HashPasswordsContext(context.Background(), passwords)
+}
+
+// HashPasswordsContext is the same as HashPasswords, but the context is handled the same way as in
+// HashPasswordContext. If the context is cancelled while waiting, the results which haven't been received have the
+// context's error.
+func (r RemotePasswordHasher) HashPasswordsContext(ctx context.Context, passwords []string) (results []gocrypt.HashResult, err error) {
+	responses, errs, err := r.batch(ctx, protocol.Request_HASHPASSWORD, passwords, func(_ int, req *protocol.Request) {
+		r.setParams(req)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results = make([]gocrypt.HashResult, len(passwords))
+	for i, res := range responses {
+		results[i].Err = errs[i]
+		if errs[i] == nil {
+			results[i].Hash = res.Hash
+		}
+	}
+	return results, nil
+}
+
+// ValidatePasswords validates the password of each pair against its hash using remote gocrypt agents. The pairs are
+// sent as a single batch, in the same way as in HashPasswords.
+func (r RemotePasswordHasher) ValidatePasswords(pairs []gocrypt.Pair) (results []gocrypt.ValidateResult, err error) {
+	return r.ValidatePasswordsContext(context.Background(), pairs)
+}
+
+// ValidatePasswordsContext is the same as ValidatePasswords, but the context is handled the same way as in
+// HashPasswordsContext.
+func (r RemotePasswordHasher) ValidatePasswordsContext(ctx context.Context, pairs []gocrypt.Pair) (results []gocrypt.ValidateResult, err error) {
+	passwords := make([]string, len(pairs))
+	for i, pair := range pairs {
+		passwords[i] = pair.Password
+	}
+	responses, errs, err := r.batch(ctx, protocol.Request_VERIFYPASSWORD, passwords, func(i int, req *protocol.Request) {
+		r.setHash(req, pairs[i].Password, pairs[i].Hash)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results = make([]gocrypt.ValidateResult, len(pairs))
+	for i, res := range responses {
+		results[i].Err = errs[i]
+		if errs[i] == nil {
+			results[i].IsValid = res.IsValid
+		}
+	}
+	return results, nil
+}
+
+// batch creates a request of the specified type for each of the passwords, lets setup fill in the fields specific to
+// the type, then submits them together and returns the responses, along with an error for each request. The batch is
+// reported to the observer as a single request.
+func (r RemotePasswordHasher) batch(ctx context.Context, requestType protocol.Request_RequestType, passwords []string,
+	setup func(i int, req *protocol.Request)) (responses []*protocol.Response, errs []error, err error) {
+	start := time.Now()
+	ctx = r.observer.RequestStarted(ctx, requestType)
+	defer func() {
+		r.observer.RequestFinished(ctx, time.Since(start), err)
+	}()
+
+	if ctx.Err() != nil {
+		return nil, nil, ctx.Err()
+	}
+	responses = make([]*protocol.Response, len(passwords))
+	errs = make([]error, len(passwords))
+	if len(passwords) == 0 {
+		return responses, errs, nil
+	}
+
+	expiryTimestamp, err := r.expiryTimestamp(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	reqs := make([]*protocol.Request, len(passwords))
+	responseKeys := make([]string, 0, len(passwords))
+	reqBytes := make([][]byte, 0, len(passwords))
+	for i, password := range passwords {
+		reqs[i], err = r.newRequest(requestType, password, expiryTimestamp)
+		if err != nil {
+			return nil, nil, err
+		}
+		setup(i, reqs[i])
+		r.injectTraceContext(ctx, reqs[i])
+
+		marshalled, err := r.marshalRequest(reqs[i])
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		responseKeys = append(responseKeys, reqs[i].ResponseKey)
+		reqBytes = append(reqBytes, marshalled)
+	}
+	if len(reqBytes) == 0 {
+		return responses, errs, nil
+	}
+
+	resBytes, waitErr, err := r.sendBatch(ctx, responseKeys, reqBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, req := range reqs {
+		if errs[i] != nil {
+			continue
+		}
+		res, ok := resBytes[req.ResponseKey]
+		if !ok {
+			errs[i] = waitErr
+			continue
+		}
+		responses[i], errs[i] = r.parseResponse(req.ResponseKey, res)
+	}
+	return responses, errs, nil
+}
+
+// sendBatch submits the requests with their priority, and waits for their responses in the same way as send. waitErr
+// is the error for the requests whose responses weren't received. err is only returned if the requests couldn't be
+// submitted.
+func (r RemotePasswordHasher) sendBatch(ctx context.Context, responseKeys []string, reqBytes [][]byte) (
+	resBytes map[string][]byte, waitErr error, err error) {
+	start := time.Now()
+	awaiter, err := transport.AwaitResponses(r.transport, responseKeys)
+	r.observeStage(ctx, StageSubscribe, start, err)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer awaiter.Close()
+
+	start = time.Now()
+	err = transport.SubmitRequests(r.transport, reqBytes, r.priorityFor(ctx))
+	r.observeStage(ctx, StageSubmit, start, err)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	timeout := r.timeoutFor(ctx)
+	start = time.Now()
+	resBytes, waitErr = awaiter.Wait(ctx, timeout)
+	if waitErr == transport.ErrTimeout {
+		waitErr = r.timeoutError(timeout)
+	}
+	r.observeStage(ctx, StageWait, start, waitErr)
+	return resBytes, waitErr, nil
+}
@@ -0,0 +1,24 @@
+//go:build integration
+// +build integration
+
+package remotePasswordHasher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+func TestRemotePasswordHasherShouldHashAndValidateBatchesUsingRedis(t *testing.T) {
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", "localhost:6379", redis.DialUseTLS(useTLS))
+		},
+	}
+
+	for _, opts := range [][]Option{nil, {WithStreams()}} {
+		rph, _ := New(4, 10*time.Second, pool, opts...)
+		testBatches(t, rph)
+	}
+}
@@ -0,0 +1,67 @@
+package remotePasswordHasher
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rsheasby/gocrypt"
+	"github.com/rsheasby/gocrypt/protocol"
+	"github.com/rsheasby/gocrypt/transport/memoryTransport"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestRemotePasswordHasherShouldHashAndValidateBatches(t *testing.T) {
+	rph, _ := New(4, 10*time.Second, nil, WithTransport(startLoopback(t)))
+	testBatches(t, rph)
+}
+
+// testBatches hashes and validates batches of passwords using the hasher.
+func testBatches(t *testing.T, rph *RemotePasswordHasher) {
+	results, err := rph.HashPasswords([]string{"abc", "def", "ghi"})
+	assert.Nil(t, err, "No error should be returned when the batch is submitted")
+	assert.Len(t, results, 3, "There should be a result for each password")
+	for _, result := range results {
+		assert.Nil(t, result.Err, "Every password should be hashed without an error")
+	}
+
+	validations, err := rph.ValidatePasswords([]gocrypt.Pair{
+		{Password: "abc", Hash: results[0].Hash},
+		{Password: "abc", Hash: results[1].Hash},
+		{Password: "ghi", Hash: "invalid"},
+	})
+	assert.Nil(t, err, "No error should be returned when the batch is submitted")
+	assert.True(t, validations[0].IsValid, "Matching passwords should validate as correct")
+	assert.False(t, validations[1].IsValid, "Passwords should only match their own hash")
+	assert.True(t, errors.Is(validations[2].Err, ErrInvalidHash), "Invalid hashes should only fail their own result")
+
+	results, err = rph.HashPasswords(nil)
+	assert.Nil(t, err, "No error should be returned for an empty batch")
+	assert.Empty(t, results, "There should be no results for an empty batch")
+}
+
+func TestRemotePasswordHasherShouldReturnErrorsForEachRequestInABatch(t *testing.T) {
+	tr := memoryTransport.New()
+	rph, _ := New(4, 100*time.Millisecond, nil, WithTransport(tr))
+
+	// Stand in for the agent, only responding to the first request.
+	go func() {
+		delivery, _ := tr.ReceiveRequest(context.Background())
+		req := &protocol.Request{}
+		_ = proto.Unmarshal(delivery.Body(), req)
+		resBytes, _ := proto.Marshal(&protocol.Response{Hash: "hash"})
+		_, _ = tr.PublishResponse(req.ResponseKey, resBytes)
+	}()
+
+	results, err := rph.HashPasswords([]string{"abc", "def"})
+	assert.Nil(t, err, "No error should be returned when the batch is submitted")
+	assert.Equal(t, "hash", results[0].Hash, "Responses which are received should be returned")
+	assert.True(t, errors.Is(results[1].Err, ErrTimeout), "Requests without a response should time out")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = rph.HashPasswordsContext(ctx, []string{"abc"})
+	assert.Equal(t, context.Canceled, err, "The context's error should be returned if it's already cancelled")
+}
@@ -1,8 +1,22 @@
 package remotePasswordHasher
 
+import "github.com/rsheasby/gocrypt/transport/redisTransport"
+
 const (
 	// RequestQueueKey specifies the redis key that will be used for the request queue.
-	RequestQueueKey = "gocrypt:RequestQueue"
+	RequestQueueKey = redisTransport.RequestQueueKey
+	// BulkRequestQueueKey specifies the redis key that will be used for the queue of bulk priority requests.
+	BulkRequestQueueKey = redisTransport.BulkRequestQueueKey
 	// ResponseKeyPrefix specifies the redis key prefix that will be used for response publishing.
-	ResponseKeyPrefix = "gocrypt:Response:"
+	ResponseKeyPrefix = redisTransport.ResponseKeyPrefix
+	// RequestStreamKey specifies the redis key that will be used for the request stream by the streams transport.
+	RequestStreamKey = redisTransport.RequestStreamKey
+	// BulkRequestStreamKey specifies the redis key that will be used for the stream of bulk priority requests by the
+	// streams transport.
+	BulkRequestStreamKey = redisTransport.BulkRequestStreamKey
+	// RequestStreamField specifies the field of the stream entries which holds the request.
+	RequestStreamField = redisTransport.RequestStreamField
+	// ReconnectRetryTime specifies how long to wait before reconnecting when the connection is lost while waiting for a
+	// response with the streams transport.
+	ReconnectRetryTime = redisTransport.ReconnectRetryTime
 )
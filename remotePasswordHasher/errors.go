@@ -0,0 +1,64 @@
+package remotePasswordHasher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rsheasby/gocrypt/protocol"
+)
+
+var (
+	// ErrInvalidRequest is returned when the agent rejects the request as invalid.
+	ErrInvalidRequest = errors.New("invalid request")
+	// ErrExpired is returned when the request expired before the agent could process it.
+	ErrExpired = errors.New("request expired")
+	// ErrInvalidHash is returned when the agent is unable to parse the provided hash.
+	ErrInvalidHash = errors.New("invalid hash")
+	// ErrTimeout is returned when no response is received from the agent within the timeout.
+	ErrTimeout = errors.New("timed out waiting for response from agent")
+	// ErrUnverifiedResponse is returned when an envelope is configured, and the response received can't be verified as
+	// coming from an agent with the same key.
+	ErrUnverifiedResponse = errors.New("unable to verify response from agent")
+)
+
+// responseError converts the error code and message in the response to an error, which can be checked using errors.Is.
+// It returns nil if the response doesn't contain an error.
+func responseError(res *protocol.Response) (err error) {
+	switch res.ErrorCode {
+	case protocol.Response_NONE:
+		return nil
+	case protocol.Response_INVALID_REQUEST:
+		return fmt.Errorf("%w: %s", ErrInvalidRequest, res.ErrorMessage)
+	case protocol.Response_EXPIRED:
+		return fmt.Errorf("%w: %s", ErrExpired, res.ErrorMessage)
+	case protocol.Response_INVALID_HASH:
+		return fmt.Errorf("%w: %s", ErrInvalidHash, res.ErrorMessage)
+	default:
+		return fmt.Errorf("agent returned unknown error code %d: %s", res.ErrorCode, res.ErrorMessage)
+	}
+}
+
+// Outcome returns a short, low-cardinality description of an error returned by the RemotePasswordHasher, such as
+// "timeout" or "invalid_hash", which is useful as a metric label. It returns "success" if err is nil, and "error" for
+// errors that aren't specific to gocrypt, such as transport errors.
+func Outcome(err error) (outcome string) {
+	switch {
+	case err == nil:
+		return "success"
+	case errors.Is(err, ErrTimeout):
+		return "timeout"
+	case errors.Is(err, ErrExpired):
+		return "expired"
+	case errors.Is(err, ErrInvalidRequest):
+		return "invalid_request"
+	case errors.Is(err, ErrInvalidHash):
+		return "invalid_hash"
+	case errors.Is(err, ErrUnverifiedResponse):
+		return "unverified_response"
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return "cancelled"
+	default:
+		return "error"
+	}
+}
@@ -0,0 +1,39 @@
+package remotePasswordHasher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/rsheasby/gocrypt/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseErrorShouldMapErrorCodes(t *testing.T) {
+	assert.Nil(t, responseError(&protocol.Response{}), "No error should be returned when there's no error code")
+
+	err := responseError(&protocol.Response{ErrorCode: protocol.Response_INVALID_REQUEST, ErrorMessage: "abc"})
+	assert.True(t, errors.Is(err, ErrInvalidRequest), "INVALID_REQUEST should map to ErrInvalidRequest")
+	assert.Contains(t, err.Error(), "abc", "Error should include the message from the agent")
+
+	err = responseError(&protocol.Response{ErrorCode: protocol.Response_EXPIRED})
+	assert.True(t, errors.Is(err, ErrExpired), "EXPIRED should map to ErrExpired")
+
+	err = responseError(&protocol.Response{ErrorCode: protocol.Response_INVALID_HASH})
+	assert.True(t, errors.Is(err, ErrInvalidHash), "INVALID_HASH should map to ErrInvalidHash")
+
+	err = responseError(&protocol.Response{ErrorCode: 1234})
+	assert.NotNil(t, err, "Unknown error codes should still return an error")
+}
+
+func TestOutcomeShouldDescribeErrors(t *testing.T) {
+	assert.Equal(t, "success", Outcome(nil), "No error should be a success")
+	assert.Equal(t, "timeout", Outcome(ErrTimeout), "ErrTimeout should be a timeout")
+	assert.Equal(t, "invalid_hash", Outcome(responseError(&protocol.Response{ErrorCode: protocol.Response_INVALID_HASH})),
+		"Wrapped agent errors should be recognised")
+	assert.Equal(t, "unverified_response", Outcome(fmt.Errorf("%w: bad tag", ErrUnverifiedResponse)),
+		"ErrUnverifiedResponse should be recognised")
+	assert.Equal(t, "cancelled", Outcome(context.DeadlineExceeded), "Context errors should be cancelled")
+	assert.Equal(t, "error", Outcome(fmt.Errorf("connection refused")), "Other errors should be reported generically")
+}
@@ -0,0 +1,72 @@
+package remotePasswordHasher
+
+import (
+	"context"
+	"time"
+
+	"github.com/rsheasby/gocrypt/protocol"
+)
+
+// Stage identifies a step of a request which is reported to the Observer.
+type Stage string
+
+const (
+	// StageServerTime is getting the transport's server time, which is used for the request expiry.
+	StageServerTime Stage = "server_time"
+	// StageSubscribe is starting to wait for the response, such as subscribing to the response key.
+	StageSubscribe Stage = "subscribe"
+	// StageSubmit is submitting the request, such as pushing it onto the request queue.
+	StageSubmit Stage = "submit"
+	// StageWait is waiting for the agent's response.
+	StageWait Stage = "wait"
+)
+
+// Observer is notified of the progress of each request, so that client-side metrics and traces can be recorded. The
+// methods are called on the goroutine making the request, so they shouldn't block.
+type Observer interface {
+	// RequestStarted is called before anything is sent. The returned context is passed to the rest of the calls for
+	// the request, so values such as a span can be added to it.
+	RequestStarted(ctx context.Context, requestType protocol.Request_RequestType) context.Context
+	// StageFinished is called after each stage of the request, with how long it took and the error it failed with, if
+	// any. Stages after a failed stage aren't reported.
+	StageFinished(ctx context.Context, stage Stage, duration time.Duration, err error)
+	// RequestFinished is called once the request has finished, with how long it took in total and the error returned
+	// to the caller, if any. Errors from the agent can be checked using errors.Is.
+	RequestFinished(ctx context.Context, duration time.Duration, err error)
+}
+
+// TraceContextInjector can optionally be implemented by an Observer to send trace context, such as the W3C traceparent
+// header, to the agent along with the request, so that the agent's spans join the client's trace.
+type TraceContextInjector interface {
+	// InjectTraceContext adds the trace context from the context returned by RequestStarted to the carrier.
+	InjectTraceContext(ctx context.Context, carrier map[string]string)
+}
+
+// nopObserver is used when no Observer is specified.
+type nopObserver struct{}
+
+func (nopObserver) RequestStarted(ctx context.Context, _ protocol.Request_RequestType) context.Context {
+	return ctx
+}
+
+func (nopObserver) StageFinished(context.Context, Stage, time.Duration, error) {}
+
+func (nopObserver) RequestFinished(context.Context, time.Duration, error) {}
+
+// observeStage reports the stage to the observer, timed from start.
+func (r RemotePasswordHasher) observeStage(ctx context.Context, stage Stage, start time.Time, err error) {
+	r.observer.StageFinished(ctx, stage, time.Since(start), err)
+}
+
+// injectTraceContext adds the observer's trace context to the request, if it supports it.
+func (r RemotePasswordHasher) injectTraceContext(ctx context.Context, req *protocol.Request) {
+	injector, ok := r.observer.(TraceContextInjector)
+	if !ok {
+		return
+	}
+	carrier := make(map[string]string)
+	injector.InjectTraceContext(ctx, carrier)
+	if len(carrier) > 0 {
+		req.TraceContext = carrier
+	}
+}
@@ -0,0 +1,109 @@
+package remotePasswordHasher
+
+import (
+	"github.com/rsheasby/gocrypt/envelope"
+	"github.com/rsheasby/gocrypt/hashAlgorithms"
+	"github.com/rsheasby/gocrypt/transport"
+	"github.com/rsheasby/gocrypt/transport/redisTransport"
+)
+
+// Option configures optional settings for a RemotePasswordHasher.
+type Option func(r *RemotePasswordHasher)
+
+// WithArgon2id makes the hasher use Argon2id instead of bcrypt, with the specified memory in KiB, iterations and
+// parallelism. The cost provided to New is ignored.
+func WithArgon2id(memory uint32, iterations uint32, parallelism uint32) Option {
+	return func(r *RemotePasswordHasher) {
+		r.params.Algorithm = hashAlgorithms.Argon2id
+		r.params.Memory = memory
+		r.params.Iterations = iterations
+		r.params.Parallelism = parallelism
+	}
+}
+
+// WithScrypt makes the hasher use scrypt instead of bcrypt. The cost provided to New is used as the base 2 logarithm of
+// the scrypt N parameter, and parallelism is used as the p parameter.
+func WithScrypt(parallelism uint32) Option {
+	return func(r *RemotePasswordHasher) {
+		r.params.Algorithm = hashAlgorithms.Scrypt
+		r.params.Parallelism = parallelism
+	}
+}
+
+// WithLegacyBcrypt makes the hasher also accept plain bcrypt hashes of the raw password, such as those imported from
+// other systems, which would otherwise fail to validate as they aren't pre-hashed with SHA-512. ValidateAndRehash
+// replaces these with native hashes.
+// Validating these hashes sends the raw password to the agent, which crosses redis in plaintext unless WithEnvelope is
+// also used, so New logs a warning if it isn't.
+func WithLegacyBcrypt() Option {
+	return func(r *RemotePasswordHasher) {
+		r.legacyBcrypt = true
+	}
+}
+
+// WithEnvelope makes the hasher encrypt requests and verify responses using the provided envelope keys, so that the
+// password hashes can't be read from redis, and responses can't be forged. The agent must be configured with the same
+// keys. Responses which can't be verified are refused with ErrUnverifiedResponse.
+func WithEnvelope(keys *envelope.Keys) Option {
+	return func(r *RemotePasswordHasher) {
+		r.envelope = keys
+	}
+}
+
+// WithStreams makes the hasher use the streams transport, which submits requests using a redis stream, and waits for
+// the response on a list using BLPOP instead of pub/sub. Responses are kept by redis until they're received, so they
+// aren't lost if the connection drops briefly, and this works with Redis Cluster. The agent must be configured to use
+// the streams transport as well. Requires Redis 6.2 or later.
+func WithStreams() Option {
+	return func(r *RemotePasswordHasher) {
+		r.streams = true
+	}
+}
+
+// WithNamespace makes the hasher submit requests to the namespace's queue, such as "gocrypt:staging:RequestQueue"
+// instead of "gocrypt:RequestQueue", so that several applications or environments can share a redis server without
+// taking each other's requests. An agent must be configured to serve the same namespace. New returns an error if the
+// namespace is refused by redisTransport.ValidateNamespace. WithNamespace has no effect when a transport is specified.
+func WithNamespace(namespace string) Option {
+	return func(r *RemotePasswordHasher) {
+		r.namespace = namespace
+		r.namespaceErr = redisTransport.ValidateNamespace(namespace)
+	}
+}
+
+// WithClientID sets the ID of the hasher's response channel, which the agents publish its responses on. It must be
+// unique for each running hasher, and is generated from the hostname and process ID by default. WithClientID has no
+// effect when a transport is specified.
+func WithClientID(id string) Option {
+	return func(r *RemotePasswordHasher) {
+		r.clientID = id
+	}
+}
+
+// WithPriority sets the priority of the hasher's requests, which is PriorityInteractive by default. Use PriorityBulk
+// for hashers doing background work, such as rehashing or importing users, so that agents always handle interactive
+// requests first. The priority can be overridden for a single request using ContextWithPriority.
+func WithPriority(priority Priority) Option {
+	return func(r *RemotePasswordHasher) {
+		r.priority = priority
+	}
+}
+
+// WithTransport makes the hasher send requests and receive responses using the provided transport instead of the redis
+// pool passed to New, so that brokers other than redis can be used. The agent must be configured with a matching
+// transport. WithStreams and WithNamespace have no effect when a transport is specified.
+func WithTransport(t transport.Transport) Option {
+	return func(r *RemotePasswordHasher) {
+		r.transport = t
+	}
+}
+
+// WithObserver makes the hasher report the progress of each request to the observer, such as how long it took to
+// submit the request and to receive the response, so that client-side metrics and traces can be recorded. If the
+// observer also implements TraceContextInjector, its trace context is sent to the agent with each request. See the
+// otelObserver package for an OpenTelemetry implementation.
+func WithObserver(o Observer) Option {
+	return func(r *RemotePasswordHasher) {
+		r.observer = o
+	}
+}
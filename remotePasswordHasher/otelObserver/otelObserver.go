@@ -0,0 +1,114 @@
+// Package otelObserver provides a remotePasswordHasher.Observer which records OpenTelemetry spans for each request, and
+// sends the trace context to the agent, so that the agent's spans join the same trace.
+package otelObserver
+
+import (
+	"context"
+	"time"
+
+	"github.com/rsheasby/gocrypt/protocol"
+	"github.com/rsheasby/gocrypt/remotePasswordHasher"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName is the name of the tracer used for the spans.
+const InstrumentationName = "github.com/rsheasby/gocrypt/remotePasswordHasher"
+
+// Observer records a client span for each request, with a child span for each stage of the request.
+type Observer struct {
+	tracerProvider trace.TracerProvider
+	propagator     propagation.TextMapPropagator
+	tracer         trace.Tracer
+}
+
+// Option configures optional settings for an Observer.
+type Option func(o *Observer)
+
+// WithTracerProvider makes the observer create spans using the provided tracer provider instead of the global one.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(o *Observer) {
+		o.tracerProvider = tp
+	}
+}
+
+// WithPropagator makes the observer send the trace context using the provided propagator instead of the W3C Trace
+// Context format. The agent only understands W3C Trace Context, so this is only useful if something else reads the
+// requests.
+func WithPropagator(p propagation.TextMapPropagator) Option {
+	return func(o *Observer) {
+		o.propagator = p
+	}
+}
+
+// New returns an Observer which can be passed to remotePasswordHasher.WithObserver.
+func New(opts ...Option) (o *Observer) {
+	o = &Observer{
+		tracerProvider: otel.GetTracerProvider(),
+		propagator:     propagation.TraceContext{},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	o.tracer = o.tracerProvider.Tracer(InstrumentationName)
+	return o
+}
+
+// RequestStarted starts the span for the request.
+func (o *Observer) RequestStarted(ctx context.Context, requestType protocol.Request_RequestType) context.Context {
+	ctx, _ = o.tracer.Start(ctx, "gocrypt "+requestType.String(),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("gocrypt.request_type", requestType.String())),
+	)
+	return ctx
+}
+
+// StageFinished records a child span covering the stage.
+func (o *Observer) StageFinished(ctx context.Context, stage remotePasswordHasher.Stage, duration time.Duration, err error) {
+	end := time.Now()
+	_, span := o.tracer.Start(ctx, "gocrypt."+string(stage), trace.WithTimestamp(end.Add(-duration)))
+	setError(span, err)
+	span.End(trace.WithTimestamp(end))
+}
+
+// RequestFinished records the outcome on the request's span, and ends it.
+func (o *Observer) RequestFinished(ctx context.Context, _ time.Duration, err error) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.String("gocrypt.outcome", remotePasswordHasher.Outcome(err)))
+	setError(span, err)
+	span.End()
+}
+
+// InjectTraceContext adds the request span's trace context to the carrier.
+func (o *Observer) InjectTraceContext(ctx context.Context, carrier map[string]string) {
+	o.propagator.Inject(ctx, mapCarrier(carrier))
+}
+
+func setError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// mapCarrier adapts the request's trace context map to a propagation.TextMapCarrier.
+type mapCarrier map[string]string
+
+func (c mapCarrier) Get(key string) string {
+	return c[key]
+}
+
+func (c mapCarrier) Set(key string, value string) {
+	c[key] = value
+}
+
+func (c mapCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,110 @@
+package otelObserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rsheasby/gocrypt/protocol"
+	"github.com/rsheasby/gocrypt/remotePasswordHasher"
+	"github.com/rsheasby/gocrypt/transport/memoryTransport"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
+)
+
+// startAgent stands in for the agent, and sends each request's trace context back to the test.
+func startAgent(ctx context.Context, tr *memoryTransport.Transport) (traceContexts chan trace.SpanContext) {
+	traceContexts = make(chan trace.SpanContext, 10)
+	go func() {
+		for {
+			delivery, err := tr.ReceiveRequest(ctx)
+			if err != nil {
+				return
+			}
+			req := &protocol.Request{}
+			_ = proto.Unmarshal(delivery.Body(), req)
+			reqCtx := propagation.TraceContext{}.Extract(context.Background(), mapCarrier(req.TraceContext))
+			traceContexts <- trace.SpanContextFromContext(reqCtx)
+			resBytes, _ := proto.Marshal(&protocol.Response{Hash: "hash"})
+			_, _ = tr.PublishResponse(req.ResponseKey, resBytes)
+		}
+	}()
+	return traceContexts
+}
+
+func TestObserverShouldRecordSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	tr := memoryTransport.New()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	traceContexts := startAgent(ctx, tr)
+
+	rph, err := remotePasswordHasher.New(4, time.Second, nil, remotePasswordHasher.WithTransport(tr),
+		remotePasswordHasher.WithObserver(New(WithTracerProvider(tp))))
+	assert.Nil(t, err, "No error should be returned when creating the hasher")
+
+	parentCtx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+	_, err = rph.HashPasswordContext(parentCtx, "password")
+	parent.End()
+	assert.Nil(t, err, "No error should be returned when the response is received")
+
+	agentSpanContext := <-traceContexts
+	assert.True(t, agentSpanContext.IsValid(), "The trace context should be sent to the agent")
+	assert.Equal(t, parent.SpanContext().TraceID(), agentSpanContext.TraceID(),
+		"The agent should receive the caller's trace")
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	requestSpan, ok := spans["gocrypt HASHPASSWORD"]
+	assert.True(t, ok, "A span should be recorded for the request")
+	if !ok {
+		return
+	}
+	assert.Equal(t, parent.SpanContext().SpanID(), requestSpan.Parent().SpanID(),
+		"The request span should be a child of the caller's span")
+	assert.Equal(t, trace.SpanKindClient, requestSpan.SpanKind(), "The request span should be a client span")
+	assert.Equal(t, requestSpan.SpanContext().SpanID(), agentSpanContext.SpanID(),
+		"The agent's spans should be children of the request span")
+
+	for _, stage := range []remotePasswordHasher.Stage{remotePasswordHasher.StageServerTime,
+		remotePasswordHasher.StageSubscribe, remotePasswordHasher.StageSubmit, remotePasswordHasher.StageWait} {
+		stageSpan, ok := spans["gocrypt."+string(stage)]
+		assert.True(t, ok, "A span should be recorded for the %s stage", stage)
+		if ok {
+			assert.Equal(t, requestSpan.SpanContext().SpanID(), stageSpan.Parent().SpanID(),
+				"The %s span should be a child of the request span", stage)
+			assert.False(t, stageSpan.EndTime().Before(stageSpan.StartTime()),
+				"The %s span shouldn't end before it starts", stage)
+		}
+	}
+}
+
+func TestObserverShouldRecordErrors(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	// Nothing handles the requests, so they time out.
+	rph, _ := remotePasswordHasher.New(4, 10*time.Millisecond, nil,
+		remotePasswordHasher.WithTransport(memoryTransport.New()),
+		remotePasswordHasher.WithObserver(New(WithTracerProvider(tp))))
+	_, err := rph.HashPassword("password")
+	assert.Equal(t, remotePasswordHasher.ErrTimeout, err, "ErrTimeout should be returned when nothing responds")
+
+	for _, span := range recorder.Ended() {
+		switch span.Name() {
+		case "gocrypt HASHPASSWORD", "gocrypt.wait":
+			assert.Equal(t, codes.Error, span.Status().Code, "The %s span should be marked as failed", span.Name())
+		default:
+			assert.Equal(t, codes.Unset, span.Status().Code, "The %s span shouldn't be marked as failed", span.Name())
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package remotePasswordHasher
+
+import (
+	"context"
+
+	"github.com/rsheasby/gocrypt/transport"
+)
+
+// Priority specifies how urgently a request needs to be handled. Agents always handle the waiting requests with the
+// highest priority first, so that bulk work can't hold up interactive logins.
+type Priority = transport.Priority
+
+const (
+	// PriorityInteractive is for requests which someone is waiting on, such as logins. It's the default.
+	PriorityInteractive = transport.PriorityInteractive
+	// PriorityBulk is for background work, such as rehashing or importing users, which is only handled while no
+	// interactive requests are waiting.
+	PriorityBulk = transport.PriorityBulk
+)
+
+type priorityKey struct{}
+
+// ContextWithPriority returns a copy of the context which makes the requests made with it use the priority instead of
+// the hasher's priority, which is set using WithPriority.
+func ContextWithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// priorityFor returns the priority set on the context using ContextWithPriority, or the hasher's priority otherwise.
+func (r RemotePasswordHasher) priorityFor(ctx context.Context) (priority Priority) {
+	if priority, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return priority
+	}
+	return r.priority
+}
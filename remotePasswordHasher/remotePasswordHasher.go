@@ -1,14 +1,21 @@
 package remotePasswordHasher
 
 import (
+	"context"
 	"crypto/sha512"
 	"fmt"
+	"io"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/gomodule/redigo/redis"
 	"github.com/google/uuid"
+	"github.com/rsheasby/gocrypt/envelope"
+	"github.com/rsheasby/gocrypt/hashAlgorithms"
 	"github.com/rsheasby/gocrypt/protocol"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/rsheasby/gocrypt/transport"
+	"github.com/rsheasby/gocrypt/transport/redisTransport"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -18,48 +25,85 @@ type RedisPool interface {
 	Close() error
 }
 
-// RemotePasswordHasher performs password hashing using a remote gocrypt hashing agent accessible through the provided redis pool.
+// RemotePasswordHasher performs password hashing using a remote gocrypt hashing agent accessible through the provided redis pool,
+// or another transport if one is specified using WithTransport.
 type RemotePasswordHasher struct {
-	cost    int
-	timeout time.Duration
-	pool    RedisPool
+	params       hashAlgorithms.Params
+	legacyBcrypt bool
+	envelope     *envelope.Keys
+	streams      bool
+	namespace    string
+	namespaceErr error
+	clientID     string
+	priority     Priority
+	timeout      time.Duration
+	transport    transport.Transport
+	ownTransport bool
+	observer     Observer
 }
 
-func testPoolConnection(pool RedisPool) (err error) {
-	if pool == nil {
-		return fmt.Errorf("redis pool cannot be nil")
+// New returns a PasswordHasher instance relying on a remote gocrypt agent to perform the
+// hashing. Bcrypt is used with the provided cost unless a different algorithm is specified using the options.
+// This validates the connection, cost and other parameters, and returns an error if there is a problem. The pool is
+// ignored, and may be nil, if a transport is specified using WithTransport.
+func New(cost int, timeout time.Duration, pool RedisPool, opts ...Option) (ph *RemotePasswordHasher, err error) {
+	ph = &RemotePasswordHasher{
+		params: hashAlgorithms.Params{
+			Algorithm: hashAlgorithms.Bcrypt,
+			Cost:      cost,
+		},
+		timeout: timeout,
+	}
+	for _, opt := range opts {
+		opt(ph)
 	}
-	testConn := pool.Get()
-	if testConn == nil {
-		// It doesn't seem like this ever happens,
-		// as redigo opts to return the error when doing the actual operation instead of when getting the connection.
-		// Irregardless, doesn't hurt to check it just in-case redigo changes, or my understanding is incorrect.
-		return fmt.Errorf("nil connection returned from redis pool")
+	if ph.observer == nil {
+		ph.observer = nopObserver{}
 	}
-	result, err := redis.String(testConn.Do("PING"))
+
+	err = ph.params.Validate()
 	if err != nil {
-		return fmt.Errorf("error PINGing redis: %v", err)
+		return nil, err
 	}
-	if result != "PONG" {
-		// Unsure how to test this in a simple way, so it'll have to do without any coverage for now.
-		return fmt.Errorf(`unexpected response when PINGing redis - expected "PONG", received "%s"`, result)
+	if ph.namespaceErr != nil {
+		return nil, ph.namespaceErr
 	}
-
-	return nil
-}
-
-// New returns a PasswordHasher instance relying on a remote gocrypt agent to perform the
-// hashing. This validates the connection and cost, and returns an error if there is a problem.
-func New(cost int, timeout time.Duration, pool RedisPool) (ph *RemotePasswordHasher, err error) {
-	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
-		return nil, fmt.Errorf("cost of %d is invalid - cost must be between %d and %d", cost, bcrypt.MinCost, bcrypt.MaxCost)
+	if ph.legacyBcrypt && ph.envelope == nil {
+		log.Printf("gocrypt: WithLegacyBcrypt is used without WithEnvelope, so the raw passwords of legacy hashes " +
+			"will be sent to the agent in plaintext")
+	}
+	if ph.transport == nil {
+		if pool == nil {
+			return nil, fmt.Errorf("redis pool cannot be nil")
+		}
+		var opts []redisTransport.Option
+		if ph.streams {
+			opts = append(opts, redisTransport.WithStreams())
+		}
+		if ph.namespace != "" {
+			opts = append(opts, redisTransport.WithNamespace(ph.namespace))
+		}
+		if ph.clientID != "" {
+			opts = append(opts, redisTransport.WithClientID(ph.clientID))
+		}
+		ph.transport = redisTransport.New(pool, opts...)
+		ph.ownTransport = true
 	}
-	err = testPoolConnection(pool)
+	err = ph.transport.Ping()
 	if err != nil {
 		return nil, err
 	}
 
-	return &RemotePasswordHasher{cost: cost, timeout: timeout, pool: pool}, nil
+	return ph, nil
+}
+
+// Close releases the connection shared by the requests waiting for responses. The hasher can't be used afterwards. A
+// transport specified using WithTransport isn't closed, as it's owned by the caller.
+func (r RemotePasswordHasher) Close() (err error) {
+	if closer, ok := r.transport.(io.Closer); ok && r.ownTransport {
+		return closer.Close()
+	}
+	return nil
 }
 
 func generateResponseKey() (responseKey string, err error) {
@@ -76,91 +120,190 @@ func encodePassword(password string) (encoded []byte) {
 	return shaBytes[:]
 }
 
-func (r RemotePasswordHasher) submitRequestAndGetResponse(req *protocol.Request) (res *protocol.Response, err error) {
-	// Subscribe to hash res
-	subConn := &redis.PubSubConn{
-		Conn: r.pool.Get(),
+// timeoutFor returns how long to wait for a response, which is the hasher's timeout, unless the context deadline is
+// sooner.
+func (r RemotePasswordHasher) timeoutFor(ctx context.Context) (timeout time.Duration) {
+	timeout = r.timeout
+	if deadline, ok := ctx.Deadline(); ok {
+		if untilDeadline := time.Until(deadline); untilDeadline < timeout {
+			timeout = untilDeadline
+		}
+	}
+	return timeout
+}
+
+func (r RemotePasswordHasher) submitRequestAndGetResponse(ctx context.Context, req *protocol.Request) (res *protocol.Response, err error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	reqBytes, err := r.marshalRequest(req)
+	if err != nil {
+		return nil, err
 	}
-	defer subConn.Close()
 
-	err = subConn.Subscribe(ResponseKeyPrefix + req.ResponseKey)
+	resBytes, err := r.send(ctx, req.ResponseKey, reqBytes)
 	if err != nil {
-		return nil, fmt.Errorf("failed to subscribe to res key: %v", err)
+		return nil, err
 	}
-	defer subConn.Unsubscribe() //nolint
 
-	// Submit hash req
-	redisConn := r.pool.Get()
-	defer redisConn.Close()
+	return r.parseResponse(req.ResponseKey, resBytes)
+}
 
-	reqBytes, err := proto.Marshal(req)
+// marshalRequest marshals the request, and seals it if an envelope is used.
+func (r RemotePasswordHasher) marshalRequest(req *protocol.Request) (reqBytes []byte, err error) {
+	reqBytes, err = proto.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshall req: %v", err)
 	}
+	if r.envelope != nil {
+		reqBytes, err = r.envelope.SealRequest(reqBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to seal req: %v", err)
+		}
+	}
+	return reqBytes, nil
+}
 
-	_, err = redisConn.Do("LPUSH", RequestQueueKey, reqBytes)
+// parseResponse opens the response to the request with the response key if an envelope is used, and unmarshals it.
+// If the agent couldn't handle the request, the error it responded with is returned along with the response.
+func (r RemotePasswordHasher) parseResponse(responseKey string, resBytes []byte) (res *protocol.Response, err error) {
+	if r.envelope != nil {
+		resBytes, err = r.envelope.OpenResponse(responseKey, resBytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnverifiedResponse, err)
+		}
+	}
+	res = &protocol.Response{}
+	err = proto.Unmarshal(resBytes, res)
 	if err != nil {
-		return nil, fmt.Errorf("failed to submit hashing job: %v", err)
+		return nil, fmt.Errorf("failed to unmarshall res from agent: %v", err)
 	}
-	// Release connection early if successful. Double closing is safe. The deferred close is just for error conditions.
-	redisConn.Close()
-
-	// Receive hash res
-	for {
-		switch subResponse := subConn.ReceiveWithTimeout(r.timeout).(type) {
-		case error:
-			return nil, fmt.Errorf("failed to receive res from agent: %v", subResponse)
-		case redis.Message:
-			res = &protocol.Response{}
-			err = proto.Unmarshal(subResponse.Data, res)
-			if err != nil {
-				return nil, fmt.Errorf("failed to unmarshall res from agent: %v", err)
-			}
+	return res, responseError(res)
+}
 
-			return
-		}
+// timeoutError returns the error for when no response was received within the timeout. If the context deadline is
+// what limited the wait, it's reported the same way as a cancelled context would be.
+func (r RemotePasswordHasher) timeoutError(timeout time.Duration) error {
+	if timeout < r.timeout {
+		return context.DeadlineExceeded
 	}
+	return ErrTimeout
 }
 
-func (r RemotePasswordHasher) getRedisTime() (redisTime time.Time, err error) {
-	conn := r.pool.Get()
-	defer conn.Close()
+// send submits the request with its priority, and waits for the response to be delivered on the response key. Each step
+// is reported to the observer.
+func (r RemotePasswordHasher) send(ctx context.Context, responseKey string, reqBytes []byte) (resBytes []byte, err error) {
+	// Start waiting before the request is submitted, so that the response can't be missed.
+	start := time.Now()
+	awaiter, err := r.transport.AwaitResponse(responseKey)
+	r.observeStage(ctx, StageSubscribe, start, err)
+	if err != nil {
+		return nil, err
+	}
+	defer awaiter.Close()
 
-	timestamps, err := redis.Int64s(conn.Do("TIME"))
+	start = time.Now()
+	err = transport.SubmitRequestWithPriority(r.transport, reqBytes, r.priorityFor(ctx))
+	r.observeStage(ctx, StageSubmit, start, err)
 	if err != nil {
-		return time.Time{}, fmt.Errorf("couldn't receive timestamp from redis: %v", err)
+		return nil, err
 	}
 
-	// Should never happen, but may as well check for it just in case
-	if len(timestamps) != 2 {
-		return time.Time{}, fmt.Errorf("couldn't receive timestamp from redis - invalid response")
+	timeout := r.timeoutFor(ctx)
+	start = time.Now()
+	resBytes, err = awaiter.Wait(ctx, timeout)
+	if err == transport.ErrTimeout {
+		err = r.timeoutError(timeout)
 	}
+	r.observeStage(ctx, StageWait, start, err)
+	if err != nil {
+		return nil, err
+	}
+	return resBytes, nil
+}
+
+// request creates a request of the specified type, lets setup fill in the fields specific to the type, then submits it
+// and returns the response. The request is reported to the observer.
+func (r RemotePasswordHasher) request(ctx context.Context, requestType protocol.Request_RequestType, password string,
+	setup func(req *protocol.Request)) (res *protocol.Response, err error) {
+	start := time.Now()
+	ctx = r.observer.RequestStarted(ctx, requestType)
+	defer func() {
+		r.observer.RequestFinished(ctx, time.Since(start), err)
+	}()
 
-	return time.Unix(timestamps[0], timestamps[1]), nil
+	expiryTimestamp, err := r.expiryTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := r.newRequest(requestType, password, expiryTimestamp)
+	if err != nil {
+		return nil, err
+	}
+	setup(req)
+	r.injectTraceContext(ctx, req)
+	return r.submitRequestAndGetResponse(ctx, req)
 }
 
-// HashPassword hashes the provided password using a remote gocrypt agent.
-func (r RemotePasswordHasher) HashPassword(password string) (hash string, err error) {
-	responseKey, err := generateResponseKey()
+// expiryTimestamp returns the expiry timestamp for requests made now. It's based on the transport's server time, so
+// that the agent can check it regardless of any clock differences between the client and agent.
+func (r RemotePasswordHasher) expiryTimestamp(ctx context.Context) (expiryTimestamp int64, err error) {
+	start := time.Now()
+	serverTime, err := r.transport.ServerTime()
+	r.observeStage(ctx, StageServerTime, start, err)
 	if err != nil {
-		return "", fmt.Errorf("couldn't generate response key: %v", err)
+		return 0, fmt.Errorf("couldn't get server time: %v", err)
 	}
+	return serverTime.Add(r.timeoutFor(ctx)).UnixNano(), nil
+}
 
-	redisTime, err := r.getRedisTime()
+// newRequest creates a request with a new response key. If the transport receives every response on a single channel,
+// the request asks for its response to be published there.
+func (r RemotePasswordHasher) newRequest(requestType protocol.Request_RequestType, password string, expiryTimestamp int64) (req *protocol.Request, err error) {
+	responseKey, err := generateResponseKey()
 	if err != nil {
-		return "", fmt.Errorf("couldn't get redis time: %v", err)
+		return nil, fmt.Errorf("couldn't generate response key: %v", err)
 	}
-	redisTime = redisTime.Add(r.timeout)
 
-	req := &protocol.Request{
-		RequestType:     protocol.Request_HASHPASSWORD,
+	return &protocol.Request{
+		RequestType:     requestType,
 		ResponseKey:     responseKey,
+		ResponseChannel: transport.ResponseChannel(r.transport),
 		Password:        encodePassword(password),
-		Cost:            int32(r.cost),
-		ExpiryTimestamp: redisTime.UnixNano(),
+		ExpiryTimestamp: expiryTimestamp,
+	}, nil
+}
+
+// setParams sets the hashing algorithm and parameters on the request.
+func (r RemotePasswordHasher) setParams(req *protocol.Request) {
+	req.Algorithm = protocol.Request_Algorithm(r.params.Algorithm)
+	req.Cost = int32(r.params.Cost)
+	req.Memory = r.params.Memory
+	req.Iterations = r.params.Iterations
+	req.Parallelism = r.params.Parallelism
+}
+
+// setHash sets the hash to validate against on the request. The raw password is only sent if it's needed to validate
+// a legacy hash, or a plain bcrypt hash if WithLegacyBcrypt is used, and is sent in plaintext unless WithEnvelope is
+// used.
+func (r RemotePasswordHasher) setHash(req *protocol.Request, password string, hash string) {
+	req.Hash = hash
+	if hashAlgorithms.IsLegacy(hash) || (r.legacyBcrypt && strings.HasPrefix(hash, "$2")) {
+		req.LegacyPassword = []byte(password)
 	}
+}
+
+// HashPassword hashes the provided password using a remote gocrypt agent.
+func (r RemotePasswordHasher) HashPassword(password string) (hash string, err error) {
+	return r.HashPasswordContext(context.Background(), password)
+}
 
-	res, err := r.submitRequestAndGetResponse(req)
+// HashPasswordContext hashes the provided password using a remote gocrypt agent. If the context has a deadline which is
+// sooner than the timeout, the deadline is used as the request expiry instead. If the context is cancelled, this stops
+// waiting for the response and returns the context's error.
+func (r RemotePasswordHasher) HashPasswordContext(ctx context.Context, password string) (hash string, err error) {
+	res, err := r.request(ctx, protocol.Request_HASHPASSWORD, password, r.setParams)
 	if err != nil {
 		return "", err
 	}
@@ -170,29 +313,40 @@ func (r RemotePasswordHasher) HashPassword(password string) (hash string, err er
 
 // ValidatePassword validates the password against the provided password hash using a remote gocrypt agent.
 func (r RemotePasswordHasher) ValidatePassword(password string, hash string) (isValid bool, err error) {
-	responseKey, err := generateResponseKey()
-	if err != nil {
-		return false, fmt.Errorf("couldn't generate response key: %v", err)
-	}
+	return r.ValidatePasswordContext(context.Background(), password, hash)
+}
 
-	redisTime, err := r.getRedisTime()
+// ValidatePasswordContext validates the password against the provided password hash using a remote gocrypt agent. The
+// context is handled the same way as in HashPasswordContext.
+func (r RemotePasswordHasher) ValidatePasswordContext(ctx context.Context, password string, hash string) (isValid bool, err error) {
+	res, err := r.request(ctx, protocol.Request_VERIFYPASSWORD, password, func(req *protocol.Request) {
+		r.setHash(req, password, hash)
+	})
 	if err != nil {
-		return false, fmt.Errorf("couldn't get redis time: %v", err)
+		return false, err
 	}
-	redisTime = redisTime.Add(r.timeout)
 
-	req := &protocol.Request{
-		RequestType:     protocol.Request_VERIFYPASSWORD,
-		ResponseKey:     responseKey,
-		Password:        encodePassword(password),
-		Hash:            hash,
-		ExpiryTimestamp: redisTime.UnixNano(),
-	}
+	return res.IsValid, nil
+}
 
-	res, err := r.submitRequestAndGetResponse(req)
+// ValidateAndRehash validates the password against the provided password hash using a remote gocrypt agent. If the
+// password is valid but the provided hash uses a different algorithm or lower parameters than this
+// RemotePasswordHasher, is a legacy hash, or isn't peppered with the agent's current pepper key, the agent also returns a
+// new hash in the same response.
+func (r RemotePasswordHasher) ValidateAndRehash(password string, hash string) (isValid bool, newHash string, err error) {
+	return r.ValidateAndRehashContext(context.Background(), password, hash)
+}
+
+// ValidateAndRehashContext is the same as ValidateAndRehash, but the context is handled the same way as in
+// HashPasswordContext.
+func (r RemotePasswordHasher) ValidateAndRehashContext(ctx context.Context, password string, hash string) (isValid bool, newHash string, err error) {
+	res, err := r.request(ctx, protocol.Request_VERIFYPASSWORDANDREHASH, password, func(req *protocol.Request) {
+		r.setHash(req, password, hash)
+		r.setParams(req)
+	})
 	if err != nil {
-		return false, err
+		return false, "", err
 	}
 
-	return res.IsValid, nil
+	return res.IsValid, res.Hash, nil
 }
@@ -0,0 +1,161 @@
+//go:build integration
+// +build integration
+
+package remotePasswordHasher
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/rsheasby/gocrypt/envelope"
+	"github.com/rsheasby/gocrypt/localPasswordHasher"
+	"github.com/rsheasby/gocrypt/protocol"
+	"github.com/rsheasby/gocrypt/transport"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/protobuf/proto"
+)
+
+var useTLS bool
+
+func init() {
+	_, useTLS = os.LookupEnv("REDIS_TLS")
+}
+
+// The tests in the integration test files are only built with the integration tag, e.g. "make test-integration".
+// An active redis server on localhost:6379 is necessary, and a gocrypt agent needs to be running, as well as a second
+// agent using the streams transport.
+
+func TestNewRemotePasswordHasherShouldConnectToRedis(t *testing.T) {
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", "localhost:6379", redis.DialUseTLS(useTLS))
+		},
+	}
+	ph, err := New(10, time.Second*10, pool)
+
+	assert.NotNil(t, ph, "Returned PasswordHasher shouldn't be nil")
+	assert.Nil(t, err, "No error should be returned when the PasswordHasher was successfully created")
+}
+
+func TestRemotePasswordHasherShouldHashAndValidateUsingRedis(t *testing.T) {
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", "localhost:6379", redis.DialUseTLS(useTLS))
+		},
+	}
+	rph, _ := New(4, time.Second*10, pool)
+	lph, _ := localPasswordHasher.New(4)
+
+	password := "password123!"
+	hash, err := rph.HashPassword(password)
+	assert.Nil(t, err, "No error should be returned from the remote password hasher.")
+	isValid, _ := lph.ValidatePassword(password, hash)
+	assert.True(t, isValid, "Hash from remote password hasher should validate using the local hasher.")
+
+	isValid, newHash, err := rph.ValidateAndRehash(password, hash)
+	assert.Nil(t, err, "Validate and rehash returned an error")
+	assert.True(t, isValid, "Validate and rehash didn't correctly validate")
+	assert.Empty(t, newHash, "No new hash should be returned when the hash cost is sufficient")
+
+	_, err = rph.ValidatePassword(password, "$2y")
+	assert.True(t, errors.Is(err, ErrInvalidHash), "Agent errors should be returned")
+
+	// Requests which expired before the agent received them are rejected by the agent
+	responseKey, _ := generateResponseKey()
+	redisTime, _ := rph.transport.ServerTime()
+	_, err = rph.submitRequestAndGetResponse(context.Background(), &protocol.Request{
+		RequestType:     protocol.Request_HASHPASSWORD,
+		ResponseKey:     responseKey,
+		ResponseChannel: transport.ResponseChannel(rph.transport),
+		Password:        encodePassword("abc"),
+		Cost:            int32(bcrypt.MinCost),
+		ExpiryTimestamp: redisTime.Add(-time.Second).UnixNano(),
+	})
+	assert.True(t, errors.Is(err, ErrExpired), "Should return ErrExpired for an expired request")
+}
+
+func TestRemotePasswordHasherShouldRefuseUnverifiedRedisResponses(t *testing.T) {
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", "localhost:6379", redis.DialUseTLS(useTLS))
+		},
+	}
+	keys, _ := envelope.NewKeys("1", map[string][]byte{"1": []byte("0123456789abcdef0123456789abcdef")})
+	rph, err := New(10, time.Second*10, pool, WithEnvelope(keys))
+	assert.Nil(t, err, "No error should be returned with WithEnvelope")
+
+	responseKey, _ := generateResponseKey()
+	redisTime, _ := rph.transport.ServerTime()
+
+	// Keep publishing a forged plain response until the hasher stops waiting.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		conn := pool.Get()
+		defer conn.Close()
+		forgedBytes, _ := proto.Marshal(&protocol.Response{Hash: "forged"})
+		forgedBytes, _ = proto.Marshal(&protocol.ChannelResponse{ResponseKey: responseKey, Response: forgedBytes})
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(50 * time.Millisecond):
+				_, _ = conn.Do("PUBLISH", ResponseKeyPrefix+transport.ResponseChannel(rph.transport), forgedBytes)
+			}
+		}
+	}()
+
+	res, err := rph.submitRequestAndGetResponse(context.Background(), &protocol.Request{
+		RequestType:     protocol.Request_HASHPASSWORD,
+		ResponseKey:     responseKey,
+		ResponseChannel: transport.ResponseChannel(rph.transport),
+		Password:        encodePassword("abc"),
+		Cost:            int32(bcrypt.MinCost),
+		ExpiryTimestamp: redisTime.Add(rph.timeout).UnixNano(),
+	})
+	assert.True(t, errors.Is(err, ErrUnverifiedResponse), "Should return ErrUnverifiedResponse for a forged response")
+	assert.Nil(t, res, "Forged response shouldn't be returned")
+}
+
+func TestRemotePasswordHasherShouldSupportStreams(t *testing.T) {
+	timeout := time.Second * 10
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", "localhost:6379", redis.DialUseTLS(useTLS))
+		},
+	}
+
+	rph, err := New(4, timeout, pool, WithStreams())
+	assert.Nil(t, err, "No error should be returned with WithStreams")
+	lph, _ := localPasswordHasher.New(4)
+
+	password := "password123!"
+	hash, err := rph.HashPassword(password)
+	assert.Nil(t, err, "No error should be returned from the remote password hasher.")
+	isValid, _ := lph.ValidatePassword(password, hash)
+	assert.True(t, isValid, "Hash from remote password hasher should validate using the local hasher.")
+
+	isValid, err = rph.ValidatePassword(password, hash)
+	assert.Nil(t, err, "No error should be returned when validating")
+	assert.True(t, isValid, "Hash should validate using the remote password hasher.")
+
+	_, err = rph.ValidatePassword(password, "$2y")
+	assert.True(t, errors.Is(err, ErrInvalidHash), "Agent errors should be returned with streams")
+
+	// Cancelling the context should stop the wait immediately. A high cost keeps the agent busy until then.
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	slowRph, _ := New(14, timeout, pool, WithStreams())
+	start := time.Now()
+	_, err = slowRph.HashPasswordContext(ctx, password)
+	assert.True(t, errors.Is(err, context.Canceled), "Should return context.Canceled when the context is cancelled")
+	assert.True(t, time.Since(start) < 500*time.Millisecond, "Should stop waiting as soon as the context is cancelled")
+}
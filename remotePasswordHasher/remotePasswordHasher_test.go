@@ -1,50 +1,67 @@
 package remotePasswordHasher
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gomodule/redigo/redis"
+	"github.com/rsheasby/gocrypt/envelope"
+	"github.com/rsheasby/gocrypt/hashAlgorithms"
 	"github.com/rsheasby/gocrypt/localPasswordHasher"
+	"github.com/rsheasby/gocrypt/loopbackAgent"
+	"github.com/rsheasby/gocrypt/protocol"
+	"github.com/rsheasby/gocrypt/transport"
+	"github.com/rsheasby/gocrypt/transport/memoryTransport"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/protobuf/proto"
 )
 
-var useTLS bool
+// The tests in this file run against a loopback agent using the in-memory transport, so they don't need redis or a
+// running agent. The tests which do are in the integration test files, which are only built with the integration tag.
+// They also rely on the localPasswordHasher which serves as a reference implementation, so if that's broken,
+// these tests can't be relied on.
 
-func init() {
-	_, useTLS = os.LookupEnv("REDIS_TLS")
+// startLoopback starts a loopback agent with the options, and returns its transport. The agent stops once the test
+// finishes.
+func startLoopback(t *testing.T, opts ...loopbackAgent.Option) (tr *memoryTransport.Transport) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return loopbackAgent.Start(ctx, opts...)
 }
 
-// The tests in this file are both unit tests and integration tests.
-// An active redis server on localhost:6379 is necessary, and a gocrypt agent needs to be running.
-// It also relies on the localPasswordHasher which serves as a reference implementation, so if that's broken,
-// these tests can't be relied on.
-
 func TestNewRemotePasswordHasher(t *testing.T) {
 	cost := 10
 	timeout := time.Second * 10
-	pool := &redis.Pool{
-		Dial: func() (redis.Conn, error) {
-			return redis.Dial("tcp", "localhost:6379", redis.DialUseTLS(useTLS))
-		},
-	}
-	ph, err := New(cost, timeout, pool)
+	tr := startLoopback(t)
+	ph, err := New(cost, timeout, nil, WithTransport(tr))
 
 	assert.NotNil(t, ph, "Returned PasswordHasher shouldn't be nil")
 	assert.Nil(t, err, "No error should be returned when the PasswordHasher was successfully created")
 
 	// Ensure it validates the cost
-	ph, err = New(bcrypt.MinCost-1, timeout, pool)
+	ph, err = New(bcrypt.MinCost-1, timeout, nil, WithTransport(tr))
 	assert.Nil(t, ph, "PasswordHasher shouldn't be returned when the specified cost is below the minimum")
 	assert.NotNil(t, err, "An error should be returned when the specified cost is below the minimum")
 
-	ph, err = New(bcrypt.MaxCost+1, timeout, pool)
+	ph, err = New(bcrypt.MaxCost+1, timeout, nil, WithTransport(tr))
 	assert.Nil(t, ph, "PasswordHasher shouldn't be returned when the specified cost is above the maximum")
 	assert.NotNil(t, err, "An error should be returned when the specified cost is above the maximum")
 
+	// Ensure it validates the namespace
+	for _, namespace := range []string{"Processing", "staging:*"} {
+		ph, err = New(cost, timeout, nil, WithTransport(tr), WithNamespace(namespace))
+		assert.Nil(t, ph, "PasswordHasher shouldn't be returned when the namespace is invalid")
+		assert.NotNil(t, err, "An error should be returned when the namespace is %q", namespace)
+	}
+
 	// Ensure it tests the pool connection properly
 	ph, err = New(cost, timeout, nil)
 	assert.Nil(t, ph, "PasswordHasher shouldn't be returned when a nil pool is provided")
@@ -72,14 +89,9 @@ func TestNewRemotePasswordHasher(t *testing.T) {
 func TestHashPassword(t *testing.T) {
 	cost := 10
 	timeout := time.Second * 10
-	pool := &redis.Pool{
-		Dial: func() (redis.Conn, error) {
-			return redis.Dial("tcp", "localhost:6379", redis.DialUseTLS(useTLS))
-		},
-	}
 
 	lph, _ := localPasswordHasher.New(cost)
-	rph, _ := New(cost, timeout, pool)
+	rph, _ := New(cost, timeout, nil, WithTransport(startLoopback(t)))
 
 	// Test that it hashes passwords correctly
 	pwd := "abc"
@@ -94,14 +106,9 @@ func TestHashPassword(t *testing.T) {
 func TestValidatePassword(t *testing.T) {
 	cost := 10
 	timeout := time.Second * 10
-	pool := &redis.Pool{
-		Dial: func() (redis.Conn, error) {
-			return redis.Dial("tcp", "localhost:6379", redis.DialUseTLS(useTLS))
-		},
-	}
 
 	lph, _ := localPasswordHasher.New(cost)
-	rph, _ := New(cost, timeout, pool)
+	rph, _ := New(cost, timeout, nil, WithTransport(startLoopback(t)))
 
 	password := "password123!"
 	hash, _ := lph.HashPassword(password)
@@ -114,4 +121,362 @@ func TestValidatePassword(t *testing.T) {
 	isValid, err = rph.ValidatePassword(invalidPassword, hash)
 	assert.Nil(t, err, "Validate password returned an error")
 	assert.False(t, isValid, "Validate password didn't detect incorrect password")
+
+	isValid, err = rph.ValidatePassword(password, hash[:32])
+	assert.True(t, errors.Is(err, ErrInvalidHash), "Validate password should return ErrInvalidHash with an invalid hash")
+	assert.False(t, isValid, "Validate password should validate as incorrect with an invalid hash")
+}
+
+func TestRemotePasswordHasherShouldReturnAgentErrors(t *testing.T) {
+	rph, _ := New(10, time.Second*10, nil, WithTransport(startLoopback(t)))
+
+	responseKey, _ := generateResponseKey()
+	serverTime, _ := rph.transport.ServerTime()
+
+	// Requests with an invalid cost are rejected by the agent
+	_, err := rph.submitRequestAndGetResponse(context.Background(), &protocol.Request{
+		RequestType:     protocol.Request_HASHPASSWORD,
+		ResponseKey:     responseKey,
+		ResponseChannel: transport.ResponseChannel(rph.transport),
+		Password:        encodePassword("abc"),
+		Cost:            int32(bcrypt.MinCost - 1),
+		ExpiryTimestamp: serverTime.Add(rph.timeout).UnixNano(),
+	})
+	assert.True(t, errors.Is(err, ErrInvalidRequest), "Should return ErrInvalidRequest for an invalid request")
+
+	// Requests which expired before the agent received them are rejected by the agent
+	responseKey, _ = generateResponseKey()
+	_, err = rph.submitRequestAndGetResponse(context.Background(), &protocol.Request{
+		RequestType:     protocol.Request_HASHPASSWORD,
+		ResponseKey:     responseKey,
+		ResponseChannel: transport.ResponseChannel(rph.transport),
+		Password:        encodePassword("abc"),
+		Cost:            int32(bcrypt.MinCost),
+		ExpiryTimestamp: serverTime.Add(-time.Second).UnixNano(),
+	})
+	assert.True(t, errors.Is(err, ErrExpired), "Should return ErrExpired for an expired request")
+
+	// Requests without a response key can't be responded to, so they time out
+	rph.timeout = 100 * time.Millisecond
+	_, err = rph.submitRequestAndGetResponse(context.Background(), &protocol.Request{
+		RequestType:     protocol.Request_HASHPASSWORD,
+		Password:        encodePassword("abc"),
+		Cost:            int32(bcrypt.MinCost),
+		ExpiryTimestamp: serverTime.Add(time.Second).UnixNano(),
+	})
+	assert.True(t, errors.Is(err, ErrTimeout), "Should return ErrTimeout when no response is received")
+}
+
+func TestValidateAndRehash(t *testing.T) {
+	cost := 10
+	timeout := time.Second * 10
+
+	oldLph, _ := localPasswordHasher.New(cost - 1)
+	lph, _ := localPasswordHasher.New(cost)
+	rph, _ := New(cost, timeout, nil, WithTransport(startLoopback(t)))
+
+	password := "password123!"
+	oldHash, _ := oldLph.HashPassword(password)
+	currentHash, _ := lph.HashPassword(password)
+
+	isValid, newHash, err := rph.ValidateAndRehash(password, oldHash)
+	assert.Nil(t, err, "Validate and rehash returned an error")
+	assert.True(t, isValid, "Validate and rehash didn't correctly validate")
+	isValid, _ = lph.ValidatePassword(password, newHash)
+	assert.True(t, isValid, "New hash from remote password hasher should validate using the local hasher.")
+	newCost, _ := bcrypt.Cost([]byte(newHash))
+	assert.Equal(t, cost, newCost, "New hash should use the hasher's cost")
+
+	isValid, newHash, err = rph.ValidateAndRehash(password, currentHash)
+	assert.Nil(t, err, "Validate and rehash returned an error")
+	assert.True(t, isValid, "Validate and rehash didn't correctly validate")
+	assert.Empty(t, newHash, "No new hash should be returned when the hash cost is sufficient")
+
+	invalidPassword := "Password123!"
+	isValid, newHash, err = rph.ValidateAndRehash(invalidPassword, oldHash)
+	assert.Nil(t, err, "Validate and rehash returned an error")
+	assert.False(t, isValid, "Validate and rehash didn't detect incorrect password")
+	assert.Empty(t, newHash, "No new hash should be returned when the password is incorrect")
+}
+
+func TestRemotePasswordHasherShouldHonorContext(t *testing.T) {
+	rph, _ := New(10, time.Second*10, nil, WithTransport(startLoopback(t)))
+
+	// Already cancelled contexts shouldn't submit anything
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := rph.HashPasswordContext(ctx, "abc")
+	assert.True(t, errors.Is(err, context.Canceled), "Hash password should return the context error when it's cancelled")
+
+	// Requests without a response key are never responded to, so these wait until the context is done
+	serverTime, _ := rph.transport.ServerTime()
+	req := &protocol.Request{
+		RequestType:     protocol.Request_HASHPASSWORD,
+		Password:        encodePassword("abc"),
+		Cost:            int32(bcrypt.MinCost),
+		ExpiryTimestamp: serverTime.Add(rph.timeout).UnixNano(),
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	_, err = rph.submitRequestAndGetResponse(ctx, req)
+	assert.True(t, errors.Is(err, context.Canceled), "Should return the context error when it's cancelled while waiting")
+	assert.Less(t, int64(time.Since(start)), int64(time.Second), "Should stop waiting as soon as the context is cancelled")
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	_, err = rph.submitRequestAndGetResponse(ctx, req)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "Should return the context error when the deadline passes")
+	assert.Less(t, int64(time.Since(start)), int64(time.Second), "Should stop waiting when the context deadline passes")
+
+	// The context deadline should be used as the expiry when it's sooner than the timeout
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	expiryTimestamp, _ := rph.expiryTimestamp(ctx)
+	expiry := time.Unix(0, expiryTimestamp)
+	serverTime, _ = rph.transport.ServerTime()
+	assert.WithinDuration(t, serverTime.Add(time.Second), expiry, 500*time.Millisecond, "Expiry should be based on the context deadline")
+}
+
+func TestRemotePasswordHasherShouldSupportOtherAlgorithms(t *testing.T) {
+	timeout := time.Second * 10
+	tr := startLoopback(t)
+
+	ph, err := New(0, timeout, nil, WithTransport(tr), WithArgon2id(0, 1, 1))
+	assert.Nil(t, ph, "PasswordHasher shouldn't be returned with invalid argon2id parameters")
+	assert.NotNil(t, err, "An error should be returned with invalid argon2id parameters")
+
+	lph, _ := localPasswordHasher.New(4)
+	argon2idRph, err := New(0, timeout, nil, WithTransport(tr), WithArgon2id(64, 1, 1))
+	assert.Nil(t, err, "No error should be returned with valid argon2id parameters")
+	scryptRph, err := New(hashAlgorithms.MinScryptCost, timeout, nil, WithTransport(tr), WithScrypt(1))
+	assert.Nil(t, err, "No error should be returned with valid scrypt parameters")
+
+	password := "password123!"
+	for _, rph := range []*RemotePasswordHasher{argon2idRph, scryptRph} {
+		hash, err := rph.HashPassword(password)
+		assert.Nil(t, err, "No error should be returned from the remote password hasher.")
+
+		isValid, err := lph.ValidatePassword(password, hash)
+		assert.True(t, isValid, "Hash from remote password hasher should validate using the local hasher.")
+		assert.Nil(t, err, "No error should be returned when validating the hash. Invalid hash is likely the cause.")
+	}
+
+	bcryptHash, _ := lph.HashPassword(password)
+	isValid, newHash, err := argon2idRph.ValidateAndRehash(password, bcryptHash)
+	assert.Nil(t, err, "Validate and rehash returned an error")
+	assert.True(t, isValid, "Validate and rehash didn't correctly validate")
+	assert.True(t, strings.HasPrefix(newHash, "$argon2id$"), "Bcrypt hash should be rehashed with argon2id")
+}
+
+func TestRemotePasswordHasherShouldValidateAndMigrateLegacyHashes(t *testing.T) {
+	timeout := time.Second * 10
+
+	rph, err := New(4, timeout, nil, WithTransport(startLoopback(t)), WithLegacyBcrypt())
+	assert.Nil(t, err, "No error should be returned with WithLegacyBcrypt")
+	lph, _ := localPasswordHasher.New(4)
+
+	legacyHashes := []string{
+		"pbkdf2_sha256$1000$seasalt$YIWkt6M1JFXrHg5s0jZjBSc7C2Cz6QvchSJ0h8Y+i7c=",
+		"$6$rounds=1000$abcdefgh$wuAp2XWwaaguzVxZjeM2bd1yLSqbC/I9sr9DFeOfIPoAZiIj3ecL6rf9ibuAg8RDmh1vqbaeL0NSLJtGPF7b60",
+		"$2y$10$z3QlTH2S0HFcX0bXY6B.8OQ.jj4mbdYPho4PnhEgM0qk2kbFNnw92",
+	}
+	for _, hash := range legacyHashes {
+		isValid, err := rph.ValidatePassword("password", hash)
+		assert.Nil(t, err, "Validating legacy hash %q returned an error", hash)
+		assert.True(t, isValid, "Legacy hash %q didn't validate", hash)
+
+		isValid, newHash, err := rph.ValidateAndRehash("password", hash)
+		assert.Nil(t, err, "Validate and rehash returned an error for legacy hash %q", hash)
+		assert.True(t, isValid, "Legacy hash %q didn't validate", hash)
+
+		isValid, _ = lph.ValidatePassword("password", newHash)
+		assert.True(t, isValid, "Legacy hash %q should be replaced with a native hash", hash)
+	}
+}
+
+func TestNewShouldWarnAboutLegacyBcryptWithoutAnEnvelope(t *testing.T) {
+	logBuffer := &bytes.Buffer{}
+	log.SetOutput(logBuffer)
+	defer log.SetOutput(os.Stderr)
+
+	keys, _ := envelope.NewKeys("1", map[string][]byte{"1": []byte("0123456789abcdef0123456789abcdef")})
+	_, _ = New(4, time.Second, nil, WithTransport(memoryTransport.New()), WithLegacyBcrypt(), WithEnvelope(keys))
+	assert.Empty(t, logBuffer.String(), "No warning should be logged when an envelope is used")
+
+	_, _ = New(4, time.Second, nil, WithTransport(memoryTransport.New()), WithLegacyBcrypt())
+	assert.Contains(t, logBuffer.String(), "plaintext",
+		"A warning should be logged when the raw password would be sent in plaintext")
+}
+
+func TestRemotePasswordHasherShouldSupportEnvelopes(t *testing.T) {
+	keys, _ := envelope.NewKeys("1", map[string][]byte{"1": []byte("0123456789abcdef0123456789abcdef")})
+	rph, err := New(4, time.Second*10, nil, WithTransport(startLoopback(t, loopbackAgent.WithEnvelope(keys))),
+		WithEnvelope(keys))
+	assert.Nil(t, err, "No error should be returned with WithEnvelope")
+	lph, _ := localPasswordHasher.New(4)
+
+	hash, err := rph.HashPassword("password")
+	assert.Nil(t, err, "No error should be returned when the response is sealed by the agent")
+	isValid, _ := lph.ValidatePassword("password", hash)
+	assert.True(t, isValid, "Hash from remote password hasher should validate using the local hasher.")
+
+	// The agent refuses requests which aren't sealed, so they time out.
+	plainRph, _ := New(4, 100*time.Millisecond, nil, WithTransport(startLoopback(t, loopbackAgent.WithEnvelope(keys))))
+	_, err = plainRph.HashPassword("password")
+	assert.True(t, errors.Is(err, ErrTimeout), "Should return ErrTimeout when the agent refuses the request")
+}
+
+func TestRemotePasswordHasherShouldRefuseUnverifiedResponses(t *testing.T) {
+	tr := memoryTransport.New()
+	keys, _ := envelope.NewKeys("1", map[string][]byte{"1": []byte("0123456789abcdef0123456789abcdef")})
+	rph, err := New(10, time.Second*10, nil, WithTransport(tr), WithEnvelope(keys))
+	assert.Nil(t, err, "No error should be returned with WithEnvelope")
+
+	// Stand in for the agent, responding with a forged plain response.
+	go func() {
+		delivery, err := tr.ReceiveRequest(context.Background())
+		if err != nil {
+			return
+		}
+		reqBytes, _ := keys.OpenRequest(delivery.Body())
+		req := &protocol.Request{}
+		_ = proto.Unmarshal(reqBytes, req)
+		forgedBytes, _ := proto.Marshal(&protocol.Response{Hash: "forged"})
+		_, _ = tr.PublishResponse(req.ResponseKey, forgedBytes)
+	}()
+
+	hash, err := rph.HashPassword("abc")
+	assert.True(t, errors.Is(err, ErrUnverifiedResponse), "Should return ErrUnverifiedResponse for a forged response")
+	assert.Empty(t, hash, "Forged response shouldn't be returned")
+}
+
+func TestRemotePasswordHasherShouldSupportOtherTransports(t *testing.T) {
+	tr := memoryTransport.New()
+	rph, err := New(4, time.Second, nil, WithTransport(tr))
+	assert.Nil(t, err, "No error should be returned with WithTransport, even if the pool is nil")
+
+	// Stand in for the agent, so that this doesn't depend on redis.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for {
+			delivery, err := tr.ReceiveRequest(ctx)
+			if err != nil {
+				return
+			}
+			req := &protocol.Request{}
+			_ = proto.Unmarshal(delivery.Body(), req)
+			// Requests without a password are dropped, so that the hasher times out.
+			if len(req.Password) == 0 {
+				continue
+			}
+			resBytes, _ := proto.Marshal(&protocol.Response{Hash: "hash", IsValid: true})
+			_, _ = tr.PublishResponse(req.ResponseKey, resBytes)
+		}
+	}()
+
+	hash, err := rph.HashPassword("password")
+	assert.Nil(t, err, "No error should be returned when the response is received")
+	assert.Equal(t, "hash", hash, "The hash in the response should be returned")
+
+	isValid, err := rph.ValidatePassword("password", "hash")
+	assert.Nil(t, err, "No error should be returned when the response is received")
+	assert.True(t, isValid, "The result in the response should be returned")
+
+	_, err = rph.submitRequestAndGetResponse(context.Background(), &protocol.Request{ResponseKey: "key"})
+	assert.True(t, errors.Is(err, ErrTimeout), "Should return ErrTimeout when no response is received")
+}
+
+func TestRemotePasswordHasherShouldSubmitRequestsWithPriority(t *testing.T) {
+	tr := memoryTransport.New()
+	rph, _ := New(4, 10*time.Millisecond, nil, WithTransport(tr), WithPriority(PriorityBulk))
+
+	// Nothing is receiving the requests, so they're left in the queues after timing out.
+	_, err := rph.HashPassword("password")
+	assert.True(t, errors.Is(err, ErrTimeout), "Should return ErrTimeout when no response is received")
+	_, _ = rph.ValidatePasswordContext(ContextWithPriority(context.Background(), PriorityInteractive), "password", "hash")
+
+	req := &protocol.Request{}
+	delivery, _ := tr.ReceiveRequest(context.Background())
+	_ = proto.Unmarshal(delivery.Body(), req)
+	assert.Equal(t, protocol.Request_VERIFYPASSWORD, req.RequestType, "The context's priority should override the hasher's")
+	delivery, _ = tr.ReceiveRequest(context.Background())
+	_ = proto.Unmarshal(delivery.Body(), req)
+	assert.Equal(t, protocol.Request_HASHPASSWORD, req.RequestType, "The hasher's priority should be used by default")
+}
+
+// recordingObserver records what's reported to it, and sends a fixed trace context.
+type recordingObserver struct {
+	requestType protocol.Request_RequestType
+	stages      []Stage
+	stageErrors []error
+	finished    bool
+	err         error
+}
+
+type observerKey struct{}
+
+func (o *recordingObserver) RequestStarted(ctx context.Context, requestType protocol.Request_RequestType) context.Context {
+	o.requestType = requestType
+	return context.WithValue(ctx, observerKey{}, "started")
+}
+
+func (o *recordingObserver) StageFinished(ctx context.Context, stage Stage, _ time.Duration, err error) {
+	if ctx.Value(observerKey{}) == "started" {
+		o.stages = append(o.stages, stage)
+		o.stageErrors = append(o.stageErrors, err)
+	}
+}
+
+func (o *recordingObserver) RequestFinished(ctx context.Context, _ time.Duration, err error) {
+	o.finished = ctx.Value(observerKey{}) == "started"
+	o.err = err
+}
+
+func (o *recordingObserver) InjectTraceContext(_ context.Context, carrier map[string]string) {
+	carrier["traceparent"] = "trace"
+}
+
+func TestRemotePasswordHasherShouldReportToObserver(t *testing.T) {
+	tr := memoryTransport.New()
+	observer := &recordingObserver{}
+	rph, _ := New(4, 100*time.Millisecond, nil, WithTransport(tr), WithObserver(observer))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	traceContexts := make(chan map[string]string, 1)
+	go func() {
+		delivery, err := tr.ReceiveRequest(ctx)
+		if err != nil {
+			return
+		}
+		req := &protocol.Request{}
+		_ = proto.Unmarshal(delivery.Body(), req)
+		traceContexts <- req.TraceContext
+		resBytes, _ := proto.Marshal(&protocol.Response{IsValid: true})
+		_, _ = tr.PublishResponse(req.ResponseKey, resBytes)
+	}()
+
+	_, err := rph.ValidatePassword("password", "hash")
+	assert.Nil(t, err, "No error should be returned when the response is received")
+	assert.Equal(t, map[string]string{"traceparent": "trace"}, <-traceContexts,
+		"The observer's trace context should be sent with the request")
+	assert.Equal(t, protocol.Request_VERIFYPASSWORD, observer.requestType, "The request type should be reported")
+	assert.Equal(t, []Stage{StageServerTime, StageSubscribe, StageSubmit, StageWait}, observer.stages,
+		"Each stage should be reported in order, with the context returned by RequestStarted")
+	assert.Equal(t, []error{nil, nil, nil, nil}, observer.stageErrors, "No stage should have failed")
+	assert.True(t, observer.finished, "The end of the request should be reported")
+	assert.Nil(t, observer.err, "The request shouldn't have failed")
+
+	// Nothing handles this request, so it times out.
+	observer.stages, observer.stageErrors = nil, nil
+	_, err = rph.HashPassword("password")
+	assert.Equal(t, ErrTimeout, err, "ErrTimeout should be returned when no response is received")
+	assert.Equal(t, []Stage{StageServerTime, StageSubscribe, StageSubmit, StageWait}, observer.stages,
+		"Each stage should be reported in order")
+	assert.Equal(t, []error{nil, nil, nil, ErrTimeout}, observer.stageErrors, "The wait should be reported as timed out")
+	assert.Equal(t, ErrTimeout, observer.err, "The error returned to the caller should be reported")
 }
@@ -0,0 +1,31 @@
+package requestHandler
+
+import (
+	"github.com/rsheasby/gocrypt/hashAlgorithms"
+	"github.com/rsheasby/gocrypt/protocol"
+)
+
+// HashPassword hashes a password using the specified algorithm and parameters. Bcrypt hashes are returned in unix "$2a"
+// encoding, and other algorithms are returned in PHC string format. If peppers is non-nil, the password is peppered
+// with the current key first.
+func HashPassword(password []byte, params hashAlgorithms.Params, peppers *hashAlgorithms.Peppers) (hash string) {
+	hash, err := peppers.Hash(password, params)
+	if err != nil {
+		// Hashing only fails if something went very wrong, like OOM or parameters that are out of bounds.
+		// Invalid parameters should be caught by the validation, so if hashing fails, it's probably worth killing
+		// everything and investigating.
+		panic(err)
+	}
+	return hash
+}
+
+// RequestParams returns the hashing algorithm and parameters specified in the request.
+func RequestParams(req *protocol.Request) (params hashAlgorithms.Params) {
+	return hashAlgorithms.Params{
+		Algorithm:   hashAlgorithms.Algorithm(req.Algorithm),
+		Cost:        int(req.Cost),
+		Memory:      req.Memory,
+		Iterations:  req.Iterations,
+		Parallelism: req.Parallelism,
+	}
+}
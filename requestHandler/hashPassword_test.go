@@ -0,0 +1,65 @@
+package requestHandler
+
+import (
+	"testing"
+
+	"github.com/rsheasby/gocrypt/hashAlgorithms"
+	"github.com/rsheasby/gocrypt/protocol"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHashPasswordShouldSucceedForValidCost(t *testing.T) {
+	pwd := []byte("password")
+	cost := 10
+	hash := HashPassword([]byte(pwd), hashAlgorithms.Params{Algorithm: hashAlgorithms.Bcrypt, Cost: cost}, nil)
+	err := bcrypt.CompareHashAndPassword([]byte(hash), pwd)
+
+	assert.NoError(t, err, "Generated hash should validate correctly")
+}
+
+func TestHashPasswordShouldSucceedForOtherAlgorithms(t *testing.T) {
+	pwd := []byte("password")
+	params := []hashAlgorithms.Params{
+		{Algorithm: hashAlgorithms.Argon2id, Memory: 64, Iterations: 1, Parallelism: 1},
+		{Algorithm: hashAlgorithms.Scrypt, Cost: hashAlgorithms.MinScryptCost, Parallelism: 1},
+	}
+	for _, p := range params {
+		hash := HashPassword(pwd, p, nil)
+		isValid, _, err := ValidatePassword(pwd, nil, hash, nil)
+
+		assert.NoError(t, err, "Generated %s hash should validate without an error", p.Algorithm)
+		assert.True(t, isValid, "Generated %s hash should validate correctly", p.Algorithm)
+	}
+}
+
+// The reason for panicking with invalid cost is that it should be caught by the validation function in real operation.
+func TestHashShouldPanicWithAboveMaxCost(t *testing.T) {
+	defer func() {
+		err := recover()
+		assert.NotNil(t, err, "Hashing with above max cost should panic with an error")
+	}()
+
+	pwd := []byte("password")
+	cost := 32
+	_ = HashPassword([]byte(pwd), hashAlgorithms.Params{Algorithm: hashAlgorithms.Bcrypt, Cost: cost}, nil)
+}
+
+func TestRequestParamsShouldReturnRequestParameters(t *testing.T) {
+	req := &protocol.Request{
+		Algorithm:   protocol.Request_ARGON2ID,
+		Cost:        4,
+		Memory:      64,
+		Iterations:  2,
+		Parallelism: 3,
+	}
+	params := RequestParams(req)
+
+	assert.Equal(t, hashAlgorithms.Params{
+		Algorithm:   hashAlgorithms.Argon2id,
+		Cost:        4,
+		Memory:      64,
+		Iterations:  2,
+		Parallelism: 3,
+	}, params, "Params should match the request")
+}
@@ -0,0 +1,55 @@
+package requestHandler
+
+import (
+	"fmt"
+
+	"github.com/rsheasby/gocrypt/envelope"
+	"github.com/rsheasby/gocrypt/protocol"
+	"google.golang.org/protobuf/proto"
+)
+
+// OpenRequest opens the request body using the envelope keys if they're non-nil, and unmarshals it. Requests which
+// can't be opened can't be responded to, as the response key is inside the envelope.
+func OpenRequest(reqBytes []byte, keys *envelope.Keys) (req *protocol.Request, err error) {
+	if keys != nil {
+		reqBytes, err = keys.OpenRequest(reqBytes)
+		if err != nil {
+			return nil, err
+		}
+	}
+	req = &protocol.Request{}
+	err = proto.Unmarshal(reqBytes, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshall request: %v", err)
+	}
+	return req, nil
+}
+
+// EncodeResponse marshals the response to the request, and seals it using the envelope keys if they're non-nil. If the
+// request specifies a response channel, the response is wrapped along with its response key, so that the client can
+// route it to the request waiting for it. The response should be published on the returned response key.
+func EncodeResponse(res *protocol.Response, req *protocol.Request, keys *envelope.Keys) (responseKey string,
+	resBytes []byte, err error) {
+	responseKey = req.ResponseKey
+	resBytes, err = proto.Marshal(res)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshall response: %v", err)
+	}
+	if keys != nil {
+		resBytes, err = keys.SealResponse(responseKey, resBytes)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to seal response: %v", err)
+		}
+	}
+	if req.ResponseChannel != "" {
+		resBytes, err = proto.Marshal(&protocol.ChannelResponse{
+			ResponseKey: responseKey,
+			Response:    resBytes,
+		})
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to marshall channel response: %v", err)
+		}
+		responseKey = req.ResponseChannel
+	}
+	return responseKey, resBytes, nil
+}
@@ -0,0 +1,49 @@
+package requestHandler
+
+import (
+	"testing"
+
+	"github.com/rsheasby/gocrypt/envelope"
+	"github.com/rsheasby/gocrypt/protocol"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestOpenRequestShouldRefuseRequestsWithoutAnEnvelope(t *testing.T) {
+	keys, _ := envelope.NewKeys("1", map[string][]byte{"1": []byte("0123456789abcdef0123456789abcdef")})
+	reqBytes, _ := proto.Marshal(&protocol.Request{ResponseKey: "key"})
+
+	req, err := OpenRequest(reqBytes, nil)
+	assert.Nil(t, err, "Plain requests should be opened without an envelope")
+	assert.Equal(t, "key", req.ResponseKey, "The request should be unmarshalled")
+
+	_, err = OpenRequest(reqBytes, keys)
+	assert.NotNil(t, err, "Plain requests should be refused when an envelope is used")
+
+	sealed, _ := keys.SealRequest(reqBytes)
+	req, err = OpenRequest(sealed, keys)
+	assert.Nil(t, err, "Sealed requests should be opened")
+	assert.Equal(t, "key", req.ResponseKey, "The request should be unmarshalled")
+}
+
+func TestEncodeResponseShouldSealAndWrapResponses(t *testing.T) {
+	keys, _ := envelope.NewKeys("1", map[string][]byte{"1": []byte("0123456789abcdef0123456789abcdef")})
+	req := &protocol.Request{ResponseKey: "key", ResponseChannel: "channel"}
+
+	responseKey, resBytes, err := EncodeResponse(&protocol.Response{Hash: "hash"}, req, keys)
+	assert.Nil(t, err, "No error should be returned when encoding")
+	assert.Equal(t, "channel", responseKey, "The response should be published on the response channel")
+	channelRes := &protocol.ChannelResponse{}
+	_ = proto.Unmarshal(resBytes, channelRes)
+	assert.Equal(t, "key", channelRes.ResponseKey, "The response should be wrapped with its response key")
+	opened, err := keys.OpenResponse("key", channelRes.Response)
+	assert.Nil(t, err, "The response should be sealed for its response key")
+	res := &protocol.Response{}
+	_ = proto.Unmarshal(opened, res)
+	assert.Equal(t, "hash", res.Hash, "The response should be marshalled")
+
+	req.ResponseChannel = ""
+	responseKey, _, err = EncodeResponse(&protocol.Response{Hash: "hash"}, req, nil)
+	assert.Nil(t, err, "No error should be returned when encoding")
+	assert.Equal(t, "key", responseKey, "The response should be published on the response key without a channel")
+}
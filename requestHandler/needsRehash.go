@@ -0,0 +1,12 @@
+package requestHandler
+
+import (
+	"github.com/rsheasby/gocrypt/hashAlgorithms"
+)
+
+// NeedsRehash takes a hash and the desired algorithm and parameters, and returns if the hash uses a different algorithm
+// or lower parameters, or isn't peppered with the current key, and should be replaced.
+func NeedsRehash(hash string, params hashAlgorithms.Params, peppers *hashAlgorithms.Peppers) (needsRehash bool,
+	err error) {
+	return peppers.NeedsRehash(hash, params)
+}
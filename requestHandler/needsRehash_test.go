@@ -0,0 +1,32 @@
+package requestHandler
+
+import (
+	"testing"
+
+	"github.com/rsheasby/gocrypt/hashAlgorithms"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNeedsRehashShouldDetectLowerCost(t *testing.T) {
+	hash := "$2y$10$z3QlTH2S0HFcX0bXY6B.8OQ.jj4mbdYPho4PnhEgM0qk2kbFNnw92"
+
+	needsRehash, err := NeedsRehash(hash, hashAlgorithms.Params{Algorithm: hashAlgorithms.Bcrypt, Cost: 12}, nil)
+	assert.NoError(t, err, "Should not return error when checking a valid hash")
+	assert.True(t, needsRehash, "Should need a rehash when the desired cost is higher than the hash cost")
+
+	needsRehash, err = NeedsRehash(hash, hashAlgorithms.Params{Algorithm: hashAlgorithms.Bcrypt, Cost: 10}, nil)
+	assert.NoError(t, err, "Should not return error when checking a valid hash")
+	assert.False(t, needsRehash, "Should not need a rehash when the desired cost is equal to the hash cost")
+
+	needsRehash, err = NeedsRehash(hash, hashAlgorithms.Params{Algorithm: hashAlgorithms.Argon2id, Memory: 64,
+		Iterations: 1, Parallelism: 1}, nil)
+	assert.NoError(t, err, "Should not return error when checking a valid hash")
+	assert.True(t, needsRehash, "Should need a rehash when the desired algorithm is different")
+}
+
+func TestNeedsRehashShouldErrorWithInvalidHash(t *testing.T) {
+	needsRehash, err := NeedsRehash("$2y", hashAlgorithms.Params{Algorithm: hashAlgorithms.Bcrypt, Cost: 10}, nil)
+
+	assert.False(t, needsRehash, "Should not need a rehash when there's an invalid hash provided")
+	assert.NotNil(t, err, "Should return an error when an invalid hash is provided")
+}
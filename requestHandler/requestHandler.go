@@ -0,0 +1,96 @@
+// Package requestHandler implements the handling of requests which is shared by the gocrypt agent and the loopback
+// agent, so that both validate requests and produce responses in exactly the same way. Receiving requests and
+// publishing responses is left to the caller, along with any logging, retries and metrics.
+package requestHandler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rsheasby/gocrypt/hashAlgorithms"
+	"github.com/rsheasby/gocrypt/protocol"
+)
+
+// DefaultMinResponseKeyLength specifies the minimum length for the response key.
+// 16 is a decent length to be relatively sure you won't have collisions,
+// and is also the length of a UUID in binary representation.
+// Our client uses test UUIDs with a timestamp which will be well over 40 characters,
+// but there's no need to enforce that level of security on the agent-side.
+const DefaultMinResponseKeyLength = 16
+
+// Observer records how long hashing and validation take.
+type Observer interface {
+	ObserveHash(params hashAlgorithms.Params, duration time.Duration)
+	ObserveVerify(hash string, duration time.Duration)
+}
+
+// Handler hashes and validates the passwords in requests.
+type Handler struct {
+	// Peppers peppers new hashes with the current key, and validates peppered hashes using the key matching their key
+	// ID. It may be nil, in which case nothing is peppered.
+	Peppers *hashAlgorithms.Peppers
+	// Observer records how long each hash and validation took. It may be nil.
+	Observer Observer
+}
+
+// Handle returns the response to a request which has passed ValidateRequest. Valid hashes are replaced in the response
+// to VERIFYPASSWORDANDREHASH requests if the password is correct and the hash is legacy, or uses a different algorithm
+// or lower parameters than requested. An error is returned if the request's hash is invalid, which should be published
+// as an INVALID_HASH error, or ErrInvalidRequestType if the request type is unknown.
+func (h *Handler) Handle(req *protocol.Request) (res *protocol.Response, err error) {
+	params := RequestParams(req)
+	switch req.RequestType {
+	case protocol.Request_HASHPASSWORD:
+		return &protocol.Response{
+			Hash: h.hashPassword(req.Password, params),
+		}, nil
+	case protocol.Request_VERIFYPASSWORD, protocol.Request_VERIFYPASSWORDANDREHASH:
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrInvalidRequestType, req.RequestType)
+	}
+
+	isValid, isLegacy, err := h.validatePassword(req)
+	if err != nil {
+		return nil, err
+	}
+	res = &protocol.Response{
+		IsValid: isValid,
+	}
+	// Only rehash if the password is correct, otherwise we'd be handing out a valid hash for an incorrect password.
+	if req.RequestType != protocol.Request_VERIFYPASSWORDANDREHASH || !isValid {
+		return res, nil
+	}
+
+	// Legacy hashes are always replaced, so that users are moved onto the native scheme.
+	needsRehash := isLegacy
+	if !needsRehash {
+		needsRehash, err = NeedsRehash(req.Hash, params, h.Peppers)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if needsRehash {
+		res.Hash = h.hashPassword(req.Password, params)
+	}
+	return res, nil
+}
+
+// hashPassword hashes the password, and records how long it took.
+func (h *Handler) hashPassword(password []byte, params hashAlgorithms.Params) (hash string) {
+	start := time.Now()
+	hash = HashPassword(password, params, h.Peppers)
+	if h.Observer != nil {
+		h.Observer.ObserveHash(params, time.Since(start))
+	}
+	return hash
+}
+
+// validatePassword validates the password in the request against its hash, and records how long it took.
+func (h *Handler) validatePassword(req *protocol.Request) (isValid bool, isLegacy bool, err error) {
+	start := time.Now()
+	isValid, isLegacy, err = ValidatePassword(req.Password, req.LegacyPassword, req.Hash, h.Peppers)
+	if err == nil && h.Observer != nil {
+		h.Observer.ObserveVerify(req.Hash, time.Since(start))
+	}
+	return isValid, isLegacy, err
+}
@@ -0,0 +1,101 @@
+package requestHandler
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rsheasby/gocrypt/hashAlgorithms"
+	"github.com/rsheasby/gocrypt/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingObserver counts the hashes and validations it observes.
+type countingObserver struct {
+	hashes, verifies int
+}
+
+func (o *countingObserver) ObserveHash(params hashAlgorithms.Params, duration time.Duration) {
+	o.hashes++
+}
+
+func (o *countingObserver) ObserveVerify(hash string, duration time.Duration) {
+	o.verifies++
+}
+
+func TestHandlerShouldHashAndValidatePasswords(t *testing.T) {
+	observer := &countingObserver{}
+	handler := &Handler{Observer: observer}
+
+	res, err := handler.Handle(&protocol.Request{
+		RequestType: protocol.Request_HASHPASSWORD,
+		Password:    []byte("password"),
+		Cost:        4,
+	})
+	assert.Nil(t, err, "No error should be returned when hashing")
+	assert.NotEmpty(t, res.Hash, "The password should be hashed")
+	hash := res.Hash
+
+	res, err = handler.Handle(&protocol.Request{
+		RequestType: protocol.Request_VERIFYPASSWORD,
+		Password:    []byte("password"),
+		Hash:        hash,
+	})
+	assert.Nil(t, err, "No error should be returned when validating")
+	assert.True(t, res.IsValid, "The password should be valid")
+
+	res, err = handler.Handle(&protocol.Request{
+		RequestType: protocol.Request_VERIFYPASSWORD,
+		Password:    []byte("wrong"),
+		Hash:        hash,
+	})
+	assert.Nil(t, err, "No error should be returned when validating")
+	assert.False(t, res.IsValid, "The password should be invalid")
+	assert.Equal(t, 1, observer.hashes, "Each hash should be observed")
+	assert.Equal(t, 2, observer.verifies, "Each validation should be observed")
+}
+
+func TestHandlerShouldOnlyRehashValidPasswordsWhenNecessary(t *testing.T) {
+	handler := &Handler{}
+	hash := HashPassword([]byte("password"), hashAlgorithms.Params{Algorithm: hashAlgorithms.Bcrypt, Cost: 5}, nil)
+	req := &protocol.Request{
+		RequestType: protocol.Request_VERIFYPASSWORDANDREHASH,
+		Password:    []byte("password"),
+		Hash:        hash,
+		Cost:        5,
+	}
+
+	res, err := handler.Handle(req)
+	assert.Nil(t, err, "No error should be returned when validating")
+	assert.True(t, res.IsValid, "The password should be valid")
+	assert.Empty(t, res.Hash, "The hash shouldn't be replaced when the cost is sufficient")
+
+	req.Cost = 6
+	res, err = handler.Handle(req)
+	assert.Nil(t, err, "No error should be returned when validating")
+	assert.NotEmpty(t, res.Hash, "The hash should be replaced when the cost is higher")
+
+	req.Password = []byte("wrong")
+	res, err = handler.Handle(req)
+	assert.Nil(t, err, "No error should be returned when validating")
+	assert.False(t, res.IsValid, "The password should be invalid")
+	assert.Empty(t, res.Hash, "The hash shouldn't be replaced when the password is invalid")
+}
+
+func TestHandlerShouldReturnErrorsForInvalidHashesAndRequestTypes(t *testing.T) {
+	handler := &Handler{}
+
+	_, err := handler.Handle(&protocol.Request{
+		RequestType: protocol.Request_VERIFYPASSWORDANDREHASH,
+		Password:    []byte("password"),
+		Hash:        "$2y",
+		Cost:        4,
+	})
+	assert.NotNil(t, err, "Should return an error when the hash is invalid")
+
+	_, err = handler.Handle(&protocol.Request{
+		RequestType: 3,
+		Password:    []byte("password"),
+	})
+	assert.True(t, errors.Is(err, ErrInvalidRequestType), "Should return ErrInvalidRequestType for unknown types")
+}
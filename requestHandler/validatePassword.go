@@ -0,0 +1,14 @@
+package requestHandler
+
+import (
+	"github.com/rsheasby/gocrypt/hashAlgorithms"
+)
+
+// ValidatePassword takes a hash, a password and optionally the raw password, and returns if the password is valid. The
+// hashing algorithm is detected from the hash's prefix. Legacy hashes, and plain bcrypt hashes which don't match the
+// password, are validated using the raw password if it's provided, in which case isLegacy is true if the password
+// matched and the hash should be replaced. Peppered hashes are validated using the key matching their key ID.
+func ValidatePassword(password []byte, legacyPassword []byte, hash string, peppers *hashAlgorithms.Peppers) (
+	isValid bool, isLegacy bool, err error) {
+	return peppers.CompareWithLegacy(password, legacyPassword, hash)
+}
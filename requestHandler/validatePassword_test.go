@@ -0,0 +1,61 @@
+package requestHandler
+
+import (
+	"testing"
+
+	"github.com/rsheasby/gocrypt/hashAlgorithms"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidatePasswordShouldValidateCorrectPassword(t *testing.T) {
+	password := []byte("password")
+	hash := "$2y$10$z3QlTH2S0HFcX0bXY6B.8OQ.jj4mbdYPho4PnhEgM0qk2kbFNnw92"
+	isValid, _, err := ValidatePassword(password, nil, hash, nil)
+
+	assert.NoError(t, err, "Should not return error when validating a valid hash")
+	assert.True(t, isValid, "Should validate as true when the correct password is provided")
+}
+
+func TestValidatePasswordShouldValidateIncorrectPassword(t *testing.T) {
+	password := []byte("password1")
+	hash := "$2y$10$z3QlTH2S0HFcX0bXY6B.8OQ.jj4mbdYPho4PnhEgM0qk2kbFNnw92"
+	isValid, _, err := ValidatePassword(password, nil, hash, nil)
+
+	assert.NoError(t, err, "Should not return error when validating a valid hash")
+	assert.False(t, isValid, "Should validate as false when the incorrect password is provided")
+}
+
+func TestValidatePasswordShouldErrorWithInvalidHash(t *testing.T) {
+	password := []byte("password")
+	hash := "$2y$32$z3QlTH2S0HFcX0bXY6B.8OQ.jj4mbdYPho4PnhEgM0qk2kbFNnw92"
+	isValid, _, err := ValidatePassword(password, nil, hash, nil)
+
+	assert.False(t, isValid, "Should validate as false when there's an invalid hash provided")
+	assert.NotNil(t, err, "Should return an error when an invalid hash is provided")
+}
+
+func TestValidatePasswordShouldValidateLegacyHashes(t *testing.T) {
+	legacyPassword := []byte("password")
+	hash := "$6$rounds=1000$abcdefgh$wuAp2XWwaaguzVxZjeM2bd1yLSqbC/I9sr9DFeOfIPoAZiIj3ecL6rf9ibuAg8RDmh1vqbaeL0NSLJtGPF7b60"
+	isValid, isLegacy, err := ValidatePassword([]byte("prehashed"), legacyPassword, hash, nil)
+
+	assert.NoError(t, err, "Should not return error when validating a valid legacy hash")
+	assert.True(t, isValid, "Should validate as true when the correct raw password is provided")
+	assert.True(t, isLegacy, "Should report the hash as a legacy hash")
+
+	_, _, err = ValidatePassword([]byte("prehashed"), nil, hash, nil)
+	assert.NotNil(t, err, "Should return an error when validating a legacy hash without the raw password")
+}
+
+func TestValidatePasswordShouldValidatePepperedHashes(t *testing.T) {
+	password := []byte("password")
+	peppers, _ := hashAlgorithms.NewPeppers("1", map[string][]byte{"1": []byte("0123456789abcdef")})
+	hash := HashPassword(password, hashAlgorithms.Params{Algorithm: hashAlgorithms.Bcrypt, Cost: 4}, peppers)
+
+	isValid, _, err := ValidatePassword(password, nil, hash, peppers)
+	assert.NoError(t, err, "Should not return error when validating a peppered hash")
+	assert.True(t, isValid, "Should validate as true when the correct password and pepper are provided")
+
+	_, _, err = ValidatePassword(password, nil, hash, nil)
+	assert.NotNil(t, err, "Should return an error when validating a peppered hash without the pepper")
+}
@@ -0,0 +1,65 @@
+package requestHandler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rsheasby/gocrypt/hashAlgorithms"
+	"github.com/rsheasby/gocrypt/protocol"
+)
+
+// ErrInvalidRequestType is returned for requests which aren't HASHPASSWORD, VERIFYPASSWORD or VERIFYPASSWORDANDREHASH
+// requests.
+var ErrInvalidRequestType = errors.New("invalid request type provided")
+
+// ValidateRequest returns an error if the request's response key is shorter than minResponseKeyLength, if it's missing
+// any of the fields required for its type, or if its hashing parameters are invalid. The error should be published as
+// an INVALID_REQUEST error.
+func ValidateRequest(req *protocol.Request, minResponseKeyLength int) (err error) {
+	// Ensure the request type is valid
+	if req.RequestType != protocol.Request_HASHPASSWORD && req.RequestType != protocol.Request_VERIFYPASSWORD &&
+		req.RequestType != protocol.Request_VERIFYPASSWORDANDREHASH {
+		return fmt.Errorf("%w - should be either HASHPASSWORD, VERIFYPASSWORD or VERIFYPASSWORDANDREHASH but "+
+			"received invalid int instead: %d", ErrInvalidRequestType, req.RequestType)
+	}
+
+	// Input validation for all request types
+	if len(req.ResponseKey) < minResponseKeyLength {
+		return fmt.Errorf("response key is too short - should be %d characters at a minimum, but provided key had a length of %d", minResponseKeyLength, len(req.ResponseKey))
+	}
+	if len(req.Password) == 0 {
+		return fmt.Errorf("password field is empty")
+	}
+
+	// Input validation for HASHPASSWORD and VERIFYPASSWORDANDREHASH requests
+	if req.RequestType == protocol.Request_HASHPASSWORD || req.RequestType == protocol.Request_VERIFYPASSWORDANDREHASH {
+		err = RequestParams(req).Validate()
+		if err != nil {
+			return fmt.Errorf("invalid hashing parameters provided - %v", err)
+		}
+	}
+
+	// Input validation for VERIFYPASSWORD and VERIFYPASSWORDANDREHASH requests
+	if req.RequestType == protocol.Request_VERIFYPASSWORD || req.RequestType == protocol.Request_VERIFYPASSWORDANDREHASH {
+		if len(req.Hash) == 0 {
+			return fmt.Errorf("hash field is empty")
+		}
+		if hashAlgorithms.IsLegacy(req.Hash) && len(req.LegacyPassword) == 0 {
+			return fmt.Errorf("legacy password field is empty, but is required to validate legacy hashes")
+		}
+	}
+	return nil
+}
+
+// CheckExpiry returns how long before the server time the request expired, and an error which should be published as
+// an EXPIRED error if it has expired. The server time must come from the transport, as the client sets the expiry using
+// the transport's clock rather than its own.
+func CheckExpiry(req *protocol.Request, serverTime time.Time) (lateness time.Duration, err error) {
+	lateness = serverTime.Sub(time.Unix(0, req.ExpiryTimestamp))
+	if lateness > 0 {
+		return lateness, fmt.Errorf("request expired %1.3f seconds before it was received by the agent",
+			lateness.Seconds())
+	}
+	return lateness, nil
+}
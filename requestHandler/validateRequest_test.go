@@ -0,0 +1,244 @@
+package requestHandler
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/rsheasby/gocrypt/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRequestShouldCatchGenericErrors(t *testing.T) {
+	// Invalid request type
+	req := &protocol.Request{
+		RequestType:     3,
+		ResponseKey:     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		Password:        []byte("abc"),
+		Hash:            "abc",
+		Cost:            4,
+		ExpiryTimestamp: math.MaxInt64,
+	}
+
+	err := ValidateRequest(req, DefaultMinResponseKeyLength)
+	assert.NotNil(t, err, "Should return an error when an invalid request type is provided")
+
+	// Response key too short
+	req = &protocol.Request{
+		RequestType:     protocol.Request_HASHPASSWORD,
+		ResponseKey:     "ABCD",
+		Password:        []byte("abc"),
+		Hash:            "abc",
+		Cost:            4,
+		ExpiryTimestamp: math.MaxInt64,
+	}
+
+	err = ValidateRequest(req, DefaultMinResponseKeyLength)
+	assert.NotNil(t, err, "Should return an error when response key is too short")
+
+	// Password field empty
+	req = &protocol.Request{
+		RequestType:     protocol.Request_HASHPASSWORD,
+		ResponseKey:     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		Password:        []byte(""),
+		Hash:            "abc",
+		Cost:            4,
+		ExpiryTimestamp: math.MaxInt64,
+	}
+
+	err = ValidateRequest(req, DefaultMinResponseKeyLength)
+	assert.NotNil(t, err, "Should return an error when password field is empty")
+}
+
+func TestValidateRequestShouldCatchHashPasswordErrors(t *testing.T) {
+	// Low cost
+	req := &protocol.Request{
+		RequestType:     protocol.Request_HASHPASSWORD,
+		ResponseKey:     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		Password:        []byte("abc"),
+		Hash:            "abc",
+		Cost:            3,
+		ExpiryTimestamp: math.MaxInt64,
+	}
+
+	err := ValidateRequest(req, DefaultMinResponseKeyLength)
+	assert.NotNil(t, err, "Should return an error when low cost provided")
+
+	// High cost
+	req = &protocol.Request{
+		RequestType:     protocol.Request_HASHPASSWORD,
+		ResponseKey:     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		Password:        []byte("abc"),
+		Hash:            "abc",
+		Cost:            32,
+		ExpiryTimestamp: math.MaxInt64,
+	}
+
+	err = ValidateRequest(req, DefaultMinResponseKeyLength)
+	assert.NotNil(t, err, "Should return an error when high cost provided")
+}
+
+func TestValidateRequestShouldCatchVerifyPasswordErrors(t *testing.T) {
+	// Empty hash
+	req := &protocol.Request{
+		RequestType:     protocol.Request_VERIFYPASSWORD,
+		ResponseKey:     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		Password:        []byte("abc"),
+		Hash:            "",
+		Cost:            4,
+		ExpiryTimestamp: math.MaxInt64,
+	}
+
+	err := ValidateRequest(req, DefaultMinResponseKeyLength)
+	assert.NotNil(t, err, "Should return an error when empty hash provided")
+}
+
+func TestValidateRequestShouldCatchVerifyPasswordAndRehashErrors(t *testing.T) {
+	// Empty hash
+	req := &protocol.Request{
+		RequestType:     protocol.Request_VERIFYPASSWORDANDREHASH,
+		ResponseKey:     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		Password:        []byte("abc"),
+		Hash:            "",
+		Cost:            10,
+		ExpiryTimestamp: math.MaxInt64,
+	}
+
+	err := ValidateRequest(req, DefaultMinResponseKeyLength)
+	assert.NotNil(t, err, "Should return an error when empty hash provided")
+
+	// Invalid cost
+	req = &protocol.Request{
+		RequestType:     protocol.Request_VERIFYPASSWORDANDREHASH,
+		ResponseKey:     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		Password:        []byte("abc"),
+		Hash:            "abc",
+		Cost:            3,
+		ExpiryTimestamp: math.MaxInt64,
+	}
+
+	err = ValidateRequest(req, DefaultMinResponseKeyLength)
+	assert.NotNil(t, err, "Should return an error when low cost provided")
+
+	// Legacy hash without the raw password
+	req = &protocol.Request{
+		RequestType:     protocol.Request_VERIFYPASSWORD,
+		ResponseKey:     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		Password:        []byte("abc"),
+		Hash:            "pbkdf2_sha256$1000$seasalt$YIWkt6M1JFXrHg5s0jZjBSc7C2Cz6QvchSJ0h8Y+i7c=",
+		ExpiryTimestamp: math.MaxInt64,
+	}
+
+	err = ValidateRequest(req, DefaultMinResponseKeyLength)
+	assert.NotNil(t, err, "Should return an error when a legacy hash is provided without the legacy password")
+}
+
+func TestValidateRequestShouldNotErrorWithValidRequest(t *testing.T) {
+	// Valid hash request
+	req := &protocol.Request{
+		RequestType:     protocol.Request_HASHPASSWORD,
+		ResponseKey:     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		Password:        []byte("abd"),
+		Cost:            10,
+		ExpiryTimestamp: math.MaxInt64,
+	}
+
+	err := ValidateRequest(req, DefaultMinResponseKeyLength)
+	assert.Nil(t, err, "Should not error with valid hash request")
+
+	// Valid verify request
+	req = &protocol.Request{
+		RequestType:     protocol.Request_VERIFYPASSWORD,
+		ResponseKey:     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		Password:        []byte("abd"),
+		Hash:            "abc",
+		ExpiryTimestamp: math.MaxInt64,
+	}
+
+	err = ValidateRequest(req, DefaultMinResponseKeyLength)
+	assert.Nil(t, err, "Should not error with valid verify request")
+
+	// Valid verify and rehash request
+	req = &protocol.Request{
+		RequestType:     protocol.Request_VERIFYPASSWORDANDREHASH,
+		ResponseKey:     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		Password:        []byte("abd"),
+		Hash:            "abc",
+		Cost:            10,
+		ExpiryTimestamp: math.MaxInt64,
+	}
+
+	err = ValidateRequest(req, DefaultMinResponseKeyLength)
+	assert.Nil(t, err, "Should not error with valid verify and rehash request")
+}
+
+func TestValidateRequestShouldCatchAlgorithmErrors(t *testing.T) {
+	// Unknown algorithm
+	req := &protocol.Request{
+		RequestType:     protocol.Request_HASHPASSWORD,
+		ResponseKey:     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		Password:        []byte("abc"),
+		Algorithm:       3,
+		Cost:            10,
+		ExpiryTimestamp: math.MaxInt64,
+	}
+
+	err := ValidateRequest(req, DefaultMinResponseKeyLength)
+	assert.NotNil(t, err, "Should return an error when an unknown algorithm is provided")
+
+	// Missing argon2id parameters
+	req = &protocol.Request{
+		RequestType:     protocol.Request_HASHPASSWORD,
+		ResponseKey:     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		Password:        []byte("abc"),
+		Algorithm:       protocol.Request_ARGON2ID,
+		ExpiryTimestamp: math.MaxInt64,
+	}
+
+	err = ValidateRequest(req, DefaultMinResponseKeyLength)
+	assert.NotNil(t, err, "Should return an error when argon2id parameters are missing")
+
+	// Valid argon2id parameters
+	req = &protocol.Request{
+		RequestType:     protocol.Request_VERIFYPASSWORDANDREHASH,
+		ResponseKey:     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		Password:        []byte("abc"),
+		Hash:            "abc",
+		Algorithm:       protocol.Request_ARGON2ID,
+		Memory:          65536,
+		Iterations:      3,
+		Parallelism:     4,
+		ExpiryTimestamp: math.MaxInt64,
+	}
+
+	err = ValidateRequest(req, DefaultMinResponseKeyLength)
+	assert.Nil(t, err, "Should not error with valid argon2id parameters")
+
+	// Invalid scrypt cost
+	req = &protocol.Request{
+		RequestType:     protocol.Request_HASHPASSWORD,
+		ResponseKey:     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		Password:        []byte("abc"),
+		Algorithm:       protocol.Request_SCRYPT,
+		Cost:            4,
+		Parallelism:     1,
+		ExpiryTimestamp: math.MaxInt64,
+	}
+
+	err = ValidateRequest(req, DefaultMinResponseKeyLength)
+	assert.NotNil(t, err, "Should return an error when an invalid scrypt cost is provided")
+}
+
+func TestCheckExpiryShouldUseTheServerTime(t *testing.T) {
+	serverTime := time.Now()
+	req := &protocol.Request{
+		ExpiryTimestamp: serverTime.Add(-time.Second).UnixNano(),
+	}
+
+	lateness, err := CheckExpiry(req, serverTime)
+	assert.NotNil(t, err, "Should return an error when the request expired before the server time")
+	assert.Equal(t, time.Second, lateness, "Lateness should be measured from the server time")
+
+	_, err = CheckExpiry(req, serverTime.Add(-2*time.Second))
+	assert.Nil(t, err, "Should not return an error when the request expires after the server time")
+}
@@ -0,0 +1,65 @@
+package transport
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNoAgentRegistry is returned when listing agents using a transport which agents don't register themselves with.
+var ErrNoAgentRegistry = errors.New("transport doesn't keep a registry of agents")
+
+// AgentInfo describes a running agent, as registered by its heartbeat.
+type AgentInfo struct {
+	// ID uniquely identifies the agent.
+	ID string `json:"id"`
+	// Hostname is the hostname of the machine or container the agent is running on.
+	Hostname string `json:"hostname"`
+	// Version is the version of the agent's build.
+	Version string `json:"version"`
+	// Threads is how many requests the agent can handle at once.
+	Threads int `json:"threads"`
+	// Algorithms lists the hashing algorithms which the agent supports for new hashes.
+	Algorithms []string `json:"algorithms"`
+	// BusyThreads is how many of the threads were busy handling a request when the heartbeat was sent.
+	BusyThreads int `json:"busy_threads"`
+	// StartedAt is when the agent was started.
+	StartedAt time.Time `json:"started_at"`
+	// HeartbeatAt is when the agent last sent its heartbeat.
+	HeartbeatAt time.Time `json:"heartbeat_at"`
+}
+
+// Load returns the share of the agent's threads which were busy when the heartbeat was sent, from 0 to 1.
+func (a AgentInfo) Load() float64 {
+	if a.Threads <= 0 {
+		return 0
+	}
+	return float64(a.BusyThreads) / float64(a.Threads)
+}
+
+// AgentRegistry is implemented by transports which agents register themselves with using a heartbeat, so that clients
+// can tell whether any agents are running before they submit requests.
+type AgentRegistry interface {
+	// ListAgents returns the agents whose heartbeat hasn't expired, ordered by ID.
+	ListAgents() (agents []AgentInfo, err error)
+	// AgentsAvailable returns whether any agent's heartbeat hasn't expired.
+	AgentsAvailable() (available bool, err error)
+}
+
+// ListAgents lists the running agents if the transport is an AgentRegistry, otherwise it returns ErrNoAgentRegistry.
+func ListAgents(t Transport) (agents []AgentInfo, err error) {
+	registry, ok := t.(AgentRegistry)
+	if !ok {
+		return nil, ErrNoAgentRegistry
+	}
+	return registry.ListAgents()
+}
+
+// AgentsAvailable returns whether any agents are running if the transport is an AgentRegistry, otherwise it returns
+// ErrNoAgentRegistry.
+func AgentsAvailable(t Transport) (available bool, err error) {
+	registry, ok := t.(AgentRegistry)
+	if !ok {
+		return false, ErrNoAgentRegistry
+	}
+	return registry.AgentsAvailable()
+}
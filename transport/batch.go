@@ -0,0 +1,106 @@
+package transport
+
+import (
+	"context"
+	"time"
+)
+
+// BatchAwaiter waits for the responses to a batch of requests.
+type BatchAwaiter interface {
+	// Wait blocks until every response is received, and returns them by response key. If the timeout passes or the
+	// context is cancelled first, the responses received so far are returned, along with ErrTimeout or the context's
+	// error.
+	Wait(ctx context.Context, timeout time.Duration) (responses map[string][]byte, err error)
+	// Close stops waiting for the responses, and releases anything held by the BatchAwaiter.
+	Close() (err error)
+}
+
+// BatchTransport is implemented by transports which can submit a batch of requests and wait for their responses more
+// efficiently than one at a time, such as by sharing a single subscription.
+type BatchTransport interface {
+	// SubmitRequests queues the requests with the priority, like SubmitRequestWithPriority.
+	SubmitRequests(reqs [][]byte, priority Priority) (err error)
+	// AwaitResponses starts waiting for the responses with the specified response keys. Like AwaitResponse, it must be
+	// called before the requests are submitted.
+	AwaitResponses(responseKeys []string) (awaiter BatchAwaiter, err error)
+}
+
+// SubmitRequests submits the requests together if the transport is a BatchTransport. Otherwise, they're submitted one
+// at a time, stopping at the first error.
+func SubmitRequests(t Transport, reqs [][]byte, priority Priority) (err error) {
+	if batchTransport, ok := t.(BatchTransport); ok {
+		return batchTransport.SubmitRequests(reqs, priority)
+	}
+	for _, req := range reqs {
+		err = SubmitRequestWithPriority(t, req, priority)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AwaitResponses starts waiting for the responses together if the transport is a BatchTransport. Otherwise, it waits
+// for each response separately.
+func AwaitResponses(t Transport, responseKeys []string) (awaiter BatchAwaiter, err error) {
+	if batchTransport, ok := t.(BatchTransport); ok {
+		return batchTransport.AwaitResponses(responseKeys)
+	}
+	awaiters := make(multiAwaiter, len(responseKeys))
+	for _, responseKey := range responseKeys {
+		awaiters[responseKey], err = t.AwaitResponse(responseKey)
+		if err != nil {
+			delete(awaiters, responseKey)
+			_ = awaiters.Close()
+			return nil, err
+		}
+	}
+	return awaiters, nil
+}
+
+// multiAwaiter waits for a batch of responses using an Awaiter for each response key.
+type multiAwaiter map[string]Awaiter
+
+// Wait waits for each of the responses at the same time.
+func (m multiAwaiter) Wait(ctx context.Context, timeout time.Duration) (responses map[string][]byte, err error) {
+	type result struct {
+		responseKey string
+		res         []byte
+		err         error
+	}
+	received := make(chan result, len(m))
+	for responseKey, awaiter := range m {
+		go func(responseKey string, awaiter Awaiter) {
+			res, err := awaiter.Wait(ctx, timeout)
+			received <- result{responseKey, res, err}
+		}(responseKey, awaiter)
+	}
+
+	responses = make(map[string][]byte, len(m))
+	for range m {
+		result := <-received
+		if result.err != nil {
+			if err == nil {
+				err = result.err
+			}
+			continue
+		}
+		responses[result.responseKey] = result.res
+	}
+	// The context's error takes precedence, as any awaiter which was still waiting returns it as well.
+	if err != nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	return responses, err
+}
+
+// Close closes each of the awaiters, and returns the first error.
+func (m multiAwaiter) Close() (err error) {
+	for _, awaiter := range m {
+		closeErr := awaiter.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,20 @@
+package transport
+
+// ResponseChannelTransport is implemented by transports which receive every response for a client on a single channel,
+// instead of waiting on each response key separately. The client sends the channel along with each request, and the
+// agent publishes the response on the channel using PublishResponse, in place of the response key, wrapped in a
+// protocol.ChannelResponse so that the client can route it to the request waiting for it.
+type ResponseChannelTransport interface {
+	// ResponseChannel returns the channel which responses for this client should be published on. An empty channel
+	// means that responses should be published on their response keys as usual.
+	ResponseChannel() (channel string)
+}
+
+// ResponseChannel returns the channel which responses should be published on if the transport is a
+// ResponseChannelTransport, otherwise it returns an empty string.
+func ResponseChannel(t Transport) (channel string) {
+	if channelTransport, ok := t.(ResponseChannelTransport); ok {
+		return channelTransport.ResponseChannel()
+	}
+	return ""
+}
@@ -0,0 +1,195 @@
+// Package memoryTransport implements the gocrypt transport in memory, so that clients and agents in the same process
+// can talk to each other without a broker. It's intended for tests.
+package memoryTransport
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rsheasby/gocrypt/transport"
+)
+
+// DefaultQueueLength specifies how many requests of each priority can be queued before SubmitRequest blocks.
+const DefaultQueueLength = 1024
+
+// Transport carries requests and responses through channels. Like the redis pub/sub transport, responses are only
+// delivered if a client is waiting for them.
+type Transport struct {
+	requests     chan []byte
+	bulkRequests chan []byte
+
+	mu        sync.Mutex
+	responses map[string]chan []byte
+}
+
+var (
+	_ transport.Transport         = (*Transport)(nil)
+	_ transport.PrioritySubmitter = (*Transport)(nil)
+)
+
+// New returns an empty Transport which can queue up to the DefaultQueueLength requests of each priority.
+func New() (t *Transport) {
+	return &Transport{
+		requests:     make(chan []byte, DefaultQueueLength),
+		bulkRequests: make(chan []byte, DefaultQueueLength),
+		responses:    make(map[string]chan []byte),
+	}
+}
+
+// Ping always succeeds, as there's no broker to reach.
+func (t *Transport) Ping() (err error) {
+	return nil
+}
+
+// SubmitRequest queues the request with the interactive priority. It blocks if the queue is full.
+func (t *Transport) SubmitRequest(req []byte) (err error) {
+	return t.SubmitRequestWithPriority(req, transport.PriorityInteractive)
+}
+
+// SubmitRequestWithPriority queues the request on the queue for the priority. It blocks if the queue is full.
+func (t *Transport) SubmitRequestWithPriority(req []byte, priority transport.Priority) (err error) {
+	t.queue(priority) <- req
+	return nil
+}
+
+// queue returns the queue for requests with the priority.
+func (t *Transport) queue(priority transport.Priority) chan []byte {
+	if priority == transport.PriorityBulk {
+		return t.bulkRequests
+	}
+	return t.requests
+}
+
+// QueueDepth returns how many requests of every priority are waiting to be received.
+func (t *Transport) QueueDepth() (depth int64, err error) {
+	return int64(len(t.requests) + len(t.bulkRequests)), nil
+}
+
+// AwaitResponse registers the response key, so that the response is delivered once it's published.
+func (t *Transport) AwaitResponse(responseKey string) (awaiter transport.Awaiter, err error) {
+	received := make(chan []byte, 1)
+	t.mu.Lock()
+	t.responses[responseKey] = received
+	t.mu.Unlock()
+
+	return &memoryAwaiter{
+		transport:   t,
+		responseKey: responseKey,
+		received:    received,
+	}, nil
+}
+
+// ReceiveRequest takes the next request off the queue with the highest priority, blocking until there is one or the
+// context is cancelled.
+func (t *Transport) ReceiveRequest(ctx context.Context) (delivery transport.Delivery, err error) {
+	// A select picks at random between the queues which are ready, so the interactive queue is checked on its own
+	// first.
+	select {
+	case req := <-t.requests:
+		return t.newDelivery(req, transport.PriorityInteractive), nil
+	default:
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case req := <-t.requests:
+		return t.newDelivery(req, transport.PriorityInteractive), nil
+	case req := <-t.bulkRequests:
+		return t.newDelivery(req, transport.PriorityBulk), nil
+	}
+}
+
+// newDelivery returns the delivery for a request taken off the queue for the priority.
+func (t *Transport) newDelivery(req []byte, priority transport.Priority) (delivery *memoryDelivery) {
+	return &memoryDelivery{
+		transport: t,
+		body:      req,
+		priority:  priority,
+	}
+}
+
+// PublishResponse delivers the response if a client is waiting for it. Only the first response for each response key
+// is delivered.
+func (t *Transport) PublishResponse(responseKey string, res []byte) (delivered bool, err error) {
+	t.mu.Lock()
+	received, ok := t.responses[responseKey]
+	t.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+
+	select {
+	case received <- res:
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// ServerTime returns the local time, as clients and agents share the same clock.
+func (t *Transport) ServerTime() (serverTime time.Time, err error) {
+	return time.Now(), nil
+}
+
+// memoryAwaiter waits for the response to be published to its channel.
+type memoryAwaiter struct {
+	transport   *Transport
+	responseKey string
+	received    chan []byte
+}
+
+// Wait blocks until the response is published, the timeout passes, or the context is cancelled.
+func (a *memoryAwaiter) Wait(ctx context.Context, timeout time.Duration) (res []byte, err error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+		return nil, transport.ErrTimeout
+	case res = <-a.received:
+		return res, nil
+	}
+}
+
+// Close unregisters the response key, so responses published afterwards aren't delivered.
+func (a *memoryAwaiter) Close() (err error) {
+	a.transport.mu.Lock()
+	if a.transport.responses[a.responseKey] == a.received {
+		delete(a.transport.responses, a.responseKey)
+	}
+	a.transport.mu.Unlock()
+	return nil
+}
+
+// memoryDelivery is a request taken off the queue. Nothing needs to be acknowledged, as requests aren't redelivered.
+type memoryDelivery struct {
+	transport *Transport
+	body      []byte
+	priority  transport.Priority
+}
+
+// Body returns the request as it was submitted.
+func (d *memoryDelivery) Body() (req []byte) {
+	return d.body
+}
+
+// Ack does nothing.
+func (d *memoryDelivery) Ack() (err error) {
+	return nil
+}
+
+// Requeue puts the request back onto the end of the queue it was taken from. Unlike SubmitRequest, an error is returned
+// instead of blocking if the queue is full.
+func (d *memoryDelivery) Requeue() (err error) {
+	select {
+	case d.transport.queue(d.priority) <- d.body:
+		return nil
+	default:
+		return fmt.Errorf("request queue is full")
+	}
+}
@@ -0,0 +1,112 @@
+package memoryTransport
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rsheasby/gocrypt/transport"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransportShouldDeliverRequestsAndResponses(t *testing.T) {
+	tr := New()
+	assert.Nil(t, tr.Ping(), "Ping should always succeed")
+
+	awaiter, err := tr.AwaitResponse("key")
+	assert.Nil(t, err, "No error should be returned when awaiting a response")
+	defer awaiter.Close()
+
+	assert.Nil(t, tr.SubmitRequest([]byte("request")), "No error should be returned when submitting a request")
+	delivery, err := tr.ReceiveRequest(context.Background())
+	assert.Nil(t, err, "No error should be returned when receiving a request")
+	assert.Equal(t, []byte("request"), delivery.Body(), "Received request should match the submitted request")
+	assert.Nil(t, delivery.Ack(), "Acknowledging a request should succeed")
+
+	delivered, err := tr.PublishResponse("other key", []byte("response"))
+	assert.Nil(t, err, "No error should be returned when publishing a response")
+	assert.False(t, delivered, "Responses shouldn't be delivered if nobody is waiting for them")
+
+	delivered, err = tr.PublishResponse("key", []byte("response"))
+	assert.Nil(t, err, "No error should be returned when publishing a response")
+	assert.True(t, delivered, "Responses should be delivered to the waiting client")
+
+	res, err := awaiter.Wait(context.Background(), time.Second)
+	assert.Nil(t, err, "No error should be returned when the response is received")
+	assert.Equal(t, []byte("response"), res, "Received response should match the published response")
+}
+
+func TestTransportShouldStopWaiting(t *testing.T) {
+	tr := New()
+
+	awaiter, _ := tr.AwaitResponse("key")
+	_, err := awaiter.Wait(context.Background(), time.Millisecond)
+	assert.Equal(t, transport.ErrTimeout, err, "ErrTimeout should be returned if no response is received in time")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = awaiter.Wait(ctx, time.Second)
+	assert.Equal(t, context.Canceled, err, "The context's error should be returned if it's cancelled")
+
+	_, err = tr.ReceiveRequest(ctx)
+	assert.Equal(t, context.Canceled, err, "The context's error should be returned if it's cancelled")
+
+	awaiter.Close()
+	delivered, _ := tr.PublishResponse("key", []byte("response"))
+	assert.False(t, delivered, "Responses shouldn't be delivered once the client has stopped waiting")
+}
+
+func TestTransportShouldRequeueRequests(t *testing.T) {
+	tr := New()
+	_ = tr.SubmitRequest([]byte("request"))
+
+	depth, _ := tr.QueueDepth()
+	assert.Equal(t, int64(1), depth, "Queue depth should count submitted requests")
+
+	delivery, _ := tr.ReceiveRequest(context.Background())
+	assert.Nil(t, delivery.Requeue(), "Requeueing a request should succeed")
+	depth, _ = tr.QueueDepth()
+	assert.Equal(t, int64(1), depth, "Queue depth should count requeued requests")
+
+	delivery, err := tr.ReceiveRequest(context.Background())
+	assert.Nil(t, err, "No error should be returned when receiving a requeued request")
+	assert.Equal(t, []byte("request"), delivery.Body(), "Requeued request should be received again")
+}
+
+func TestTransportShouldReceiveInteractiveRequestsFirst(t *testing.T) {
+	tr := New()
+	_ = tr.SubmitRequestWithPriority([]byte("bulk"), transport.PriorityBulk)
+	_ = tr.SubmitRequest([]byte("interactive"))
+
+	depth, _ := tr.QueueDepth()
+	assert.Equal(t, int64(2), depth, "Queue depth should count requests of every priority")
+
+	delivery, _ := tr.ReceiveRequest(context.Background())
+	assert.Equal(t, []byte("interactive"), delivery.Body(), "Interactive requests should be received first")
+	delivery, _ = tr.ReceiveRequest(context.Background())
+	assert.Equal(t, []byte("bulk"), delivery.Body(), "Bulk requests should be received once no interactive requests are waiting")
+
+	_ = delivery.Requeue()
+	_ = tr.SubmitRequest([]byte("interactive"))
+	delivery, _ = tr.ReceiveRequest(context.Background())
+	assert.Equal(t, []byte("interactive"), delivery.Body(), "Requeued bulk requests should keep their priority")
+}
+
+func TestTransportShouldHandleBatches(t *testing.T) {
+	tr := New()
+	awaiter, err := transport.AwaitResponses(tr, []string{"first", "second"})
+	assert.Nil(t, err, "No error should be returned when waiting for a batch of responses")
+	defer awaiter.Close()
+	err = transport.SubmitRequests(tr, [][]byte{[]byte("first"), []byte("second")}, transport.PriorityBulk)
+	assert.Nil(t, err, "No error should be returned when submitting a batch of requests")
+
+	depth, _ := tr.QueueDepth()
+	assert.Equal(t, int64(2), depth, "Every request in the batch should be submitted")
+	delivery, _ := tr.ReceiveRequest(context.Background())
+	_, _ = tr.PublishResponse(string(delivery.Body()), []byte("response"))
+
+	responses, err := awaiter.Wait(context.Background(), 10*time.Millisecond)
+	assert.Equal(t, transport.ErrTimeout, err, "ErrTimeout should be returned if some responses aren't received")
+	assert.Equal(t, map[string][]byte{"first": []byte("response")}, responses,
+		"The responses received before the timeout should be returned")
+}
@@ -0,0 +1,43 @@
+package transport
+
+// Priority specifies how urgently a request needs to be handled. Agents always receive the waiting requests with the
+// highest priority first, so that bulk work such as rehashing or importing users can't hold up interactive logins.
+type Priority int
+
+const (
+	// PriorityInteractive is for requests which someone is waiting on, such as logins. It's the default.
+	PriorityInteractive Priority = iota
+	// PriorityBulk is for background work, which is only received while no interactive requests are waiting.
+	PriorityBulk
+)
+
+// Priorities lists the priorities from highest to lowest, which is the order that agents receive them in.
+var Priorities = []Priority{PriorityInteractive, PriorityBulk}
+
+// String returns the name of the priority, as used in logs and metrics.
+func (p Priority) String() string {
+	switch p {
+	case PriorityInteractive:
+		return "interactive"
+	case PriorityBulk:
+		return "bulk"
+	default:
+		return "unknown"
+	}
+}
+
+// PrioritySubmitter is implemented by transports which support request priorities.
+type PrioritySubmitter interface {
+	// SubmitRequestWithPriority queues the request to be received by an agent once no requests with a higher priority
+	// are waiting.
+	SubmitRequestWithPriority(req []byte, priority Priority) (err error)
+}
+
+// SubmitRequestWithPriority submits the request with the priority if the transport supports priorities. Otherwise,
+// it's submitted as usual, and is received in the same order as every other request.
+func SubmitRequestWithPriority(t Transport, req []byte, priority Priority) (err error) {
+	if submitter, ok := t.(PrioritySubmitter); ok {
+		return submitter.SubmitRequestWithPriority(req, priority)
+	}
+	return t.SubmitRequest(req)
+}
@@ -0,0 +1,137 @@
+package redisTransport
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/rsheasby/gocrypt/transport"
+)
+
+var _ transport.BatchTransport = (*Transport)(nil)
+
+// SubmitRequests pushes the requests onto the request queue for the priority, or adds them to the request stream for
+// the priority with streams enabled. The commands are pipelined, so the whole batch is submitted in a single round
+// trip.
+func (t *Transport) SubmitRequests(reqs [][]byte, priority transport.Priority) (err error) {
+	conn := t.pool.Get()
+	defer conn.Close()
+
+	for _, req := range reqs {
+		if t.streams {
+			err = conn.Send("XADD", t.keys.requestStream(priority), "*", RequestStreamField, req)
+		} else {
+			err = conn.Send("LPUSH", t.keys.requestQueue(priority), req)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to submit hashing jobs: %v", err)
+		}
+	}
+	err = conn.Flush()
+	if err != nil {
+		return fmt.Errorf("failed to submit hashing jobs: %v", err)
+	}
+	// Every reply has to be received, even after an error, so that the connection can be reused.
+	for range reqs {
+		_, replyErr := conn.Receive()
+		if replyErr != nil && err == nil {
+			err = fmt.Errorf("failed to submit hashing job: %v", replyErr)
+		}
+	}
+	return err
+}
+
+// AwaitResponses starts waiting for every response to be published on the client's response channel, using the
+// shared subscriber. With streams enabled, the responses are popped using a single connection once Wait is called
+// instead.
+func (t *Transport) AwaitResponses(responseKeys []string) (awaiter transport.BatchAwaiter, err error) {
+	if t.streams {
+		return &streamBatchAwaiter{
+			transport:    t,
+			responseKeys: responseKeys,
+		}, nil
+	}
+
+	awaiters, err := t.subscriber.awaitAll(responseKeys)
+	if err != nil {
+		return nil, err
+	}
+	return sharedBatchAwaiter(awaiters), nil
+}
+
+// streamBatchAwaiter waits for the responses to be pushed onto the response keys.
+type streamBatchAwaiter struct {
+	transport    *Transport
+	responseKeys []string
+}
+
+// Wait pops the responses off the response keys, one at a time as they're pushed, using a single connection. As with a
+// single response, the pops happen in the background so that we can stop waiting if the context is cancelled.
+func (a *streamBatchAwaiter) Wait(ctx context.Context, timeout time.Duration) (responses map[string][]byte,
+	err error) {
+	responses = make(map[string][]byte, len(a.responseKeys))
+	remaining := append([]string(nil), a.responseKeys...)
+	deadline := time.Now().Add(timeout)
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		for len(remaining) > 0 {
+			responseKey, resBytes, err := a.transport.popResponses(remaining, deadline)
+			select {
+			case <-stop:
+				done <- nil
+				return
+			default:
+			}
+			if err != nil {
+				done <- err
+				return
+			}
+			responses[responseKey] = resBytes
+			remaining = removeKey(remaining, responseKey)
+		}
+		done <- nil
+	}()
+
+	select {
+	case <-ctx.Done():
+		close(stop)
+		// Pushing an empty value onto every key unblocks the pop, so the connection is released as soon as possible.
+		// The keys are then deleted, in case some of the responses were popped instead of the empty values. The
+		// commands are pipelined, and Do with no command sends them and receives every reply.
+		wakeConn := a.transport.pool.Get()
+		defer wakeConn.Close()
+		for _, responseKey := range a.responseKeys {
+			_ = wakeConn.Send("LPUSH", a.transport.keys.ResponsePrefix+responseKey, "")
+		}
+		_, _ = wakeConn.Do("")
+		<-done
+		for _, responseKey := range a.responseKeys {
+			_ = wakeConn.Send("DEL", a.transport.keys.ResponsePrefix+responseKey)
+		}
+		_, _ = wakeConn.Do("")
+		return responses, ctx.Err()
+	case err = <-done:
+	}
+
+	if err == redis.ErrNil {
+		return responses, transport.ErrTimeout
+	}
+	return responses, err
+}
+
+// Close does nothing, as nothing is held between calls to Wait.
+func (a *streamBatchAwaiter) Close() (err error) {
+	return nil
+}
+
+// removeKey returns the keys without the specified key.
+func removeKey(keys []string, key string) []string {
+	for i := range keys {
+		if keys[i] == key {
+			return append(keys[:i], keys[i+1:]...)
+		}
+	}
+	return keys
+}
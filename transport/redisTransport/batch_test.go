@@ -0,0 +1,41 @@
+package redisTransport
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/rsheasby/gocrypt/transport"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubmitRequestsShouldPipelineTheBatch(t *testing.T) {
+	pool := newMockPool()
+	first := pool.Conn.Command("LPUSH", BulkRequestQueueKey, []byte("first")).Expect(int64(1))
+	second := pool.Conn.Command("LPUSH", BulkRequestQueueKey, []byte("second")).ExpectError(redis.ErrPoolExhausted)
+	third := pool.Conn.Command("LPUSH", BulkRequestQueueKey, []byte("third")).Expect(int64(3))
+
+	err := New(pool).SubmitRequests([][]byte{[]byte("first"), []byte("second"), []byte("third")}, transport.PriorityBulk)
+	assert.Error(t, err, "An error should be returned if any of the requests can't be submitted")
+	assert.True(t, first.Called && second.Called && third.Called, "Every request should be submitted")
+}
+
+func TestStreamBatchAwaiterShouldPopEveryResponse(t *testing.T) {
+	pool := newMockPool()
+	pool.Conn.GenericCommand("BLPOP").Handle(func(args []interface{}) (interface{}, error) {
+		// The keys come before the timeout, and the last key is always popped first.
+		key := args[len(args)-2]
+		return []interface{}{[]byte(key.(string)), []byte("response:" + key.(string))}, nil
+	})
+
+	awaiter, err := New(pool, WithStreams()).AwaitResponses([]string{"first", "second"})
+	assert.Nil(t, err, "No error should be returned when waiting for responses with streams")
+	responses, err := awaiter.Wait(context.Background(), time.Second)
+	assert.Nil(t, err, "No error should be returned once every response is received")
+	assert.Equal(t, map[string][]byte{
+		"first":  []byte("response:" + ResponseKeyPrefix + "first"),
+		"second": []byte("response:" + ResponseKeyPrefix + "second"),
+	}, responses, "Every response should be returned by its response key")
+	assert.Nil(t, awaiter.Close(), "Closing the awaiter should succeed")
+}
@@ -0,0 +1,194 @@
+package redisTransport
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rsheasby/gocrypt/transport"
+)
+
+const (
+	// KeyPrefix specifies the prefix shared by the default redis keys. A namespace is inserted after it.
+	KeyPrefix = "gocrypt:"
+	// RequestQueueKey specifies the redis key that will be used for the request queue.
+	RequestQueueKey = "gocrypt:RequestQueue"
+	// BulkRequestQueueKey specifies the redis key that will be used for the queue of bulk priority requests.
+	BulkRequestQueueKey = "gocrypt:BulkRequestQueue"
+	// ResponseKeyPrefix specifies the redis key prefix that will be used for response publishing.
+	ResponseKeyPrefix = "gocrypt:Response:"
+	// ClientChannelPrefix specifies the prefix of each client's response channel, which comes after the response key
+	// prefix, such as gocrypt:Response:Client:<client ID>.
+	ClientChannelPrefix = "Client:"
+	// RequestStreamKey specifies the redis key that will be used for the request stream with streams enabled.
+	RequestStreamKey = "gocrypt:RequestStream"
+	// BulkRequestStreamKey specifies the redis key that will be used for the stream of bulk priority requests with
+	// streams enabled.
+	BulkRequestStreamKey = "gocrypt:BulkRequestStream"
+	// RequestStreamField specifies the field of the stream entries which holds the request.
+	RequestStreamField = "request"
+	// ConsumerGroup specifies the consumer group which agents use to read the request stream.
+	ConsumerGroup = "gocrypt"
+	// ProcessingListPrefix specifies the redis key prefix for the per-agent processing lists used in reliable mode.
+	ProcessingListPrefix = "gocrypt:Processing:"
+	// AgentKeyPrefix specifies the redis key prefix for the agent heartbeat keys, which describe each running agent.
+	AgentKeyPrefix = "gocrypt:Agent:"
+	// AgentRegistryKey specifies the redis key of the set of agent IDs which have registered a heartbeat key.
+	AgentRegistryKey = "gocrypt:Agents"
+	// DefaultPopTimeout specifies the default timeout for the blocking request pop. This could be arbitrarily long, but
+	// you have to set a limit so I reckon 10 seconds is reasonable. The connection timeout must be longer than this.
+	DefaultPopTimeout = 10 * time.Second
+	// BulkPollInterval specifies how long agents block waiting for an interactive request in reliable mode or with
+	// streams enabled, before checking for bulk requests again. Redis can't block on several queues while moving the
+	// request, or on several streams while only reading one request, so bulk requests which are submitted while an
+	// agent is idle can wait up to this long.
+	BulkPollInterval = time.Second
+	// AgentTTL specifies how long an agent's heartbeat key lasts. If an agent doesn't refresh it within this time, it's
+	// considered dead, so it's no longer listed, and in reliable mode its processing list is requeued by the other
+	// agents.
+	AgentTTL = 30 * time.Second
+	// HeartbeatInterval specifies how often the agent refreshes its heartbeat key. Must be shorter than the AgentTTL.
+	HeartbeatInterval = 10 * time.Second
+	// ReaperInterval specifies how often the agent checks for processing lists left behind by dead agents.
+	ReaperInterval = 30 * time.Second
+	// ClaimIdleTime specifies how long a request can be pending without being acknowledged before another agent claims
+	// it, assuming that the agent handling it has died.
+	ClaimIdleTime = 30 * time.Second
+	// ResponseTTL specifies how long responses are kept for with streams enabled if the client doesn't receive them.
+	ResponseTTL = 60 * time.Second
+	// SubscriberPingInterval specifies how often the connection shared by the clients waiting for responses is pinged
+	// while it's open. If nothing is received on it for twice as long, it's considered lost.
+	SubscriberPingInterval = 15 * time.Second
+	// ReconnectRetryTime specifies how long to wait before reconnecting when the connection is lost while waiting for a
+	// response with streams enabled.
+	ReconnectRetryTime = 100 * time.Millisecond
+)
+
+// Keys specifies the redis keys used by the transport. Clients and agents must use the same keys.
+type Keys struct {
+	// RequestQueue is the key of the request queue.
+	RequestQueue string
+	// BulkRequestQueue is the key of the queue of bulk priority requests.
+	BulkRequestQueue string
+	// ResponsePrefix is prepended to the response key of each request.
+	ResponsePrefix string
+	// RequestStream is the key of the request stream with streams enabled.
+	RequestStream string
+	// BulkRequestStream is the key of the stream of bulk priority requests with streams enabled.
+	BulkRequestStream string
+	// ConsumerGroup is the consumer group which agents use to read the request stream.
+	ConsumerGroup string
+	// ProcessingListPrefix is prepended to the agent ID for the processing lists used in reliable mode.
+	ProcessingListPrefix string
+	// AgentPrefix is prepended to the agent ID for the heartbeat keys.
+	AgentPrefix string
+	// AgentRegistry is the key of the set of agent IDs which have registered a heartbeat key.
+	AgentRegistry string
+}
+
+// DefaultKeys returns the keys used unless a namespace or other keys are specified using WithNamespace or WithKeys.
+func DefaultKeys() (keys Keys) {
+	return Keys{
+		RequestQueue:         RequestQueueKey,
+		BulkRequestQueue:     BulkRequestQueueKey,
+		ResponsePrefix:       ResponseKeyPrefix,
+		RequestStream:        RequestStreamKey,
+		BulkRequestStream:    BulkRequestStreamKey,
+		ConsumerGroup:        ConsumerGroup,
+		ProcessingListPrefix: ProcessingListPrefix,
+		AgentPrefix:          AgentKeyPrefix,
+		AgentRegistry:        AgentRegistryKey,
+	}
+}
+
+// NamespacedKeys returns the keys used in the namespace, which is inserted after the KeyPrefix of each of the
+// DefaultKeys, such as "gocrypt:staging:RequestQueue". The consumer group is left as it is, as it belongs to the
+// namespaced stream. The DefaultKeys are returned for the empty namespace. The namespace should be checked using
+// ValidateNamespace first.
+func NamespacedKeys(namespace string) (keys Keys) {
+	keys = DefaultKeys()
+	if namespace == "" {
+		return keys
+	}
+	namespaced := func(key string) string {
+		return KeyPrefix + namespace + ":" + strings.TrimPrefix(key, KeyPrefix)
+	}
+	keys.RequestQueue = namespaced(keys.RequestQueue)
+	keys.BulkRequestQueue = namespaced(keys.BulkRequestQueue)
+	keys.ResponsePrefix = namespaced(keys.ResponsePrefix)
+	keys.RequestStream = namespaced(keys.RequestStream)
+	keys.BulkRequestStream = namespaced(keys.BulkRequestStream)
+	keys.ProcessingListPrefix = namespaced(keys.ProcessingListPrefix)
+	keys.AgentPrefix = namespaced(keys.AgentPrefix)
+	keys.AgentRegistry = namespaced(keys.AgentRegistry)
+	return keys
+}
+
+// invalidNamespaceChars can't be used in namespaces, as they would either split the namespace into several key
+// segments, or be matched as patterns when scanning for keys.
+const invalidNamespaceChars = ":*?[]\\ \t\n"
+
+// ValidateNamespace returns an error if the namespace can't be used with WithNamespace. A namespace can't contain
+// whitespace or any of the invalidNamespaceChars, and can't be the same as the first segment of any of the DefaultKeys,
+// such as "Processing", as its keys would then overlap with the keys of the default namespace. For example, scanning
+// for the default processing lists would also find the processing lists of the "Processing" namespace. The empty
+// namespace is the default namespace, so it's valid.
+func ValidateNamespace(namespace string) (err error) {
+	if strings.ContainsAny(namespace, invalidNamespaceChars) {
+		return fmt.Errorf("namespace %q can't contain whitespace or any of %q", namespace,
+			strings.TrimSpace(invalidNamespaceChars))
+	}
+	if namespace == "" {
+		return nil
+	}
+	defaults := DefaultKeys()
+	for _, key := range []string{defaults.RequestQueue, defaults.BulkRequestQueue, defaults.ResponsePrefix,
+		defaults.RequestStream, defaults.BulkRequestStream, defaults.ProcessingListPrefix, defaults.AgentPrefix,
+		defaults.AgentRegistry} {
+		segment := strings.SplitN(strings.TrimPrefix(key, KeyPrefix), ":", 2)[0]
+		if namespace == segment {
+			return fmt.Errorf("namespace %q is reserved, as it's used by the default keys", namespace)
+		}
+	}
+	return nil
+}
+
+// withDefaults returns the keys with empty keys replaced with the defaults.
+func (k Keys) withDefaults(defaults Keys) (keys Keys) {
+	keys = k
+	for _, key := range []struct {
+		value        *string
+		defaultValue string
+	}{
+		{&keys.RequestQueue, defaults.RequestQueue},
+		{&keys.BulkRequestQueue, defaults.BulkRequestQueue},
+		{&keys.ResponsePrefix, defaults.ResponsePrefix},
+		{&keys.RequestStream, defaults.RequestStream},
+		{&keys.BulkRequestStream, defaults.BulkRequestStream},
+		{&keys.ConsumerGroup, defaults.ConsumerGroup},
+		{&keys.ProcessingListPrefix, defaults.ProcessingListPrefix},
+		{&keys.AgentPrefix, defaults.AgentPrefix},
+		{&keys.AgentRegistry, defaults.AgentRegistry},
+	} {
+		if *key.value == "" {
+			*key.value = key.defaultValue
+		}
+	}
+	return keys
+}
+
+// requestQueue returns the key of the queue for requests with the priority.
+func (k Keys) requestQueue(priority transport.Priority) string {
+	if priority == transport.PriorityBulk {
+		return k.BulkRequestQueue
+	}
+	return k.RequestQueue
+}
+
+// requestStream returns the key of the stream for requests with the priority.
+func (k Keys) requestStream(priority transport.Priority) string {
+	if priority == transport.PriorityBulk {
+		return k.BulkRequestStream
+	}
+	return k.RequestStream
+}
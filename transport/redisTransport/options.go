@@ -0,0 +1,83 @@
+package redisTransport
+
+import (
+	"time"
+
+	"github.com/rsheasby/gocrypt/transport"
+)
+
+// Option configures optional settings for a Transport.
+type Option func(t *Transport)
+
+// WithStreams makes the transport submit requests using a redis stream, and deliver responses on a list per request
+// using BLPOP instead of pub/sub. Responses are kept by redis until they're received, so they aren't lost if the
+// connection drops briefly, and this works with Redis Cluster. Requires Redis 6.2 or later.
+func WithStreams() Option {
+	return func(t *Transport) {
+		t.streams = true
+	}
+}
+
+// WithReliableQueue makes agents move requests into their own processing list while they're being handled, instead of
+// popping them off the queue, so that they're requeued by another agent if the agent dies. It has no effect on clients,
+// or with streams enabled, as the consumer group already keeps track of pending requests. Requires Redis 6.2 or later.
+func WithReliableQueue() Option {
+	return func(t *Transport) {
+		t.reliableQueue = true
+	}
+}
+
+// WithAgentID sets the ID used for the agent's heartbeat key and processing list in reliable mode, and as its consumer
+// name with streams enabled. It must be unique for each running agent. By default, an ID is generated from the hostname
+// and process ID.
+func WithAgentID(id string) Option {
+	return func(t *Transport) {
+		t.agentID = id
+	}
+}
+
+// WithAgentInfo sets the function which provides the agent's info, such as its version and current load, each time its
+// heartbeat is sent. The ID, hostname and heartbeat time are filled in by the transport.
+func WithAgentInfo(info func() transport.AgentInfo) Option {
+	return func(t *Transport) {
+		t.agentInfo = info
+	}
+}
+
+// WithClientID sets the ID used for the client's response channel, which agents publish its responses on. It must be
+// unique for each running client. By default, an ID is generated from the hostname and process ID.
+func WithClientID(id string) Option {
+	return func(t *Transport) {
+		t.clientID = id
+	}
+}
+
+// WithKeys makes the transport use the provided redis keys instead of the DefaultKeys. Empty keys are left as the
+// default, or as the namespaced key if a namespace is specified using WithNamespace.
+func WithKeys(keys Keys) Option {
+	return func(t *Transport) {
+		t.keys = keys
+	}
+}
+
+// WithNamespace makes the transport use the NamespacedKeys of the namespace, so that clients and agents in different
+// namespaces never receive each other's requests or responses. This allows several applications or environments, such
+// as staging and production, to share a redis server. Clients and agents must use the same namespace, which should be
+// checked using ValidateNamespace first.
+func WithNamespace(namespace string) Option {
+	return func(t *Transport) {
+		t.namespace = namespace
+	}
+}
+
+// WithPopTimeout sets how long agents block waiting for a request before checking whether they've been stopped. It's
+// rounded down to whole seconds, with a minimum of 1 second. The redis connection timeout must be longer than this. In
+// reliable mode or with streams enabled, agents only block for up to the BulkPollInterval instead.
+func WithPopTimeout(timeout time.Duration) Option {
+	return func(t *Transport) {
+		if timeout < time.Second {
+			timeout = time.Second
+		}
+		t.popTimeout = timeout.Truncate(time.Second)
+	}
+}
@@ -0,0 +1,237 @@
+// Package redisTransport implements the gocrypt transport using Redis. By default, requests are submitted to a list and
+// responses are published using pub/sub. With streams enabled, requests are submitted to a stream which agents read
+// using a consumer group, and responses are pushed onto a list per request instead.
+package redisTransport
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/rsheasby/gocrypt/transport"
+)
+
+// Pool represents a generic, mockable redigo pool.
+type Pool interface {
+	// Get returns a redis connection instance.
+	Get() redis.Conn
+}
+
+// Transport carries requests and responses through redis. The same Transport can be used by both clients and agents.
+type Transport struct {
+	pool          Pool
+	namespace     string
+	keys          Keys
+	popTimeout    time.Duration
+	streams       bool
+	reliableQueue bool
+	agentID       string
+	clientID      string
+	agentInfo     func() transport.AgentInfo
+	subscriber    *subscriber
+}
+
+var (
+	_ transport.Transport                = (*Transport)(nil)
+	_ transport.PrioritySubmitter        = (*Transport)(nil)
+	_ transport.ResponseChannelTransport = (*Transport)(nil)
+)
+
+// New returns a Transport using the provided redis pool. Clients and agents must use the same options for streams,
+// otherwise their requests won't reach each other.
+func New(pool Pool, opts ...Option) (t *Transport) {
+	t = &Transport{
+		pool:       pool,
+		popTimeout: DefaultPopTimeout,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	t.keys = t.keys.withDefaults(NamespacedKeys(t.namespace))
+	if t.agentID == "" {
+		t.agentID = generateID()
+	}
+	if t.clientID == "" {
+		t.clientID = generateID()
+	}
+	t.subscriber = &subscriber{transport: t}
+	return t
+}
+
+// generateID returns an ID made up of the hostname and process ID, which makes it easy to tell which agent or client it
+// belongs to, as well as some random bytes to prevent collisions between restarts.
+func generateID() (id string) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	random := make([]byte, 4)
+	_, _ = rand.Read(random)
+	return fmt.Sprintf("%s-%d-%x", hostname, os.Getpid(), random)
+}
+
+// AgentID returns the ID used for this agent's heartbeat key and processing list in reliable mode, and as its consumer
+// name with streams enabled.
+func (t *Transport) AgentID() string {
+	return t.agentID
+}
+
+// ClientID returns the ID used for this client's response channel.
+func (t *Transport) ClientID() string {
+	return t.clientID
+}
+
+// ResponseChannel returns the channel which agents should publish this client's responses on. The client subscribes to
+// it once, instead of subscribing to each response key. With streams enabled, responses are pushed onto their response
+// keys instead, so an empty channel is returned.
+func (t *Transport) ResponseChannel() (channel string) {
+	if t.streams {
+		return ""
+	}
+	return ClientChannelPrefix + t.clientID
+}
+
+// Namespace returns the namespace specified using WithNamespace, which is empty by default.
+func (t *Transport) Namespace() string {
+	return t.namespace
+}
+
+// Ping checks that redis can be reached.
+func (t *Transport) Ping() (err error) {
+	conn := t.pool.Get()
+	if conn == nil {
+		// It doesn't seem like this ever happens,
+		// as redigo opts to return the error when doing the actual operation instead of when getting the connection.
+		// Irregardless, doesn't hurt to check it just in-case redigo changes, or my understanding is incorrect.
+		return fmt.Errorf("nil connection returned from redis pool")
+	}
+	defer conn.Close()
+
+	result, err := redis.String(conn.Do("PING"))
+	if err != nil {
+		return fmt.Errorf("error PINGing redis: %v", err)
+	}
+	if result != "PONG" {
+		// Unsure how to test this in a simple way, so it'll have to do without any coverage for now.
+		return fmt.Errorf(`unexpected response when PINGing redis - expected "PONG", received "%s"`, result)
+	}
+	return nil
+}
+
+// SubmitRequest submits the request with the interactive priority.
+func (t *Transport) SubmitRequest(req []byte) (err error) {
+	return t.SubmitRequestWithPriority(req, transport.PriorityInteractive)
+}
+
+// SubmitRequestWithPriority pushes the request onto the request queue for the priority, or adds it to the request
+// stream for the priority with streams enabled.
+func (t *Transport) SubmitRequestWithPriority(req []byte, priority transport.Priority) (err error) {
+	conn := t.pool.Get()
+	defer conn.Close()
+
+	if t.streams {
+		_, err = conn.Do("XADD", t.keys.requestStream(priority), "*", RequestStreamField, req)
+	} else {
+		_, err = conn.Do("LPUSH", t.keys.requestQueue(priority), req)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to submit hashing job: %v", err)
+	}
+	return nil
+}
+
+// QueueDepth returns how many requests of every priority are waiting to be received. With streams enabled, this
+// includes requests which are pending in the consumer group, as requests are only deleted from the stream once they've
+// been handled.
+func (t *Transport) QueueDepth() (depth int64, err error) {
+	conn := t.pool.Get()
+	defer conn.Close()
+
+	for _, priority := range transport.Priorities {
+		var priorityDepth int64
+		if t.streams {
+			priorityDepth, err = redis.Int64(conn.Do("XLEN", t.keys.requestStream(priority)))
+		} else {
+			priorityDepth, err = redis.Int64(conn.Do("LLEN", t.keys.requestQueue(priority)))
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to get queue depth: %v", err)
+		}
+		depth += priorityDepth
+	}
+	return depth, nil
+}
+
+// AwaitResponse starts waiting for the response to be published on the client's response channel. The Transport
+// subscribes to the response channel once, using a single pub/sub connection, and routes each response to the request
+// waiting for it, so waiting for many responses at once doesn't need a connection or subscription for each. With
+// streams enabled, the response is kept until it's popped, so there's nothing to do until Wait is called.
+func (t *Transport) AwaitResponse(responseKey string) (awaiter transport.Awaiter, err error) {
+	if t.streams {
+		return &streamAwaiter{
+			transport:   t,
+			responseKey: responseKey,
+		}, nil
+	}
+	return t.subscriber.await(responseKey)
+}
+
+// Close closes the pub/sub connection used to wait for responses, if it's open. Clients should close the Transport once
+// they're done with it. Responses can't be waited for afterwards, but the Transport can still be used by agents.
+func (t *Transport) Close() (err error) {
+	return t.subscriber.close()
+}
+
+// PublishResponse publishes the response on the response key, or on the client's response channel if that's passed as
+// the response key instead, in which case it's only delivered if the client is subscribed. With streams enabled, the
+// response is pushed onto the response key instead, and is always delivered.
+func (t *Transport) PublishResponse(responseKey string, res []byte) (delivered bool, err error) {
+	conn := t.pool.Get()
+	defer conn.Close()
+
+	if t.streams {
+		err = t.pushResponse(conn, responseKey, res)
+		return err == nil, err
+	}
+
+	receivedBy, err := redis.Int(conn.Do("PUBLISH", t.keys.ResponsePrefix+responseKey, res))
+	if err != nil {
+		return false, fmt.Errorf("redis error when publishing response: %v", err)
+	}
+	return receivedBy != 0, nil
+}
+
+// ServerTime returns the redis server's current timestamp.
+func (t *Transport) ServerTime() (serverTime time.Time, err error) {
+	conn := t.pool.Get()
+	defer conn.Close()
+
+	timestamps, err := redis.Int64s(conn.Do("TIME"))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("couldn't receive timestamp from redis: %v", err)
+	}
+
+	// Should never happen, but may as well check for it just in case
+	if len(timestamps) != 2 {
+		return time.Time{}, fmt.Errorf("couldn't receive timestamp from redis - invalid response")
+	}
+
+	return time.Unix(timestamps[0], timestamps[1]), nil
+}
+
+// execTransaction executes the commands queued since MULTI was sent. An error is returned if the transaction or any of
+// its commands fail.
+func execTransaction(conn redis.Conn) (err error) {
+	results, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		if err, ok := result.(redis.Error); ok {
+			return err
+		}
+	}
+	return nil
+}
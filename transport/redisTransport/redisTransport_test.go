@@ -0,0 +1,186 @@
+package redisTransport
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/rafaeljusto/redigomock"
+	"github.com/rsheasby/gocrypt/transport"
+	"github.com/stretchr/testify/assert"
+)
+
+// The tests in this package use a mocked connection, as the redis transport is also tested end to end by the
+// RemotePasswordHasher tests.
+
+type mockPool struct {
+	Conn *redigomock.Conn
+}
+
+func newMockPool() (mp *mockPool) {
+	return &mockPool{
+		Conn: redigomock.NewConn(),
+	}
+}
+
+// Get returns a redis connection instance.
+func (mp *mockPool) Get() (conn redis.Conn) {
+	return mp.Conn
+}
+
+func TestTransportShouldPingRedis(t *testing.T) {
+	pool := newMockPool()
+	pool.Conn.Command("PING").Expect("PONG").ExpectError(fmt.Errorf("redis connection error"))
+
+	tr := New(pool)
+	assert.Nil(t, tr.Ping(), "No error should be returned when the PING succeeds")
+	assert.Error(t, tr.Ping(), "An error should be returned when the PING fails")
+}
+
+func TestTransportShouldReturnServerTime(t *testing.T) {
+	pool := newMockPool()
+	pool.Conn.Command("TIME").ExpectSlice(int64(123), int64(0))
+
+	serverTime, err := New(pool).ServerTime()
+	assert.Nil(t, err, "No error should be returned when the TIME succeeds")
+	assert.Equal(t, time.Unix(123, 0), serverTime, "The server time should match the redis timestamp")
+}
+
+func TestReceiveRequestShouldRetryTimeoutsAndRespectContext(t *testing.T) {
+	pool := newMockPool()
+	pool.Conn.Command("BRPOP", RequestQueueKey, BulkRequestQueueKey, 10).
+		ExpectError(redis.ErrNil).
+		ExpectSlice([]byte(RequestQueueKey), []byte("request")).
+		ExpectError(fmt.Errorf("random error"))
+	ack := pool.Conn.GenericCommand("LREM").Expect(int64(1))
+
+	tr := New(pool)
+	delivery, err := tr.ReceiveRequest(context.Background())
+	assert.Nil(t, err, "No error should be returned once a request is received")
+	assert.Equal(t, []byte("request"), delivery.Body(), "Received request should match the queued request")
+	assert.Nil(t, delivery.Ack(), "Acknowledging a request should succeed")
+	assert.False(t, ack.Called, "Requests shouldn't be removed from a processing list unless reliable mode is enabled")
+
+	_, err = tr.ReceiveRequest(context.Background())
+	assert.Error(t, err, "Redis errors should be returned")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = tr.ReceiveRequest(ctx)
+	assert.Equal(t, context.Canceled, err, "The context's error should be returned if it's cancelled")
+}
+
+func TestPublishResponseShouldReportWhetherResponseWasReceived(t *testing.T) {
+	pool := newMockPool()
+	pool.Conn.Command("PUBLISH", ResponseKeyPrefix+"key", []byte("response")).
+		Expect(int64(0)).
+		Expect(int64(1))
+
+	tr := New(pool)
+	delivered, err := tr.PublishResponse("key", []byte("response"))
+	assert.Nil(t, err, "No error should be returned when the PUBLISH succeeds")
+	assert.False(t, delivered, "The response shouldn't be delivered if no clients received it")
+
+	delivered, err = tr.PublishResponse("key", []byte("response"))
+	assert.Nil(t, err, "No error should be returned when the PUBLISH succeeds")
+	assert.True(t, delivered, "The response should be delivered if a client received it")
+}
+
+func TestTransportShouldUseConfiguredKeysAndTimeout(t *testing.T) {
+	pool := newMockPool()
+	brpop := pool.Conn.Command("BRPOP", "custom:Queue", BulkRequestQueueKey, 3).
+		ExpectSlice([]byte("custom:Queue"), []byte("request"))
+	publish := pool.Conn.Command("PUBLISH", ResponseKeyPrefix+"key", []byte("response")).Expect(int64(1))
+
+	tr := New(pool, WithKeys(Keys{RequestQueue: "custom:Queue"}), WithPopTimeout(3500*time.Millisecond))
+	_, err := tr.ReceiveRequest(context.Background())
+	assert.Nil(t, err, "No error should be returned when receiving a request")
+	assert.True(t, brpop.Called, "The configured queue key and pop timeout should be used")
+
+	_, _ = tr.PublishResponse("key", []byte("response"))
+	assert.True(t, publish.Called, "Keys which aren't configured should be left as the default")
+}
+
+func TestQueueDepthShouldCountWaitingRequests(t *testing.T) {
+	pool := newMockPool()
+	pool.Conn.Command("LLEN", RequestQueueKey).Expect(int64(3))
+	pool.Conn.Command("LLEN", BulkRequestQueueKey).Expect(int64(4))
+	pool.Conn.Command("XLEN", RequestStreamKey).Expect(int64(5))
+	pool.Conn.Command("XLEN", BulkRequestStreamKey).Expect(int64(6))
+
+	depth, err := New(pool).QueueDepth()
+	assert.Nil(t, err, "No error should be returned when getting the queue depth")
+	assert.Equal(t, int64(7), depth, "Queue depth should be the length of the request queues")
+
+	depth, err = New(pool, WithStreams()).QueueDepth()
+	assert.Nil(t, err, "No error should be returned when getting the queue depth")
+	assert.Equal(t, int64(11), depth, "Queue depth should be the length of the request streams with streams")
+}
+
+func TestTransportShouldUseNamespacedKeys(t *testing.T) {
+	pool := newMockPool()
+	brpop := pool.Conn.Command("BRPOP", "gocrypt:staging:RequestQueue", "gocrypt:staging:BulkRequestQueue", 10).
+		ExpectSlice([]byte("gocrypt:staging:RequestQueue"), []byte("request"))
+	publish := pool.Conn.Command("PUBLISH", "gocrypt:staging:Response:key", []byte("response")).Expect(int64(1))
+	lpush := pool.Conn.Command("LPUSH", "custom:Queue", []byte("request")).Expect(int64(1))
+
+	tr := New(pool, WithNamespace("staging"))
+	assert.Equal(t, "staging", tr.Namespace(), "The namespace should be reported")
+	_, err := tr.ReceiveRequest(context.Background())
+	assert.Nil(t, err, "No error should be returned when receiving a request")
+	assert.True(t, brpop.Called, "The namespaced request queue should be used")
+	_, _ = tr.PublishResponse("key", []byte("response"))
+	assert.True(t, publish.Called, "The namespaced response prefix should be used")
+
+	tr = New(pool, WithKeys(Keys{RequestQueue: "custom:Queue"}), WithNamespace("staging"))
+	_ = tr.SubmitRequest([]byte("request"))
+	assert.True(t, lpush.Called, "Configured keys should take precedence over the namespace")
+
+	keys := NamespacedKeys("staging")
+	assert.Equal(t, "gocrypt:staging:Processing:", keys.ProcessingListPrefix, "Every key should be namespaced")
+	assert.Equal(t, ConsumerGroup, keys.ConsumerGroup, "The consumer group should be left as it is")
+	assert.Equal(t, DefaultKeys(), NamespacedKeys(""), "The empty namespace should use the default keys")
+}
+
+func TestValidateNamespaceShouldRefuseOverlappingNamespaces(t *testing.T) {
+	for _, namespace := range []string{"", "staging", "processing"} {
+		assert.Nil(t, ValidateNamespace(namespace), "Namespace %q should be valid", namespace)
+	}
+	for _, namespace := range []string{"a:b", "a*", "a b", "Processing", "Agent", "Agents", "Response", "RequestQueue",
+		"BulkRequestQueue", "RequestStream", "BulkRequestStream"} {
+		assert.NotNil(t, ValidateNamespace(namespace), "Namespace %q should be refused", namespace)
+	}
+}
+
+func TestSubmitRequestWithPriorityShouldUseTheQueueForThePriority(t *testing.T) {
+	pool := newMockPool()
+	interactive := pool.Conn.Command("LPUSH", RequestQueueKey, []byte("interactive")).Expect(int64(1))
+	bulk := pool.Conn.Command("LPUSH", BulkRequestQueueKey, []byte("bulk")).Expect(int64(1))
+	bulkStream := pool.Conn.Command("XADD", BulkRequestStreamKey, "*", RequestStreamField, []byte("bulk")).
+		Expect("1-0")
+
+	assert.Nil(t, New(pool).SubmitRequest([]byte("interactive")), "Submitting a request should succeed")
+	assert.True(t, interactive.Called, "Requests should be interactive by default")
+	assert.Nil(t, New(pool).SubmitRequestWithPriority([]byte("bulk"), transport.PriorityBulk),
+		"Submitting a bulk request should succeed")
+	assert.True(t, bulk.Called, "Bulk requests should be pushed onto the bulk queue")
+	assert.Nil(t, New(pool, WithStreams()).SubmitRequestWithPriority([]byte("bulk"), transport.PriorityBulk),
+		"Submitting a bulk request should succeed with streams")
+	assert.True(t, bulkStream.Called, "Bulk requests should be added to the bulk stream with streams")
+}
+
+func TestReceiveRequestShouldRequeueBulkRequestsOntoTheBulkQueue(t *testing.T) {
+	pool := newMockPool()
+	pool.Conn.Command("BRPOP", RequestQueueKey, BulkRequestQueueKey, 10).
+		ExpectSlice([]byte(BulkRequestQueueKey), []byte("request"))
+	pool.Conn.Command("MULTI").Expect("OK")
+	push := pool.Conn.Command("RPUSH", BulkRequestQueueKey, []byte("request")).Expect("QUEUED")
+	pool.Conn.Command("EXEC").ExpectSlice(int64(1))
+
+	delivery, err := New(pool).ReceiveRequest(context.Background())
+	assert.Nil(t, err, "No error should be returned when receiving a bulk request")
+	assert.Nil(t, delivery.Requeue(), "Requeueing should succeed")
+	assert.True(t, push.Called, "Bulk requests should be requeued onto the bulk queue")
+}
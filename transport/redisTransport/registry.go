@@ -0,0 +1,136 @@
+package redisTransport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/rsheasby/gocrypt/transport"
+)
+
+var _ transport.AgentRegistry = (*Transport)(nil)
+
+// AgentKey returns the key of the heartbeat key used by the agent with the specified ID.
+func (t *Transport) AgentKey(agentID string) string {
+	return t.keys.AgentPrefix + agentID
+}
+
+// Heartbeat registers the agent, and sets its heartbeat key to the agent's info, which tells clients that it's running,
+// and in reliable mode, tells the other agents that its processing list is still in use. The info is provided using
+// WithAgentInfo, and the ID, hostname and heartbeat time are filled in.
+func (t *Transport) Heartbeat() (err error) {
+	var info transport.AgentInfo
+	if t.agentInfo != nil {
+		info = t.agentInfo()
+	}
+	info.ID = t.agentID
+	if info.Hostname == "" {
+		info.Hostname, _ = os.Hostname()
+	}
+	info.HeartbeatAt = time.Now()
+	infoBytes, err := json.Marshal(info)
+	// This should never happen, as the info only contains plain values
+	if err != nil {
+		return fmt.Errorf("failed to marshal agent info: %v", err)
+	}
+
+	conn := t.pool.Get()
+	defer conn.Close()
+
+	_ = conn.Send("MULTI")
+	_ = conn.Send("SET", t.AgentKey(t.agentID), infoBytes, "PX", AgentTTL.Milliseconds())
+	_ = conn.Send("SADD", t.keys.AgentRegistry, t.agentID)
+	return execTransaction(conn)
+}
+
+// StartHeartbeat refreshes the agent's heartbeat key every HeartbeatInterval until the context is cancelled.
+func (t *Transport) StartHeartbeat(ctx context.Context, logger *log.Logger) {
+	go func() {
+		ticker := time.NewTicker(HeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := t.Heartbeat()
+				if err != nil {
+					logger.Printf("Failed to refresh agent heartbeat: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Deregister deletes the agent's heartbeat key and removes it from the registry, so that clients stop listing it
+// straight away once it's stopped, instead of once its heartbeat expires.
+func (t *Transport) Deregister() (err error) {
+	conn := t.pool.Get()
+	defer conn.Close()
+
+	_ = conn.Send("MULTI")
+	_ = conn.Send("DEL", t.AgentKey(t.agentID))
+	_ = conn.Send("SREM", t.keys.AgentRegistry, t.agentID)
+	return execTransaction(conn)
+}
+
+// ListAgents returns the info of every registered agent whose heartbeat key hasn't expired, ordered by ID. Agents whose
+// heartbeat key has expired are removed from the registry, so that it doesn't keep growing as agents are replaced.
+func (t *Transport) ListAgents() (agents []transport.AgentInfo, err error) {
+	conn := t.pool.Get()
+	defer conn.Close()
+
+	agentIDs, err := redis.Strings(conn.Do("SMEMBERS", t.keys.AgentRegistry))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list agents: %v", err)
+	}
+	if len(agentIDs) == 0 {
+		return nil, nil
+	}
+	sort.Strings(agentIDs)
+
+	keys := make([]interface{}, len(agentIDs))
+	for i, agentID := range agentIDs {
+		keys[i] = t.AgentKey(agentID)
+	}
+	infos, err := redis.ByteSlices(conn.Do("MGET", keys...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get agent info: %v", err)
+	}
+
+	expired := []interface{}{t.keys.AgentRegistry}
+	for i, infoBytes := range infos {
+		if infoBytes == nil {
+			expired = append(expired, agentIDs[i])
+			continue
+		}
+		agents = append(agents, parseAgentInfo(agentIDs[i], infoBytes))
+	}
+	// An agent which is still running adds itself back with its next heartbeat, even if it's removed here just after
+	// its heartbeat key expired.
+	if len(expired) > 1 {
+		_, _ = conn.Do("SREM", expired...)
+	}
+	return agents, nil
+}
+
+// AgentsAvailable returns whether any registered agent's heartbeat key hasn't expired.
+func (t *Transport) AgentsAvailable() (available bool, err error) {
+	agents, err := t.ListAgents()
+	if err != nil {
+		return false, err
+	}
+	return len(agents) > 0, nil
+}
+
+// parseAgentInfo parses the info in the agent's heartbeat key. Only the ID is known if the info can't be parsed.
+func parseAgentInfo(agentID string, infoBytes []byte) (info transport.AgentInfo) {
+	_ = json.Unmarshal(infoBytes, &info)
+	info.ID = agentID
+	return info
+}
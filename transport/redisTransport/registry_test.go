@@ -0,0 +1,65 @@
+package redisTransport
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rsheasby/gocrypt/transport"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeartbeatShouldRegisterTheAgentInfo(t *testing.T) {
+	pool := newMockPool()
+	pool.Conn.Command("MULTI").Expect("OK")
+	var info transport.AgentInfo
+	set := pool.Conn.GenericCommand("SET").Handle(func(args []interface{}) (interface{}, error) {
+		assert.Equal(t, AgentKeyPrefix+"agent", args[0], "The agent's heartbeat key should be set")
+		assert.Equal(t, []interface{}{"PX", AgentTTL.Milliseconds()}, args[2:], "The heartbeat key should expire")
+		return "QUEUED", json.Unmarshal(args[1].([]byte), &info)
+	})
+	add := pool.Conn.Command("SADD", AgentRegistryKey, "agent").Expect("QUEUED")
+	pool.Conn.Command("EXEC").ExpectSlice("OK", int64(1))
+
+	tr := New(pool, WithAgentID("agent"), WithAgentInfo(func() transport.AgentInfo {
+		return transport.AgentInfo{ID: "ignored", Hostname: "host", Threads: 4, BusyThreads: 1}
+	}))
+	assert.Nil(t, tr.Heartbeat(), "No error should be returned when the transaction succeeds")
+	assert.True(t, set.Called, "The heartbeat key should be set")
+	assert.True(t, add.Called, "The agent should be added to the registry")
+	assert.Equal(t, "agent", info.ID, "The ID should be the transport's agent ID")
+	assert.Equal(t, "host", info.Hostname, "The provided hostname should be kept")
+	assert.Equal(t, 4, info.Threads, "The provided info should be stored")
+	assert.False(t, info.HeartbeatAt.IsZero(), "The heartbeat time should be set")
+}
+
+func TestListAgentsShouldSkipAndDeregisterExpiredAgents(t *testing.T) {
+	info, _ := json.Marshal(transport.AgentInfo{Hostname: "host", Threads: 4, BusyThreads: 2,
+		HeartbeatAt: time.Unix(100, 0)})
+
+	pool := newMockPool()
+	pool.Conn.Command("SMEMBERS", AgentRegistryKey).ExpectSlice([]byte("b"), []byte("a"), []byte("c"))
+	pool.Conn.Command("MGET", AgentKeyPrefix+"a", AgentKeyPrefix+"b", AgentKeyPrefix+"c").
+		ExpectSlice(info, nil, []byte("not json"))
+	remove := pool.Conn.Command("SREM", AgentRegistryKey, "b").Expect(int64(1))
+
+	agents, err := New(pool).ListAgents()
+	assert.Nil(t, err, "No error should be returned when listing agents")
+	assert.True(t, remove.Called, "The expired agent should be removed from the registry")
+	if assert.Len(t, agents, 2, "Only the agents with a heartbeat key should be listed") {
+		assert.Equal(t, "a", agents[0].ID, "The agents should be ordered by ID")
+		assert.Equal(t, "host", agents[0].Hostname, "The agent info should be parsed")
+		assert.Equal(t, 0.5, agents[0].Load(), "The load should be the share of busy threads")
+		assert.Equal(t, transport.AgentInfo{ID: "c"}, agents[1],
+			"Only the ID should be known when the agent info can't be parsed")
+	}
+}
+
+func TestAgentsAvailableShouldBeFalseWithoutAgents(t *testing.T) {
+	pool := newMockPool()
+	pool.Conn.Command("SMEMBERS", AgentRegistryKey).ExpectSlice()
+
+	available, err := New(pool).AgentsAvailable()
+	assert.Nil(t, err, "No error should be returned when no agents are registered")
+	assert.False(t, available, "No agents should be available")
+}
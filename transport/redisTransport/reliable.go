@@ -0,0 +1,94 @@
+package redisTransport
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ProcessingListKey returns the key of the processing list used by the agent with the specified ID in reliable mode.
+func (t *Transport) ProcessingListKey(agentID string) string {
+	return t.keys.ProcessingListPrefix + agentID
+}
+
+// StartReaper requeues the processing lists left behind by dead agents every ReaperInterval until the context is
+// cancelled.
+func (t *Transport) StartReaper(ctx context.Context, logger *log.Logger) {
+	go func() {
+		ticker := time.NewTicker(ReaperInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				t.ReapDeadAgents(logger)
+			}
+		}
+	}()
+}
+
+// ReapDeadAgents finds the processing lists belonging to agents whose heartbeat key has expired, and moves their
+// requests back onto the request queue so that they can be handled by another agent. Requests which have expired in the
+// meantime are dropped by the agent as usual.
+func (t *Transport) ReapDeadAgents(logger *log.Logger) {
+	conn := t.pool.Get()
+	defer conn.Close()
+
+	cursor := 0
+	for {
+		result, err := redis.Values(conn.Do("SCAN", cursor, "MATCH", t.keys.ProcessingListPrefix+"*"))
+		if err != nil {
+			logger.Printf("Failed to scan for processing lists: %v", err)
+			return
+		}
+		var keys []string
+		_, err = redis.Scan(result, &cursor, &keys)
+		if err != nil {
+			logger.Printf("Failed to scan for processing lists: %v", err)
+			return
+		}
+
+		for _, key := range keys {
+			agentID := strings.TrimPrefix(key, t.keys.ProcessingListPrefix)
+			isAlive, err := redis.Bool(conn.Do("EXISTS", t.AgentKey(agentID)))
+			if err != nil {
+				logger.Printf("Failed to check heartbeat of agent %q: %v", agentID, err)
+				continue
+			}
+			if isAlive {
+				continue
+			}
+			t.requeueProcessingList(conn, key, agentID, logger)
+		}
+
+		if cursor == 0 {
+			return
+		}
+	}
+}
+
+// requeueProcessingList moves every request in the processing list back onto the request queue. The requests are moved
+// one at a time so that the operation is safe even if several agents reap the same list at once. They're pushed onto
+// the end of the interactive queue which is popped next, since they've already waited once. The processing list doesn't
+// record the priority of each request, so bulk requests are handled as interactive ones this once.
+func (t *Transport) requeueProcessingList(conn redis.Conn, key string, agentID string, logger *log.Logger) {
+	requeued := 0
+	for {
+		_, err := redis.Bytes(conn.Do("LMOVE", key, t.keys.RequestQueue, "LEFT", "RIGHT"))
+		if err == redis.ErrNil {
+			break
+		}
+		if err != nil {
+			logger.Printf("Failed to requeue requests from dead agent %q: %v", agentID, err)
+			return
+		}
+		requeued++
+	}
+	if requeued > 0 {
+		logger.Printf("Requeued %d request(s) left behind by dead agent %q.", requeued, agentID)
+	}
+}
@@ -0,0 +1,117 @@
+package redisTransport
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReapDeadAgentsShouldOnlyRequeueDeadAgents(t *testing.T) {
+	pool := newMockPool()
+	tr := New(pool, WithReliableQueue())
+	pool.Conn.Command("SCAN", 0, "MATCH", ProcessingListPrefix+"*").ExpectSlice(
+		[]byte("0"),
+		[]interface{}{[]byte(tr.ProcessingListKey("alive")), []byte(tr.ProcessingListKey("dead"))},
+	)
+	pool.Conn.Command("EXISTS", tr.AgentKey("alive")).Expect(int64(1))
+	pool.Conn.Command("EXISTS", tr.AgentKey("dead")).Expect(int64(0))
+
+	remaining := 2
+	deadMove := pool.Conn.Command("LMOVE", tr.ProcessingListKey("dead"), RequestQueueKey, "LEFT", "RIGHT").Handle(
+		func(args []interface{}) (interface{}, error) {
+			if remaining == 0 {
+				return nil, nil
+			}
+			remaining--
+			return []byte("request"), nil
+		})
+	aliveMove := pool.Conn.Command("LMOVE", tr.ProcessingListKey("alive"), RequestQueueKey, "LEFT", "RIGHT").
+		Expect(nil)
+
+	logBuffer := &bytes.Buffer{}
+	logger := log.New(logBuffer, "", 0)
+
+	tr.ReapDeadAgents(logger)
+
+	assert.Equal(t, 0, remaining, "Every request from the dead agent should be requeued")
+	assert.Equal(t, 3, pool.Conn.Stats(deadMove), "Requests should be moved until the processing list is empty")
+	assert.False(t, aliveMove.Called, "Requests from live agents shouldn't be requeued")
+	assert.Contains(t, logBuffer.String(), "Requeued 2 request(s)", "Should log how many requests were requeued")
+}
+
+func TestReceiveRequestShouldUseProcessingListInReliableMode(t *testing.T) {
+	pool := newMockPool()
+	tr := New(pool, WithReliableQueue(), WithAgentID("agent"))
+	pool.Conn.Command("LMOVE", RequestQueueKey, tr.ProcessingListKey("agent"), "RIGHT", "LEFT").Expect(nil)
+	pool.Conn.Command("LMOVE", BulkRequestQueueKey, tr.ProcessingListKey("agent"), "RIGHT", "LEFT").Expect(nil)
+	pool.Conn.Command("BLMOVE", RequestQueueKey, tr.ProcessingListKey("agent"), "RIGHT", "LEFT",
+		BulkPollInterval.Seconds()).
+		Expect([]byte("request"))
+	ack := pool.Conn.Command("LREM", tr.ProcessingListKey("agent"), 1, []byte("request")).
+		Expect(int64(1)).
+		ExpectError(redis.ErrPoolExhausted)
+
+	delivery, err := tr.ReceiveRequest(context.Background())
+	assert.Nil(t, err, "No error should be returned when receiving a request")
+	assert.Equal(t, []byte("request"), delivery.Body(), "Received request should match the queued request")
+	assert.False(t, ack.Called, "Requests shouldn't be removed until they're acknowledged")
+
+	assert.Nil(t, delivery.Ack(), "No error should be returned when the request is removed")
+	assert.True(t, ack.Called, "Requests should be removed from the processing list once they're acknowledged")
+	assert.Error(t, delivery.Ack(), "An error should be returned when the request can't be removed")
+}
+
+func TestRequeueShouldMoveRequestsBackOntoTheQueue(t *testing.T) {
+	pool := newMockPool()
+	pool.Conn.Command("MULTI").Expect("OK")
+	push := pool.Conn.Command("RPUSH", RequestQueueKey, []byte("request")).Expect("QUEUED")
+	pool.Conn.Command("EXEC").ExpectSlice(int64(1))
+
+	tr := New(pool)
+	assert.Nil(t, (&delivery{transport: tr, body: []byte("request")}).Requeue(), "Requeueing should succeed")
+	assert.True(t, push.Called, "The request should be pushed back onto the queue")
+
+	pool = newMockPool()
+	pool.Conn.Command("MULTI").Expect("OK")
+	pool.Conn.Command("RPUSH", RequestQueueKey, []byte("request")).Expect("QUEUED")
+	remove := pool.Conn.Command("LREM", ProcessingListPrefix+"agent", 1, []byte("request")).Expect("QUEUED")
+	pool.Conn.Command("EXEC").ExpectSlice(int64(1), redis.Error("random error"))
+
+	tr = New(pool, WithReliableQueue(), WithAgentID("agent"))
+	err := (&delivery{transport: tr, body: []byte("request")}).Requeue()
+	assert.True(t, remove.Called, "The request should be removed from the processing list in reliable mode")
+	assert.Error(t, err, "An error should be returned if any command in the transaction fails")
+}
+
+func TestReceiveRequestShouldMoveInteractiveRequestsFirstInReliableMode(t *testing.T) {
+	pool := newMockPool()
+	tr := New(pool, WithReliableQueue(), WithAgentID("agent"))
+	interactive := pool.Conn.Command("LMOVE", RequestQueueKey, tr.ProcessingListKey("agent"), "RIGHT", "LEFT").
+		Expect([]byte("interactive")).
+		Expect(nil)
+	bulk := pool.Conn.Command("LMOVE", BulkRequestQueueKey, tr.ProcessingListKey("agent"), "RIGHT", "LEFT").
+		Expect([]byte("bulk"))
+	blmove := pool.Conn.GenericCommand("BLMOVE").Expect(nil)
+
+	delivery, err := tr.ReceiveRequest(context.Background())
+	assert.Nil(t, err, "No error should be returned when receiving a request")
+	assert.Equal(t, []byte("interactive"), delivery.Body(), "Interactive requests should be received first")
+	assert.False(t, bulk.Called, "The bulk queue shouldn't be checked while interactive requests are waiting")
+
+	delivery, err = tr.ReceiveRequest(context.Background())
+	assert.Nil(t, err, "No error should be returned when receiving a request")
+	assert.Equal(t, []byte("bulk"), delivery.Body(), "Bulk requests should be received once the interactive queue is empty")
+	assert.Equal(t, 2, pool.Conn.Stats(interactive), "The interactive queue should be checked before every receive")
+	assert.False(t, blmove.Called, "Agents shouldn't block while there are requests waiting")
+
+	pool.Conn.Command("MULTI").Expect("OK")
+	push := pool.Conn.Command("RPUSH", BulkRequestQueueKey, []byte("bulk")).Expect("QUEUED")
+	pool.Conn.Command("LREM", tr.ProcessingListKey("agent"), 1, []byte("bulk")).Expect("QUEUED")
+	pool.Conn.Command("EXEC").ExpectSlice(int64(1), int64(1))
+	assert.Nil(t, delivery.Requeue(), "Requeueing should succeed")
+	assert.True(t, push.Called, "Bulk requests should be requeued onto the bulk queue")
+}
@@ -0,0 +1,162 @@
+package redisTransport
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/rsheasby/gocrypt/transport"
+)
+
+// delivery is a request received from redis. In reliable mode, the request stays in the agent's processing list until
+// it's acknowledged. With streams enabled, it stays pending in the consumer group instead.
+type delivery struct {
+	transport *Transport
+	body      []byte
+	streamID  string
+	priority  transport.Priority
+}
+
+// Body returns the request as it was submitted.
+func (d *delivery) Body() (req []byte) {
+	return d.body
+}
+
+// Ack removes the request from the agent's processing list, or from the request stream, once it's been handled. This
+// does nothing if neither reliable mode nor streams are enabled.
+func (d *delivery) Ack() (err error) {
+	if d.streamID != "" {
+		conn := d.transport.pool.Get()
+		defer conn.Close()
+
+		err = d.transport.ackStream(conn, d.transport.keys.requestStream(d.priority), d.streamID)
+		if err != nil {
+			return fmt.Errorf("failed to acknowledge request %s in the request stream: %v", d.streamID, err)
+		}
+		return nil
+	}
+	if !d.transport.reliableQueue {
+		return nil
+	}
+
+	conn := d.transport.pool.Get()
+	defer conn.Close()
+
+	_, err = conn.Do("LREM", d.transport.ProcessingListKey(d.transport.agentID), 1, d.body)
+	if err != nil {
+		return fmt.Errorf("failed to remove request from the processing list: %v", err)
+	}
+	return nil
+}
+
+// Requeue pushes the request back onto the end of the queue it was popped from, so that it's received next. In reliable
+// mode, it's removed from the agent's processing list in the same transaction. With streams enabled, it's added to the
+// stream again as a new entry, and the original entry is acknowledged instead.
+func (d *delivery) Requeue() (err error) {
+	t := d.transport
+	conn := t.pool.Get()
+	defer conn.Close()
+
+	_ = conn.Send("MULTI")
+	if d.streamID != "" {
+		stream := t.keys.requestStream(d.priority)
+		_ = conn.Send("XADD", stream, "*", RequestStreamField, d.body)
+		_ = conn.Send("XACK", stream, t.keys.ConsumerGroup, d.streamID)
+		_ = conn.Send("XDEL", stream, d.streamID)
+	} else {
+		_ = conn.Send("RPUSH", t.keys.requestQueue(d.priority), d.body)
+		if t.reliableQueue {
+			_ = conn.Send("LREM", t.ProcessingListKey(t.agentID), 1, d.body)
+		}
+	}
+	err = execTransaction(conn)
+	if err != nil {
+		return fmt.Errorf("failed to requeue request: %v", err)
+	}
+	return nil
+}
+
+// ReceiveRequest retrieves a request from redis. Interactive requests are always received before bulk requests. If no
+// requests are currently in the queues, it blocks until one is available.
+func (t *Transport) ReceiveRequest(ctx context.Context) (req transport.Delivery, err error) {
+	// Continuously pop a request off one of the queues, retrying if IO timeout
+	conn := t.pool.Get()
+	defer conn.Close()
+
+	for {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		d, err := t.popRequest(conn)
+		if err == redis.ErrNil {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error receiving message from redis: %v", err)
+		}
+		d.transport = t
+		return d, nil
+	}
+}
+
+// popRequest pops the raw request off the queue with the highest priority. In reliable mode, the request is atomically
+// moved into the agent's processing list instead. With streams enabled, the request is read from the stream, and the
+// streamID is set. redis.ErrNil is returned if no request was received before the timeout.
+func (t *Transport) popRequest(conn redis.Conn) (d *delivery, err error) {
+	if t.streams {
+		return t.readStreams(conn)
+	}
+
+	if t.reliableQueue {
+		return t.moveRequest(conn)
+	}
+
+	// BRPOP checks the queues in the order they're listed, so the priorities are respected even while blocking.
+	args := make([]interface{}, 0, len(transport.Priorities)+1)
+	for _, priority := range transport.Priorities {
+		args = append(args, t.keys.requestQueue(priority))
+	}
+	result, err := redis.ByteSlices(conn.Do("BRPOP", append(args, t.popTimeoutSeconds())...))
+	if err != nil {
+		return nil, err
+	}
+	// This should basically never happen. If there's no error, the response should always be 2 strings. Including this check just in case though.
+	if len(result) != 2 {
+		return nil, fmt.Errorf("invalid response from Redis - expected two strings but received %d", len(result))
+	}
+	d = &delivery{body: result[1]}
+	if string(result[0]) == t.keys.BulkRequestQueue {
+		d.priority = transport.PriorityBulk
+	}
+	return d, nil
+}
+
+// moveRequest moves the request off the queue with the highest priority into the agent's processing list. If every
+// queue is empty, it blocks waiting for an interactive request for up to the BulkPollInterval, as BLMOVE can only wait
+// on a single queue.
+func (t *Transport) moveRequest(conn redis.Conn) (d *delivery, err error) {
+	processingList := t.ProcessingListKey(t.agentID)
+	for _, priority := range transport.Priorities {
+		reqBytes, err := redis.Bytes(conn.Do("LMOVE", t.keys.requestQueue(priority), processingList, "RIGHT", "LEFT"))
+		if err == redis.ErrNil {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &delivery{body: reqBytes, priority: priority}, nil
+	}
+
+	reqBytes, err := redis.Bytes(conn.Do("BLMOVE", t.keys.RequestQueue, processingList, "RIGHT", "LEFT",
+		BulkPollInterval.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	return &delivery{body: reqBytes}, nil
+}
+
+// popTimeoutSeconds returns the pop timeout in the whole seconds expected by BRPOP.
+func (t *Transport) popTimeoutSeconds() int {
+	return int(t.popTimeout / time.Second)
+}
@@ -0,0 +1,251 @@
+package redisTransport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/rsheasby/gocrypt/transport"
+)
+
+// streamAwaiter waits for the response to be pushed onto the response key.
+type streamAwaiter struct {
+	transport   *Transport
+	responseKey string
+}
+
+// Wait pops the response off the response key. The pop happens in the background so that we can stop waiting if the
+// context is cancelled.
+func (a *streamAwaiter) Wait(ctx context.Context, timeout time.Duration) (res []byte, err error) {
+	type result struct {
+		resBytes []byte
+		err      error
+	}
+	received := make(chan result, 1)
+	go func() {
+		resBytes, err := a.transport.popResponse(a.responseKey, time.Now().Add(timeout))
+		received <- result{resBytes, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		// Pushing an empty value unblocks the pop, so the connection is released as soon as possible. The key is then
+		// deleted, in case the response was popped instead of the empty value.
+		wakeConn := a.transport.pool.Get()
+		defer wakeConn.Close()
+		_, _ = wakeConn.Do("LPUSH", a.transport.keys.ResponsePrefix+a.responseKey, "")
+		<-received
+		_, _ = wakeConn.Do("DEL", a.transport.keys.ResponsePrefix+a.responseKey)
+		return nil, ctx.Err()
+	case res := <-received:
+		if res.err == redis.ErrNil {
+			return nil, transport.ErrTimeout
+		}
+		return res.resBytes, res.err
+	}
+}
+
+// Close does nothing, as nothing is held between calls to Wait.
+func (a *streamAwaiter) Close() (err error) {
+	return nil
+}
+
+// popResponse waits until the deadline for the response to be pushed onto the response key. If the connection is lost,
+// it reconnects and keeps waiting, as the response is kept until it's popped. redis.ErrNil is returned if the deadline
+// is reached.
+func (t *Transport) popResponse(responseKey string, deadline time.Time) (resBytes []byte, err error) {
+	_, resBytes, err = t.popResponses([]string{responseKey}, deadline)
+	return resBytes, err
+}
+
+// popResponses is the same as popResponse, but waits for the first response to be pushed onto any of the response
+// keys, and also returns which response key it was pushed onto.
+func (t *Transport) popResponses(responseKeys []string, deadline time.Time) (responseKey string, resBytes []byte,
+	err error) {
+	args := make([]interface{}, 0, len(responseKeys)+1)
+	for _, responseKey := range responseKeys {
+		args = append(args, t.keys.ResponsePrefix+responseKey)
+	}
+	for {
+		remaining := time.Until(deadline)
+		// A BLPOP timeout of 0 would block forever
+		if remaining < time.Millisecond {
+			return "", nil, redis.ErrNil
+		}
+
+		conn := t.pool.Get()
+		result, err := redis.ByteSlices(conn.Do("BLPOP", append(args, remaining.Seconds())...))
+		conn.Close()
+		if err == nil {
+			// This should never happen, but we'll check it for safety anyway
+			if len(result) != 2 {
+				return "", nil, fmt.Errorf("failed to receive res from agent: expected two values but received %d",
+					len(result))
+			}
+			return strings.TrimPrefix(string(result[0]), t.keys.ResponsePrefix), result[1], nil
+		}
+		if err == redis.ErrNil {
+			return "", nil, err
+		}
+
+		var netErr net.Error
+		if !errors.As(err, &netErr) && !errors.Is(err, io.EOF) {
+			return "", nil, fmt.Errorf("failed to receive res from agent: %v", err)
+		}
+		time.Sleep(ReconnectRetryTime)
+	}
+}
+
+// pushResponse pushes the response onto the response key for the client to pop. Unlike pub/sub, the response is kept
+// for the ResponseTTL even if the client isn't waiting for it yet. The push and expiry happen in a transaction, so that
+// the response is never pushed twice if it's retried.
+func (t *Transport) pushResponse(conn redis.Conn, responseKey string, res []byte) (err error) {
+	key := t.keys.ResponsePrefix + responseKey
+	_ = conn.Send("MULTI")
+	_ = conn.Send("LPUSH", key, res)
+	_ = conn.Send("PEXPIRE", key, ResponseTTL.Milliseconds())
+	err = execTransaction(conn)
+	if err != nil {
+		return fmt.Errorf("redis error when pushing response: %v", err)
+	}
+	return nil
+}
+
+// readStreams reads the next request from the request stream with the highest priority. Requests which have been
+// pending for longer than the ClaimIdleTime are claimed first, as the agent which read them has most likely died.
+// Otherwise, if there are no new requests in any of the streams, this blocks for up to the BulkPollInterval waiting for
+// a new interactive request, and returns redis.ErrNil if there isn't one. Only the interactive stream is blocked on, as
+// XREADGROUP would read a request from each of the streams at once.
+func (t *Transport) readStreams(conn redis.Conn) (d *delivery, err error) {
+	for _, priority := range transport.Priorities {
+		d, err = t.claimStream(conn, priority)
+		if err != nil || d != nil {
+			return d, t.handleNoGroup(conn, priority, err)
+		}
+	}
+	for _, priority := range transport.Priorities {
+		d, err = t.readStream(conn, priority)
+		if err != redis.ErrNil {
+			return d, t.handleNoGroup(conn, priority, err)
+		}
+	}
+	return t.readStream(conn, transport.PriorityInteractive, "BLOCK", BulkPollInterval.Milliseconds())
+}
+
+// handleNoGroup creates the consumer group for the stream of the priority if err shows that it doesn't exist yet.
+// Otherwise, err is returned as it is.
+func (t *Transport) handleNoGroup(conn redis.Conn, priority transport.Priority, err error) error {
+	if isNoGroupError(err) {
+		return t.createConsumerGroup(conn, t.keys.requestStream(priority))
+	}
+	return err
+}
+
+// readStream reads a new request from the stream of the priority, passing any extra arguments such as BLOCK on to
+// XREADGROUP. redis.ErrNil is returned if there isn't one.
+func (t *Transport) readStream(conn redis.Conn, priority transport.Priority, extraArgs ...interface{}) (d *delivery,
+	err error) {
+	args := append([]interface{}{"GROUP", t.keys.ConsumerGroup, t.agentID, "COUNT", 1}, extraArgs...)
+	args = append(args, "STREAMS", t.keys.requestStream(priority), ">")
+	result, err := redis.Values(conn.Do("XREADGROUP", args...))
+	if err != nil {
+		return nil, err
+	}
+	// The result is a list of streams, each with a list of entries. We only read one entry from one stream.
+	if len(result) != 1 {
+		return nil, fmt.Errorf("invalid response from Redis - expected one stream but received %d", len(result))
+	}
+	stream, err := redis.Values(result[0], nil)
+	if err != nil || len(stream) != 2 {
+		return nil, fmt.Errorf("invalid stream in response from Redis")
+	}
+	entries, err := redis.Values(stream[1], nil)
+	if err != nil || len(entries) != 1 {
+		return nil, fmt.Errorf("invalid stream entries in response from Redis")
+	}
+	return parseStreamEntry(entries[0], priority)
+}
+
+// claimStream claims a single request from the stream of the priority which has been pending for longer than the
+// ClaimIdleTime. d is nil if there aren't any.
+func (t *Transport) claimStream(conn redis.Conn, priority transport.Priority) (d *delivery, err error) {
+	result, err := redis.Values(conn.Do("XAUTOCLAIM", t.keys.requestStream(priority), t.keys.ConsumerGroup, t.agentID,
+		ClaimIdleTime.Milliseconds(), "0-0", "COUNT", 1))
+	if err != nil {
+		return nil, err
+	}
+	if len(result) < 2 {
+		return nil, fmt.Errorf("invalid response from Redis - expected at least two values but received %d",
+			len(result))
+	}
+	entries, err := redis.Values(result[1], nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid claimed entries in response from Redis")
+	}
+	for _, entry := range entries {
+		// Entries which were deleted while pending are returned as nil by some Redis versions.
+		if entry != nil {
+			return parseStreamEntry(entry, priority)
+		}
+	}
+	return nil, nil
+}
+
+// parseStreamEntry returns the delivery for a stream entry from the stream of the priority. The entry is a list of the
+// ID and the field value pairs.
+func parseStreamEntry(entry interface{}, priority transport.Priority) (d *delivery, err error) {
+	values, err := redis.Values(entry, nil)
+	if err != nil || len(values) != 2 {
+		return nil, fmt.Errorf("invalid stream entry in response from Redis")
+	}
+	d = &delivery{body: []byte{}, priority: priority}
+	d.streamID, err = redis.String(values[0], nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stream entry ID in response from Redis: %v", err)
+	}
+	fields, err := redis.ByteSlices(values[1], nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stream entry fields in response from Redis: %v", err)
+	}
+	for i := 0; i+1 < len(fields); i += 2 {
+		if string(fields[i]) == RequestStreamField {
+			d.body = fields[i+1]
+			break
+		}
+	}
+	// The delivery is still returned without a body if the field is missing, so that the entry can be acknowledged,
+	// otherwise it would be claimed forever.
+	return d, nil
+}
+
+// createConsumerGroup creates the consumer group on the stream, along with the stream if it doesn't exist yet. The
+// group starts at the beginning of the stream, so that requests submitted before any agent started are handled.
+// redis.ErrNil is returned if the group was created, so that the caller tries to read again like it would after a
+// timeout.
+func (t *Transport) createConsumerGroup(conn redis.Conn, stream string) (err error) {
+	_, err = conn.Do("XGROUP", "CREATE", stream, t.keys.ConsumerGroup, "0", "MKSTREAM")
+	// Another agent may have just created the group
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("couldn't create consumer group: %v", err)
+	}
+	return redis.ErrNil
+}
+
+func isNoGroupError(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "NOGROUP")
+}
+
+// ackStream acknowledges and deletes the request from the stream, so the stream doesn't grow indefinitely.
+func (t *Transport) ackStream(conn redis.Conn, stream string, streamID string) (err error) {
+	_, err = conn.Do("XACK", stream, t.keys.ConsumerGroup, streamID)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Do("XDEL", stream, streamID)
+	return err
+}
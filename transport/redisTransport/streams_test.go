@@ -0,0 +1,106 @@
+package redisTransport
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func streamEntry(id string, reqBytes []byte) []interface{} {
+	return []interface{}{[]byte(id), []interface{}{[]byte(RequestStreamField), reqBytes}}
+}
+
+func TestReceiveRequestShouldReadAndClaimFromStream(t *testing.T) {
+	pool := newMockPool()
+	claim := pool.Conn.Command("XAUTOCLAIM", RequestStreamKey, ConsumerGroup, "agent", ClaimIdleTime.Milliseconds(),
+		"0-0", "COUNT", 1).
+		ExpectError(fmt.Errorf("NOGROUP No such key 'gocrypt:RequestStream' or consumer group 'gocrypt'")).
+		ExpectSlice([]byte("0-0"), []interface{}{streamEntry("1-0", []byte("claimed"))}).
+		ExpectSlice([]byte("0-0"), []interface{}{})
+	createGroup := pool.Conn.Command("XGROUP", "CREATE", RequestStreamKey, ConsumerGroup, "0", "MKSTREAM").
+		Expect("OK")
+	pool.Conn.Command("XAUTOCLAIM", BulkRequestStreamKey, ConsumerGroup, "agent", ClaimIdleTime.Milliseconds(),
+		"0-0", "COUNT", 1).
+		ExpectSlice([]byte("0-0"), []interface{}{})
+	pool.Conn.Command("XREADGROUP", "GROUP", ConsumerGroup, "agent", "COUNT", 1, "STREAMS", RequestStreamKey, ">").
+		Expect(nil)
+	pool.Conn.Command("XREADGROUP", "GROUP", ConsumerGroup, "agent", "COUNT", 1, "STREAMS", BulkRequestStreamKey, ">").
+		Expect(nil)
+	pool.Conn.Command("XREADGROUP", "GROUP", ConsumerGroup, "agent", "COUNT", 1, "BLOCK", BulkPollInterval.Milliseconds(),
+		"STREAMS", RequestStreamKey, ">").
+		ExpectSlice([]interface{}{[]byte(RequestStreamKey), []interface{}{streamEntry("2-0", []byte("new"))}})
+	ack := pool.Conn.Command("XACK", RequestStreamKey, ConsumerGroup, "1-0").Expect(int64(1))
+	del := pool.Conn.Command("XDEL", RequestStreamKey, "1-0").Expect(int64(1))
+
+	tr := New(pool, WithStreams(), WithAgentID("agent"))
+	delivery, err := tr.ReceiveRequest(context.Background())
+	assert.Nil(t, err, "No error should be returned when receiving a claimed request")
+	assert.True(t, createGroup.Called, "The consumer group should be created if it doesn't exist")
+	assert.Equal(t, []byte("claimed"), delivery.Body(), "Pending requests should be claimed first")
+
+	assert.Nil(t, delivery.Ack(), "No error should be returned when acknowledging a request")
+	assert.True(t, ack.Called, "Requests should be acknowledged in the consumer group")
+	assert.True(t, del.Called, "Requests should be deleted from the stream once they're acknowledged")
+
+	delivery, err = tr.ReceiveRequest(context.Background())
+	assert.Nil(t, err, "No error should be returned when receiving a new request")
+	assert.Equal(t, []byte("new"), delivery.Body(), "New requests should be read once nothing is claimed")
+	assert.Equal(t, 3, pool.Conn.Stats(claim), "Pending requests should be checked before every read")
+}
+
+func TestPublishResponseShouldPushResponsesWithStreams(t *testing.T) {
+	pool := newMockPool()
+	pool.Conn.Command("MULTI").Expect("OK")
+	push := pool.Conn.Command("LPUSH", ResponseKeyPrefix+"key", []byte("response")).Expect("QUEUED")
+	expire := pool.Conn.Command("PEXPIRE", ResponseKeyPrefix+"key", ResponseTTL.Milliseconds()).Expect("QUEUED")
+	pool.Conn.Command("EXEC").
+		ExpectSlice(int64(1), int64(1)).
+		ExpectError(fmt.Errorf("random error"))
+	publish := pool.Conn.GenericCommand("PUBLISH").Expect(int64(1))
+
+	tr := New(pool, WithStreams())
+	delivered, err := tr.PublishResponse("key", []byte("response"))
+	assert.Nil(t, err, "No error should be returned when the response is pushed")
+	assert.True(t, delivered, "Pushed responses should always be delivered")
+	assert.True(t, push.Called, "The response should be pushed onto the response key")
+	assert.True(t, expire.Called, "The response should expire if it isn't received")
+	assert.False(t, publish.Called, "Responses shouldn't be published using pub/sub with streams")
+
+	delivered, err = tr.PublishResponse("key", []byte("response"))
+	assert.Error(t, err, "An error should be returned when the transaction fails")
+	assert.False(t, delivered, "The response shouldn't be delivered when the transaction fails")
+}
+
+func TestRequeueShouldReaddRequestsToTheStream(t *testing.T) {
+	pool := newMockPool()
+	pool.Conn.Command("MULTI").Expect("OK")
+	add := pool.Conn.Command("XADD", RequestStreamKey, "*", RequestStreamField, []byte("request")).Expect("QUEUED")
+	ack := pool.Conn.Command("XACK", RequestStreamKey, ConsumerGroup, "1-0").Expect("QUEUED")
+	del := pool.Conn.Command("XDEL", RequestStreamKey, "1-0").Expect("QUEUED")
+	pool.Conn.Command("EXEC").ExpectSlice("2-0", int64(1), int64(1))
+
+	tr := New(pool, WithStreams())
+	err := (&delivery{transport: tr, body: []byte("request"), streamID: "1-0"}).Requeue()
+	assert.Nil(t, err, "Requeueing should succeed")
+	assert.True(t, add.Called, "The request should be added to the stream again")
+	assert.True(t, ack.Called && del.Called, "The original entry should be acknowledged and deleted")
+}
+
+func TestReceiveRequestShouldReadBulkRequestsOnceNoInteractiveRequestsAreWaiting(t *testing.T) {
+	pool := newMockPool()
+	pool.Conn.GenericCommand("XAUTOCLAIM").ExpectSlice([]byte("0-0"), []interface{}{})
+	pool.Conn.Command("XREADGROUP", "GROUP", ConsumerGroup, "agent", "COUNT", 1, "STREAMS", RequestStreamKey, ">").
+		Expect(nil)
+	pool.Conn.Command("XREADGROUP", "GROUP", ConsumerGroup, "agent", "COUNT", 1, "STREAMS", BulkRequestStreamKey, ">").
+		ExpectSlice([]interface{}{[]byte(BulkRequestStreamKey), []interface{}{streamEntry("1-0", []byte("bulk"))}})
+	ack := pool.Conn.Command("XACK", BulkRequestStreamKey, ConsumerGroup, "1-0").Expect(int64(1))
+	del := pool.Conn.Command("XDEL", BulkRequestStreamKey, "1-0").Expect(int64(1))
+
+	delivery, err := New(pool, WithStreams(), WithAgentID("agent")).ReceiveRequest(context.Background())
+	assert.Nil(t, err, "No error should be returned when receiving a bulk request")
+	assert.Equal(t, []byte("bulk"), delivery.Body(), "Bulk requests should be read once the interactive stream is empty")
+	assert.Nil(t, delivery.Ack(), "No error should be returned when acknowledging a request")
+	assert.True(t, ack.Called && del.Called, "Bulk requests should be acknowledged in the bulk stream")
+}
@@ -0,0 +1,366 @@
+package redisTransport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/rsheasby/gocrypt/protocol"
+	"github.com/rsheasby/gocrypt/transport"
+	"google.golang.org/protobuf/proto"
+)
+
+// errClosed is returned when waiting for a response after the transport has been closed.
+var errClosed = errors.New("transport is closed")
+
+// subscriber shares a single pub/sub connection between every response being waited for, so that waiting for many
+// responses at once only needs one connection. It subscribes to the client's response channel once, and routes the
+// responses published on it to their awaiters using the response key inside each message. The connection is opened
+// when it's first needed, reopened and resubscribed if it's lost, and kept until the transport is closed.
+type subscriber struct {
+	transport *Transport
+
+	// writeMu is held while writing to the connection, separately from mu, so that the receive loop is never blocked
+	// by a write.
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	sub     *subscription
+	closed  bool
+	// awaiters holds the awaiter for each response key. Awaiters are kept when the connection is lost, so that they
+	// still receive responses published once it's been resubscribed. Responses published while it's down aren't
+	// received by anyone, so they're only received if the agent's publish retries outlast the reconnect, otherwise
+	// their requests time out.
+	awaiters map[string]*sharedAwaiter
+}
+
+// subscription is the subscription to the response channel using a single connection.
+type subscription struct {
+	conn    *redis.PubSubConn
+	stopped chan struct{}
+	// confirmed is closed once the subscription is confirmed, or once the connection fails, in which case err is set.
+	confirmed chan struct{}
+	err       error
+	once      sync.Once
+}
+
+// sharedAwaiter waits for the response to be routed to it by the shared subscriber.
+type sharedAwaiter struct {
+	subscriber  *subscriber
+	responseKey string
+	received    chan sharedResult
+}
+
+// sharedResult is a response received by the shared subscriber, or the error which stopped it.
+type sharedResult struct {
+	res []byte
+	err error
+}
+
+// await starts waiting for the response with the response key, and waits until the response channel is subscribed to,
+// so that the response can't be missed once the request is submitted.
+func (s *subscriber) await(responseKey string) (awaiter *sharedAwaiter, err error) {
+	awaiters, err := s.awaitAll([]string{responseKey})
+	if err != nil {
+		return nil, err
+	}
+	return awaiters[0], nil
+}
+
+// awaitAll starts waiting for the responses with each of the response keys, like await.
+func (s *subscriber) awaitAll(responseKeys []string) (awaiters []*sharedAwaiter, err error) {
+	awaiters = make([]*sharedAwaiter, len(responseKeys))
+	for i, responseKey := range responseKeys {
+		awaiters[i] = &sharedAwaiter{
+			subscriber:  s,
+			responseKey: responseKey,
+			received:    make(chan sharedResult, 1),
+		}
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil, errClosed
+	}
+	if s.awaiters == nil {
+		s.awaiters = make(map[string]*sharedAwaiter)
+	}
+	for _, awaiter := range awaiters {
+		s.awaiters[awaiter.responseKey] = awaiter
+	}
+	sub := s.connect()
+	s.mu.Unlock()
+
+	<-sub.confirmed
+	if sub.err != nil {
+		for _, awaiter := range awaiters {
+			_ = awaiter.Close()
+		}
+		return nil, fmt.Errorf("failed to subscribe to response channel: %v", sub.err)
+	}
+	return awaiters, nil
+}
+
+// connect returns the current subscription, opening a new connection and subscribing if there isn't one. s.mu must be
+// held.
+func (s *subscriber) connect() (sub *subscription) {
+	if s.sub != nil {
+		return s.sub
+	}
+
+	s.sub = &subscription{
+		conn:      &redis.PubSubConn{Conn: s.transport.pool.Get()},
+		stopped:   make(chan struct{}),
+		confirmed: make(chan struct{}),
+	}
+	go s.receive(s.sub)
+	go s.ping(s.sub)
+	return s.sub
+}
+
+// reconnect opens a new connection after the connection has been lost, as long as there are still awaiters waiting for
+// their responses. Otherwise, it's opened when it's next needed.
+func (s *subscriber) reconnect() {
+	for {
+		s.mu.Lock()
+		if s.closed || s.sub != nil || len(s.awaiters) == 0 {
+			s.mu.Unlock()
+			return
+		}
+		sub := s.connect()
+		s.mu.Unlock()
+
+		<-sub.confirmed
+		if sub.err == nil {
+			return
+		}
+		time.Sleep(ReconnectRetryTime)
+	}
+}
+
+// write calls fn while holding the write lock, unless the subscription has been stopped since it was started.
+func (s *subscriber) write(sub *subscription, fn func() error) (err error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.mu.Lock()
+	isCurrent := s.sub == sub
+	s.mu.Unlock()
+	if !isCurrent {
+		return errClosed
+	}
+	return fn()
+}
+
+// receive subscribes to the response channel, and routes everything received on the connection to the awaiters until
+// the connection fails or the subscriber is closed.
+func (s *subscriber) receive(sub *subscription) {
+	defer close(sub.stopped)
+	err := s.write(sub, func() error {
+		if s.isClosed() {
+			return errClosed
+		}
+		return sub.conn.Subscribe(s.transport.keys.ResponsePrefix + s.transport.ResponseChannel())
+	})
+	if err != nil {
+		s.stop(sub, err)
+		return
+	}
+
+	for {
+		// The subscriber pings the connection regularly, so nothing being received for longer means it's been lost.
+		switch received := sub.conn.ReceiveWithTimeout(2 * SubscriberPingInterval).(type) {
+		case redis.Message:
+			s.route(received.Data)
+		case redis.Subscription:
+			if received.Kind == "subscribe" {
+				sub.confirm(nil)
+			}
+			// The response channel is only unsubscribed from once the subscriber is closed.
+			if received.Kind == "unsubscribe" {
+				s.stop(sub, errClosed)
+				return
+			}
+		case error:
+			s.stop(sub, received)
+			return
+		}
+	}
+}
+
+// route passes the response to the awaiter waiting for its response key. Responses which can't be parsed, or which
+// nothing is waiting for, are ignored, as the request may have timed out or been handled twice.
+func (s *subscriber) route(msg []byte) {
+	channelRes := &protocol.ChannelResponse{}
+	err := proto.Unmarshal(msg, channelRes)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	awaiter := s.awaiters[channelRes.ResponseKey]
+	s.mu.Unlock()
+	if awaiter != nil {
+		awaiter.deliver(sharedResult{res: channelRes.Response})
+	}
+}
+
+// stop closes the connection. If the subscriber has been closed, every awaiter is stopped with the error. Otherwise,
+// the awaiters keep waiting while the connection is reopened and resubscribed, but responses published in the meantime
+// are lost.
+func (s *subscriber) stop(sub *subscription, err error) {
+	s.mu.Lock()
+	if s.sub == sub {
+		s.sub = nil
+	}
+	closed := s.closed
+	var awaiters map[string]*sharedAwaiter
+	if closed {
+		awaiters = s.awaiters
+		s.awaiters = nil
+	}
+	s.mu.Unlock()
+	// Closing the connection writes to it, so that it's unsubscribed before it's returned to the pool.
+	s.writeMu.Lock()
+	sub.conn.Close()
+	s.writeMu.Unlock()
+
+	sub.confirm(err)
+	for _, awaiter := range awaiters {
+		awaiter.deliver(sharedResult{err: err})
+	}
+	// A subscription which failed before it was confirmed is either retried by the reconnect loop which opened it, or
+	// its error is returned to the awaiters waiting for it.
+	if !closed && sub.err == nil {
+		go s.reconnect()
+	}
+}
+
+// isClosed returns whether the subscriber has been closed.
+func (s *subscriber) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// ping pings the connection every SubscriberPingInterval until it's stopped, so that a lost connection is noticed even
+// while nothing is being published.
+func (s *subscriber) ping(sub *subscription) {
+	ticker := time.NewTicker(SubscriberPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sub.stopped:
+			return
+		case <-ticker.C:
+			s.writeMu.Lock()
+			_ = sub.conn.Ping("")
+			s.writeMu.Unlock()
+		}
+	}
+}
+
+// close closes the shared connection, which stops every awaiter, and stops it from being opened again. Closing the
+// connection while it's being received from would race with the receive loop, so the response channel is unsubscribed
+// from instead, and the receive loop closes the connection once that's confirmed.
+func (s *subscriber) close() (err error) {
+	s.mu.Lock()
+	sub := s.sub
+	s.closed = true
+	s.mu.Unlock()
+	if sub == nil {
+		return nil
+	}
+
+	err = s.write(sub, func() error {
+		return sub.conn.Unsubscribe()
+	})
+	// The receive loop has stopped, or will stop, by itself if the connection was lost or never subscribed.
+	if err == errClosed {
+		err = nil
+	}
+	<-sub.stopped
+	return err
+}
+
+// confirm marks the subscription as confirmed, or as failed if err is set. Only the first call has any effect.
+func (sub *subscription) confirm(err error) {
+	sub.once.Do(func() {
+		sub.err = err
+		close(sub.confirmed)
+	})
+}
+
+// deliver passes the result to the awaiter. Only the first result is kept, as the same response may be published twice
+// if a request is handled twice.
+func (a *sharedAwaiter) deliver(result sharedResult) {
+	select {
+	case a.received <- result:
+	default:
+	}
+}
+
+// Wait waits for the response to be routed to the awaiter.
+func (a *sharedAwaiter) Wait(ctx context.Context, timeout time.Duration) (res []byte, err error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+		return nil, transport.ErrTimeout
+	case result := <-a.received:
+		if result.err != nil {
+			return nil, fmt.Errorf("failed to receive res from agent: %v", result.err)
+		}
+		return result.res, nil
+	}
+}
+
+// Close stops waiting for the response. The response channel stays subscribed to for the other awaiters.
+func (a *sharedAwaiter) Close() (err error) {
+	s := a.subscriber
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.awaiters[a.responseKey] == a {
+		delete(s.awaiters, a.responseKey)
+	}
+	return nil
+}
+
+// sharedBatchAwaiter waits for a batch of responses to be routed to their awaiters by the shared subscriber.
+type sharedBatchAwaiter []*sharedAwaiter
+
+// Wait waits for every response to be routed to its awaiter.
+func (b sharedBatchAwaiter) Wait(ctx context.Context, timeout time.Duration) (responses map[string][]byte,
+	err error) {
+	responses = make(map[string][]byte, len(b))
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for _, awaiter := range b {
+		select {
+		case <-ctx.Done():
+			return responses, ctx.Err()
+		case <-timer.C:
+			return responses, transport.ErrTimeout
+		case result := <-awaiter.received:
+			if result.err != nil {
+				return responses, fmt.Errorf("failed to receive res from agent: %v", result.err)
+			}
+			responses[awaiter.responseKey] = result.res
+		}
+	}
+	return responses, nil
+}
+
+// Close stops waiting for the responses.
+func (b sharedBatchAwaiter) Close() (err error) {
+	for _, awaiter := range b {
+		_ = awaiter.Close()
+	}
+	return nil
+}
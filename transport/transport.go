@@ -0,0 +1,56 @@
+// Package transport defines how requests are carried from clients to gocrypt agents, and how responses are carried
+// back again. Both the RemotePasswordHasher and the agent program against the Transport interface, so brokers other
+// than Redis can be supported without changing either of them.
+package transport
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrTimeout is returned by Awaiter.Wait when the response isn't received within the timeout.
+var ErrTimeout = errors.New("timed out waiting for response")
+
+// Transport carries marshalled requests and responses between clients and agents. Requests are queued so that each one
+// is received by a single agent, and each response is delivered to the client waiting on its response key.
+// Implementations must be safe for concurrent use.
+type Transport interface {
+	// Ping checks that the transport is able to reach its broker.
+	Ping() (err error)
+	// SubmitRequest queues the request to be received by an agent.
+	SubmitRequest(req []byte) (err error)
+	// AwaitResponse starts waiting for the response with the specified response key. It must be called before the
+	// request is submitted, so that the response can't be missed. The Awaiter must be closed once it's done with.
+	AwaitResponse(responseKey string) (awaiter Awaiter, err error)
+	// ReceiveRequest blocks until a request is received, or the context is cancelled, in which case the context's error
+	// is returned. The request must be acknowledged once it's been handled.
+	ReceiveRequest(ctx context.Context) (delivery Delivery, err error)
+	// PublishResponse delivers the response to the client waiting on the response key. delivered is false if the
+	// response definitely wasn't received by a client, in which case it can be retried.
+	PublishResponse(responseKey string, res []byte) (delivered bool, err error)
+	// ServerTime returns the current time according to the broker. Request expiry is based on this time, so that it
+	// doesn't matter if the clocks of the clients and agents differ.
+	ServerTime() (serverTime time.Time, err error)
+}
+
+// Awaiter waits for the response to a single request.
+type Awaiter interface {
+	// Wait blocks until the response is received and returns it. ErrTimeout is returned if it isn't received within the
+	// timeout, and the context's error is returned if the context is cancelled first.
+	Wait(ctx context.Context, timeout time.Duration) (res []byte, err error)
+	// Close stops waiting for the response, and releases anything held by the Awaiter.
+	Close() (err error)
+}
+
+// Delivery is a request received by an agent.
+type Delivery interface {
+	// Body returns the request as it was submitted.
+	Body() (req []byte)
+	// Ack acknowledges that the request has been handled. Transports which redeliver requests if an agent dies only
+	// forget about the request once it's acknowledged.
+	Ack() (err error)
+	// Requeue puts the request back onto the queue without handling it, so that it's received again by this or another
+	// agent. It's used instead of Ack when the agent stops before the request is handled.
+	Requeue() (err error)
+}